@@ -0,0 +1,79 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AlertChannelSpec defines the desired state of AlertChannel
+type AlertChannelSpec struct {
+	// Name of the alert channel in Instana.
+	Name string `json:"name"`
+	// Kind of the alert channel.
+	//+kubebuilder:validation:Enum=EMAIL;SLACK;WEB_HOOK;OPS_GENIE
+	Kind string `json:"kind"`
+	// Emails are the recipients of an EMAIL channel.
+	Emails []string `json:"emails,omitempty"`
+	// WebhookUrlSecretRef selects the webhook url of a SLACK channel, or the
+	// newline separated webhook urls of a WEB_HOOK channel.
+	WebhookUrlSecretRef *SecretKeyReference `json:"webhook-url-secret-ref,omitempty"`
+	// Channel overrides the Slack channel of the webhook.
+	Channel string `json:"channel,omitempty"`
+	// Headers are sent with the requests of a WEB_HOOK channel, as "Name: value".
+	Headers []string `json:"headers,omitempty"`
+	// ApiKeySecretRef selects the API key of an OPS_GENIE channel.
+	ApiKeySecretRef *SecretKeyReference `json:"api-key-secret-ref,omitempty"`
+	// Region of the Opsgenie account.
+	//+kubebuilder:validation:Enum=EU;US
+	Region string `json:"region,omitempty"`
+	// Tags are added to Opsgenie alerts.
+	Tags []string `json:"tags,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=ialertch,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.kind`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// AlertChannel is the Schema for the alertchannels API
+type AlertChannel struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AlertChannelSpec      `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *AlertChannel) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// AlertChannelList contains a list of AlertChannel
+type AlertChannelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AlertChannel `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AlertChannel{}, &AlertChannelList{})
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// APITokenSpec defines the desired state of APIToken
+type APITokenSpec struct {
+	// Name of the token in Instana.
+	Name string `json:"name"`
+	// Permissions granted to the token, by the names of the flags of the
+	// Instana API, e.g. "canConfigureCustomAlerts". The token has no
+	// permissions besides read access if empty.
+	Permissions []string `json:"permissions,omitempty"`
+	// SecretName is the name of the Secret the operator creates in the
+	// namespace, with the token as "token".
+	SecretName string `json:"secret-name"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=itoken,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Token",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.spec.secret-name`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// APIToken is the Schema for the apitokens API
+type APIToken struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   APITokenSpec          `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *APIToken) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// APITokenList contains a list of APIToken
+type APITokenList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []APIToken `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&APIToken{}, &APITokenList{})
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApplicationAlertConfigSpec defines the desired state of ApplicationAlertConfig
+type ApplicationAlertConfigSpec struct {
+	// Name of the alert in Instana.
+	Name string `json:"name"`
+	// Description of the alert, used as the text of the events.
+	Description string `json:"description,omitempty"`
+	// ApplicationPerspectiveRef is the name of the ApplicationPerspective in
+	// the namespace the alert is defined for.
+	ApplicationPerspectiveRef string `json:"application-perspective-ref,omitempty"`
+	// ApplicationId of an application perspective not managed as a resource.
+	// Either application-perspective-ref or application-id is required.
+	ApplicationId string `json:"application-id,omitempty"`
+	// BoundaryScope defines which calls of the application are evaluated.
+	//+kubebuilder:validation:Enum=ALL;INBOUND
+	//+kubebuilder:default=INBOUND
+	BoundaryScope string `json:"boundary-scope,omitempty"`
+	// TagFilterExpression further restricts the evaluated calls, in the
+	// format of the Instana API.
+	TagFilterExpression *apiextensionsv1.JSON `json:"tag-filter-expression,omitempty"`
+	// Rule of the alert in the format of the Instana API, e.g.
+	// {"alertType": "errorRate", "metricName": "errors", "aggregation": "MEAN"}.
+	Rule *apiextensionsv1.JSON `json:"rule"`
+	// Threshold of the rule in the format of the Instana API, e.g.
+	// {"type": "staticThreshold", "operator": ">=", "value": 0.05}.
+	Threshold *apiextensionsv1.JSON `json:"threshold"`
+	// TimeWindow in which the threshold has to be violated.
+	//+kubebuilder:default="10m"
+	TimeWindow metav1.Duration `json:"time-window,omitempty"`
+	// Severity of the events.
+	//+kubebuilder:validation:Enum=WARNING;CRITICAL
+	//+kubebuilder:default=WARNING
+	Severity string `json:"severity,omitempty"`
+	// Triggering creates an incident for the events.
+	Triggering bool `json:"triggering,omitempty"`
+	// AlertChannelRefs are the names of the AlertChannels in the namespace
+	// the alerts are sent to.
+	AlertChannelRefs []string `json:"alert-channel-refs,omitempty"`
+	// AlertChannelIds are the ids of alert channels not managed as resources.
+	AlertChannelIds []string `json:"alert-channel-ids,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=iappalert,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Alert",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// ApplicationAlertConfig is the Schema for the applicationalertconfigs API
+type ApplicationAlertConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationAlertConfigSpec `json:"spec,omitempty"`
+	Status InstanaResourceStatus      `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *ApplicationAlertConfig) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// ApplicationAlertConfigList contains a list of ApplicationAlertConfig
+type ApplicationAlertConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApplicationAlertConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ApplicationAlertConfig{}, &ApplicationAlertConfigList{})
+}
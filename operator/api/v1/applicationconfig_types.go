@@ -0,0 +1,79 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceMatchRule matches a tag of the calls mapped by an ApplicationConfig.
+type ServiceMatchRule struct {
+	// Key of the tag, e.g. "kubernetes.container.name".
+	Key string `json:"key"`
+	// Value the tag has to match, a regular expression.
+	Value string `json:"value"`
+}
+
+// ApplicationConfigSpec defines the desired state of ApplicationConfig
+type ApplicationConfigSpec struct {
+	// Name of the service mapping rule in Instana.
+	Name string `json:"name"`
+	// Comment describing the rule.
+	Comment string `json:"comment,omitempty"`
+	// Label is the name of the mapped services, a template which can use
+	// tags, e.g. "{kubernetes.namespace.name}-{kubernetes.container.name}".
+	Label string `json:"label"`
+	// Match are the tags the calls of the services have to match.
+	//+kubebuilder:validation:MinItems=1
+	Match []ServiceMatchRule `json:"match"`
+	// Disabled stops applying the rule without deleting it.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=iappcfg,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Rule",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Label",type=string,JSONPath=`.spec.label`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// ApplicationConfig is the Schema for the applicationconfigs API. It maps
+// calls to custom services with a service mapping rule.
+type ApplicationConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationConfigSpec `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *ApplicationConfig) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// ApplicationConfigList contains a list of ApplicationConfig
+type ApplicationConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApplicationConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ApplicationConfig{}, &ApplicationConfigList{})
+}
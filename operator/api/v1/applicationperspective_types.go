@@ -0,0 +1,74 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApplicationPerspectiveSpec defines the desired state of ApplicationPerspective
+type ApplicationPerspectiveSpec struct {
+	// Label is the name of the application perspective in Instana.
+	Label string `json:"label"`
+	// Scope defines which downstream services are part of the perspective.
+	//+kubebuilder:validation:Enum=INCLUDE_NO_DOWNSTREAM;INCLUDE_IMMEDIATE_DOWNSTREAM_DATABASE_AND_MESSAGING;INCLUDE_ALL_DOWNSTREAM
+	//+kubebuilder:default=INCLUDE_NO_DOWNSTREAM
+	Scope string `json:"scope,omitempty"`
+	// BoundaryScope defines which calls count as inbound calls of the perspective.
+	//+kubebuilder:validation:Enum=ALL;INBOUND;DEFAULT
+	//+kubebuilder:default=DEFAULT
+	BoundaryScope string `json:"boundary-scope,omitempty"`
+	// TagFilterExpression selects the services, endpoints and calls of the
+	// perspective, in the format of the Instana API.
+	TagFilterExpression *apiextensionsv1.JSON `json:"tag-filter-expression"`
+	// AccessRules of the perspective. Defaults to read and write access for everyone.
+	AccessRules *apiextensionsv1.JSON `json:"access-rules,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=iap,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Label",type=string,JSONPath=`.spec.label`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// ApplicationPerspective is the Schema for the applicationperspectives API
+type ApplicationPerspective struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationPerspectiveSpec `json:"spec,omitempty"`
+	Status InstanaResourceStatus      `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *ApplicationPerspective) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// ApplicationPerspectiveList contains a list of ApplicationPerspective
+type ApplicationPerspectiveList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApplicationPerspective `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ApplicationPerspective{}, &ApplicationPerspectiveList{})
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AutomationScript is a script run by an AutomationAction on a host agent.
+type AutomationScript struct {
+	// Interpreter of the script.
+	//+kubebuilder:validation:Enum=bash;python;powershell
+	//+kubebuilder:default=bash
+	Interpreter string `json:"interpreter,omitempty"`
+	// Source of the script. Inputs are available as @@name@@.
+	Source string `json:"source"`
+}
+
+// AutomationHttpRequest is a request sent by an AutomationAction.
+type AutomationHttpRequest struct {
+	// URL of the request.
+	URL string `json:"url"`
+	// Method of the request.
+	//+kubebuilder:validation:Enum=GET;HEAD;OPTIONS;PATCH;POST;PUT;DELETE
+	//+kubebuilder:default=GET
+	Method string `json:"method,omitempty"`
+	// Headers of the request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body of the request.
+	Body string `json:"body,omitempty"`
+	// IgnoreCertErrors skips the verification of the certificate of the server.
+	IgnoreCertErrors bool `json:"ignore-cert-errors,omitempty"`
+}
+
+// AutomationInput is an input parameter of an AutomationAction.
+type AutomationInput struct {
+	// Name of the input, used as @@name@@ in the action.
+	Name string `json:"name"`
+	// Label of the input in the UI.
+	Label string `json:"label,omitempty"`
+	// Description of the input.
+	Description string `json:"description,omitempty"`
+	// Type of the input.
+	//+kubebuilder:validation:Enum=static;dynamic;vault
+	//+kubebuilder:default=static
+	Type string `json:"type,omitempty"`
+	// Value is the default value of the input.
+	Value string `json:"value,omitempty"`
+	// Required inputs have to be set to run the action.
+	Required bool `json:"required,omitempty"`
+	// Hidden inputs are not shown in the UI.
+	Hidden bool `json:"hidden,omitempty"`
+	// Secured inputs are masked in the UI and the logs.
+	Secured bool `json:"secured,omitempty"`
+}
+
+// AutomationActionSpec defines the desired state of AutomationAction
+type AutomationActionSpec struct {
+	// Name of the action in Instana.
+	Name string `json:"name"`
+	// Description of the action.
+	Description string `json:"description,omitempty"`
+	// Tags of the action.
+	Tags []string `json:"tags,omitempty"`
+	// Script runs a script on a host agent.
+	Script *AutomationScript `json:"script,omitempty"`
+	// HTTP sends a request. Either script or http is required.
+	HTTP *AutomationHttpRequest `json:"http,omitempty"`
+	// Timeout of the action.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Inputs are the parameters of the action.
+	Inputs []AutomationInput `json:"inputs,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=iaction,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Action",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// AutomationAction is the Schema for the automationactions API
+type AutomationAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AutomationActionSpec  `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *AutomationAction) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// AutomationActionList contains a list of AutomationAction
+type AutomationActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AutomationAction `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AutomationAction{}, &AutomationActionList{})
+}
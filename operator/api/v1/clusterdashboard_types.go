@@ -0,0 +1,57 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterDashboardNameLabel is set on the Dashboard generated for a
+// ClusterDashboard and holds the name of the ClusterDashboard.
+const ClusterDashboardNameLabel = "custom.instana.io/cluster-dashboard"
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster,shortName=icdash,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Dashboard-Id",type=string,JSONPath=`.status.dashboard-id`
+//+kubebuilder:printcolumn:name="Dashboard-Title",type=string,JSONPath=`.status.dashboard-title`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.dashboard-url`,priority=1
+// ClusterDashboard is the cluster-scoped variant of Dashboard for dashboards
+// owned by the platform instead of a team namespace. It is synced through a
+// Dashboard generated in the namespace of the tenant config, so references
+// like spec.config-from and spec.widgets-from resolve in that namespace.
+type ClusterDashboard struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DashboardSpec   `json:"spec,omitempty"`
+	Status DashboardStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterDashboardList contains a list of ClusterDashboard
+type ClusterDashboardList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterDashboard `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterDashboard{}, &ClusterDashboardList{})
+}
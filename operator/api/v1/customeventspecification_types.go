@@ -0,0 +1,84 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CustomEventSpecificationSpec defines the desired state of CustomEventSpecification
+type CustomEventSpecificationSpec struct {
+	// Name of the event in Instana.
+	Name string `json:"name"`
+	// Description of the event.
+	Description string `json:"description,omitempty"`
+	// EntityType the rule is evaluated for, e.g. "host" or "any" for
+	// system and entity verification rules.
+	EntityType string `json:"entity-type"`
+	// Query restricts the evaluated entities, in the dynamic focus query syntax.
+	Query string `json:"query,omitempty"`
+	// Rule of the event in the format of the Instana API, e.g. {"ruleType":
+	// "threshold", "metricName": "cpu.used", "rollup": 0, "window": 60000,
+	// "aggregation": "avg", "conditionOperator": ">", "conditionValue": 0.9}.
+	Rule *apiextensionsv1.JSON `json:"rule"`
+	// Severity of the events.
+	//+kubebuilder:validation:Enum=WARNING;CRITICAL
+	//+kubebuilder:default=WARNING
+	Severity string `json:"severity,omitempty"`
+	// Triggering creates an incident for the events.
+	Triggering bool `json:"triggering,omitempty"`
+	// ExpirationTime after which an event is closed once the rule is no
+	// longer violated.
+	ExpirationTime *metav1.Duration `json:"expiration-time,omitempty"`
+	// Disabled stops evaluating the rule without deleting it.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=ievent,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Event",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Entity",type=string,JSONPath=`.spec.entity-type`
+//+kubebuilder:printcolumn:name="Severity",type=string,JSONPath=`.spec.severity`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// CustomEventSpecification is the Schema for the customeventspecifications API
+type CustomEventSpecification struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CustomEventSpecificationSpec `json:"spec,omitempty"`
+	Status InstanaResourceStatus        `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *CustomEventSpecification) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// CustomEventSpecificationList contains a list of CustomEventSpecification
+type CustomEventSpecificationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CustomEventSpecification `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CustomEventSpecification{}, &CustomEventSpecificationList{})
+}
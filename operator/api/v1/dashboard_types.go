@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -26,22 +27,338 @@ type DashboardSpec struct {
 	InstanaApiTokenRelationId string `json:"instana-api-token-relation-id"`
 	// TODO move into secret
 	InstanaUserId string `json:"instana-user-id"`
-	// Config the json definition of the custom dashoard
-	Config string `json:"config,omitempty"`
+	// Config the json definition of the custom dashoard. Older resources
+	// store the json as a string, these are migrated by the operator.
+	// +kubebuilder:validation:Type=object
+	Config *apiextensionsv1.JSON `json:"config,omitempty"`
+	// ConfigFrom selects a key of a ConfigMap holding the json definition of
+	// the dashboard, used instead of Config. Keys ending in ".jsonnet" are
+	// evaluated like Jsonnet. Changes of the ConfigMap are synced right away.
+	ConfigFrom *ConfigMapKeyReference `json:"config-from,omitempty"`
+	// Jsonnet is a Jsonnet program evaluating to the json definition of the
+	// dashboard, used instead of Config. The values of spec.values and
+	// spec.values-from are its external variables, std.extVar("<name>").
+	// Imports of "<configmap>/<key>" read the key of a ConfigMap of the
+	// namespace, e.g. a library of widgets shared by teams.
+	Jsonnet string `json:"jsonnet,omitempty"`
+	// MirrorTenant is the name of a ConfigMap with the config of a secondary
+	// Instana tenant the dashboard is replicated to.
+	MirrorTenant string `json:"mirror-tenant,omitempty"`
+	// SyncPolicy defines how changes done in the Instana UI are handled.
+	// Overwrite (default) replaces them on the next sync. Import writes them
+	// back into the config of this resource. Enforce checks for them
+	// periodically and reverts them right away.
+	//+kubebuilder:validation:Enum=Overwrite;Import;Enforce
+	SyncPolicy string `json:"sync-policy,omitempty"`
+	// SyncSchedule is a cron expression in UTC, e.g. "*/5 * * * *" or
+	// "@daily", defining when the dashboard is synced and checked for drift.
+	// Overrides --drift-check-interval. Changes of the resource are synced
+	// right away regardless of the schedule.
+	SyncSchedule string `json:"sync-schedule,omitempty"`
+	// AdvisoryWidgets are the ids of widgets which may be changed in the
+	// Instana UI. With the Enforce sync policy their changes are reported but
+	// not reverted. All other widgets are enforced.
+	AdvisoryWidgets []string `json:"advisory-widgets,omitempty"`
+	// DryRun renders and validates the config and reports what would be
+	// changed in Instana in the status and events, without creating, updating
+	// or deleting anything in Instana.
+	DryRun bool `json:"dry-run,omitempty"`
+	// Patches are applied to the config of spec.config or spec.config-from,
+	// in this order, e.g. for the tweaks of an environment.
+	Patches []ConfigPatch `json:"patches,omitempty"`
+	// WidgetsFrom adds widgets of WidgetLibraries to the widgets of the
+	// config, after the widgets of the config.
+	WidgetsFrom []WidgetSource `json:"widgets-from,omitempty"`
+	// Includes add the widgets of other Dashboards and WidgetLibraries of the
+	// namespace as blocks below the widgets of the config, keeping the layout
+	// of each block. The ids of included widgets are prefixed with the name
+	// of the include.
+	Includes []DashboardInclude `json:"includes,omitempty"`
+	// Templated renders the string values of the config as Go templates with
+	// the built-in variables .ClusterName, .Zone, .Namespace, .Name and the
+	// .Vars of the operator, e.g. "value": "{{ .ClusterName }}".
+	Templated bool `json:"templated,omitempty"`
+	// ValuesFrom reads values of templated configs from Secrets, e.g.
+	// customer identifiers which shouldn't be in Git, available as
+	// .Values.<name>. Configs using them are rendered as templated configs.
+	ValuesFrom []ValueSource `json:"values-from,omitempty"`
+	// Values are values of templated configs which may be in Git, available
+	// as .Values.<name> like the values of spec.values-from, which take
+	// precedence.
+	Values map[string]string `json:"values,omitempty"`
+	// EntityRefs resolve Kubernetes workloads to the snapshot ids of their
+	// Instana entities at reconcile time, available as .Entities.<name>, so
+	// widgets follow workloads without hardcoded ids. Configs using them are
+	// rendered as templated configs.
+	EntityRefs []EntityRef `json:"entity-refs,omitempty"`
+	// Clusters makes the operator a multi-cluster hub for this dashboard: one
+	// dashboard is created per cluster, with the cluster as .ClusterName of
+	// the templated config and in the title, instead of a single dashboard.
+	Clusters []string `json:"clusters,omitempty"`
+	// Variants create one dashboard per variant, e.g. per environment,
+	// instead of a single dashboard. The config is rendered as a templated
+	// config for each variant, with the vars and patches of the variant and
+	// its name in the title. Can't be combined with spec.clusters.
+	Variants []DashboardVariant `json:"variants,omitempty"`
+	// AccessRules replace the accessRules of the config, so sharing the
+	// dashboard is enforced on every sync.
+	AccessRules []AccessRule `json:"access-rules,omitempty"`
+	// Tags group the dashboard, e.g. by team or service. Instana dashboards
+	// have no tags, they are recorded in the managed marker widget and can be
+	// filtered on by the export and the garbage collection. Tags consist of
+	// letters, digits and ".", "_", "-", ":" or "/".
+	Tags []string `json:"tags,omitempty"`
+	// TimeRange is the time window the dashboard opens with from the link
+	// in status.dashboard-url.
+	TimeRange *TimeRange `json:"time-range,omitempty"`
+	// ExistingDashboardId is the id of an existing Instana dashboard which is
+	// adopted on the first sync, e.g. one created with Terraform, instead of
+	// creating a new dashboard. The config of the resource replaces it.
+	ExistingDashboardId string `json:"existing-dashboard-id,omitempty"`
 }
 
+// TimeRange is the default time window of a dashboard.
+type TimeRange struct {
+	// Window is the size of the time window, e.g. "1h" or "168h".
+	Window *metav1.Duration `json:"window,omitempty"`
+	// AutoRefresh moves the time window along while the dashboard is open.
+	// Defaults to the setting of the Instana user.
+	AutoRefresh *bool `json:"auto-refresh,omitempty"`
+}
+
+// ConfigMapKeyReference selects a key of a ConfigMap in the namespace of the resource.
+type ConfigMapKeyReference struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+	// Key in the ConfigMap.
+	Key string `json:"key"`
+}
+
+// AccessRule shares a dashboard in Instana.
+type AccessRule struct {
+	//+kubebuilder:validation:Enum=READ;READ_WRITE
+	AccessType string `json:"access-type"`
+	//+kubebuilder:validation:Enum=USER;API_TOKEN;ROLE;TEAM;GLOBAL
+	RelationType string `json:"relation-type"`
+	// RelatedId is the id of the user, API token, role or team. Empty for GLOBAL.
+	RelatedId string `json:"related-id,omitempty"`
+}
+
+// ConfigPatch is a patch of the config of a Dashboard.
+type ConfigPatch struct {
+	// Type is JSONPatch (default), a RFC 6902 JSON Patch, or Merge, a RFC 7386
+	// JSON Merge Patch.
+	//+kubebuilder:validation:Enum=JSONPatch;Merge
+	Type string `json:"type,omitempty"`
+	// Patch is the list of operations of a JSONPatch or the object merged
+	// into the config.
+	Patch apiextensionsv1.JSON `json:"patch"`
+}
+
+const (
+	ConfigPatchJSONPatch = "JSONPatch"
+	ConfigPatchMerge     = "Merge"
+)
+
+const (
+	SyncPolicyOverwrite = "Overwrite"
+	SyncPolicyImport    = "Import"
+	SyncPolicyEnforce   = "Enforce"
+)
+
 // DashboardStatus defines the observed state of Dashboard
 type DashboardStatus struct {
 	// The id of the dashboards after it has been created.
 	DashboardId string `json:"dashboard-id"`
 	// The title of the dashboards after it has been created.
 	DashboardTitle string `json:"dashboard-title"`
+	// The url of the dashboard in Instana.
+	DashboardUrl string `json:"dashboard-url,omitempty"`
+	// The time of the last sync with Instana, successful or not.
+	LastSyncTime *metav1.Time `json:"last-sync-time,omitempty"`
+	// The number of consecutive failed syncs, reset by a successful sync.
+	SyncAttempts int32 `json:"sync-attempts,omitempty"`
+	// The error of the last failed sync, cleared by a successful sync.
+	LastError string `json:"last-error,omitempty"`
+	// The tenant the dashboard was last replicated to.
+	MirrorTenant string `json:"mirror-tenant,omitempty"`
+	// The id of the dashboard in the mirror tenant.
+	MirrorDashboardId string `json:"mirror-dashboard-id,omitempty"`
+	// The generation of the spec which was processed in the last sync,
+	// successful or not.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// The SHA256 of the config which was applied in the last sync.
+	AppliedConfigHash string `json:"applied-config-hash,omitempty"`
+	// The hotfix patch from the annotation which was applied in the last sync.
+	HotfixPatch string `json:"hotfix-patch,omitempty"`
+	// The dashboards of the clusters of the spec.
+	Clusters []ClusterDashboardStatus `json:"clusters,omitempty"`
+	// The dashboards of the variants of the spec.
+	Variants []VariantDashboardStatus `json:"variants,omitempty"`
+	// Conditions of the dashboard.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// ClusterDashboardStatus is the dashboard of a cluster of spec.clusters.
+type ClusterDashboardStatus struct {
+	// The name of the cluster.
+	Cluster string `json:"cluster"`
+	// The id of the dashboard of the cluster.
+	DashboardId string `json:"dashboard-id,omitempty"`
+	// The error of the last sync of the dashboard, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// ValueSource is a value of templated configs read from a Secret.
+type ValueSource struct {
+	// Name of the value in .Values.
+	Name string `json:"name"`
+	// SecretKeyRef selects the key of a Secret in the namespace of the
+	// Dashboard holding the value.
+	SecretKeyRef SecretKeyReference `json:"secret-key-ref"`
+}
+
+// EntityRef references the Instana entity of a Kubernetes workload.
+type EntityRef struct {
+	// Name of the entity in .Entities.
+	Name string `json:"name"`
+	// Kind of the workload.
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet;Pod;Service
+	Kind string `json:"kind"`
+	// Workload is the name of the workload.
+	Workload string `json:"workload"`
+	// Namespace of the workload. Defaults to the namespace of the Dashboard.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// DashboardVariant is a variant of a Dashboard, e.g. for an environment.
+type DashboardVariant struct {
+	// Name of the variant, appended to the title unless the title contains
+	// it already, e.g. "Shop (staging)".
+	Name string `json:"name"`
+	// Vars are added to the .Vars of the templated config.
+	Vars map[string]string `json:"vars,omitempty"`
+	// Patches are applied to the config after spec.patches.
+	Patches []ConfigPatch `json:"patches,omitempty"`
+}
+
+// VariantDashboardStatus is the dashboard of a variant of spec.variants.
+type VariantDashboardStatus struct {
+	// The name of the variant.
+	Name string `json:"name"`
+	// The id of the dashboard of the variant.
+	DashboardId string `json:"dashboard-id,omitempty"`
+	// The error of the last sync of the dashboard, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	// SkipRemoteDeleteAnnotation set to "true" deletes the resource without
+	// deleting the dashboard in Instana.
+	SkipRemoteDeleteAnnotation = "custom.instana.io/skip-remote-delete"
+
+	// ConditionSynced reports the last sync with the Instana tenant.
+	ConditionSynced = "Synced"
+
+	// ConditionCredentialsInvalid is true if Instana rejected the API token.
+	ConditionCredentialsInvalid = "CredentialsInvalid"
+
+	// ConditionPermissionsMissing is true if the API token lacks the
+	// permission for the Instana API of the resource.
+	ConditionPermissionsMissing = "PermissionsMissing"
+
+	// ConditionMirrorSynced reports the last sync with the mirror tenant.
+	ConditionMirrorSynced = "MirrorSynced"
+
+	// ConditionAdvisoryDrift is true while advisory widgets differ from the config.
+	ConditionAdvisoryDrift = "AdvisoryDrift"
+
+	// ConditionBrokenLinks is true if links embedded in the dashboard could not be resolved.
+	ConditionBrokenLinks = "BrokenLinks"
+
+	// HotfixPatchAnnotation carries a JSON Patch (RFC 6902) which is applied
+	// on top of the rendered config. Meant for urgent fixes only.
+	HotfixPatchAnnotation = "custom.instana.io/hotfix-patch"
+
+	// RestoreSnapshotAnnotation requests to re-create the dashboard in
+	// Instana from a backup snapshot, given by its timestamp or "latest". The
+	// annotation is removed once the dashboard was restored.
+	RestoreSnapshotAnnotation = "custom.instana.io/restore-snapshot"
+
+	// SyncRequestedAnnotation requests to sync the dashboard with Instana
+	// although its config is unchanged, e.g. to correct changes done in
+	// Instana. Set by the sync receiver to the time of the request. The
+	// annotation is removed once the dashboard was synced.
+	SyncRequestedAnnotation = "custom.instana.io/sync-requested"
+
+	// GenerateDashboardAnnotation set to "true" on a Namespace, Deployment or
+	// StatefulSet generates a Dashboard for it from a template.
+	GenerateDashboardAnnotation = "custom.instana.io/generate-dashboard"
+
+	// DashboardTemplateAnnotation selects the template of a generated
+	// Dashboard. Defaults to "namespace" for Namespaces and "workload" for
+	// Deployments and StatefulSets.
+	DashboardTemplateAnnotation = "custom.instana.io/dashboard-template"
+
+	// DashboardParamsAnnotation holds the parameters of the template of a
+	// generated Dashboard as key=value pairs separated by commas.
+	DashboardParamsAnnotation = "custom.instana.io/dashboard-params"
+
+	// GeneratorLabel is set on generated Dashboards and holds the generator.
+	GeneratorLabel = "custom.instana.io/generator"
+
+	// ProtectedAnnotation set to "true" denies the deletion of the Dashboard,
+	// e.g. by the deletion of its namespace.
+	ProtectedAnnotation = "dashboards.instana.io/protected"
+
+	// CustomProtectedAnnotation has the prefix of the other annotations of
+	// the operator and protects the Dashboard like ProtectedAnnotation.
+	CustomProtectedAnnotation = "custom.instana.io/protected"
+
+	// AllowDeletionAnnotation set to "true" allows to delete a protected
+	// Dashboard.
+	AllowDeletionAnnotation = "custom.instana.io/allow-deletion"
+
+	// ConditionHotfixApplied is true while a hotfix patch is applied.
+	ConditionHotfixApplied = "HotfixApplied"
+
+	// ConditionDeprecatedWidgets is true if the config uses widget types or
+	// fields which are deprecated in Instana.
+	ConditionDeprecatedWidgets = "DeprecatedWidgets"
+
+	// ConditionDegraded is true while syncs are suspended because the Instana
+	// tenant keeps failing, or while the API token can't be read from Vault.
+	ConditionDegraded = "Degraded"
+
+	// ConditionDryRun reports what a sync would change in Instana while
+	// spec.dry-run is set.
+	ConditionDryRun = "DryRun"
+
+	// ConditionReady is true once the dashboard exists in Instana with the
+	// current spec. Together with ConditionReconciling and ConditionStalled it
+	// follows the kstatus conventions understood by Argo CD and Flux.
+	ConditionReady = "Ready"
+
+	// ConditionReconciling is true while a failed sync is retried.
+	ConditionReconciling = "Reconciling"
+
+	// ConditionStalled is true if the sync fails with an error which is not
+	// resolved by retrying, e.g. an invalid config.
+	ConditionStalled = "Stalled"
+
+	// ConditionLoadShed is true while resyncs of the unchanged dashboard are
+	// skipped because the work queue of the operator is too deep.
+	ConditionLoadShed = "LoadShed"
+)
+
 //+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=idash,categories=instana
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
 //+kubebuilder:printcolumn:name="Dashboard-Id",type=string,JSONPath=`.status.dashboard-id`
 //+kubebuilder:printcolumn:name="Dashboard-Title",type=string,JSONPath=`.status.dashboard-title`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.dashboard-url`,priority=1
 // Dashboard is the Schema for the dashboards API
 type Dashboard struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -0,0 +1,60 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var dashboardlog = logf.Log.WithName("dashboard-resource")
+
+func (r *Dashboard) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-custom-instana-io-v1-dashboard,mutating=false,failurePolicy=fail,sideEffects=None,groups=custom.instana.io,resources=dashboards,verbs=delete,versions=v1,name=vdashboard.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &Dashboard{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Dashboard) ValidateCreate() error {
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Dashboard) ValidateUpdate(old runtime.Object) error {
+	return nil
+}
+
+// ValidateDelete denies the deletion of protected Dashboards unless the
+// deletion was allowed explicitly.
+func (r *Dashboard) ValidateDelete() error {
+	protected := r.Annotations[ProtectedAnnotation] == "true" || r.Annotations[CustomProtectedAnnotation] == "true"
+	if !protected || r.Annotations[AllowDeletionAnnotation] == "true" {
+		return nil
+	}
+	dashboardlog.Info("Denied deletion of protected dashboard", "namespace", r.Namespace, "name", r.Name)
+	return fmt.Errorf("dashboard %s/%s is protected, set the annotation %s: \"true\" to delete it", r.Namespace, r.Name, AllowDeletionAnnotation)
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateDelete(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantDenied  bool
+	}{
+		{name: "unprotected"},
+		{name: "protected", annotations: map[string]string{ProtectedAnnotation: "true"}, wantDenied: true},
+		{name: "protected with the custom prefix", annotations: map[string]string{CustomProtectedAnnotation: "true"}, wantDenied: true},
+		{name: "deletion allowed", annotations: map[string]string{ProtectedAnnotation: "true", AllowDeletionAnnotation: "true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dashboard := &Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "on-call", Name: "checkout", Annotations: tt.annotations}}
+			if err := dashboard.ValidateDelete(); (err != nil) != tt.wantDenied {
+				t.Errorf("ValidateDelete() = %v, want denied %v", err, tt.wantDenied)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DashboardRepositorySpec defines the desired state of DashboardRepository
+type DashboardRepositorySpec struct {
+	// URL of the Git repository, e.g. https://github.com/example/dashboards.git.
+	URL string `json:"url"`
+	// Branch to check out.
+	//+kubebuilder:default=main
+	Branch string `json:"branch,omitempty"`
+	// Path of the directory with the dashboard JSON or YAML files, relative
+	// to the root of the repository.
+	Path string `json:"path,omitempty"`
+	// Interval in which the repository is polled. Defaults to 5m.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// SecretRef is the name of a Secret in the same namespace with the keys
+	// "username" and "password" for HTTPS authentication.
+	SecretRef string `json:"secret-ref,omitempty"`
+	// InstanaApiTokenRelationId is set in the spec of the created Dashboards.
+	InstanaApiTokenRelationId string `json:"instana-api-token-relation-id"`
+	// InstanaUserId is set in the spec of the created Dashboards.
+	InstanaUserId string `json:"instana-user-id"`
+}
+
+// DashboardRepositoryStatus defines the observed state of DashboardRepository
+type DashboardRepositoryStatus struct {
+	// The commit the Dashboards were last created from.
+	LastCommit string `json:"last-commit,omitempty"`
+	// The names of the Dashboards created from the repository.
+	Dashboards []string `json:"dashboards,omitempty"`
+	// The generation of the spec which was applied in the last sync.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions of the repository.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// RepositoryLabel is set on Dashboards created from a DashboardRepository
+// and holds the name of the repository.
+const RepositoryLabel = "custom.instana.io/repository"
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=idashrepo,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
+//+kubebuilder:printcolumn:name="Commit",type=string,JSONPath=`.status.last-commit`
+// DashboardRepository is the Schema for the dashboardrepositories API. It
+// creates a Dashboard for every dashboard file in a Git repository.
+type DashboardRepository struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DashboardRepositorySpec   `json:"spec,omitempty"`
+	Status DashboardRepositoryStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DashboardRepositoryList contains a list of DashboardRepository
+type DashboardRepositoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DashboardRepository `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DashboardRepository{}, &DashboardRepositoryList{})
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InfraAlertConfigSpec defines the desired state of InfraAlertConfig
+type InfraAlertConfigSpec struct {
+	// Name of the alert in Instana.
+	Name string `json:"name"`
+	// Description of the alert, used as the text of the events.
+	Description string `json:"description,omitempty"`
+	// EntityType of the evaluated entities, e.g. "host" or "kubernetesPod".
+	EntityType string `json:"entity-type"`
+	// MetricName of the evaluated metric, e.g. "cpu.used".
+	MetricName string `json:"metric-name"`
+	// Aggregation of the metric of an entity over the time window.
+	//+kubebuilder:validation:Enum=SUM;MEAN;MAX;MIN;P25;P50;P75;P90;P95;P98;P99
+	//+kubebuilder:default=MEAN
+	Aggregation string `json:"aggregation,omitempty"`
+	// CrossSeriesAggregation aggregates the metric of the entities of a group.
+	//+kubebuilder:validation:Enum=SUM;MEAN;MAX;MIN;P25;P50;P75;P90;P95;P98;P99
+	CrossSeriesAggregation string `json:"cross-series-aggregation,omitempty"`
+	// GroupBy are the tags the entities are grouped by, e.g. ["host.name"].
+	GroupBy []string `json:"group-by,omitempty"`
+	// TagFilterExpression selects the evaluated entities, in the format of
+	// the Instana API.
+	TagFilterExpression *apiextensionsv1.JSON `json:"tag-filter-expression,omitempty"`
+	// ThresholdOperator compares the metric with the thresholds.
+	//+kubebuilder:validation:Enum=">";">=";"<";"<="
+	//+kubebuilder:default=">="
+	ThresholdOperator string `json:"threshold-operator,omitempty"`
+	// WarningThreshold raises warnings, in the format of the Instana API,
+	// e.g. {"type": "staticThreshold", "value": 80} or
+	// {"type": "adaptiveBaseline", "deviationFactor": 3, "adaptability": 0.5, "seasonality": "DAILY"}.
+	WarningThreshold *apiextensionsv1.JSON `json:"warning-threshold,omitempty"`
+	// CriticalThreshold raises critical events, in the format of warning-threshold.
+	CriticalThreshold *apiextensionsv1.JSON `json:"critical-threshold,omitempty"`
+	// TimeWindow in which the threshold has to be violated.
+	//+kubebuilder:default="10m"
+	TimeWindow metav1.Duration `json:"time-window,omitempty"`
+	// AlertChannelRefs are the names of the AlertChannels in the namespace
+	// the alerts are sent to.
+	AlertChannelRefs []string `json:"alert-channel-refs,omitempty"`
+	// AlertChannelIds are the ids of alert channels not managed as resources.
+	AlertChannelIds []string `json:"alert-channel-ids,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=iinfraalert,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Alert",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Entity",type=string,JSONPath=`.spec.entity-type`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// InfraAlertConfig is the Schema for the infraalertconfigs API
+type InfraAlertConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InfraAlertConfigSpec  `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *InfraAlertConfig) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// InfraAlertConfigList contains a list of InfraAlertConfig
+type InfraAlertConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []InfraAlertConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&InfraAlertConfig{}, &InfraAlertConfigList{})
+}
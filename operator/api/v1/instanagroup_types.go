@@ -0,0 +1,91 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InstanaGroupMember is a user of an InstanaGroup.
+type InstanaGroupMember struct {
+	// UserId of the user in Instana.
+	UserId string `json:"user-id"`
+	// Email of the user.
+	Email string `json:"email,omitempty"`
+}
+
+// InstanaGroupSpec defines the desired state of InstanaGroup
+type InstanaGroupSpec struct {
+	// Name of the group in Instana.
+	Name string `json:"name"`
+	// Members of the group. Members added in Instana are removed again.
+	Members []InstanaGroupMember `json:"members,omitempty"`
+	// Permissions of the group, e.g. "CAN_CONFIGURE_APPLICATIONS".
+	Permissions []string `json:"permissions,omitempty"`
+	// ApplicationPerspectiveRefs are the names of the ApplicationPerspectives
+	// in the namespace the group is restricted to.
+	ApplicationPerspectiveRefs []string `json:"application-perspective-refs,omitempty"`
+	// ApplicationIds are the ids of application perspectives not managed as
+	// resources the group is restricted to.
+	ApplicationIds []string `json:"application-ids,omitempty"`
+	// WebsiteRefs are the names of the WebsiteMonitoringConfigs in the
+	// namespace the group is restricted to.
+	WebsiteRefs []string `json:"website-refs,omitempty"`
+	// WebsiteIds are the ids of websites not managed as resources the group
+	// is restricted to.
+	WebsiteIds []string `json:"website-ids,omitempty"`
+	// KubernetesClusterUUIDs restrict the group to Kubernetes clusters.
+	KubernetesClusterUUIDs []string `json:"kubernetes-cluster-uuids,omitempty"`
+	// KubernetesNamespaceUIDs restrict the group to Kubernetes namespaces.
+	KubernetesNamespaceUIDs []string `json:"kubernetes-namespace-uids,omitempty"`
+	// InfraDfqFilter restricts the infrastructure entities of the group, in
+	// the dynamic focus query syntax.
+	InfraDfqFilter string `json:"infra-dfq-filter,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=igroup,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Group",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// InstanaGroup is the Schema for the instanagroups API
+type InstanaGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InstanaGroupSpec      `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *InstanaGroup) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// InstanaGroupList contains a list of InstanaGroup
+type InstanaGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []InstanaGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&InstanaGroup{}, &InstanaGroupList{})
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InstanaResourceStatus is the status of the resources which are synced with
+// an Instana settings API, like ApplicationPerspective.
+type InstanaResourceStatus struct {
+	// The id of the resource in Instana.
+	Id string `json:"id,omitempty"`
+	// The generation of the spec which was processed in the last sync,
+	// successful or not.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// The SHA256 of the payload which was applied in the last sync.
+	AppliedConfigHash string `json:"applied-config-hash,omitempty"`
+	// Conditions of the resource.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SecretKeyReference selects a key of a Secret in the namespace of the resource.
+type SecretKeyReference struct {
+	// Name of the Secret.
+	Name string `json:"name"`
+	// Key in the Secret.
+	Key string `json:"key"`
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaintenanceWindowSpec defines the desired state of MaintenanceWindow
+type MaintenanceWindowSpec struct {
+	// Name of the maintenance window in Instana.
+	Name string `json:"name"`
+	// Query selects the entities in maintenance, in the dynamic focus query
+	// syntax, e.g. "entity.kubernetes.namespace.name:shop".
+	Query string `json:"query"`
+	// Start of the (first) window.
+	Start metav1.Time `json:"start"`
+	// Duration of a window.
+	Duration metav1.Duration `json:"duration"`
+	// Recurrence of the window as an RFC 5545 recurrence rule, e.g.
+	// "FREQ=WEEKLY;BYDAY=SA". A single window if not set.
+	Recurrence string `json:"recurrence,omitempty"`
+	// Paused disables the window without deleting it.
+	Paused bool `json:"paused,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=imw,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Start",type=string,format=date-time,JSONPath=`.spec.start`
+//+kubebuilder:printcolumn:name="Recurrence",type=string,JSONPath=`.spec.recurrence`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// MaintenanceWindow is the Schema for the maintenancewindows API
+type MaintenanceWindow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MaintenanceWindowSpec `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *MaintenanceWindow) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// MaintenanceWindowList contains a list of MaintenanceWindow
+type MaintenanceWindowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MaintenanceWindow `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MaintenanceWindow{}, &MaintenanceWindowList{})
+}
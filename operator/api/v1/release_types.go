@@ -0,0 +1,90 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReleaseSpec defines the desired state of Release
+type ReleaseSpec struct {
+	// Name of the release marker in Instana, e.g. "shop 1.4.2".
+	Name string `json:"name"`
+	// Start of the release. Defaults to the creation of the resource.
+	Start *metav1.Time `json:"start,omitempty"`
+	// Applications are the names of the application perspectives the marker
+	// is shown in. All applications if empty.
+	Applications []string `json:"applications,omitempty"`
+	// Services are the names of the released services.
+	Services []string `json:"services,omitempty"`
+}
+
+const (
+	// ReleaseMarkerAnnotation set to "true" on a Deployment creates a Release
+	// on every rollout of the Deployment.
+	ReleaseMarkerAnnotation = "custom.instana.io/release-marker"
+
+	// ReleaseVersionAnnotation overrides the version in the name of the
+	// Releases of a Deployment. Defaults to the image tag of the first container.
+	ReleaseVersionAnnotation = "custom.instana.io/release-version"
+
+	// ReleaseApplicationsAnnotation lists the application perspectives of the
+	// Releases of a Deployment, separated by commas.
+	ReleaseApplicationsAnnotation = "custom.instana.io/release-applications"
+
+	// ReleaseServicesAnnotation lists the services of the Releases of a
+	// Deployment, separated by commas. Defaults to the name of the Deployment.
+	ReleaseServicesAnnotation = "custom.instana.io/release-services"
+
+	// DeploymentLabel is set on Releases created for a rollout and holds the
+	// name of the Deployment.
+	DeploymentLabel = "custom.instana.io/deployment"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=irelease,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Release",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// Release is the Schema for the releases API
+type Release struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ReleaseSpec           `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *Release) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// ReleaseList contains a list of Release
+type ReleaseList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Release `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Release{}, &ReleaseList{})
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SLIConfigSpec defines the desired state of SLIConfig
+type SLIConfigSpec struct {
+	// Name of the SLI in Instana.
+	Name string `json:"name"`
+	// MetricName of the indicator, e.g. "latency".
+	MetricName string `json:"metric-name"`
+	// Aggregation of the metric.
+	//+kubebuilder:validation:Enum=SUM;MEAN;MAX;MIN;P25;P50;P75;P90;P95;P98;P99
+	//+kubebuilder:default=P90
+	Aggregation string `json:"aggregation,omitempty"`
+	// Threshold a good call must not exceed, e.g. 500 for a latency in ms.
+	Threshold resource.Quantity `json:"threshold"`
+	// ApplicationPerspectiveRef is the name of the ApplicationPerspective in
+	// the namespace the SLI is measured for.
+	ApplicationPerspectiveRef string `json:"application-perspective-ref,omitempty"`
+	// ApplicationId of an application perspective not managed as a resource.
+	// Either application-perspective-ref or application-id is required.
+	ApplicationId string `json:"application-id,omitempty"`
+	// ServiceId restricts the SLI to a service of the application.
+	ServiceId string `json:"service-id,omitempty"`
+	// EndpointId restricts the SLI to an endpoint of the service.
+	EndpointId string `json:"endpoint-id,omitempty"`
+	// BoundaryScope defines which calls of the application are measured.
+	//+kubebuilder:validation:Enum=ALL;INBOUND
+	//+kubebuilder:default=INBOUND
+	BoundaryScope string `json:"boundary-scope,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=isli,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="SLI",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// SLIConfig is the Schema for the sliconfigs API
+type SLIConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SLIConfigSpec         `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *SLIConfig) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// SLIConfigList contains a list of SLIConfig
+type SLIConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SLIConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SLIConfig{}, &SLIConfigList{})
+}
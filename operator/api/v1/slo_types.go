@@ -0,0 +1,89 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SLOSpec defines the desired state of SLO
+type SLOSpec struct {
+	// Name of the SLO in Instana.
+	Name string `json:"name"`
+	// SLIConfigRef is the name of the SLIConfig in the namespace the
+	// objective is defined for.
+	SLIConfigRef string `json:"sli-config-ref,omitempty"`
+	// SLIId of an SLI not managed as a resource. Either sli-config-ref or
+	// sli-id is required.
+	SLIId string `json:"sli-id,omitempty"`
+	// Target is the percentage of good calls, e.g. "99.9".
+	Target resource.Quantity `json:"target"`
+	// TimeWindow is the rolling window the objective is evaluated in.
+	//+kubebuilder:default="720h"
+	TimeWindow metav1.Duration `json:"time-window,omitempty"`
+	// ReportInterval is the interval in which the compliance and the error
+	// budget are read from Instana into the status. 0 disables the reports.
+	ReportInterval *metav1.Duration `json:"report-interval,omitempty"`
+}
+
+// SLOStatus defines the observed state of SLO
+type SLOStatus struct {
+	InstanaResourceStatus `json:",inline"`
+
+	// Compliance is the percentage of good calls in the time window.
+	Compliance string `json:"compliance,omitempty"`
+	// ErrorBudgetRemaining is the percentage of the error budget of the time
+	// window which is left.
+	ErrorBudgetRemaining string `json:"error-budget-remaining,omitempty"`
+	// Reported is the time of the last report.
+	Reported *metav1.Time `json:"reported,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=islo,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.target`
+//+kubebuilder:printcolumn:name="Compliance",type=string,JSONPath=`.status.compliance`
+//+kubebuilder:printcolumn:name="Budget",type=string,JSONPath=`.status.error-budget-remaining`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// SLO is the Schema for the slos API
+type SLO struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SLOSpec   `json:"spec,omitempty"`
+	Status SLOStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *SLO) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status.InstanaResourceStatus
+}
+
+//+kubebuilder:object:root=true
+
+// SLOList contains a list of SLO
+type SLOList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SLO `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SLO{}, &SLOList{})
+}
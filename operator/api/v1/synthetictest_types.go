@@ -0,0 +1,98 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SyntheticHttpAction is a single HTTP request checked by a SyntheticTest.
+type SyntheticHttpAction struct {
+	// URL of the request.
+	URL string `json:"url"`
+	// Method of the request.
+	//+kubebuilder:validation:Enum=GET;HEAD;OPTIONS;PATCH;POST;PUT;DELETE
+	//+kubebuilder:default=GET
+	Method string `json:"method,omitempty"`
+	// Headers of the request.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body of the request.
+	Body string `json:"body,omitempty"`
+	// ExpectStatus is the expected status code of the response. Any status
+	// below 400 passes if not set.
+	ExpectStatus int `json:"expect-status,omitempty"`
+	// ValidationString must be contained in the body of the response.
+	ValidationString string `json:"validation-string,omitempty"`
+	// Timeout of the request.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// SyntheticTestSpec defines the desired state of SyntheticTest
+type SyntheticTestSpec struct {
+	// Label of the test in Instana.
+	Label string `json:"label"`
+	// Description of the test.
+	Description string `json:"description,omitempty"`
+	// Paused stops running the test without deleting it.
+	Paused bool `json:"paused,omitempty"`
+	// Locations are the ids of the PoPs running the test.
+	//+kubebuilder:validation:MinItems=1
+	Locations []string `json:"locations"`
+	// Frequency in which the test runs.
+	//+kubebuilder:default="15m"
+	Frequency metav1.Duration `json:"frequency,omitempty"`
+	// HTTP checks a single endpoint.
+	HTTP *SyntheticHttpAction `json:"http,omitempty"`
+	// Script is an API script test. Either http or script is required.
+	Script string `json:"script,omitempty"`
+	// CustomProperties are tags of the test results.
+	CustomProperties map[string]string `json:"custom-properties,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=isynth,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Label",type=string,JSONPath=`.spec.label`
+//+kubebuilder:printcolumn:name="Paused",type=boolean,JSONPath=`.spec.paused`
+//+kubebuilder:printcolumn:name="Id",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// SyntheticTest is the Schema for the synthetictests API
+type SyntheticTest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SyntheticTestSpec     `json:"spec,omitempty"`
+	Status InstanaResourceStatus `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *SyntheticTest) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// SyntheticTestList contains a list of SyntheticTest
+type SyntheticTestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SyntheticTest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SyntheticTest{}, &SyntheticTestList{})
+}
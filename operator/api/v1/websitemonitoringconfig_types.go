@@ -0,0 +1,64 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WebsiteMonitoringConfigSpec defines the desired state of WebsiteMonitoringConfig
+type WebsiteMonitoringConfigSpec struct {
+	// Name of the website in Instana.
+	Name string `json:"name"`
+	// EumKeySecret is the name of a Secret the operator creates in the
+	// namespace, with the EUM key of the website as "eum-key". Optional.
+	EumKeySecret string `json:"eum-key-secret,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=iwebsite,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Website",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Key",type=string,JSONPath=`.status.id`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// WebsiteMonitoringConfig is the Schema for the websitemonitoringconfigs API.
+// The id in the status is the EUM key used by the JavaScript agent.
+type WebsiteMonitoringConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebsiteMonitoringConfigSpec `json:"spec,omitempty"`
+	Status InstanaResourceStatus       `json:"status,omitempty"`
+}
+
+// InstanaStatus returns the status shared by the Instana settings resources.
+func (in *WebsiteMonitoringConfig) InstanaStatus() *InstanaResourceStatus {
+	return &in.Status
+}
+
+//+kubebuilder:object:root=true
+
+// WebsiteMonitoringConfigList contains a list of WebsiteMonitoringConfig
+type WebsiteMonitoringConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebsiteMonitoringConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WebsiteMonitoringConfig{}, &WebsiteMonitoringConfigList{})
+}
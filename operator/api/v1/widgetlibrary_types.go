@@ -0,0 +1,87 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WidgetLibrarySpec defines the desired state of WidgetLibrary
+type WidgetLibrarySpec struct {
+	// Widgets are the reusable widgets of the library.
+	Widgets []LibraryWidget `json:"widgets"`
+	// Params are the default values of the parameters of the widgets.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// LibraryWidget is a named widget JSON snippet. Its string values may
+// reference parameters as ${name}, e.g. "title": "Calls of ${service}".
+type LibraryWidget struct {
+	// Name of the widget in the library.
+	Name string `json:"name"`
+	// Widget is the JSON of the widget as in the widgets of a dashboard config.
+	// +kubebuilder:validation:Type=object
+	Widget apiextensionsv1.JSON `json:"widget"`
+}
+
+// WidgetSource selects widgets of a WidgetLibrary for a Dashboard.
+type WidgetSource struct {
+	// Library is the name of a WidgetLibrary in the namespace of the Dashboard.
+	Library string `json:"library"`
+	// Widgets are the names of the widgets to add, in this order. Defaults to
+	// all widgets of the library.
+	Widgets []string `json:"widgets,omitempty"`
+	// Params override the parameters of the library.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// DashboardInclude references a Dashboard or WidgetLibrary whose widgets are
+// included into a Dashboard.
+type DashboardInclude struct {
+	// Kind is Dashboard (default) or WidgetLibrary.
+	//+kubebuilder:validation:Enum=Dashboard;WidgetLibrary
+	Kind string `json:"kind,omitempty"`
+	// Name is the name of the resource in the namespace of the Dashboard.
+	Name string `json:"name"`
+	// Params override the parameters of a WidgetLibrary.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=iwidgets,categories=instana
+// WidgetLibrary is the Schema for the widgetlibraries API. Its widgets are
+// added to the config of the Dashboards selecting them in spec.widgets-from.
+type WidgetLibrary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WidgetLibrarySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WidgetLibraryList contains a list of WidgetLibrary
+type WidgetLibraryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WidgetLibrary `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WidgetLibrary{}, &WidgetLibraryList{})
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	v1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+var _ conversion.Convertible = &Dashboard{}
+
+// ConvertTo converts this Dashboard to the stored v1 version. The title and
+// the widgets are merged into spec.config.
+func (src *Dashboard) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1.Dashboard)
+	config, err := joinConfig(src.Spec.Title, src.Spec.Widgets, src.Spec.Config)
+	if err != nil {
+		return err
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = v1.DashboardSpec{
+		InstanaApiTokenRelationId: src.Spec.InstanaApiTokenRelationId,
+		InstanaUserId:             src.Spec.InstanaUserId,
+		Config:                    config,
+		MirrorTenant:              src.Spec.MirrorTenant,
+		SyncPolicy:                src.Spec.SyncPolicy,
+		SyncSchedule:              src.Spec.SyncSchedule,
+		AdvisoryWidgets:           src.Spec.AdvisoryWidgets,
+		DryRun:                    src.Spec.DryRun,
+		Templated:                 src.Spec.Templated,
+		Jsonnet:                   src.Spec.Jsonnet,
+		Values:                    src.Spec.Values,
+		Clusters:                  src.Spec.Clusters,
+		Tags:                      src.Spec.Tags,
+		ExistingDashboardId:       src.Spec.ExistingDashboardId,
+	}
+	if src.Spec.ConfigFrom != nil {
+		dst.Spec.ConfigFrom = &v1.ConfigMapKeyReference{Name: src.Spec.ConfigFrom.Name, Key: src.Spec.ConfigFrom.Key}
+	}
+	if src.Spec.TimeRange != nil {
+		dst.Spec.TimeRange = &v1.TimeRange{Window: src.Spec.TimeRange.Window, AutoRefresh: src.Spec.TimeRange.AutoRefresh}
+	}
+	for _, rule := range src.Spec.AccessRules {
+		dst.Spec.AccessRules = append(dst.Spec.AccessRules, v1.AccessRule{AccessType: rule.AccessType, RelationType: rule.RelationType, RelatedId: rule.RelatedId})
+	}
+	for _, source := range src.Spec.WidgetsFrom {
+		dst.Spec.WidgetsFrom = append(dst.Spec.WidgetsFrom, v1.WidgetSource{Library: source.Library, Widgets: source.Widgets, Params: source.Params})
+	}
+	for _, patch := range src.Spec.Patches {
+		dst.Spec.Patches = append(dst.Spec.Patches, v1.ConfigPatch{Type: patch.Type, Patch: patch.Patch})
+	}
+	for _, value := range src.Spec.ValuesFrom {
+		dst.Spec.ValuesFrom = append(dst.Spec.ValuesFrom, v1.ValueSource{Name: value.Name, SecretKeyRef: v1.SecretKeyReference{Name: value.SecretKeyRef.Name, Key: value.SecretKeyRef.Key}})
+	}
+	for _, ref := range src.Spec.EntityRefs {
+		dst.Spec.EntityRefs = append(dst.Spec.EntityRefs, v1.EntityRef{Name: ref.Name, Kind: ref.Kind, Workload: ref.Workload, Namespace: ref.Namespace})
+	}
+	for _, variant := range src.Spec.Variants {
+		converted := v1.DashboardVariant{Name: variant.Name, Vars: variant.Vars}
+		for _, patch := range variant.Patches {
+			converted.Patches = append(converted.Patches, v1.ConfigPatch{Type: patch.Type, Patch: patch.Patch})
+		}
+		dst.Spec.Variants = append(dst.Spec.Variants, converted)
+	}
+	for _, include := range src.Spec.Includes {
+		dst.Spec.Includes = append(dst.Spec.Includes, v1.DashboardInclude{Kind: include.Kind, Name: include.Name, Params: include.Params})
+	}
+	dst.Status = v1.DashboardStatus{
+		DashboardId:        src.Status.DashboardId,
+		DashboardTitle:     src.Status.DashboardTitle,
+		DashboardUrl:       src.Status.DashboardUrl,
+		LastSyncTime:       src.Status.LastSyncTime,
+		SyncAttempts:       src.Status.SyncAttempts,
+		LastError:          src.Status.LastError,
+		MirrorTenant:       src.Status.MirrorTenant,
+		MirrorDashboardId:  src.Status.MirrorDashboardId,
+		ObservedGeneration: src.Status.ObservedGeneration,
+		AppliedConfigHash:  src.Status.AppliedConfigHash,
+		HotfixPatch:        src.Status.HotfixPatch,
+		Conditions:         src.Status.Conditions,
+	}
+	for _, cluster := range src.Status.Clusters {
+		dst.Status.Clusters = append(dst.Status.Clusters, v1.ClusterDashboardStatus{Cluster: cluster.Cluster, DashboardId: cluster.DashboardId, Error: cluster.Error})
+	}
+	for _, variant := range src.Status.Variants {
+		dst.Status.Variants = append(dst.Status.Variants, v1.VariantDashboardStatus{Name: variant.Name, DashboardId: variant.DashboardId, Error: variant.Error})
+	}
+	return nil
+}
+
+// ConvertFrom converts the stored v1 version to this Dashboard. The title and
+// the widgets are taken out of spec.config if they fit into the structured
+// fields, so converting back yields the same config.
+func (dst *Dashboard) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1.Dashboard)
+	title, widgets, config := splitConfig(src.Spec.Config)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = DashboardSpec{
+		Title:                     title,
+		Widgets:                   widgets,
+		Config:                    config,
+		MirrorTenant:              src.Spec.MirrorTenant,
+		SyncPolicy:                src.Spec.SyncPolicy,
+		SyncSchedule:              src.Spec.SyncSchedule,
+		AdvisoryWidgets:           src.Spec.AdvisoryWidgets,
+		DryRun:                    src.Spec.DryRun,
+		Templated:                 src.Spec.Templated,
+		Jsonnet:                   src.Spec.Jsonnet,
+		Values:                    src.Spec.Values,
+		Clusters:                  src.Spec.Clusters,
+		Tags:                      src.Spec.Tags,
+		ExistingDashboardId:       src.Spec.ExistingDashboardId,
+		InstanaApiTokenRelationId: src.Spec.InstanaApiTokenRelationId,
+		InstanaUserId:             src.Spec.InstanaUserId,
+	}
+	if src.Spec.ConfigFrom != nil {
+		dst.Spec.ConfigFrom = &ConfigMapKeyReference{Name: src.Spec.ConfigFrom.Name, Key: src.Spec.ConfigFrom.Key}
+	}
+	if src.Spec.TimeRange != nil {
+		dst.Spec.TimeRange = &TimeRange{Window: src.Spec.TimeRange.Window, AutoRefresh: src.Spec.TimeRange.AutoRefresh}
+	}
+	for _, rule := range src.Spec.AccessRules {
+		dst.Spec.AccessRules = append(dst.Spec.AccessRules, AccessRule{AccessType: rule.AccessType, RelationType: rule.RelationType, RelatedId: rule.RelatedId})
+	}
+	for _, source := range src.Spec.WidgetsFrom {
+		dst.Spec.WidgetsFrom = append(dst.Spec.WidgetsFrom, WidgetSource{Library: source.Library, Widgets: source.Widgets, Params: source.Params})
+	}
+	for _, patch := range src.Spec.Patches {
+		dst.Spec.Patches = append(dst.Spec.Patches, ConfigPatch{Type: patch.Type, Patch: patch.Patch})
+	}
+	for _, value := range src.Spec.ValuesFrom {
+		dst.Spec.ValuesFrom = append(dst.Spec.ValuesFrom, ValueSource{Name: value.Name, SecretKeyRef: SecretKeyReference{Name: value.SecretKeyRef.Name, Key: value.SecretKeyRef.Key}})
+	}
+	for _, ref := range src.Spec.EntityRefs {
+		dst.Spec.EntityRefs = append(dst.Spec.EntityRefs, EntityRef{Name: ref.Name, Kind: ref.Kind, Workload: ref.Workload, Namespace: ref.Namespace})
+	}
+	for _, variant := range src.Spec.Variants {
+		converted := DashboardVariant{Name: variant.Name, Vars: variant.Vars}
+		for _, patch := range variant.Patches {
+			converted.Patches = append(converted.Patches, ConfigPatch{Type: patch.Type, Patch: patch.Patch})
+		}
+		dst.Spec.Variants = append(dst.Spec.Variants, converted)
+	}
+	for _, include := range src.Spec.Includes {
+		dst.Spec.Includes = append(dst.Spec.Includes, DashboardInclude{Kind: include.Kind, Name: include.Name, Params: include.Params})
+	}
+	dst.Status = DashboardStatus{
+		DashboardId:        src.Status.DashboardId,
+		DashboardTitle:     src.Status.DashboardTitle,
+		DashboardUrl:       src.Status.DashboardUrl,
+		LastSyncTime:       src.Status.LastSyncTime,
+		SyncAttempts:       src.Status.SyncAttempts,
+		LastError:          src.Status.LastError,
+		MirrorTenant:       src.Status.MirrorTenant,
+		MirrorDashboardId:  src.Status.MirrorDashboardId,
+		ObservedGeneration: src.Status.ObservedGeneration,
+		AppliedConfigHash:  src.Status.AppliedConfigHash,
+		HotfixPatch:        src.Status.HotfixPatch,
+		Conditions:         src.Status.Conditions,
+	}
+	for _, cluster := range src.Status.Clusters {
+		dst.Status.Clusters = append(dst.Status.Clusters, ClusterDashboardStatus{Cluster: cluster.Cluster, DashboardId: cluster.DashboardId, Error: cluster.Error})
+	}
+	for _, variant := range src.Status.Variants {
+		dst.Status.Variants = append(dst.Status.Variants, VariantDashboardStatus{Name: variant.Name, DashboardId: variant.DashboardId, Error: variant.Error})
+	}
+	return nil
+}
+
+// joinConfig merges the title and the widgets into the config.
+func joinConfig(title string, widgets []Widget, config *apiextensionsv1.JSON) (*apiextensionsv1.JSON, error) {
+	if title == "" && len(widgets) == 0 {
+		return config, nil
+	}
+	var fields map[string]json.RawMessage
+	if config != nil && len(config.Raw) > 0 {
+		if err := json.Unmarshal(config.Raw, &fields); err != nil {
+			return nil, fmt.Errorf("spec.config must be an object if spec.title or spec.widgets are set: %w", err)
+		}
+	}
+	if fields == nil {
+		fields = map[string]json.RawMessage{}
+	}
+	if title != "" {
+		fields["title"], _ = json.Marshal(title)
+	}
+	if len(widgets) > 0 {
+		raw, err := json.Marshal(widgets)
+		if err != nil {
+			return nil, err
+		}
+		fields["widgets"] = raw
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return &apiextensionsv1.JSON{Raw: raw}, nil
+}
+
+// splitConfig takes the title and the widgets out of the config. Widgets are
+// only taken out if all of them fit into Widget without losing fields.
+func splitConfig(config *apiextensionsv1.JSON) (string, []Widget, *apiextensionsv1.JSON) {
+	var fields map[string]json.RawMessage
+	if config == nil || json.Unmarshal(config.Raw, &fields) != nil || fields == nil {
+		return "", nil, config
+	}
+	var title string
+	if raw, ok := fields["title"]; ok {
+		if json.Unmarshal(raw, &title) == nil && title != "" {
+			delete(fields, "title")
+		} else {
+			title = ""
+		}
+	}
+	widgets := structuredWidgets(fields["widgets"])
+	if widgets != nil {
+		delete(fields, "widgets")
+	}
+	if title == "" && widgets == nil {
+		return "", nil, config
+	}
+	if len(fields) == 0 {
+		return title, widgets, nil
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return "", nil, config
+	}
+	return title, widgets, &apiextensionsv1.JSON{Raw: raw}
+}
+
+func structuredWidgets(raw json.RawMessage) []Widget {
+	var items []json.RawMessage
+	if len(raw) == 0 || json.Unmarshal(raw, &items) != nil || len(items) == 0 {
+		return nil
+	}
+	widgets := make([]Widget, len(items))
+	for i, item := range items {
+		decoder := json.NewDecoder(bytes.NewReader(item))
+		decoder.DisallowUnknownFields()
+		if decoder.Decode(&widgets[i]) != nil {
+			return nil
+		}
+		converted, err := json.Marshal(widgets[i])
+		if err != nil || !jsonEqual(item, converted) {
+			return nil
+		}
+	}
+	return widgets
+}
+
+func jsonEqual(a, b []byte) bool {
+	var av, bv interface{}
+	if json.Unmarshal(a, &av) != nil || json.Unmarshal(b, &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"reflect"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestConvertFromV1(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		wantTitle   string
+		wantWidgets int
+		wantConfig  string
+	}{
+		{
+			name:        "structured",
+			config:      `{"title":"Shop","widgets":[{"id":"a","title":"CPU","type":"chart","width":3,"height":6,"x":0,"y":0,"config":{"type":"TIME_SERIES"}}],"writable":true}`,
+			wantTitle:   "Shop",
+			wantWidgets: 1,
+			wantConfig:  `{"writable":true}`,
+		},
+		{
+			name:       "widgets with unknown fields",
+			config:     `{"title":"Shop","widgets":[{"id":"a","type":"chart","color":"red"}]}`,
+			wantTitle:  "Shop",
+			wantConfig: `{"widgets":[{"id":"a","type":"chart","color":"red"}]}`,
+		},
+		{
+			name:       "templated widgets",
+			config:     `{"widgets":[{"id":"a","type":"chart","width":"{{ .Vars.width }}"}]}`,
+			wantConfig: `{"widgets":[{"id":"a","type":"chart","width":"{{ .Vars.width }}"}]}`,
+		},
+		{
+			name:       "legacy string",
+			config:     `"{\"title\":\"Shop\"}"`,
+			wantConfig: `"{\"title\":\"Shop\"}"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &v1.Dashboard{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+				Spec: v1.DashboardSpec{
+					Config:      &apiextensionsv1.JSON{Raw: []byte(tt.config)},
+					SyncPolicy:  v1.SyncPolicyEnforce,
+					Tags:        []string{"team:shop"},
+					AccessRules: []v1.AccessRule{{AccessType: "READ", RelationType: "GLOBAL"}},
+					Includes:    []v1.DashboardInclude{{Kind: "WidgetLibrary", Name: "golden-signals", Params: map[string]string{"service": "shop"}}},
+					ValuesFrom:  []v1.ValueSource{{Name: "entity", SecretKeyRef: v1.SecretKeyReference{Name: "shop-ids", Key: "entity-id"}}},
+					EntityRefs:  []v1.EntityRef{{Name: "payments", Kind: "Deployment", Workload: "payments", Namespace: "prod"}},
+					Variants: []v1.DashboardVariant{{Name: "prod", Vars: map[string]string{"stage": "prod"}, Patches: []v1.ConfigPatch{
+						{Type: v1.ConfigPatchMerge, Patch: apiextensionsv1.JSON{Raw: []byte(`{"writable":false}`)}},
+					}}},
+				},
+				Status: v1.DashboardStatus{DashboardId: "id", Clusters: []v1.ClusterDashboardStatus{{Cluster: "prod"}}},
+			}
+			var dst Dashboard
+			if err := dst.ConvertFrom(src); err != nil {
+				t.Fatal(err)
+			}
+			if dst.Spec.Title != tt.wantTitle || len(dst.Spec.Widgets) != tt.wantWidgets {
+				t.Errorf("title = %q, widgets = %+v", dst.Spec.Title, dst.Spec.Widgets)
+			}
+			if !jsonEqual(dst.Spec.Config.Raw, []byte(tt.wantConfig)) {
+				t.Errorf("config = %s, want %s", dst.Spec.Config.Raw, tt.wantConfig)
+			}
+			if dst.Spec.SyncPolicy != v1.SyncPolicyEnforce || dst.Spec.AccessRules[0].RelationType != "GLOBAL" || dst.Status.Clusters[0].Cluster != "prod" || dst.Spec.Tags[0] != "team:shop" {
+				t.Errorf("dst = %+v", dst)
+			}
+
+			// converting back yields the same config
+			var back v1.Dashboard
+			if err := dst.ConvertTo(&back); err != nil {
+				t.Fatal(err)
+			}
+			if !jsonEqual(back.Spec.Config.Raw, []byte(tt.config)) {
+				t.Errorf("config = %s after the round trip, want %s", back.Spec.Config.Raw, tt.config)
+			}
+			back.Spec.Config, src.Spec.Config = nil, nil
+			if !reflect.DeepEqual(back, *src) {
+				t.Errorf("round trip = %+v, want %+v", back, *src)
+			}
+		})
+	}
+}
+
+func TestConvertToV1(t *testing.T) {
+	width := int32(3)
+	src := &Dashboard{Spec: DashboardSpec{
+		Title:   "Shop",
+		Widgets: []Widget{{Id: "a", Type: "markdown", Width: &width, Config: &apiextensionsv1.JSON{Raw: []byte(`"hello"`)}}},
+		Config:  &apiextensionsv1.JSON{Raw: []byte(`{"title":"overridden","writable":true}`)},
+	}}
+	var dst v1.Dashboard
+	if err := src.ConvertTo(&dst); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"title":"Shop","widgets":[{"id":"a","type":"markdown","width":3,"config":"hello"}],"writable":true}`
+	if !jsonEqual(dst.Spec.Config.Raw, []byte(want)) {
+		t.Errorf("config = %s, want %s", dst.Spec.Config.Raw, want)
+	}
+
+	src.Spec.Config = &apiextensionsv1.JSON{Raw: []byte(`"legacy"`)}
+	if err := src.ConvertTo(&dst); err == nil {
+		t.Error("expected an error for a config which is not an object")
+	}
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DashboardSpec defines the desired state of Dashboard. Compared to v1 the
+// title and the widgets of the dashboard are structured fields.
+type DashboardSpec struct {
+	// Title of the dashboard in Instana.
+	Title string `json:"title,omitempty"`
+	// Widgets of the dashboard.
+	Widgets []Widget `json:"widgets,omitempty"`
+	// Config holds the remaining fields of the json definition of the
+	// dashboard. Configs with widgets which don't fit into Widgets are kept
+	// here as a whole.
+	// +kubebuilder:validation:Type=object
+	Config *apiextensionsv1.JSON `json:"config,omitempty"`
+	// ConfigFrom selects a key of a ConfigMap holding the json definition of
+	// the dashboard, used instead of Title, Widgets and Config.
+	ConfigFrom *ConfigMapKeyReference `json:"configFrom,omitempty"`
+	// Jsonnet is a Jsonnet program evaluating to the json definition of the
+	// dashboard, used instead of Title, Widgets and Config.
+	Jsonnet string `json:"jsonnet,omitempty"`
+	// AccessRules replace the accessRules of the config, so sharing the
+	// dashboard is enforced on every sync.
+	AccessRules []AccessRule `json:"accessRules,omitempty"`
+	// MirrorTenant is the name of a ConfigMap with the config of a secondary
+	// Instana tenant the dashboard is replicated to.
+	MirrorTenant string `json:"mirrorTenant,omitempty"`
+	// SyncPolicy defines how changes done in the Instana UI are handled.
+	// Overwrite (default) replaces them on the next sync. Import writes them
+	// back into the config of this resource. Enforce checks for them
+	// periodically and reverts them right away.
+	//+kubebuilder:validation:Enum=Overwrite;Import;Enforce
+	SyncPolicy string `json:"syncPolicy,omitempty"`
+	// SyncSchedule is a cron expression in UTC, e.g. "*/5 * * * *" or
+	// "@daily", defining when the dashboard is synced and checked for drift.
+	SyncSchedule string `json:"syncSchedule,omitempty"`
+	// AdvisoryWidgets are the ids of widgets which may be changed in the
+	// Instana UI. With the Enforce sync policy their changes are reported but
+	// not reverted.
+	AdvisoryWidgets []string `json:"advisoryWidgets,omitempty"`
+	// DryRun reports what would be changed in Instana without changing it.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Patches are applied to the config of the dashboard, in this order.
+	Patches []ConfigPatch `json:"patches,omitempty"`
+	// WidgetsFrom adds widgets of WidgetLibraries after the widgets of the
+	// dashboard.
+	WidgetsFrom []WidgetSource `json:"widgetsFrom,omitempty"`
+	// Includes add the widgets of other Dashboards and WidgetLibraries as
+	// blocks below the widgets of the dashboard.
+	Includes []DashboardInclude `json:"includes,omitempty"`
+	// Templated renders the string values of the config as Go templates.
+	Templated bool `json:"templated,omitempty"`
+	// ValuesFrom reads values of templated configs from Secrets, available
+	// as .Values.<name>.
+	ValuesFrom []ValueSource `json:"valuesFrom,omitempty"`
+	// Values are values of templated configs and of Jsonnet, available as
+	// .Values.<name>.
+	Values map[string]string `json:"values,omitempty"`
+	// EntityRefs resolve Kubernetes workloads to the snapshot ids of their
+	// Instana entities, available as .Entities.<name>.
+	EntityRefs []EntityRef `json:"entityRefs,omitempty"`
+	// Clusters creates one dashboard per cluster instead of a single one.
+	Clusters []string `json:"clusters,omitempty"`
+	// Variants create one dashboard per variant, e.g. per environment,
+	// instead of a single one.
+	Variants []DashboardVariant `json:"variants,omitempty"`
+	// Tags group the dashboard, e.g. by team or service.
+	Tags []string `json:"tags,omitempty"`
+	// TimeRange is the time window the dashboard opens with from the link
+	// in status.dashboardUrl.
+	TimeRange *TimeRange `json:"timeRange,omitempty"`
+	// ExistingDashboardId is the id of an existing Instana dashboard which is
+	// adopted on the first sync instead of creating a new dashboard.
+	ExistingDashboardId string `json:"existingDashboardId,omitempty"`
+	// Deprecated: not used by the operator.
+	InstanaApiTokenRelationId string `json:"instanaApiTokenRelationId,omitempty"`
+	// Deprecated: not used by the operator.
+	InstanaUserId string `json:"instanaUserId,omitempty"`
+}
+
+// Widget is a widget of a dashboard.
+type Widget struct {
+	// Id of the widget, unique within the dashboard.
+	Id string `json:"id,omitempty"`
+	// Title of the widget.
+	Title string `json:"title,omitempty"`
+	// Type of the widget, e.g. "chart" or "markdown".
+	Type string `json:"type"`
+	// Width of the widget in grid columns.
+	Width *int32 `json:"width,omitempty"`
+	// Height of the widget in grid rows.
+	Height *int32 `json:"height,omitempty"`
+	// X is the column of the widget.
+	X *int32 `json:"x,omitempty"`
+	// Y is the row of the widget.
+	Y *int32 `json:"y,omitempty"`
+	// Config of the widget, depending on its type.
+	// +kubebuilder:validation:Type=object
+	Config *apiextensionsv1.JSON `json:"config,omitempty"`
+}
+
+// TimeRange is the default time window of a dashboard.
+type TimeRange struct {
+	// Window is the size of the time window, e.g. "1h" or "168h".
+	Window *metav1.Duration `json:"window,omitempty"`
+	// AutoRefresh moves the time window along while the dashboard is open.
+	// Defaults to the setting of the Instana user.
+	AutoRefresh *bool `json:"autoRefresh,omitempty"`
+}
+
+// ConfigMapKeyReference selects a key of a ConfigMap in the namespace of the resource.
+type ConfigMapKeyReference struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+	// Key in the ConfigMap.
+	Key string `json:"key"`
+}
+
+// AccessRule shares a dashboard in Instana.
+type AccessRule struct {
+	//+kubebuilder:validation:Enum=READ;READ_WRITE
+	AccessType string `json:"accessType"`
+	//+kubebuilder:validation:Enum=USER;API_TOKEN;ROLE;TEAM;GLOBAL
+	RelationType string `json:"relationType"`
+	// RelatedId is the id of the user, API token, role or team. Empty for GLOBAL.
+	RelatedId string `json:"relatedId,omitempty"`
+}
+
+// WidgetSource selects widgets of a WidgetLibrary.
+type WidgetSource struct {
+	// Library is the name of a WidgetLibrary in the namespace of the Dashboard.
+	Library string `json:"library"`
+	// Widgets are the names of the widgets to add, in this order. Defaults to
+	// all widgets of the library.
+	Widgets []string `json:"widgets,omitempty"`
+	// Params override the parameters of the library.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// ConfigPatch is a patch of the config of a Dashboard.
+type ConfigPatch struct {
+	// Type is JSONPatch (default), a RFC 6902 JSON Patch, or Merge, a RFC 7386
+	// JSON Merge Patch.
+	//+kubebuilder:validation:Enum=JSONPatch;Merge
+	Type string `json:"type,omitempty"`
+	// Patch is the list of operations of a JSONPatch or the object merged
+	// into the config.
+	Patch apiextensionsv1.JSON `json:"patch"`
+}
+
+// DashboardInclude references a Dashboard or WidgetLibrary whose widgets are
+// included.
+type DashboardInclude struct {
+	// Kind is Dashboard (default) or WidgetLibrary.
+	//+kubebuilder:validation:Enum=Dashboard;WidgetLibrary
+	Kind string `json:"kind,omitempty"`
+	// Name is the name of the resource in the namespace of the Dashboard.
+	Name string `json:"name"`
+	// Params override the parameters of a WidgetLibrary.
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// DashboardStatus defines the observed state of Dashboard
+type DashboardStatus struct {
+	// The id of the dashboard after it has been created.
+	DashboardId string `json:"dashboardId,omitempty"`
+	// The title of the dashboard after it has been created.
+	DashboardTitle string `json:"dashboardTitle,omitempty"`
+	// The url of the dashboard in Instana.
+	DashboardUrl string `json:"dashboardUrl,omitempty"`
+	// The time of the last sync with Instana, successful or not.
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// The number of consecutive failed syncs, reset by a successful sync.
+	SyncAttempts int32 `json:"syncAttempts,omitempty"`
+	// The error of the last failed sync, cleared by a successful sync.
+	LastError string `json:"lastError,omitempty"`
+	// The tenant the dashboard was last replicated to.
+	MirrorTenant string `json:"mirrorTenant,omitempty"`
+	// The id of the dashboard in the mirror tenant.
+	MirrorDashboardId string `json:"mirrorDashboardId,omitempty"`
+	// The generation of the spec which was processed in the last sync,
+	// successful or not.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// The SHA256 of the config which was applied in the last sync.
+	AppliedConfigHash string `json:"appliedConfigHash,omitempty"`
+	// The hotfix patch from the annotation which was applied in the last sync.
+	HotfixPatch string `json:"hotfixPatch,omitempty"`
+	// The dashboards of the clusters of the spec.
+	Clusters []ClusterDashboardStatus `json:"clusters,omitempty"`
+	// The dashboards of the variants of the spec.
+	Variants []VariantDashboardStatus `json:"variants,omitempty"`
+	// Conditions of the dashboard.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ClusterDashboardStatus is the dashboard of a cluster of spec.clusters.
+type ClusterDashboardStatus struct {
+	// The name of the cluster.
+	Cluster string `json:"cluster"`
+	// The id of the dashboard of the cluster.
+	DashboardId string `json:"dashboardId,omitempty"`
+	// The error of the last sync of the dashboard, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// ValueSource is a value of templated configs read from a Secret.
+type ValueSource struct {
+	// Name of the value in .Values.
+	Name string `json:"name"`
+	// SecretKeyRef selects the key of a Secret holding the value.
+	SecretKeyRef SecretKeyReference `json:"secretKeyRef"`
+}
+
+// SecretKeyReference selects a key of a Secret in the namespace of the Dashboard.
+type SecretKeyReference struct {
+	// Name of the Secret.
+	Name string `json:"name"`
+	// Key in the Secret.
+	Key string `json:"key"`
+}
+
+// EntityRef references the Instana entity of a Kubernetes workload.
+type EntityRef struct {
+	// Name of the entity in .Entities.
+	Name string `json:"name"`
+	// Kind of the workload.
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet;Pod;Service
+	Kind string `json:"kind"`
+	// Workload is the name of the workload.
+	Workload string `json:"workload"`
+	// Namespace of the workload. Defaults to the namespace of the Dashboard.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// DashboardVariant is a variant of a Dashboard, e.g. for an environment.
+type DashboardVariant struct {
+	// Name of the variant, appended to the title.
+	Name string `json:"name"`
+	// Vars are added to the .Vars of the templated config.
+	Vars map[string]string `json:"vars,omitempty"`
+	// Patches are applied to the config after spec.patches.
+	Patches []ConfigPatch `json:"patches,omitempty"`
+}
+
+// VariantDashboardStatus is the dashboard of a variant of spec.variants.
+type VariantDashboardStatus struct {
+	// The name of the variant.
+	Name string `json:"name"`
+	// The id of the dashboard of the variant.
+	DashboardId string `json:"dashboardId,omitempty"`
+	// The error of the last sync of the dashboard, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=idash,categories=instana
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Dashboard-Id",type=string,JSONPath=`.status.dashboardId`
+//+kubebuilder:printcolumn:name="Dashboard-Title",type=string,JSONPath=`.status.dashboardTitle`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.dashboardUrl`,priority=1
+// Dashboard is the Schema for the dashboards API
+type Dashboard struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DashboardSpec   `json:"spec,omitempty"`
+	Status DashboardStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DashboardList contains a list of Dashboard
+type DashboardList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Dashboard `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Dashboard{}, &DashboardList{})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/controllers"
+)
+
+// adopt writes the ids of existing Instana dashboards into the status of the
+// Dashboards which were not synced yet, so the operator takes them over
+// instead of creating copies.
+func adopt(args []string) error {
+	fs := flag.NewFlagSet("adopt", flag.ExitOnError)
+	api := tenantFlags(fs)
+	kube := kubeFlags(fs)
+	var vars controllers.RenderVariables
+	var templateVars, mappingFile string
+	var allNamespaces, dryRun bool
+	fs.StringVar(&vars.ClusterName, "cluster-name", "", "The cluster name of the operator, for templated configs.")
+	fs.StringVar(&vars.Zone, "zone", "", "The zone of the operator, for templated configs.")
+	fs.StringVar(&templateVars, "template-vars", "", "The template variables of the operator, for templated configs.")
+	fs.StringVar(&mappingFile, "mapping", "", "A YAML file mapping <namespace>/<name> of Dashboards to Instana dashboard ids. Dashboards without an entry are matched by title.")
+	fs.BoolVar(&allNamespaces, "all-namespaces", false, "Adopt the Dashboards of all namespaces.")
+	fs.BoolVar(&dryRun, "dry-run", false, "Only print the dashboards which would be adopted.")
+	_ = fs.Parse(args)
+	vars.Vars = controllers.ParseKeyValues(templateVars)
+
+	mapping := map[string]string{}
+	if mappingFile != "" {
+		data, err := ioutil.ReadFile(mappingFile)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(data, &mapping); err != nil {
+			return fmt.Errorf("invalid --mapping: %w", err)
+		}
+	}
+
+	ctx := context.Background()
+	c, namespace, err := kube.client()
+	if err != nil {
+		return err
+	}
+	if api.BaseUrl == "" {
+		tenant, err := controllers.LoadTenantConfig(ctx, c, "")
+		if err != nil {
+			return err
+		}
+		tenant.AuthScheme = api.AuthScheme
+		if api.ApiToken != "" {
+			tenant.ApiToken = api.ApiToken
+		}
+		*api = tenant
+	}
+	var opts []client.ListOption
+	if !allNamespaces {
+		opts = append(opts, client.InNamespace(namespace))
+	}
+	var dashboards customv1.DashboardList
+	if err := c.List(ctx, &dashboards, opts...); err != nil {
+		return err
+	}
+
+	adoptions, err := controllers.AdoptDashboards(ctx, c, vars, *api, dashboards.Items, mapping, logr.Discard())
+	if err != nil {
+		return err
+	}
+	adopted := 0
+	for _, a := range adoptions {
+		if a.DashboardId == "" {
+			fmt.Fprintf(os.Stderr, "Skipped %s/%s: %s\n", a.Namespace, a.Name, a.Skipped)
+			continue
+		}
+		if !dryRun {
+			dashboard := &customv1.Dashboard{}
+			if err := c.Get(ctx, client.ObjectKey{Namespace: a.Namespace, Name: a.Name}, dashboard); err != nil {
+				return err
+			}
+			if dashboard.Status.DashboardId != "" {
+				fmt.Fprintf(os.Stderr, "Skipped %s/%s: synced in the meantime\n", a.Namespace, a.Name)
+				continue
+			}
+			patch := client.MergeFrom(dashboard.DeepCopy())
+			dashboard.Status.DashboardId = a.DashboardId
+			if err := c.Status().Patch(ctx, dashboard, patch); err != nil {
+				return err
+			}
+		}
+		adopted++
+		fmt.Printf("%s/%s adopts dashboard %s\n", a.Namespace, a.Name, a.DashboardId)
+	}
+	fmt.Fprintf(os.Stderr, "Adopted %d of %d dashboards\n", adopted, len(adoptions))
+	return nil
+}
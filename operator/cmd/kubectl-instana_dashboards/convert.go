@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/luebken/custom-dashboards/controllers"
+)
+
+func convert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	var file, name, namespace, tokenRelationId string
+	fs.StringVar(&file, "f", "-", "The dashboard JSON exported from the Instana UI. - reads stdin.")
+	fs.StringVar(&name, "name", "", "The name of the resource. Derived from the title if empty.")
+	fs.StringVar(&namespace, "namespace", "default", "The namespace of the resource.")
+	fs.StringVar(&namespace, "n", "default", "Shorthand for --namespace.")
+	fs.StringVar(&tokenRelationId, "api-token-relation-id", "", "The instana-api-token-relation-id of the resource.")
+	_ = fs.Parse(args)
+
+	data, err := readFile(file)
+	if err != nil {
+		return err
+	}
+	dashboard, err := controllers.ConvertExport(data, name, controllers.ExportOptions{
+		Namespace:                 namespace,
+		InstanaApiTokenRelationId: tokenRelationId,
+	})
+	if err != nil {
+		return err
+	}
+	return writeYaml(os.Stdout, []namedObject{{name: dashboard.Name, object: manifest(dashboard)}})
+}
+
+// readFile reads a JSON or YAML file as JSON, "-" reads stdin.
+func readFile(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err = yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return data, nil
+}
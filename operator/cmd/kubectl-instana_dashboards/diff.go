@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/controllers"
+)
+
+// errDiffFound makes the command exit with 1 like diff(1), without an error message.
+var errDiffFound = errors.New("differences found")
+
+func diff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	api := tenantFlags(fs)
+	kube := kubeFlags(fs)
+	var vars controllers.RenderVariables
+	var templateVars string
+	var namespaceCredentials bool
+	fs.StringVar(&vars.ClusterName, "cluster-name", "", "The cluster name of the operator, for templated configs.")
+	fs.StringVar(&vars.Zone, "zone", "", "The zone of the operator, for templated configs.")
+	fs.StringVar(&templateVars, "template-vars", "", "The template variables of the operator, for templated configs.")
+	fs.BoolVar(&namespaceCredentials, "namespace-credentials", false, "Use the credentials Secret of the namespace of the dashboard, like the operator with --namespace-credentials.")
+	_ = fs.Parse(args)
+	vars.Vars = controllers.ParseKeyValues(templateVars)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: kubectl instana-dashboards diff <dashboard> [-n <namespace>]")
+	}
+
+	ctx := context.Background()
+	c, namespace, err := kube.client()
+	if err != nil {
+		return err
+	}
+	var dashboard customv1.Dashboard
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: fs.Arg(0)}, &dashboard); err != nil {
+		return err
+	}
+	if api.BaseUrl == "" {
+		tenant, err := controllers.LoadDashboardTenant(ctx, c, dashboard, namespaceCredentials)
+		if err != nil {
+			return err
+		}
+		if api.AuthScheme != "" {
+			tenant.AuthScheme = api.AuthScheme
+		}
+		if api.ApiToken != "" {
+			tenant.ApiToken = api.ApiToken
+		}
+		*api = tenant
+	}
+	if dashboard.Annotations[customv1.HotfixPatchAnnotation] != "" {
+		fmt.Fprintln(os.Stderr, "The hotfix patch of the dashboard is not part of its spec, its changes are listed as differences.")
+	}
+
+	diffs, err := controllers.DiffDashboard(ctx, c, vars, *api, dashboard, logr.Discard())
+	if err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		fmt.Printf("%s\n  desired: %s\n  live:    %s\n", d.Path, jsonValue(d.Desired), jsonValue(d.Live))
+	}
+	if len(diffs) > 0 {
+		return errDiffFound
+	}
+	return nil
+}
+
+func jsonValue(v interface{}) string {
+	if v == nil {
+		return "<missing>"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+type kubeOptions struct {
+	kubeconfig string
+	namespace  string
+}
+
+// kubeFlags registers the flags selecting the cluster and namespace. They
+// default to the current kubectl context.
+func kubeFlags(fs *flag.FlagSet) *kubeOptions {
+	o := &kubeOptions{}
+	fs.StringVar(&o.kubeconfig, "kubeconfig", "", "The kubeconfig file. Defaults to the kubectl defaults.")
+	fs.StringVar(&o.namespace, "namespace", "", "The namespace of the dashboard. Defaults to the namespace of the current context.")
+	fs.StringVar(&o.namespace, "n", "", "Shorthand for --namespace.")
+	return o
+}
+
+func (o *kubeOptions) client() (client.Client, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+	config, err := cc.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace := o.namespace
+	if namespace == "" {
+		if namespace, _, err = cc.Namespace(); err != nil {
+			return nil, "", err
+		}
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	return c, namespace, err
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/yaml"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/controllers"
+	"github.com/luebken/custom-dashboards/terraform"
+)
+
+// tenantFlags registers the flags selecting the Instana tenant. They
+// default to the INSTANA_BASE_URL and INSTANA_API_TOKEN environment variables.
+func tenantFlags(fs *flag.FlagSet) *controllers.InstanaApi {
+	api := &controllers.InstanaApi{}
+	fs.StringVar(&api.BaseUrl, "base-url", os.Getenv("INSTANA_BASE_URL"), "The base url of the Instana tenant.")
+	fs.StringVar(&api.ApiToken, "api-token", os.Getenv("INSTANA_API_TOKEN"), "The API token of the Instana tenant.")
+	fs.StringVar(&api.AuthScheme, "auth-scheme", "", "The scheme of the authorization header. Defaults to apiToken.")
+	fs.StringVar(&api.ApiPath, "api-path", "", "The path replacing /api in the request urls, for gateways serving the Instana API under another path.")
+	return api
+}
+
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	api := tenantFlags(fs)
+	var namespace, title, accessRule, tags, outputDir, tokenRelationId, format string
+	var withIds bool
+	fs.StringVar(&namespace, "namespace", "default", "The namespace of the exported resources.")
+	fs.StringVar(&namespace, "n", "default", "Shorthand for --namespace.")
+	fs.StringVar(&title, "title", "", "Only export dashboards whose title matches this regular expression.")
+	fs.StringVar(&accessRule, "access-rule", "", "Only export dashboards with this access rule: <relationType> or <relationType>:<relatedId>, e.g. GLOBAL.")
+	fs.StringVar(&tags, "tag", "", "Only export dashboards with these comma separated tags of spec.tags.")
+	fs.StringVar(&outputDir, "output-dir", "", "Write one file per dashboard into this directory instead of stdout.")
+	fs.StringVar(&tokenRelationId, "api-token-relation-id", "", "The instana-api-token-relation-id of the exported resources.")
+	fs.BoolVar(&withIds, "with-ids", false, "Also write the id store ConfigMap, so an operator running with --id-store=configmap takes over the dashboards instead of creating copies. With --format=terraform import blocks adopt the dashboards.")
+	fs.StringVar(&format, "format", "yaml", "The output format: yaml for Dashboard resources or terraform for instana_custom_dashboard resources of the Instana Terraform provider.")
+	_ = fs.Parse(args)
+	if api.BaseUrl == "" || api.ApiToken == "" {
+		return fmt.Errorf("--base-url and --api-token are required")
+	}
+	if format != "yaml" && format != "terraform" {
+		return fmt.Errorf("invalid --format %q, must be yaml or terraform", format)
+	}
+
+	filter := controllers.ExportFilter{AccessRule: accessRule}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+	if title != "" {
+		re, err := regexp.Compile(title)
+		if err != nil {
+			return fmt.Errorf("invalid --title: %w", err)
+		}
+		filter.Title = re
+	}
+	dashboards, err := controllers.ExportDashboards(*api, filter, controllers.ExportOptions{
+		Namespace:                 namespace,
+		InstanaApiTokenRelationId: tokenRelationId,
+	}, logr.Discard())
+	if err != nil {
+		return err
+	}
+
+	if format == "terraform" {
+		return writeTerraformFiles(dashboards, withIds, outputDir)
+	}
+
+	var objects []namedObject
+	ids := map[string]string{}
+	for _, d := range dashboards {
+		objects = append(objects, namedObject{name: d.Name, object: manifest(d.Dashboard)})
+		ids[d.Namespace+"_"+d.Name] = d.Id
+	}
+	if withIds {
+		objects = append(objects, namedObject{name: "instana-custom-dashboard-ids", object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]string{"namespace": "default", "name": "instana-custom-dashboard-ids"},
+			"data":       ids,
+		}})
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d dashboards\n", len(dashboards))
+	if outputDir == "" {
+		return writeYaml(os.Stdout, objects)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	for _, o := range objects {
+		f, err := os.Create(filepath.Join(outputDir, o.name+".yaml"))
+		if err != nil {
+			return err
+		}
+		err = writeYaml(f, []namedObject{o})
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTerraformFiles writes the dashboards to stdout or one .tf file per
+// dashboard into outputDir.
+func writeTerraformFiles(dashboards []controllers.ExportedDashboard, withIds bool, outputDir string) error {
+	var resources []terraform.Dashboard
+	for _, d := range dashboards {
+		r := terraform.Dashboard{Name: d.Name, Config: d.Spec.Config.Raw}
+		if withIds {
+			r.Id = d.Id
+		}
+		resources = append(resources, r)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d dashboards\n", len(dashboards))
+	if outputDir == "" {
+		return terraform.Write(os.Stdout, resources)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	for _, r := range resources {
+		f, err := os.Create(filepath.Join(outputDir, r.Name+".tf"))
+		if err != nil {
+			return err
+		}
+		err = terraform.Write(f, []terraform.Dashboard{r})
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type namedObject struct {
+	name   string
+	object interface{}
+}
+
+// manifest returns the parts of a Dashboard which belong into a manifest.
+func manifest(d customv1.Dashboard) interface{} {
+	return struct {
+		ApiVersion string                 `json:"apiVersion"`
+		Kind       string                 `json:"kind"`
+		Metadata   map[string]string      `json:"metadata"`
+		Spec       customv1.DashboardSpec `json:"spec"`
+	}{
+		ApiVersion: d.APIVersion,
+		Kind:       d.Kind,
+		Metadata:   map[string]string{"namespace": d.Namespace, "name": d.Name},
+		Spec:       d.Spec,
+	}
+}
+
+func writeYaml(w io.Writer, objects []namedObject) error {
+	for _, o := range objects {
+		data, err := yaml.Marshal(o.object)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
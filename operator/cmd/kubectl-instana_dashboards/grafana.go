@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/luebken/custom-dashboards/controllers"
+	"github.com/luebken/custom-dashboards/grafana"
+)
+
+func convertGrafana(args []string) error {
+	fs := flag.NewFlagSet("grafana", flag.ExitOnError)
+	var file, name, namespace, tokenRelationId, report string
+	fs.StringVar(&file, "f", "-", "The Grafana dashboard JSON. - reads stdin.")
+	fs.StringVar(&name, "name", "", "The name of the resource. Derived from the title if empty.")
+	fs.StringVar(&namespace, "namespace", "default", "The namespace of the resource.")
+	fs.StringVar(&namespace, "n", "default", "Shorthand for --namespace.")
+	fs.StringVar(&tokenRelationId, "api-token-relation-id", "", "The instana-api-token-relation-id of the resource.")
+	fs.StringVar(&report, "report", "", "Write the report of unconvertible panels into this file instead of stderr.")
+	_ = fs.Parse(args)
+
+	data, err := readFile(file)
+	if err != nil {
+		return err
+	}
+	result, err := grafana.Convert(data)
+	if err != nil {
+		return err
+	}
+	dashboard, err := controllers.ConvertExport(result.Config, name, controllers.ExportOptions{
+		Namespace:                 namespace,
+		InstanaApiTokenRelationId: tokenRelationId,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeYaml(os.Stdout, []namedObject{{name: dashboard.Name, object: manifest(dashboard)}}); err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("%d panels or queries could not be converted\n", len(result.Report))
+	if len(result.Report) > 0 {
+		text += "* " + strings.Join(result.Report, "\n* ") + "\n"
+	}
+	if report == "" {
+		_, err = fmt.Fprint(os.Stderr, text)
+		return err
+	}
+	return ioutil.WriteFile(report, []byte(text), 0644)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/lint"
+)
+
+// errLintFailed makes the command exit with 1, without an error message.
+var errLintFailed = errors.New("lint errors found")
+
+func lintConfig(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	var file, widgetTypes string
+	fs.StringVar(&file, "f", "-", "A Dashboard resource or a dashboard JSON. - reads stdin.")
+	fs.StringVar(&widgetTypes, "widget-types", "", "Comma-separated widget types known in addition to "+fmt.Sprint(lint.WidgetTypes)+".")
+	_ = fs.Parse(args)
+
+	data, err := readFile(file)
+	if err != nil {
+		return err
+	}
+	config, err := lintedConfig(data)
+	if err != nil {
+		return err
+	}
+	var options lint.Options
+	for _, t := range strings.Split(widgetTypes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			options.WidgetTypes = append(options.WidgetTypes, t)
+		}
+	}
+	findings, err := lint.Config(config, options)
+	if err != nil {
+		return err
+	}
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if len(lint.Errors(findings)) > 0 {
+		return errLintFailed
+	}
+	return nil
+}
+
+// lintedConfig returns spec.config of a Dashboard resource, other JSON is
+// returned as is.
+func lintedConfig(data []byte) ([]byte, error) {
+	var object struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil || object.Kind != "Dashboard" {
+		return data, nil
+	}
+	var dashboard customv1.Dashboard
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		return nil, err
+	}
+	if dashboard.Spec.ConfigFrom != nil {
+		return nil, fmt.Errorf("dashboard %s reads its config from the ConfigMap %s, lint the config instead", dashboard.Name, dashboard.Spec.ConfigFrom.Name)
+	}
+	if dashboard.Spec.Jsonnet != "" {
+		return nil, fmt.Errorf("dashboard %s renders its config from spec.jsonnet, lint the rendered config instead", dashboard.Name)
+	}
+	if dashboard.Spec.Config == nil {
+		return nil, fmt.Errorf("dashboard %s has no spec.config", dashboard.Name)
+	}
+	return dashboard.Spec.Config.Raw, nil
+}
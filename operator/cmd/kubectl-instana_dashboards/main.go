@@ -0,0 +1,47 @@
+// Command kubectl-instana_dashboards is a kubectl plugin to bring existing
+// Instana dashboards under the management of the operator.
+//
+//	kubectl instana-dashboards export --title 'Team A.*' -n team-a
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var commands = map[string]func(args []string) error{
+	"export":           export,
+	"convert":          convert,
+	"diff":             diff,
+	"grafana":          convertGrafana,
+	"restore":          restore,
+	"lint":             lintConfig,
+	"terraform-import": terraformImport,
+	"adopt":            adopt,
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: kubectl instana-dashboards <command> [flags]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "commands:")
+		fmt.Fprintln(os.Stderr, "  export   write dashboards of an Instana tenant as Dashboard resources")
+		fmt.Fprintln(os.Stderr, "  convert  wrap a dashboard JSON exported from the Instana UI into a Dashboard resource")
+		fmt.Fprintln(os.Stderr, "  diff     show where the live dashboard in Instana differs from the resource")
+		fmt.Fprintln(os.Stderr, "  grafana  convert a Grafana dashboard JSON into a Dashboard resource")
+		fmt.Fprintln(os.Stderr, "  restore  re-create a dashboard in Instana from a backup snapshot")
+		fmt.Fprintln(os.Stderr, "  lint     check a dashboard config for common mistakes")
+		fmt.Fprintln(os.Stderr, "  terraform-import")
+		fmt.Fprintln(os.Stderr, "           write the dashboards of a Terraform state file as Dashboard resources adopting them")
+		fmt.Fprintln(os.Stderr, "  adopt    take over existing dashboards matching the titles of Dashboards which were not synced yet")
+		os.Exit(2)
+	}
+	err := commands[os.Args[1]](os.Args[2:])
+	if err == errDiffFound || err == errLintFailed {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: "+err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/controllers"
+)
+
+// restore annotates the Dashboard with the snapshot to restore. The operator
+// restores the dashboard in Instana in place, or re-creates it and rebinds
+// the status to it.
+func restore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	kube := kubeFlags(fs)
+	snapshot := fs.String("snapshot", "latest", "The timestamp of the snapshot to restore, e.g. 20210604T120000Z.")
+	location := fs.String("backup-location", "", "The backup location of the operator. Required for --list, validates --snapshot if set.")
+	list := fs.Bool("list", false, "List the snapshots of the dashboard instead of restoring one.")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: kubectl instana-dashboards restore <dashboard> [-n <namespace>] [--snapshot <timestamp>]")
+	}
+
+	ctx := context.Background()
+	c, namespace, err := kube.client()
+	if err != nil {
+		return err
+	}
+	if *location != "" {
+		store, err := controllers.NewBackupStore(*location)
+		if err != nil {
+			return err
+		}
+		snapshots, err := controllers.ListSnapshots(ctx, store, namespace, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if *list {
+			for _, s := range snapshots {
+				fmt.Println(s)
+			}
+			return nil
+		}
+		if !contains(snapshots, *snapshot) && !(*snapshot == "latest" && len(snapshots) > 0) {
+			return fmt.Errorf("snapshot %s not found for %s/%s", *snapshot, namespace, fs.Arg(0))
+		}
+	} else if *list {
+		return fmt.Errorf("--list requires --backup-location")
+	}
+
+	var dashboard customv1.Dashboard
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: fs.Arg(0)}, &dashboard); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(dashboard.DeepCopy())
+	if dashboard.Annotations == nil {
+		dashboard.Annotations = map[string]string{}
+	}
+	dashboard.Annotations[customv1.RestoreSnapshotAnnotation] = *snapshot
+	if err := c.Patch(ctx, &dashboard, patch); err != nil {
+		return err
+	}
+	fmt.Printf("Requested restore of %s/%s from snapshot %s\n", namespace, dashboard.Name, *snapshot)
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/luebken/custom-dashboards/controllers"
+	"github.com/luebken/custom-dashboards/terraform"
+)
+
+func terraformImport(args []string) error {
+	fs := flag.NewFlagSet("terraform-import", flag.ExitOnError)
+	var file, namespace, tokenRelationId string
+	fs.StringVar(&file, "f", "terraform.tfstate", "The Terraform state file. - reads stdin.")
+	fs.StringVar(&namespace, "namespace", "default", "The namespace of the resources.")
+	fs.StringVar(&namespace, "n", "default", "Shorthand for --namespace.")
+	fs.StringVar(&tokenRelationId, "api-token-relation-id", "", "The instana-api-token-relation-id of the resources.")
+	_ = fs.Parse(args)
+
+	data, err := readFile(file)
+	if err != nil {
+		return err
+	}
+	resources, err := terraform.ParseState(data)
+	if err != nil {
+		return err
+	}
+	var objects []namedObject
+	names := map[string]int{}
+	for _, r := range resources {
+		dashboard, err := controllers.ConvertExport(r.Config, "", controllers.ExportOptions{
+			Namespace:                 namespace,
+			InstanaApiTokenRelationId: tokenRelationId,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to convert %s: %w", r.Name, err)
+		}
+		names[dashboard.Name]++
+		if names[dashboard.Name] > 1 {
+			dashboard.Name = fmt.Sprintf("%s-%d", dashboard.Name, names[dashboard.Name])
+		}
+		dashboard.Spec.ExistingDashboardId = r.Id
+		objects = append(objects, namedObject{name: dashboard.Name, object: manifest(dashboard)})
+		fmt.Fprintf(os.Stderr, "%s -> %s/%s\n", r.Name, namespace, dashboard.Name)
+	}
+	return writeYaml(os.Stdout, objects)
+}
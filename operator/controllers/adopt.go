@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// adoptExistingDashboard links the dashboard of spec.existing-dashboard-id
+// to a Dashboard which was not synced yet. The "adoption-namespaces" of the
+// tenant restrict the namespaces which may adopt dashboards, and dashboards
+// managed for another Dashboard are refused, so a resource can't take over
+// the dashboard of another team. Refusals are stalledErrors.
+func (r *DashboardReconciler) adoptExistingDashboard(dashboard *customv1.Dashboard, tenant *corev1.ConfigMap, instanaClient InstanaClient, log logr.Logger) error {
+	id := dashboard.Spec.ExistingDashboardId
+	if tenant != nil && !namespaceAllowed(tenant.Data["adoption-namespaces"], dashboard.Namespace) {
+		return stalledError{fmt.Errorf("namespace %s may not adopt existing dashboards of tenant %s", dashboard.Namespace, tenant.Name)}
+	}
+	live, err := instanaClient.getDashboard(id, log)
+	if isInstanaNotFound(err) {
+		return stalledError{fmt.Errorf("existing dashboard %s does not exist", id)}
+	}
+	if err != nil {
+		return err
+	}
+	if marker, ok := parseManagedMarker(live); ok && marker.UID != "" && marker.UID != string(dashboard.UID) {
+		return stalledError{fmt.Errorf("existing dashboard %s is managed by Dashboard %s/%s", id, marker.Namespace, marker.Name)}
+	}
+	log.Info("Adopting existing Instana dashboard " + id)
+	dashboard.Status.DashboardId = id
+	r.Recorder.Event(dashboard, corev1.EventTypeNormal, "Adopted", "Adopted the existing dashboard "+id+" from spec.existing-dashboard-id")
+	return nil
+}
+
+// Adoption is the Instana dashboard found for a Dashboard which was not
+// synced yet, or the reason why none was adopted.
+type Adoption struct {
+	Namespace   string
+	Name        string
+	Title       string
+	DashboardId string
+	// Skipped is the reason no dashboard was adopted, empty if DashboardId is set.
+	Skipped string
+}
+
+// AdoptDashboards matches the Dashboards without a dashboard id against the
+// dashboards of the tenant: by the "<namespace>/<name>" entries of mapping if
+// present, else by the rendered title including the title policy of the
+// tenant. Titles matching several dashboards, dashboards already claimed by
+// a Dashboard of any namespace and dashboards whose managed marker belongs
+// to another Dashboard are skipped, so nothing is adopted twice.
+func AdoptDashboards(ctx context.Context, c client.Reader, vars RenderVariables, apiConfig InstanaApi, dashboards []customv1.Dashboard, mapping map[string]string, log logr.Logger) ([]Adoption, error) {
+	live, err := apiConfig.listDashboards(log)
+	if err != nil {
+		return nil, err
+	}
+	exists := map[string]bool{}
+	byTitle := map[string][]string{}
+	for _, d := range live {
+		exists[d.Id] = true
+		byTitle[d.Title] = append(byTitle[d.Title], d.Id)
+	}
+	// the given Dashboards may be limited to a namespace, the dashboards of
+	// all namespaces are claimed
+	var all customv1.DashboardList
+	if err := c.List(ctx, &all); err != nil {
+		return nil, err
+	}
+	claimed := map[string]string{}
+	for _, d := range append(all.Items, dashboards...) {
+		owner := d.Namespace + "/" + d.Name
+		if d.Status.DashboardId != "" {
+			claimed[d.Status.DashboardId] = owner
+		}
+		for _, cluster := range d.Status.Clusters {
+			claimed[cluster.DashboardId] = owner
+		}
+		for _, variant := range d.Status.Variants {
+			claimed[variant.DashboardId] = owner
+		}
+	}
+
+	var adoptions []Adoption
+	for _, d := range dashboards {
+		if d.Status.DashboardId != "" {
+			continue
+		}
+		key := d.Namespace + "/" + d.Name
+		adoption := Adoption{Namespace: d.Namespace, Name: d.Name}
+		id, mapped := mapping[key]
+		switch {
+		case len(d.Spec.Clusters) > 0:
+			adoption.Skipped = "dashboards of spec.clusters are not adopted"
+		case len(d.Spec.Variants) > 0:
+			adoption.Skipped = "dashboards of spec.variants are not adopted"
+		case mapped && !exists[id]:
+			adoption.Skipped = "dashboard " + id + " of the mapping does not exist"
+		case mapped:
+			adoption.DashboardId = id
+		default:
+			adoption.Title, err = renderedTitle(ctx, c, vars, apiConfig, d)
+			if err != nil {
+				adoption.Skipped = err.Error()
+				break
+			}
+			switch ids := byTitle[adoption.Title]; len(ids) {
+			case 0:
+				adoption.Skipped = "no dashboard titled " + adoption.Title
+			case 1:
+				adoption.DashboardId = ids[0]
+			default:
+				adoption.Skipped = fmt.Sprintf("%d dashboards titled %s", len(ids), adoption.Title)
+			}
+		}
+		if owner, ok := claimed[adoption.DashboardId]; ok {
+			adoption.Skipped = "dashboard " + adoption.DashboardId + " is already adopted by " + owner
+			adoption.DashboardId = ""
+		}
+		if adoption.DashboardId != "" {
+			if skipped := managedByOther(apiConfig, adoption.DashboardId, d, log); skipped != "" {
+				adoption.Skipped = skipped
+				adoption.DashboardId = ""
+			}
+		}
+		if adoption.DashboardId != "" {
+			claimed[adoption.DashboardId] = key
+		}
+		adoptions = append(adoptions, adoption)
+	}
+	return adoptions, nil
+}
+
+// managedByOther returns why the dashboard with the id may not be adopted by
+// the Dashboard: its managed marker belongs to another Dashboard, or it
+// can't be read. It returns "" if the dashboard may be adopted.
+func managedByOther(apiConfig InstanaApi, id string, dashboard customv1.Dashboard, log logr.Logger) string {
+	live, err := apiConfig.getDashboard(id, log)
+	if err != nil {
+		return "unable to read dashboard " + id + ": " + err.Error()
+	}
+	if marker, ok := parseManagedMarker(live); ok && marker.UID != "" && marker.UID != string(dashboard.UID) {
+		return "dashboard " + id + " is managed by Dashboard " + marker.Namespace + "/" + marker.Name
+	}
+	return ""
+}
+
+// renderedTitle returns the title the dashboard gets in Instana.
+func renderedTitle(ctx context.Context, c client.Reader, vars RenderVariables, apiConfig InstanaApi, dashboard customv1.Dashboard) (string, error) {
+	vars.Instana = apiConfig
+	config, err := renderConfig(ctx, c, vars, dashboard)
+	if err == nil {
+		config, err = applyTitlePolicy(config, apiConfig)
+	}
+	if err != nil {
+		return "", err
+	}
+	var payload struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return "", err
+	}
+	if payload.Title == "" {
+		return "", fmt.Errorf("the config has no title")
+	}
+	return payload.Title, nil
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/instanatest"
+)
+
+func TestAdoptDashboards(t *testing.T) {
+	instana := instanatest.NewServer("token")
+	defer instana.Close()
+	instana.PutDashboard("d1", map[string]interface{}{"title": "[prod] Checkout"})
+	instana.PutDashboard("d2", map[string]interface{}{"title": "[prod] Search"})
+	instana.PutDashboard("d3", map[string]interface{}{"title": "[prod] Search"})
+	instana.PutDashboard("d4", map[string]interface{}{"title": "[prod] Legacy"})
+	instana.PutDashboard("d5", map[string]interface{}{"title": "[prod] Reports"})
+	managed, _ := injectManagedMarker([]byte(`{"title":"[prod] Orders"}`), ManagedMarker{Namespace: "orders", Name: "orders", UID: "uid-orders"})
+	var orders map[string]interface{}
+	_ = json.Unmarshal(managed, &orders)
+	instana.PutDashboard("d6", orders)
+
+	dashboard := func(name string, title string, id string) customv1.Dashboard {
+		return customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name},
+			Spec:       customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"` + title + `"}`)}},
+			Status:     customv1.DashboardStatus{DashboardId: id},
+		}
+	}
+	dashboards := []customv1.Dashboard{
+		dashboard("checkout", "Checkout", ""),
+		dashboard("search", "Search", ""),
+		dashboard("legacy", "Renamed", ""),
+		dashboard("payments", "Payments", ""),
+		dashboard("synced", "Synced", "d9"),
+		dashboard("checkout-copy", "Checkout", ""),
+		dashboard("reports", "Reports", ""),
+		dashboard("orders", "Orders", ""),
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	// d5 is synced by a Dashboard of a namespace which is not adopted
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "finance", Name: "reports"},
+		Status:     customv1.DashboardStatus{DashboardId: "d5"},
+	}).Build()
+	api := InstanaApi{BaseUrl: instana.URL, ApiToken: "token", TitlePrefix: "[prod] "}
+
+	adoptions, err := AdoptDashboards(context.Background(), c, RenderVariables{}, api, dashboards,
+		map[string]string{"shop/legacy": "d4", "shop/payments": "missing"}, ctrl.Log.WithName("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"checkout": "d1", "search": "", "legacy": "d4", "payments": "", "checkout-copy": "", "reports": "", "orders": ""}
+	if len(adoptions) != len(want) {
+		t.Fatalf("adoptions = %+v", adoptions)
+	}
+	for _, a := range adoptions {
+		if a.DashboardId != want[a.Name] || (a.DashboardId == "") == (a.Skipped == "") {
+			t.Errorf("%s adopts %q (skipped: %q), want %q", a.Name, a.DashboardId, a.Skipped, want[a.Name])
+		}
+		if owner := map[string]string{"reports": "finance/reports", "orders": "orders/orders"}[a.Name]; owner != "" && !strings.Contains(a.Skipped, owner) {
+			t.Errorf("%s skipped because %q, want the owner %s", a.Name, a.Skipped, owner)
+		}
+	}
+}
+
+func TestAdoptExistingDashboard(t *testing.T) {
+	log := ctrl.Log.WithName("test")
+	instana := newFakeInstanaClient()
+	instana.dashboards["unmanaged"] = []byte(`{"title":"Terraform","widgets":[]}`)
+	own, _ := injectManagedMarker([]byte(`{"title":"Shop","widgets":[]}`), ManagedMarker{Namespace: "shop", Name: "shop", UID: "uid-1"})
+	instana.dashboards["own"] = own
+	other, _ := injectManagedMarker([]byte(`{"title":"Billing","widgets":[]}`), ManagedMarker{Namespace: "billing", Name: "billing", UID: "uid-2"})
+	instana.dashboards["other"] = other
+	r := &DashboardReconciler{Recorder: record.NewFakeRecorder(10)}
+
+	tests := []struct {
+		id          string
+		namespaces  string
+		wantRefused bool
+	}{
+		{id: "unmanaged"},
+		{id: "own"},
+		{id: "other", wantRefused: true},
+		{id: "missing", wantRefused: true},
+		{id: "unmanaged", namespaces: "billing, team-*", wantRefused: true},
+		{id: "unmanaged", namespaces: "billing, sh*"},
+	}
+	for _, tt := range tests {
+		dashboard := &customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "shop", UID: "uid-1"},
+			Spec:       customv1.DashboardSpec{ExistingDashboardId: tt.id},
+		}
+		tenant := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: instanaConfigName}, Data: map[string]string{"adoption-namespaces": tt.namespaces}}
+		err := r.adoptExistingDashboard(dashboard, tenant, instana, log)
+		if refused := errors.As(err, &stalledError{}); refused != tt.wantRefused || (err != nil && !refused) {
+			t.Errorf("adoptExistingDashboard(%s, %q) = %v, want refused %v", tt.id, tt.namespaces, err, tt.wantRefused)
+		}
+		if adopted := dashboard.Status.DashboardId == tt.id; adopted == tt.wantRefused {
+			t.Errorf("dashboard id = %q after adopting %s", dashboard.Status.DashboardId, tt.id)
+		}
+	}
+}
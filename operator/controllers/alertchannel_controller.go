@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=alertchannels,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=alertchannels/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=alertchannels/finalizers,verbs=update
+
+// alertChannelKind syncs AlertChannels with the alerting channels of the
+// Events settings API.
+var alertChannelKind = InstanaResourceKind{
+	Kind:      "AlertChannel",
+	New:       func() InstanaResource { return &customv1.AlertChannel{} },
+	Path:      "/api/events/settings/alertingChannels",
+	ClientIds: true,
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		channel := obj.(*customv1.AlertChannel)
+		spec := channel.Spec
+		payload := map[string]interface{}{"name": spec.Name, "kind": spec.Kind}
+		switch spec.Kind {
+		case "EMAIL":
+			if len(spec.Emails) == 0 {
+				return nil, stalledError{fmt.Errorf("an EMAIL channel requires emails")}
+			}
+			payload["emails"] = spec.Emails
+		case "SLACK", "WEB_HOOK":
+			url, err := secretValue(ctx, c, channel.Namespace, spec.WebhookUrlSecretRef)
+			if err != nil {
+				return nil, err
+			}
+			if spec.Kind == "SLACK" {
+				payload["webhookUrl"] = url
+				if spec.Channel != "" {
+					payload["channel"] = spec.Channel
+				}
+			} else {
+				payload["webhookUrls"] = strings.Fields(url)
+				if len(spec.Headers) > 0 {
+					payload["headers"] = spec.Headers
+				}
+			}
+		case "OPS_GENIE":
+			apiKey, err := secretValue(ctx, c, channel.Namespace, spec.ApiKeySecretRef)
+			if err != nil {
+				return nil, err
+			}
+			payload["apiKey"] = apiKey
+			payload["region"] = spec.Region
+			payload["tags"] = strings.Join(spec.Tags, ",")
+		}
+		return payload, nil
+	},
+}
+
+// alertChannelIds returns the ids of the AlertChannels named by refs in the
+// namespace, followed by the ids of unmanaged alert channels.
+func alertChannelIds(ctx context.Context, c client.Client, namespace string, refs []string, ids []string) ([]string, error) {
+	channelIds := append([]string{}, ids...)
+	for _, name := range refs {
+		id, err := instanaIdOf(ctx, c, namespace, name, &customv1.AlertChannel{})
+		if err != nil {
+			return nil, err
+		}
+		channelIds = append(channelIds, id)
+	}
+	return channelIds, nil
+}
+
+// secretValue reads the selected key of a Secret in the namespace.
+func secretValue(ctx context.Context, c client.Reader, namespace string, ref *customv1.SecretKeyReference) (string, error) {
+	if ref == nil {
+		return "", stalledError{fmt.Errorf("secret reference is required")}
+	}
+	var secret corev1.Secret
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+		return "", fmt.Errorf("unable to load secret %s: %w", ref.Name, err)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", ref.Name, ref.Key)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
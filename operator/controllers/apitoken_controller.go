@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=apitokens,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=apitokens/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=apitokens/finalizers,verbs=update
+
+// apiTokenKind syncs APITokens with the API tokens of the settings API. The
+// token is chosen by the operator and kept in spec.secret-name.
+var apiTokenKind = InstanaResourceKind{
+	Kind:      "APIToken",
+	New:       func() InstanaResource { return &customv1.APIToken{} },
+	Path:      "/api/settings/api-tokens",
+	ClientIds: true,
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		apiToken := obj.(*customv1.APIToken)
+		token, err := apiTokenSecret(ctx, c, apiToken)
+		if err != nil {
+			return nil, err
+		}
+		payload := map[string]interface{}{
+			"name":                apiToken.Spec.Name,
+			"internalId":          string(apiToken.UID),
+			"accessGrantingToken": token,
+		}
+		for _, permission := range apiToken.Spec.Permissions {
+			if !strings.HasPrefix(permission, "can") {
+				return nil, stalledError{fmt.Errorf("invalid permission %s", permission)}
+			}
+			payload[permission] = true
+		}
+		return payload, nil
+	},
+}
+
+// apiTokenSecret returns the token of the Secret of the APIToken. The Secret
+// is created with a random token, owned by the APIToken, if it does not exist.
+func apiTokenSecret(ctx context.Context, c client.Client, apiToken *customv1.APIToken) (string, error) {
+	var secret corev1.Secret
+	err := c.Get(ctx, client.ObjectKey{Namespace: apiToken.Namespace, Name: apiToken.Spec.SecretName}, &secret)
+	if err == nil {
+		if !metav1.IsControlledBy(&secret, apiToken) {
+			return "", stalledError{fmt.Errorf("secret %s exists and is not owned by the token", secret.Name)}
+		}
+		return string(secret.Data["token"]), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	secret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: apiToken.Namespace, Name: apiToken.Spec.SecretName},
+		Data:       map[string][]byte{"token": []byte(token)},
+	}
+	if err := controllerutil.SetControllerReference(apiToken, &secret, c.Scheme()); err != nil {
+		return "", err
+	}
+	return token, c.Create(ctx, &secret)
+}
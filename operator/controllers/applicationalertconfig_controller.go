@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=applicationalertconfigs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=applicationalertconfigs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=applicationalertconfigs/finalizers,verbs=update
+
+// severities maps the severities of the spec to the ones of the Instana API.
+var severities = map[string]int{"WARNING": 5, "CRITICAL": 10}
+
+// applicationAlertConfigKind syncs ApplicationAlertConfigs with the
+// application Smart Alerts of the Events settings API.
+var applicationAlertConfigKind = InstanaResourceKind{
+	Kind:         "ApplicationAlertConfig",
+	New:          func() InstanaResource { return &customv1.ApplicationAlertConfig{} },
+	Path:         "/api/events/settings/application-alert-configs",
+	UpdateMethod: http.MethodPost,
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		alert := obj.(*customv1.ApplicationAlertConfig)
+		spec := alert.Spec
+		applicationId, err := applicationPerspectiveId(ctx, c, alert.Namespace, spec.ApplicationPerspectiveRef, spec.ApplicationId)
+		if err != nil {
+			return nil, err
+		}
+		channelIds, err := alertChannelIds(ctx, c, alert.Namespace, spec.AlertChannelRefs, spec.AlertChannelIds)
+		if err != nil {
+			return nil, err
+		}
+		tagFilterExpression, err := jsonValue(spec.TagFilterExpression, map[string]interface{}{
+			"type": "EXPRESSION", "logicalOperator": "AND", "elements": []interface{}{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		rule, err := jsonValue(spec.Rule, nil)
+		if err != nil {
+			return nil, err
+		}
+		threshold, err := jsonValue(spec.Threshold, nil)
+		if err != nil {
+			return nil, err
+		}
+		timeWindow := spec.TimeWindow.Milliseconds()
+		return map[string]interface{}{
+			"name":          spec.Name,
+			"description":   spec.Description,
+			"boundaryScope": spec.BoundaryScope,
+			"applicationId": applicationId,
+			"applications": map[string]interface{}{
+				applicationId: map[string]interface{}{"applicationId": applicationId, "inclusive": true, "services": map[string]interface{}{}},
+			},
+			"evaluationType":      "PER_AP",
+			"tagFilterExpression": tagFilterExpression,
+			"rule":                rule,
+			"threshold":           threshold,
+			"granularity":         timeWindow,
+			"timeThreshold":       map[string]interface{}{"type": "violationsInSequence", "timeWindow": timeWindow},
+			"severity":            severities[spec.Severity],
+			"triggering":          spec.Triggering,
+			"alertChannelIds":     channelIds,
+		}, nil
+	},
+}
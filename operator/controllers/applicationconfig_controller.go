@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=applicationconfigs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=applicationconfigs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=applicationconfigs/finalizers,verbs=update
+
+// applicationConfigKind syncs ApplicationConfigs with the custom service
+// rules of the Application Monitoring settings API.
+var applicationConfigKind = InstanaResourceKind{
+	Kind:      "ApplicationConfig",
+	New:       func() InstanaResource { return &customv1.ApplicationConfig{} },
+	Path:      "/api/application-monitoring/settings/service",
+	ClientIds: true,
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		spec := obj.(*customv1.ApplicationConfig).Spec
+		match := []interface{}{}
+		for _, rule := range spec.Match {
+			match = append(match, map[string]interface{}{"key": rule.Key, "value": rule.Value})
+		}
+		return map[string]interface{}{
+			"name":               spec.Name,
+			"comment":            spec.Comment,
+			"label":              spec.Label,
+			"enabled":            !spec.Disabled,
+			"matchSpecification": match,
+		}, nil
+	},
+}
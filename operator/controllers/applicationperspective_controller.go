@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=applicationperspectives,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=applicationperspectives/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=applicationperspectives/finalizers,verbs=update
+
+// applicationPerspectiveKind syncs ApplicationPerspectives with the
+// application configs of the Application Monitoring settings API.
+var applicationPerspectiveKind = InstanaResourceKind{
+	Kind: "ApplicationPerspective",
+	New:  func() InstanaResource { return &customv1.ApplicationPerspective{} },
+	Path: "/api/application-monitoring/settings/application",
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		spec := obj.(*customv1.ApplicationPerspective).Spec
+		tagFilterExpression, err := jsonValue(spec.TagFilterExpression, nil)
+		if err != nil {
+			return nil, err
+		}
+		accessRules, err := jsonValue(spec.AccessRules, []interface{}{
+			map[string]interface{}{"accessType": "READ_WRITE", "relationType": "GLOBAL"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"label":               spec.Label,
+			"scope":               spec.Scope,
+			"boundaryScope":       spec.BoundaryScope,
+			"tagFilterExpression": tagFilterExpression,
+			"accessRules":         accessRules,
+		}, nil
+	},
+}
+
+// applicationPerspectiveId returns the id of the ApplicationPerspective named
+// by ref in the namespace, or id for perspectives not managed as resources.
+func applicationPerspectiveId(ctx context.Context, c client.Client, namespace string, ref string, id string) (string, error) {
+	if ref != "" {
+		return instanaIdOf(ctx, c, namespace, ref, &customv1.ApplicationPerspective{})
+	}
+	if id == "" {
+		return "", stalledError{fmt.Errorf("either application-perspective-ref or application-id is required")}
+	}
+	return id, nil
+}
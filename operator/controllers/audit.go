@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AuditTrail records every create, update and delete sent to Instana with
+// the custom resource on whose behalf it was sent, the id of the Instana
+// object and the HTTP status of the response. Reads are not recorded.
+type AuditTrail struct {
+	// Log receives one structured entry per request.
+	Log logr.Logger
+	// Recorder additionally records the requests as Events of the actor if set.
+	Recorder record.EventRecorder
+}
+
+// Audit is the audit trail of all requests against Instana.
+var Audit = &AuditTrail{Log: ctrl.Log.WithName("audit")}
+
+// AuditRecord is a mutating request against Instana.
+type AuditRecord struct {
+	Time time.Time
+	// Actor is "<kind> <namespace>/<name>" of the custom resource, or "operator"
+	// for requests not done on behalf of a resource, e.g. of the garbage collector.
+	Actor      string
+	Tenant     string
+	Method     string
+	Path       string
+	Id         string
+	StatusCode int
+	RequestId  string
+	Error      string
+}
+
+func (a *AuditTrail) record(apiConfig InstanaApi, method string, requestPath string, statusCode int, body []byte, err error) {
+	if a == nil || method == http.MethodGet || method == http.MethodHead {
+		return
+	}
+	// query payloads may carry config values, only the path is recorded
+	if i := strings.Index(requestPath, "?"); i >= 0 {
+		requestPath = requestPath[:i]
+	}
+	entry := AuditRecord{
+		Time:       time.Now().UTC(),
+		Actor:      auditActor(apiConfig.Actor),
+		Tenant:     apiConfig.BaseUrl,
+		Method:     method,
+		Path:       requestPath,
+		Id:         auditId(method, requestPath, body),
+		StatusCode: statusCode,
+		RequestId:  apiConfig.RequestId,
+	}
+	if err != nil {
+		entry.Error = redactError(err)
+	}
+	a.Log.Info("Instana API call", "time", entry.Time.Format(time.RFC3339Nano), "actor", entry.Actor,
+		"tenant", entry.Tenant, "method", entry.Method, "path", entry.Path, "id", entry.Id,
+		"status", entry.StatusCode, "requestId", entry.RequestId, "error", entry.Error)
+	if a.Recorder != nil && apiConfig.Actor != nil {
+		a.Recorder.Event(apiConfig.Actor, entry.eventType(), entry.reason(), entry.message())
+	}
+}
+
+func (entry AuditRecord) eventType() string {
+	if entry.Error != "" {
+		return corev1.EventTypeWarning
+	}
+	return corev1.EventTypeNormal
+}
+
+func (entry AuditRecord) reason() string {
+	if entry.Error != "" {
+		return "InstanaRequestFailed"
+	}
+	switch entry.Method {
+	case http.MethodPost:
+		return "InstanaCreated"
+	case http.MethodDelete:
+		return "InstanaDeleted"
+	}
+	return "InstanaUpdated"
+}
+
+func (entry AuditRecord) message() string {
+	status := "no response"
+	if entry.StatusCode != 0 {
+		status = fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode))
+	}
+	return fmt.Sprintf("%s %s%s: %s (request %s)", entry.Method, entry.Tenant, entry.Path, status, entry.RequestId)
+}
+
+func auditActor(actor client.Object) string {
+	if actor == nil || reflect.ValueOf(actor).IsNil() {
+		return "operator"
+	}
+	return reflect.TypeOf(actor).Elem().Name() + " " + client.ObjectKeyFromObject(actor).String()
+}
+
+// auditId returns the id of the Instana object of a request: the id of the
+// response of a create, else the last segment of the path.
+func auditId(method string, requestPath string, body []byte) string {
+	if method == http.MethodPost {
+		var response struct {
+			Id string `json:"id"`
+		}
+		_ = json.Unmarshal(body, &response)
+		return response.Id
+	}
+	return path.Base(requestPath)
+}
+
+type actorKey struct{}
+
+// withActor returns a context whose Instana requests are recorded in the
+// audit trail as done on behalf of obj.
+func withActor(ctx context.Context, obj client.Object) context.Context {
+	return context.WithValue(ctx, actorKey{}, obj)
+}
+
+func actorFrom(ctx context.Context) client.Object {
+	obj, _ := ctx.Value(actorKey{}).(client.Object)
+	return obj
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestAuditTrail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			_, _ = w.Write([]byte(`{"id":"new-id","title":"Overview"}`))
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+	recorder := record.NewFakeRecorder(10)
+	defer func(audit *AuditTrail) { Audit = audit }(Audit)
+	Audit = &AuditTrail{Log: ctrl.Log.WithName("audit"), Recorder: recorder}
+
+	dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "overview"}}
+	instanaApi := InstanaApi{BaseUrl: server.URL, ApiToken: "token", RequestId: "req-1", Actor: dashboard}
+	log := ctrl.Log.WithName("test")
+	if _, err := instanaApi.createDashboard([]byte(`{"title":"Overview"}`), log); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instanaApi.getDashboard("new-id", log); err != nil {
+		t.Fatal(err)
+	}
+	if err := instanaApi.deleteDashboard("new-id", log); err == nil {
+		t.Fatal("expected the delete to fail")
+	}
+
+	events := []string{<-recorder.Events, <-recorder.Events}
+	if !strings.HasPrefix(events[0], "Normal InstanaCreated POST "+server.URL+"/api/custom-dashboard: 200 OK (request req-1)") {
+		t.Errorf("create event = %s", events[0])
+	}
+	if !strings.HasPrefix(events[1], "Warning InstanaRequestFailed DELETE "+server.URL+"/api/custom-dashboard/new-id: 403 Forbidden") {
+		t.Errorf("delete event = %s", events[1])
+	}
+	select {
+	case event := <-recorder.Events:
+		t.Errorf("unexpected event for a read: %s", event)
+	default:
+	}
+}
+
+func TestAuditActorAndId(t *testing.T) {
+	dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "overview"}}
+	if actor := auditActor(dashboard); actor != "Dashboard team-a/overview" {
+		t.Errorf("auditActor() = %s", actor)
+	}
+	var none *customv1.Dashboard
+	if actor := auditActor(none); actor != "operator" {
+		t.Errorf("auditActor(nil) = %s", actor)
+	}
+	if id := auditId(http.MethodPost, "/api/custom-dashboard", []byte(`{"id":"abc"}`)); id != "abc" {
+		t.Errorf("auditId() = %s for a create", id)
+	}
+	if id := auditId(http.MethodPut, "/api/custom-dashboard/abc", nil); id != "abc" {
+		t.Errorf("auditId() = %s for an update", id)
+	}
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=automationactions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=automationactions/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=automationactions/finalizers,verbs=update
+
+// automationActionKind syncs AutomationActions with the actions of the
+// Automation Framework API.
+var automationActionKind = InstanaResourceKind{
+	Kind: "AutomationAction",
+	New:  func() InstanaResource { return &customv1.AutomationAction{} },
+	Path: "/api/automation/actions",
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		spec := obj.(*customv1.AutomationAction).Spec
+		field := func(name string, value string) map[string]interface{} {
+			return map[string]interface{}{"name": name, "value": value, "secured": false}
+		}
+		var actionType string
+		var fields []interface{}
+		switch {
+		case spec.Script != nil && spec.HTTP != nil:
+			return nil, stalledError{fmt.Errorf("only one of script and http may be set")}
+		case spec.Script != nil:
+			actionType = "SCRIPT"
+			script := field("script_ssh", base64.StdEncoding.EncodeToString([]byte(spec.Script.Source)))
+			script["encoding"] = "base64"
+			fields = append(fields, script, field("subtype", spec.Script.Interpreter))
+		case spec.HTTP != nil:
+			actionType = "HTTP"
+			headers, err := json.Marshal(spec.HTTP.Headers)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields,
+				field("host", spec.HTTP.URL),
+				field("method", spec.HTTP.Method),
+				field("headers", string(headers)),
+				field("body", spec.HTTP.Body),
+				field("ignoreCertErrors", strconv.FormatBool(spec.HTTP.IgnoreCertErrors)))
+		default:
+			return nil, stalledError{fmt.Errorf("either script or http is required")}
+		}
+		if spec.Timeout != nil {
+			fields = append(fields, field("timeout", strconv.FormatInt(int64(spec.Timeout.Seconds()), 10)))
+		}
+		inputs := []interface{}{}
+		for _, input := range spec.Inputs {
+			inputs = append(inputs, map[string]interface{}{
+				"name":        input.Name,
+				"label":       input.Label,
+				"description": input.Description,
+				"type":        input.Type,
+				"value":       input.Value,
+				"required":    input.Required,
+				"hidden":      input.Hidden,
+				"secured":     input.Secured,
+			})
+		}
+		return map[string]interface{}{
+			"name":            spec.Name,
+			"description":     spec.Description,
+			"type":            actionType,
+			"tags":            nonNil(spec.Tags),
+			"fields":          fields,
+			"inputParameters": inputs,
+		}, nil
+	},
+}
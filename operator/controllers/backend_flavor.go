@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	BackendFlavorSaaS   = "saas"
+	BackendFlavorOnPrem = "onprem"
+)
+
+// Capabilities of an Instana backend, probed from its API.
+type Capabilities struct {
+	// Version is the release of the backend, e.g. "1.213.555-0".
+	Version string
+	// CustomDashboards is true if the custom dashboards API is available.
+	CustomDashboards bool
+}
+
+// probeCapabilities determines the release and the available endpoints of
+// the backend. Self-hosted backends may run releases without the custom
+// dashboards API.
+func probeCapabilities(instanaClient InstanaClient, log logr.Logger) (Capabilities, error) {
+	var caps Capabilities
+	bodyBytes, err := instanaClient.do("GET", "/api/instana/version", nil, log)
+	if err != nil {
+		return caps, err
+	}
+	var version struct {
+		ImageTag string `json:"imageTag"`
+	}
+	if err := json.Unmarshal(bodyBytes, &version); err != nil {
+		return caps, err
+	}
+	caps.Version = version.ImageTag
+
+	_, err = instanaClient.do("GET", "/api/custom-dashboard", nil, log)
+	var apiErr *InstanaApiError
+	switch {
+	case err == nil:
+		caps.CustomDashboards = true
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		caps.CustomDashboards = false
+	default:
+		return caps, err
+	}
+	return caps, nil
+}
+
+// CapabilityCache probes the capabilities of self-hosted backends once per
+// base url. SaaS backends are assumed to provide all endpoints.
+type CapabilityCache struct {
+	mu    sync.Mutex
+	byUrl map[string]Capabilities
+}
+
+// Get returns the capabilities of the backend of apiConfig, probing it with
+// instanaClient if necessary.
+func (c *CapabilityCache) Get(apiConfig InstanaApi, instanaClient InstanaClient, log logr.Logger) (Capabilities, error) {
+	if apiConfig.BackendFlavor != BackendFlavorOnPrem {
+		return Capabilities{CustomDashboards: true}, nil
+	}
+	if c == nil {
+		return probeCapabilities(instanaClient, log)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if caps, ok := c.byUrl[apiConfig.BaseUrl]; ok {
+		return caps, nil
+	}
+	caps, err := probeCapabilities(instanaClient, log)
+	if err != nil {
+		return caps, err
+	}
+	if c.byUrl == nil {
+		c.byUrl = map[string]Capabilities{}
+	}
+	c.byUrl[apiConfig.BaseUrl] = caps
+	log.Info("Probed Instana backend", "version", caps.Version, "customDashboards", caps.CustomDashboards)
+	return caps, nil
+}
+
+// Forget drops the capabilities of a backend, e.g. after its config changed.
+func (c *CapabilityCache) Forget(baseUrl string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.byUrl, baseUrl)
+}
+
+// checkCapabilities returns an error if the backend does not provide the
+// custom dashboards API.
+func (c *CapabilityCache) checkCapabilities(apiConfig InstanaApi, instanaClient InstanaClient, log logr.Logger) error {
+	caps, err := c.Get(apiConfig, instanaClient, log)
+	if err != nil {
+		return fmt.Errorf("unable to probe Instana backend: %w", err)
+	}
+	if !caps.CustomDashboards {
+		return fmt.Errorf("the custom dashboards API is not available in Instana release %s", caps.Version)
+	}
+	return nil
+}
+
+// CapabilityProbe probes the default tenant on manager start, so a backend
+// without the required endpoints is reported right away.
+type CapabilityProbe struct {
+	client.Client
+	Log   logr.Logger
+	Cache *CapabilityCache
+	// Instana creates the client of the tenant, so the probe shares its rate
+	// limit and circuit breaker with the reconcilers.
+	Instana *InstanaClients
+}
+
+func (p *CapabilityProbe) Start(ctx context.Context) error {
+	_, instanaApi := loadInstanaConfig(ctx, p.Client)
+	if instanaApi.BaseUrl == "" {
+		return nil
+	}
+	if err := p.Cache.checkCapabilities(instanaApi, p.Instana.Client(ctx, instanaApi), p.Log); err != nil {
+		p.Log.Error(err, "Instana backend does not support dashboards", "baseUrl", instanaApi.BaseUrl)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupStore persists dashboard snapshots under slash separated keys.
+type BackupStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List returns the keys starting with prefix in lexical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBackupStore returns the store for a location:
+//
+//	s3://<bucket>/<prefix>?region=<region>&endpoint=<url>  Amazon S3 or a compatible store
+//	gs://<bucket>/<prefix>                                 Google Cloud Storage via its S3 interoperability API
+//	/<path> or file:///<path>                              a directory, e.g. a mounted PVC
+//
+// S3 and GCS use the HMAC credentials of the environment variables
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func NewBackupStore(location string) (BackupStore, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup location: %w", err)
+	}
+	switch u.Scheme {
+	case "", "file":
+		return DirectoryBackupStore{Dir: u.Path}, nil
+	case "s3", "gs":
+		store := &S3BackupStore{
+			Endpoint:  u.Query().Get("endpoint"),
+			Region:    u.Query().Get("region"),
+			Bucket:    u.Host,
+			Prefix:    strings.Trim(u.Path, "/"),
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}
+		if store.Region == "" {
+			store.Region = os.Getenv("AWS_REGION")
+		}
+		if store.Region == "" {
+			store.Region = "us-east-1"
+		}
+		if store.Endpoint == "" && u.Scheme == "gs" {
+			store.Endpoint = "https://storage.googleapis.com"
+			store.Region = "auto"
+		}
+		if store.Endpoint == "" {
+			store.Endpoint = "https://s3." + store.Region + ".amazonaws.com"
+		}
+		if store.AccessKey == "" || store.SecretKey == "" {
+			return nil, fmt.Errorf("backup location %s requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", location)
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("unsupported backup location %s", location)
+}
+
+// DirectoryBackupStore keeps snapshots as files below Dir.
+type DirectoryBackupStore struct {
+	Dir string
+}
+
+func (s DirectoryBackupStore) path(key string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(key))
+}
+
+func (s DirectoryBackupStore) Put(ctx context.Context, key string, data []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func (s DirectoryBackupStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(key))
+}
+
+func (s DirectoryBackupStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.Dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (s DirectoryBackupStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// S3BackupStore keeps snapshots as objects of an S3 compatible bucket. It
+// uses path style requests signed with AWS Signature Version 4.
+type S3BackupStore struct {
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to all keys.
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// HttpClient defaults to a client with a timeout of 30s if nil.
+	HttpClient *http.Client
+}
+
+func (s *S3BackupStore) objectKey(key string) string {
+	if s.Prefix == "" {
+		return key
+	}
+	return s.Prefix + "/" + key
+}
+
+func (s *S3BackupStore) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.do(ctx, http.MethodPut, s.objectKey(key), nil, data)
+	return err
+}
+
+func (s *S3BackupStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.do(ctx, http.MethodGet, s.objectKey(key), nil, nil)
+}
+
+func (s *S3BackupStore) Delete(ctx context.Context, key string) error {
+	_, err := s.do(ctx, http.MethodDelete, s.objectKey(key), nil, nil)
+	return err
+}
+
+func (s *S3BackupStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	query := url.Values{"list-type": {"2"}, "prefix": {s.objectKey(prefix)}}
+	for {
+		body, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("unable to parse bucket listing: %w", err)
+		}
+		for _, c := range result.Contents {
+			key := c.Key
+			if s.Prefix != "" {
+				key = strings.TrimPrefix(key, s.Prefix+"/")
+			}
+			keys = append(keys, key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *S3BackupStore) do(ctx context.Context, method string, key string, query url.Values, body []byte) ([]byte, error) {
+	path := "/" + s.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(s.Endpoint, "/")+s3Escape(path), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	s.sign(req, body, time.Now().UTC())
+	httpClient := s.HttpClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s failed with status %s", method, path, resp.Status)
+	}
+	return data, nil
+}
+
+// sign adds the Authorization header of AWS Signature Version 4.
+func (s *S3BackupStore) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSha256([]byte("AWS4"+s.SecretKey), date)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSha256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="+signature)
+}
+
+// s3Escape escapes a path as required by S3, keeping the slashes.
+func s3Escape(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(segment), "+", "%20")
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
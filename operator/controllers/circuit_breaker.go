@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// ErrCircuitOpen is returned instead of sending requests to a tenant whose
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open after repeated failures of the Instana API")
+
+// CircuitBreaker suspends requests against an Instana tenant after
+// Threshold consecutive server errors or timeouts. After Cooldown the breaker
+// is half-open: a single probe request is let through while all others still
+// fail fast. If the probe succeeds the breaker closes, if it fails the
+// breaker opens again for Cooldown. Tenants are identified by their base url.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive failures which open the breaker. 0 disables it.
+	Threshold int
+	Cooldown  time.Duration
+
+	mu      sync.Mutex
+	tenants map[string]*breakerState
+}
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+	// probing is true while the probe request of the half-open breaker is in flight
+	probing bool
+}
+
+// Wrap returns a client which fails fast with ErrCircuitOpen while the
+// breaker of the tenant is open.
+func (b *CircuitBreaker) Wrap(apiConfig InstanaApi, instanaClient InstanaClient) InstanaClient {
+	if b == nil || b.Threshold <= 0 {
+		return instanaClient
+	}
+	return &breakerClient{breaker: b, tenant: apiConfig.BaseUrl, next: instanaClient}
+}
+
+// RetryAfter returns the time until the breaker of the tenant lets requests
+// through again, at least a second while it is not closed.
+func (b *CircuitBreaker) RetryAfter(baseUrl string) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.tenants[baseUrl]
+	if !ok || state.failures < b.Threshold {
+		return 0
+	}
+	if d := time.Until(state.openUntil); d > time.Second {
+		return d
+	}
+	return time.Second
+}
+
+// allow returns true if a request may be sent: always while the breaker is
+// closed, and only for the single probe once the cooldown is over.
+func (b *CircuitBreaker) allow(tenant string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.tenants[tenant]
+	if !ok || state.failures < b.Threshold {
+		return true
+	}
+	if state.probing || time.Now().Before(state.openUntil) {
+		return false
+	}
+	state.probing = true
+	return true
+}
+
+func (b *CircuitBreaker) record(tenant string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tenants == nil {
+		b.tenants = map[string]*breakerState{}
+	}
+	state, ok := b.tenants[tenant]
+	if !ok {
+		state = &breakerState{}
+		b.tenants[tenant] = state
+	}
+	state.probing = false
+	outage := isOutage(err)
+	if !outage && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrDryRun)) {
+		// the request was cancelled before Instana answered, e.g. while
+		// waiting for the rate limiter, or not sent because of a dry run, a
+		// probe is sent by the next request
+		return
+	}
+	if !outage {
+		state.failures = 0
+		circuitBreakerOpen.WithLabelValues(tenant).Set(0)
+		return
+	}
+	state.failures++
+	if state.failures >= b.Threshold {
+		state.openUntil = time.Now().Add(b.Cooldown)
+		circuitBreakerOpen.WithLabelValues(tenant).Set(1)
+	}
+}
+
+// isOutage returns true for errors indicating that Instana is unavailable,
+// i.e. 5xx responses and timeouts.
+func isOutage(err error) bool {
+	var apiErr *InstanaApiError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+type breakerClient struct {
+	breaker *CircuitBreaker
+	tenant  string
+	next    InstanaClient
+}
+
+func (c *breakerClient) createDashboard(config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	if !c.breaker.allow(c.tenant) {
+		return InstanaApiResponse{}, ErrCircuitOpen
+	}
+	r, err := c.next.createDashboard(config, log)
+	c.breaker.record(c.tenant, err)
+	return r, err
+}
+
+func (c *breakerClient) updateDashboard(id string, config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	if !c.breaker.allow(c.tenant) {
+		return InstanaApiResponse{}, ErrCircuitOpen
+	}
+	r, err := c.next.updateDashboard(id, config, log)
+	c.breaker.record(c.tenant, err)
+	return r, err
+}
+
+func (c *breakerClient) deleteDashboard(id string, log logr.Logger) error {
+	if !c.breaker.allow(c.tenant) {
+		return ErrCircuitOpen
+	}
+	err := c.next.deleteDashboard(id, log)
+	c.breaker.record(c.tenant, err)
+	return err
+}
+
+func (c *breakerClient) getDashboard(id string, log logr.Logger) ([]byte, error) {
+	if !c.breaker.allow(c.tenant) {
+		return nil, ErrCircuitOpen
+	}
+	config, err := c.next.getDashboard(id, log)
+	c.breaker.record(c.tenant, err)
+	return config, err
+}
+
+func (c *breakerClient) listDashboards(log logr.Logger) ([]InstanaApiResponse, error) {
+	if !c.breaker.allow(c.tenant) {
+		return nil, ErrCircuitOpen
+	}
+	list, err := c.next.listDashboards(log)
+	c.breaker.record(c.tenant, err)
+	return list, err
+}
+
+func (c *breakerClient) searchSnapshots(plugin string, query string, log logr.Logger) ([]InstanaSnapshot, error) {
+	if !c.breaker.allow(c.tenant) {
+		return nil, ErrCircuitOpen
+	}
+	snapshots, err := c.next.searchSnapshots(plugin, query, log)
+	c.breaker.record(c.tenant, err)
+	return snapshots, err
+}
+
+func (c *breakerClient) getSnapshot(id string, log logr.Logger) (InstanaSnapshot, error) {
+	if !c.breaker.allow(c.tenant) {
+		return InstanaSnapshot{}, ErrCircuitOpen
+	}
+	snapshot, err := c.next.getSnapshot(id, log)
+	c.breaker.record(c.tenant, err)
+	return snapshot, err
+}
+
+func (c *breakerClient) do(method string, path string, body []byte, log logr.Logger) ([]byte, error) {
+	if !c.breaker.allow(c.tenant) {
+		return nil, ErrCircuitOpen
+	}
+	response, err := c.next.do(method, path, body, log)
+	c.breaker.record(c.tenant, err)
+	return response, err
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const tenant = "https://breaker.instana.io"
+	breaker := &CircuitBreaker{Threshold: 2, Cooldown: 50 * time.Millisecond}
+	instana := newFakeInstanaClient()
+	instana.dashboards["a"] = []byte(`{}`)
+	client := breaker.Wrap(InstanaApi{BaseUrl: tenant}, instana)
+	get := func() error {
+		_, err := client.getDashboard("a", ctrl.Log)
+		return err
+	}
+	outage := &InstanaApiError{Method: "GET", StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+
+	instana.err = outage
+	_ = get()
+	if err := get(); !errors.Is(err, outage) {
+		t.Fatalf("second failure = %v", err)
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("request while open = %v, want ErrCircuitOpen", err)
+	}
+	if open := testutil.ToFloat64(circuitBreakerOpen.WithLabelValues(tenant)); open != 1 {
+		t.Errorf("instana_dashboards_circuit_breaker_open = %v, want 1", open)
+	}
+	if d := breaker.RetryAfter(tenant); d < time.Second {
+		t.Errorf("RetryAfter() while open = %v", d)
+	}
+
+	// half-open: the probe fails and the breaker opens again
+	time.Sleep(60 * time.Millisecond)
+	if err := get(); !errors.Is(err, outage) {
+		t.Fatalf("probe = %v, want it to be sent", err)
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("request after a failed probe = %v, want ErrCircuitOpen", err)
+	}
+
+	// half-open: only a single probe is let through at a time
+	time.Sleep(60 * time.Millisecond)
+	if !breaker.allow(tenant) {
+		t.Fatal("the probe was not allowed")
+	}
+	if breaker.allow(tenant) {
+		t.Error("a second request was allowed while the probe is in flight")
+	}
+	breaker.record(tenant, context.Canceled)
+	if !breaker.allow(tenant) {
+		t.Fatal("no new probe was allowed after the probe was cancelled")
+	}
+	breaker.record(tenant, fmt.Errorf("%w: would send PUT /api/custom-dashboard/a", ErrDryRun))
+	if open := testutil.ToFloat64(circuitBreakerOpen.WithLabelValues(tenant)); open != 1 {
+		t.Error("a request refused by a dry run closed the breaker")
+	}
+	if !breaker.allow(tenant) {
+		t.Fatal("no new probe was allowed after a dry run")
+	}
+	breaker.record(tenant, nil)
+
+	// the successful probe closed the breaker
+	instana.err = nil
+	for i := 0; i < 3; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("request %d after the breaker closed = %v", i, err)
+		}
+	}
+	if open := testutil.ToFloat64(circuitBreakerOpen.WithLabelValues(tenant)); open != 0 {
+		t.Errorf("instana_dashboards_circuit_breaker_open = %v, want 0", open)
+	}
+	if d := breaker.RetryAfter(tenant); d != 0 {
+		t.Errorf("RetryAfter() while closed = %v", d)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Minute}
+	instana := newFakeInstanaClient()
+	client := breaker.Wrap(InstanaApi{BaseUrl: "https://client-errors.instana.io"}, instana)
+	for i := 0; i < 3; i++ {
+		// the fake answers unknown dashboards with 404
+		if _, err := client.getDashboard("missing", ctrl.Log); errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("a 404 opened the breaker")
+		}
+	}
+}
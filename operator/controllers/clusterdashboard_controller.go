@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// clusterDashboardPrefix prefixes the names of the Dashboards generated for
+// ClusterDashboards.
+const clusterDashboardPrefix = "clusterdashboard-"
+
+// clusterDashboardAnnotations are copied from the ClusterDashboard to its
+// Dashboard, as they change how the Dashboard is synced or deleted.
+var clusterDashboardAnnotations = []string{
+	customv1.HotfixPatchAnnotation,
+	customv1.SkipRemoteDeleteAnnotation,
+	customv1.ProtectedAnnotation,
+	customv1.CustomProtectedAnnotation,
+	customv1.AllowDeletionAnnotation,
+}
+
+// clusterDashboardRequestAnnotations request a single action and are moved
+// from the ClusterDashboard to its Dashboard, which removes them once done.
+var clusterDashboardRequestAnnotations = []string{
+	customv1.SyncRequestedAnnotation,
+	customv1.RestoreSnapshotAnnotation,
+}
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=clusterdashboards,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=custom.instana.io,resources=clusterdashboards/status,verbs=get;update;patch
+
+// ClusterDashboardReconciler syncs every ClusterDashboard through a Dashboard
+// clusterdashboard-<name> in the namespace of the tenant config, which is
+// owned by the ClusterDashboard and deleted with it. The status of the
+// Dashboard is copied back to the ClusterDashboard, with the generation of the
+// ClusterDashboard as observedGeneration. The labels of the ClusterDashboard
+// are copied to the Dashboard, so it is selected by the same shard.
+type ClusterDashboardReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Shard limits the reconciler to the ClusterDashboards whose Dashboard
+	// belongs to this shard.
+	Shard Shard
+}
+
+// Reconcile applies the Dashboard of the ClusterDashboard and copies its status.
+func (r *ClusterDashboardReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("clusterdashboard", req.Name)
+
+	var clusterDashboard customv1.ClusterDashboard
+	if err := r.Get(ctx, req.NamespacedName, &clusterDashboard); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if clusterDashboard.DeletionTimestamp != nil {
+		// the dashboard is deleted with the cluster dashboard
+		return ctrl.Result{}, nil
+	}
+	if !r.Shard.OwnsClusterDashboard(&clusterDashboard) {
+		return ctrl.Result{}, nil
+	}
+
+	dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: clusterDashboardPrefix + clusterDashboard.Name}}
+	err := r.Get(ctx, client.ObjectKeyFromObject(dashboard), dashboard)
+	if err == nil && !metav1.IsControlledBy(dashboard, &clusterDashboard) {
+		err = fmt.Errorf("dashboard %s/%s exists and is not owned by the cluster dashboard", dashboard.Namespace, dashboard.Name)
+		log.Error(err, "unable to apply cluster dashboard")
+		return ctrl.Result{}, err
+	}
+	if client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, dashboard, func() error {
+		dashboard.Labels = map[string]string{}
+		for key, value := range clusterDashboard.Labels {
+			dashboard.Labels[key] = value
+		}
+		dashboard.Labels[customv1.GeneratorLabel] = "clusterdashboard"
+		dashboard.Labels[customv1.ClusterDashboardNameLabel] = clusterDashboard.Name
+		for _, key := range clusterDashboardAnnotations {
+			if value, ok := clusterDashboard.Annotations[key]; ok {
+				metav1.SetMetaDataAnnotation(&dashboard.ObjectMeta, key, value)
+			} else {
+				delete(dashboard.Annotations, key)
+			}
+		}
+		for _, key := range clusterDashboardRequestAnnotations {
+			if value, ok := clusterDashboard.Annotations[key]; ok {
+				metav1.SetMetaDataAnnotation(&dashboard.ObjectMeta, key, value)
+			}
+		}
+		dashboard.Spec = *clusterDashboard.Spec.DeepCopy()
+		return controllerutil.SetControllerReference(&clusterDashboard, dashboard, r.Scheme)
+	})
+	if err != nil {
+		log.Error(err, "unable to apply cluster dashboard")
+		return ctrl.Result{}, err
+	}
+	if result != controllerutil.OperationResultNone {
+		log.Info("Applied cluster dashboard", "dashboard", dashboard.Name, "result", result)
+	}
+
+	requested := false
+	for _, key := range clusterDashboardRequestAnnotations {
+		if _, ok := clusterDashboard.Annotations[key]; ok {
+			delete(clusterDashboard.Annotations, key)
+			requested = true
+		}
+	}
+	if requested {
+		if err := r.Update(ctx, &clusterDashboard); err != nil {
+			log.Error(err, "unable to remove the request annotations passed to the dashboard")
+			return ctrl.Result{}, err
+		}
+	}
+
+	status := *dashboard.Status.DeepCopy()
+	// the Dashboard's generation differs from the ClusterDashboard's, the
+	// status observes the ClusterDashboard once the Dashboard synced its spec
+	status.ObservedGeneration = clusterDashboard.Status.ObservedGeneration
+	if result == controllerutil.OperationResultNone && dashboard.Status.ObservedGeneration == dashboard.Generation {
+		status.ObservedGeneration = clusterDashboard.Generation
+	}
+	if !equality.Semantic.DeepEqual(clusterDashboard.Status, status) {
+		clusterDashboard.Status = status
+		if err := r.Status().Update(ctx, &clusterDashboard); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterDashboardReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&customv1.ClusterDashboard{}, builder.WithPredicates(predicate.NewPredicateFuncs(r.Shard.OwnsClusterDashboard))).
+		// Restore edited Dashboards and copy their status
+		Owns(&customv1.Dashboard{}, builder.WithPredicates(r.Shard.DashboardPredicate())).
+		Complete(r)
+}
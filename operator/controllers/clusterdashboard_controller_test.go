@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestClusterDashboardReconciler(t *testing.T) {
+	ctx := context.Background()
+	clusterDashboard := &customv1.ClusterDashboard{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "platform",
+			UID:        "cd-1",
+			Generation: 2,
+			Annotations: map[string]string{
+				customv1.SkipRemoteDeleteAnnotation: "true",
+				customv1.CustomProtectedAnnotation:  "true",
+				customv1.SyncRequestedAnnotation:    "2026-10-15T08:00:00Z",
+			},
+		},
+		Spec: customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Platform"}`)}},
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	r := &ClusterDashboardReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(clusterDashboard).Build(),
+		Log:    ctrl.Log.WithName("test"),
+		Scheme: scheme,
+	}
+	reconcile := func() {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(clusterDashboard)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := client.ObjectKey{Namespace: instanaConfigNamespace, Name: "clusterdashboard-platform"}
+
+	reconcile()
+	var dashboard customv1.Dashboard
+	if err := r.Get(ctx, key, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	if string(dashboard.Spec.Config.Raw) != `{"title":"Platform"}` {
+		t.Errorf("config = %s", dashboard.Spec.Config.Raw)
+	}
+	if !metav1.IsControlledBy(&dashboard, clusterDashboard) || dashboard.Labels[customv1.ClusterDashboardNameLabel] != "platform" {
+		t.Errorf("dashboard should be owned by the cluster dashboard and labelled, got %+v", dashboard.ObjectMeta)
+	}
+	if dashboard.Annotations[customv1.SkipRemoteDeleteAnnotation] != "true" || dashboard.Annotations[customv1.CustomProtectedAnnotation] != "true" ||
+		dashboard.Annotations[customv1.SyncRequestedAnnotation] != "2026-10-15T08:00:00Z" {
+		t.Errorf("annotations = %v", dashboard.Annotations)
+	}
+	var applied customv1.ClusterDashboard
+	if err := r.Get(ctx, client.ObjectKeyFromObject(clusterDashboard), &applied); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := applied.Annotations[customv1.SyncRequestedAnnotation]; ok {
+		t.Error("the sync request should be moved to the dashboard")
+	}
+
+	// the dashboard removes the request once synced, it is not requested again
+	delete(dashboard.Annotations, customv1.SyncRequestedAnnotation)
+	if err := r.Update(ctx, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	dashboard = customv1.Dashboard{}
+	if err := r.Get(ctx, key, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dashboard.Annotations[customv1.SyncRequestedAnnotation]; ok {
+		t.Error("the sync was requested again")
+	}
+
+	// the status of the dashboard is copied
+	dashboard.Status.DashboardId = "abc"
+	if err := r.Status().Update(ctx, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if err := r.Get(ctx, client.ObjectKeyFromObject(clusterDashboard), clusterDashboard); err != nil {
+		t.Fatal(err)
+	}
+	if clusterDashboard.Status.DashboardId != "abc" || clusterDashboard.Status.ObservedGeneration != 2 {
+		t.Errorf("status = %+v, want the dashboard id and the generation of the cluster dashboard", clusterDashboard.Status)
+	}
+
+	// dashboards of the same name which are not owned are left alone
+	other := &customv1.ClusterDashboard{ObjectMeta: metav1.ObjectMeta{Name: "team", UID: "cd-2"}}
+	if err := r.Create(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Create(ctx, &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: "clusterdashboard-team"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(other)}); err == nil {
+		t.Error("expected an error for a dashboard not owned by the cluster dashboard")
+	}
+}
+
+func TestClusterDashboardShard(t *testing.T) {
+	ctx := context.Background()
+	selector, err := labels.Parse("team=platform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	own := &customv1.ClusterDashboard{
+		ObjectMeta: metav1.ObjectMeta{Name: "platform", UID: "cd-1", Labels: map[string]string{"team": "platform"}},
+		Spec:       customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Platform"}`)}},
+	}
+	other := &customv1.ClusterDashboard{
+		ObjectMeta: metav1.ObjectMeta{Name: "billing", UID: "cd-2", Labels: map[string]string{"team": "billing"}},
+		Spec:       customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Billing"}`)}},
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	shard := Shard{DashboardSelector: selector}
+	r := &ClusterDashboardReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(own, other).Build(),
+		Log:    ctrl.Log.WithName("test"),
+		Scheme: scheme,
+		Shard:  shard,
+	}
+	for _, clusterDashboard := range []*customv1.ClusterDashboard{own, other} {
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(clusterDashboard)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var dashboard customv1.Dashboard
+	if err := r.Get(ctx, client.ObjectKey{Namespace: instanaConfigNamespace, Name: "clusterdashboard-platform"}, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	if dashboard.Labels["team"] != "platform" || dashboard.Labels[customv1.ClusterDashboardNameLabel] != "platform" {
+		t.Errorf("labels = %v, want the labels of the cluster dashboard", dashboard.Labels)
+	}
+	// the Dashboard is synced by the shard of the cluster dashboard
+	if !shard.OwnsDashboard(&dashboard) {
+		t.Error("the dashboard of the cluster dashboard is not selected by its shard")
+	}
+	err = r.Get(ctx, client.ObjectKey{Namespace: instanaConfigNamespace, Name: "clusterdashboard-billing"}, &customv1.Dashboard{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("the cluster dashboard of another shard was applied, err = %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// configHash returns the SHA256 of a rendered config.
+func configHash(config []byte) string {
+	sum := sha256.Sum256(config)
+	return hex.EncodeToString(sum[:])
+}
+
+// configDrift compares the desired dashboard config with the live config in
+// Instana and returns the JSON paths which differ, including fields which
+// are only present in the live config. The serverSideFields set by Instana
+// are ignored.
+func configDrift(desired []byte, live []byte) ([]string, error) {
+	return jsonDrift(desired, live, true)
+}
+
+// resourceDrift is configDrift for the configs of InstanaResources, whose
+// server side fields differ per kind, so fields which are only present in
+// the live config are ignored.
+func resourceDrift(desired []byte, live []byte) ([]string, error) {
+	return jsonDrift(desired, live, false)
+}
+
+func jsonDrift(desired []byte, live []byte, liveOnly bool) ([]string, error) {
+	var d, l interface{}
+	if err := json.Unmarshal(desired, &d); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(live, &l); err != nil {
+		return nil, err
+	}
+	var diffs []string
+	diffValues("", d, l, liveOnly, &diffs)
+	return diffs, nil
+}
+
+// diffValues appends the paths in which desired and live differ. Keys only
+// present in live are compared if liveOnly is set.
+func diffValues(path string, desired interface{}, live interface{}, liveOnly bool, diffs *[]string) {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, pathOrRoot(path))
+			return
+		}
+		keys := make([]string, 0, len(d))
+		for key := range d {
+			keys = append(keys, key)
+		}
+		if liveOnly {
+			for key := range l {
+				if _, ok := d[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if path == "" && containsString(serverSideFields, key) {
+				continue
+			}
+			_, inDesired := d[key]
+			_, inLive := l[key]
+			if !inDesired || !inLive {
+				*diffs = append(*diffs, path+"/"+key)
+				continue
+			}
+			diffValues(path+"/"+key, d[key], l[key], liveOnly, diffs)
+		}
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			*diffs = append(*diffs, pathOrRoot(path))
+			return
+		}
+		for i := range d {
+			diffValues(fmt.Sprintf("%s/%d", path, i), d[i], l[i], liveOnly, diffs)
+		}
+	default:
+		if !reflect.DeepEqual(desired, live) {
+			*diffs = append(*diffs, pathOrRoot(path))
+		}
+	}
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// ConfigDifference is a path in which the live dashboard differs from the
+// desired config. Live is nil if the path does not exist in the live
+// dashboard, Desired if it only exists in the live dashboard.
+type ConfigDifference struct {
+	Path    string
+	Desired interface{}
+	Live    interface{}
+}
+
+// DiffDashboard compares the rendered config of the spec of a Dashboard
+// resource with its live dashboard in Instana, the tenant of apiConfig.
+// Server side fields and the managed marker are ignored. A hotfix patch is
+// not part of the spec, so the changes of the patch are listed as drift.
+// Templated configs are rendered with vars.
+func DiffDashboard(ctx context.Context, c client.Reader, vars RenderVariables, apiConfig InstanaApi, dashboard customv1.Dashboard, log logr.Logger) ([]ConfigDifference, error) {
+	if dashboard.Status.DashboardId == "" {
+		return nil, fmt.Errorf("dashboard %s/%s has not been synced with Instana yet", dashboard.Namespace, dashboard.Name)
+	}
+	vars.Instana = apiConfig
+	desired, err := renderSpecConfig(ctx, c, vars, dashboard)
+	if err == nil {
+		desired, err = applyTitlePolicy(desired, apiConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+	live, err := apiConfig.getDashboard(dashboard.Status.DashboardId, log)
+	if err != nil {
+		return nil, err
+	}
+	live, err = importableConfig(live)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := configDrift(desired, live)
+	if err != nil {
+		return nil, err
+	}
+	var d, l interface{}
+	_ = json.Unmarshal(desired, &d)
+	_ = json.Unmarshal(live, &l)
+	diffs := make([]ConfigDifference, 0, len(paths))
+	for _, path := range paths {
+		diffs = append(diffs, ConfigDifference{Path: path, Desired: lookupPath(d, path), Live: lookupPath(l, path)})
+	}
+	return diffs, nil
+}
+
+// lookupPath returns the value at a path as returned by configDrift.
+func lookupPath(value interface{}, path string) interface{} {
+	if path == "/" {
+		return value
+	}
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[segment]
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i >= len(v) {
+				return nil
+			}
+			value = v[i]
+		default:
+			return nil
+		}
+	}
+	return value
+}
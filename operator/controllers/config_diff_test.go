@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/instanatest"
+)
+
+func TestDiffDashboard(t *testing.T) {
+	ctx := context.Background()
+	defaultTenant := instanatest.NewServer("default-token")
+	defer defaultTenant.Close()
+	teamTenant := instanatest.NewServer("team-token")
+	defer teamTenant.Close()
+	// the operator synced the dashboard with the hotfix patch into the tenant of the namespace
+	teamTenant.PutDashboard("d1", map[string]interface{}{"title": "Hotfix", "ownerId": "user-1", "widgets": []interface{}{}})
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	dashboard := customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "team-a",
+			Name:        "shop",
+			Annotations: map[string]string{customv1.HotfixPatchAnnotation: `[{"op":"replace","path":"/title","value":"Hotfix"}]`},
+		},
+		Spec:   customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)}},
+		Status: customv1.DashboardStatus{DashboardId: "d1"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": defaultTenant.URL, "instana-api-token": "default-token"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: namespaceCredentialsName},
+			Data:       map[string][]byte{"instana-base-url": []byte(teamTenant.URL), "instana-api-token": []byte("team-token")},
+		},
+	).Build()
+
+	tenant, err := LoadDashboardTenant(ctx, c, dashboard, false)
+	if err != nil || tenant.BaseUrl != defaultTenant.URL {
+		t.Errorf("LoadDashboardTenant() = %s, %v, want the default tenant", tenant.BaseUrl, err)
+	}
+	tenant, err = LoadDashboardTenant(ctx, c, dashboard, true)
+	if err != nil || tenant.BaseUrl != teamTenant.URL || tenant.ApiToken != "team-token" {
+		t.Fatalf("LoadDashboardTenant() with namespace credentials = %s, %v, want the tenant of the namespace", tenant.BaseUrl, err)
+	}
+
+	diffs, err := DiffDashboard(ctx, c, RenderVariables{}, tenant, dashboard, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Path != "/title" || diffs[0].Desired != "Shop" || diffs[0].Live != "Hotfix" {
+		t.Errorf("diffs = %+v, want the title changed by the hotfix patch", diffs)
+	}
+
+	dashboard.Status.DashboardId = ""
+	if _, err := DiffDashboard(ctx, c, RenderVariables{}, tenant, dashboard, ctrl.Log); err == nil {
+		t.Error("expected an error for a dashboard which was not synced yet")
+	}
+}
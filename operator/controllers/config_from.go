@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// dashboardConfig returns the config of the dashboard, read from the
+// ConfigMap selected by spec.config-from if set, with spec.patches applied.
+func dashboardConfig(ctx context.Context, c client.Reader, dashboard customv1.Dashboard) ([]byte, error) {
+	config, err := baseConfig(ctx, c, dashboard)
+	if err != nil {
+		return nil, err
+	}
+	return applyConfigPatches(dashboard, config)
+}
+
+// baseConfig returns the config of spec.config-from, spec.jsonnet or
+// spec.config, in this order.
+func baseConfig(ctx context.Context, c client.Reader, dashboard customv1.Dashboard) ([]byte, error) {
+	ref := dashboard.Spec.ConfigFrom
+	if ref == nil && dashboard.Spec.Jsonnet != "" {
+		return evaluateJsonnet(ctx, c, dashboard, "spec.jsonnet", dashboard.Spec.Jsonnet)
+	}
+	if ref == nil {
+		config, _, err := specConfig(dashboard)
+		return config, err
+	}
+	var configMap corev1.ConfigMap
+	if err := c.Get(ctx, client.ObjectKey{Namespace: dashboard.Namespace, Name: ref.Name}, &configMap); err != nil {
+		return nil, fmt.Errorf("unable to read the ConfigMap of spec.config-from: %w", err)
+	}
+	config, ok := configMap.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s has no key %s", ref.Name, ref.Key)
+	}
+	if strings.HasSuffix(ref.Key, ".jsonnet") {
+		return evaluateJsonnet(ctx, c, dashboard, ref.Name+"/"+ref.Key, config)
+	}
+	if !json.Valid([]byte(config)) {
+		return nil, fmt.Errorf("key %s of ConfigMap %s is not valid JSON", ref.Key, ref.Name)
+	}
+	return []byte(config), nil
+}
+
+// dashboardsForConfigMap maps a ConfigMap to the Dashboards reading their
+// config from it.
+func (r *DashboardReconciler) dashboardsForConfigMap(obj client.Object) []reconcile.Request {
+	var dashboards customv1.DashboardList
+	if err := r.List(context.Background(), &dashboards, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "unable to list dashboards")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, dashboard := range dashboards.Items {
+		if dashboard.Spec.ConfigFrom != nil && dashboard.Spec.ConfigFrom.Name == obj.GetName() && r.Shard.OwnsDashboard(&dashboard) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&dashboard)})
+		}
+	}
+	return requests
+}
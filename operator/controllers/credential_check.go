@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// CredentialCheck validates the API config of every tenant with an
+// authenticated request when the manager starts and whenever the config
+// changes, so a wrong token or base url is reported right away instead of by
+// failing syncs. Tenants rejected by Instana are reported by a Warning event
+// on their config map and make the manager not ready until fixed.
+// Connectivity problems are retried but don't affect the readiness.
+//
+// The permissions of the token are verified as well: the APIs of the Kinds
+// with resources in the cluster are read for the default tenant, and the
+// custom dashboards API for every tenant used by Dashboards. The kinds
+// Instana denies are reported by a PermissionsMissing event, and Dashboards
+// of tenants lacking the custom dashboards permission get the
+// PermissionsMissing condition.
+type CredentialCheck struct {
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// Kinds are the kinds whose permissions are verified.
+	Kinds []InstanaResourceKind
+	// NamespaceCredentials verifies the tokens of the credentials Secrets of
+	// the namespaces instead of the default tenant for Dashboards, as with
+	// DashboardReconciler.NamespaceCredentials.
+	NamespaceCredentials bool
+	// Shard limits the Dashboards whose condition is set to the namespaces
+	// of this shard.
+	Shard Shard
+	// Instana creates the client of a tenant, so the checks share its rate
+	// limit and circuit breaker with the reconcilers.
+	Instana *InstanaClients
+
+	mu       sync.Mutex
+	rejected map[string]error
+	missing  map[string][]string
+}
+
+// Status returns the status writer of the client, redacting the status of
+// the Dashboards.
+func (c *CredentialCheck) Status() client.StatusWriter {
+	return redactingStatusWriter{StatusWriter: c.Client.Status()}
+}
+
+// Reconcile validates the API config of a tenant config map.
+func (c *CredentialCheck) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := c.Log.WithValues("tenant", req.Name)
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, req.NamespacedName, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			c.forget(req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !tenantReady(cm) {
+		c.forget(req.Name)
+		return ctrl.Result{}, nil
+	}
+	instanaApi := instanaApiFromConfigMap(cm)
+	instanaClient := c.Instana.Client(ctx, instanaApi)
+	err := validateCredentials(req.Name, instanaApi.BaseUrl, instanaClient, log)
+	var apiErr *InstanaApiError
+	if err != nil && !errors.As(err, &apiErr) {
+		log.Error(err, "unable to validate Instana credentials. Retrying.")
+		return ctrl.Result{}, err
+	}
+	c.record(req.Name, err)
+	if err != nil {
+		log.Error(err, "Instana rejected the tenant config", "baseUrl", instanaApi.BaseUrl)
+		c.Recorder.Event(cm, corev1.EventTypeWarning, "CredentialsInvalid", err.Error())
+		return ctrl.Result{}, nil
+	}
+	log.Info("Validated Instana credentials", "baseUrl", instanaApi.BaseUrl)
+	dashboards, err := c.tenantDashboards(ctx, req.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	namespaceCredentials := c.NamespaceCredentials && req.Name == instanaConfigName
+	missing, err := c.missingPermissions(ctx, req.Name, instanaClient, len(dashboards) > 0 && !namespaceCredentials, log)
+	if err != nil {
+		log.Error(err, "unable to verify the permissions of the API token. Retrying.")
+		return ctrl.Result{}, err
+	}
+	c.recordMissing(req.Name, missing)
+	if len(missing) > 0 {
+		log.Info("The API token lacks permissions", "kinds", missing)
+		c.Recorder.Event(cm, corev1.EventTypeWarning, "PermissionsMissing",
+			"The API token lacks the permissions to manage "+strings.Join(missing, ", "))
+	}
+	if namespaceCredentials {
+		c.checkNamespaceCredentials(ctx, instanaApi, dashboards, log)
+	}
+	return ctrl.Result{}, c.setDashboardConditions(ctx, dashboards, log)
+}
+
+// tenantDashboards returns the Dashboards of this shard using the tenant:
+// all Dashboards for the default tenant, the Dashboards mirrored to it
+// otherwise.
+func (c *CredentialCheck) tenantDashboards(ctx context.Context, tenant string) ([]customv1.Dashboard, error) {
+	var list customv1.DashboardList
+	if err := c.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	var dashboards []customv1.Dashboard
+	for _, dashboard := range list.Items {
+		if !c.Shard.OwnsDashboard(&dashboard) || dashboard.DeletionTimestamp != nil {
+			continue
+		}
+		if tenant == instanaConfigName || dashboard.Spec.MirrorTenant == tenant {
+			dashboards = append(dashboards, dashboard)
+		}
+	}
+	return dashboards, nil
+}
+
+// missingPermissions returns the kinds whose API Instana denies to the token
+// of the tenant: Dashboard if dashboards is set, and for the default tenant
+// the kinds with resources in the cluster.
+func (c *CredentialCheck) missingPermissions(ctx context.Context, tenant string, instanaClient InstanaClient, dashboards bool, log logr.Logger) ([]string, error) {
+	var missing []string
+	if dashboards {
+		if _, err := instanaClient.do(http.MethodGet, dashboardsPath, nil, log); isForbidden(err) {
+			missing = append(missing, dashboardsKind)
+		}
+	}
+	if tenant != instanaConfigName {
+		// Instana resources are synced with the default tenant only
+		return missing, nil
+	}
+	for _, kind := range c.Kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(customv1.GroupVersion.WithKind(kind.Kind + "List"))
+		if err := c.List(ctx, list, client.Limit(1)); err != nil {
+			return nil, err
+		}
+		if len(list.Items) == 0 {
+			continue
+		}
+		_, err := instanaClient.do(http.MethodGet, kind.Path, nil, log)
+		if isForbidden(err) {
+			missing = append(missing, kind.Kind)
+		}
+	}
+	return missing, nil
+}
+
+// checkNamespaceCredentials verifies the permission of the tokens of the
+// credentials Secrets of the namespaces of the Dashboards. Namespaces without
+// a Secret are skipped, their syncs fail with the reason.
+func (c *CredentialCheck) checkNamespaceCredentials(ctx context.Context, instanaApi InstanaApi, dashboards []customv1.Dashboard, log logr.Logger) {
+	checked := map[string]bool{}
+	for _, dashboard := range dashboards {
+		if checked[dashboard.Namespace] {
+			continue
+		}
+		checked[dashboard.Namespace] = true
+		tenant := namespaceCredentialsTenant(dashboard.Namespace)
+		namespaceApi, err := loadNamespaceCredentials(ctx, c.Client, dashboard.Namespace, instanaApi)
+		if err != nil {
+			c.recordMissing(tenant, nil)
+			continue
+		}
+		missing, _ := c.missingPermissions(ctx, tenant, c.Instana.Client(ctx, namespaceApi), true, log.WithValues("namespace", dashboard.Namespace))
+		c.recordMissing(tenant, missing)
+	}
+}
+
+// setDashboardConditions sets the PermissionsMissing condition of the
+// Dashboards according to the last check of the tenants they use.
+func (c *CredentialCheck) setDashboardConditions(ctx context.Context, dashboards []customv1.Dashboard, log logr.Logger) error {
+	for i := range dashboards {
+		dashboard := &dashboards[i]
+		tenants := []string{instanaConfigName}
+		if c.NamespaceCredentials {
+			tenants[0] = namespaceCredentialsTenant(dashboard.Namespace)
+		}
+		if dashboard.Spec.MirrorTenant != "" {
+			tenants = append(tenants, dashboard.Spec.MirrorTenant)
+		}
+		var lacking []string
+		for _, tenant := range tenants {
+			for _, kind := range c.MissingPermissions(tenant) {
+				if kind == dashboardsKind {
+					lacking = append(lacking, tenant)
+				}
+			}
+		}
+		if !setDashboardPermissionsStatus(dashboard, lacking) {
+			continue
+		}
+		if err := c.Status().Update(ctx, dashboard); err != nil {
+			log.Error(err, "unable to update dashboard status", "dashboard", client.ObjectKeyFromObject(dashboard))
+			return err
+		}
+	}
+	return nil
+}
+
+// namespaceCredentialsTenant is the name under which the permissions of the
+// credentials Secret of the namespace are recorded.
+func namespaceCredentialsTenant(namespace string) string {
+	return namespace + "/" + namespaceCredentialsName
+}
+
+// MissingPermissions returns the kinds the token of the tenant lacks the
+// permissions for, as of the last check.
+func (c *CredentialCheck) MissingPermissions(tenant string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.missing[tenant]
+}
+
+func (c *CredentialCheck) recordMissing(tenant string, missing []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.missing == nil {
+		c.missing = map[string][]string{}
+	}
+	if len(missing) == 0 {
+		delete(c.missing, tenant)
+		return
+	}
+	c.missing[tenant] = missing
+}
+
+// validateCredentials lists the dashboards of the tenant, which requires a
+// valid token. A token lacking the permission to manage custom dashboards is
+// valid, the permission is verified by missingPermissions.
+func validateCredentials(tenant string, baseUrl string, instanaClient InstanaClient, log logr.Logger) error {
+	_, err := instanaClient.do(http.MethodGet, dashboardsPath, nil, log)
+	if isForbidden(err) {
+		return nil
+	}
+	var apiErr *InstanaApiError
+	if err == nil || !errors.As(err, &apiErr) {
+		return err
+	}
+	if isAuthError(err) {
+		return fmt.Errorf("Instana rejected the API token of %s: %w", tenant, err)
+	}
+	return fmt.Errorf("the instana-base-url %s of %s is not an Instana API: %w", baseUrl, tenant, err)
+}
+
+// forget clears the results of a deleted or incomplete tenant config.
+func (c *CredentialCheck) forget(tenant string) {
+	c.record(tenant, nil)
+	c.recordMissing(tenant, nil)
+}
+
+func (c *CredentialCheck) record(tenant string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rejected == nil {
+		c.rejected = map[string]error{}
+	}
+	if err == nil {
+		delete(c.rejected, tenant)
+		return
+	}
+	c.rejected[tenant] = err
+}
+
+// Checker is a healthz.Checker failing while Instana rejects the config of a
+// tenant.
+func (c *CredentialCheck) Checker(_ *http.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.rejected) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(c.rejected))
+	for _, err := range c.rejected {
+		messages = append(messages, err.Error())
+	}
+	sort.Strings(messages)
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (c *CredentialCheck) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("credentialcheck").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(tenantChangedPredicate)).
+		Watches(&source.Kind{Type: &customv1.Dashboard{}},
+			handler.EnqueueRequestsFromMapFunc(tenantsOfDashboard),
+			builder.WithPredicates(dashboardTenantsChangedPredicate)).
+		Complete(c)
+}
+
+// tenantsOfDashboard maps a Dashboard to the tenant config maps it uses, so
+// the permissions are verified when a Dashboard starts using a tenant.
+func tenantsOfDashboard(obj client.Object) []reconcile.Request {
+	requests := []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: instanaConfigNamespace, Name: instanaConfigName}}}
+	if dashboard, ok := obj.(*customv1.Dashboard); ok && dashboard.Spec.MirrorTenant != "" {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Namespace: instanaConfigNamespace, Name: dashboard.Spec.MirrorTenant}})
+	}
+	return requests
+}
+
+// dashboardTenantsChangedPredicate passes created Dashboards and Dashboards
+// whose mirror tenant changed.
+var dashboardTenantsChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		old, ok := e.ObjectOld.(*customv1.Dashboard)
+		if !ok {
+			return false
+		}
+		new, ok := e.ObjectNew.(*customv1.Dashboard)
+		return ok && old.Spec.MirrorTenant != new.Spec.MirrorTenant
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
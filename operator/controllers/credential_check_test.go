@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestCredentialCheck(t *testing.T) {
+	ctx := context.Background()
+	status := http.StatusUnauthorized
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+		Data:       map[string]string{"instana-base-url": server.URL, "instana-api-token": "expired-token"},
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).Build()
+	recorder := record.NewFakeRecorder(10)
+	check := &CredentialCheck{Client: c, Log: ctrl.Log.WithName("test"), Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(cm)}
+
+	if _, err := check.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := check.Checker(nil); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("Checker() = %v, want the rejected token", err)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "CredentialsInvalid") || strings.Contains(event, "expired-token") {
+		t.Errorf("event = %s", event)
+	}
+
+	status = http.StatusOK
+	if _, err := check.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := check.Checker(nil); err != nil {
+		t.Errorf("Checker() = %v after the token was fixed", err)
+	}
+
+	// unreachable tenants are retried without affecting the readiness
+	server.Close()
+	if _, err := check.Reconcile(ctx, req); err == nil {
+		t.Error("expected an error for an unreachable tenant")
+	}
+	if err := check.Checker(nil); err != nil {
+		t.Errorf("Checker() = %v for an unreachable tenant", err)
+	}
+}
+
+func TestCredentialCheckForgetsTenants(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: "team-a"},
+		Data:       map[string]string{"instana-base-url": server.URL, "instana-api-token": "expired-token"},
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).Build()
+	check := &CredentialCheck{Client: c, Log: ctrl.Log.WithName("test"), Recorder: record.NewFakeRecorder(10)}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(cm)}
+	reject := func() {
+		t.Helper()
+		if _, err := check.Reconcile(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		if err := check.Checker(nil); err == nil {
+			t.Fatal("expected the tenant to be rejected")
+		}
+	}
+
+	// the token is removed
+	reject()
+	notReady := cm.DeepCopy()
+	delete(notReady.Data, "instana-api-token")
+	if !tenantChangedPredicate.Update(event.UpdateEvent{ObjectOld: cm, ObjectNew: notReady}) {
+		t.Error("tenantChangedPredicate should pass tenants which are no longer ready")
+	}
+	if err := c.Update(ctx, notReady); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := check.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := check.Checker(nil); err != nil {
+		t.Errorf("Checker() = %v for a tenant which is no longer ready", err)
+	}
+
+	// the config map is deleted
+	if err := c.Update(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	reject()
+	if !tenantChangedPredicate.Delete(event.DeleteEvent{Object: cm}) {
+		t.Error("tenantChangedPredicate should pass deleted tenants")
+	}
+	if err := c.Delete(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := check.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := check.Checker(nil); err != nil {
+		t.Errorf("Checker() = %v for a deleted tenant", err)
+	}
+}
+
+func TestCredentialCheckPermissions(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == sloKind.Path {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+		Data:       map[string]string{"instana-base-url": server.URL, "instana-api-token": "token"},
+	}
+	slo := &customv1.SLO{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "checkout"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm, slo).Build()
+	recorder := record.NewFakeRecorder(10)
+	check := &CredentialCheck{Client: c, Log: ctrl.Log.WithName("test"), Recorder: recorder,
+		Kinds: []InstanaResourceKind{sloKind, applicationPerspectiveKind}}
+
+	if _, err := check.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(cm)}); err != nil {
+		t.Fatal(err)
+	}
+	if missing := check.MissingPermissions(instanaConfigName); len(missing) != 1 || missing[0] != "SLO" {
+		t.Errorf("MissingPermissions() = %v, want [SLO]", missing)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "PermissionsMissing") || !strings.Contains(event, "SLO") {
+		t.Errorf("event = %s", event)
+	}
+	if err := check.Checker(nil); err != nil {
+		t.Errorf("Checker() = %v, missing permissions don't affect the readiness", err)
+	}
+}
+
+func TestCredentialCheckDashboardPermissions(t *testing.T) {
+	ctx := context.Background()
+	forbidden := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if forbidden && r.Header.Get("authorization") == "apiToken mirror-token" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	mirror := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: "team-b"},
+		Data:       map[string]string{"instana-base-url": server.URL, "instana-api-token": "mirror-token"},
+	}
+	key := client.ObjectKey{Namespace: "team-a", Name: "shop"}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(mirror,
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec:       customv1.DashboardSpec{MirrorTenant: "team-b"},
+		},
+		&customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "other"}},
+	).Build()
+	check := &CredentialCheck{Client: c, Log: ctrl.Log.WithName("test"), Recorder: record.NewFakeRecorder(10)}
+	reconcile := func() customv1.Dashboard {
+		t.Helper()
+		if _, err := check.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(mirror)}); err != nil {
+			t.Fatal(err)
+		}
+		var dashboard customv1.Dashboard
+		if err := c.Get(ctx, key, &dashboard); err != nil {
+			t.Fatal(err)
+		}
+		return dashboard
+	}
+
+	dashboard := reconcile()
+	condition := meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionPermissionsMissing)
+	if condition == nil || condition.Status != metav1.ConditionTrue || !strings.Contains(condition.Message, "team-b") {
+		t.Errorf("PermissionsMissing condition = %+v, want the mirror tenant", condition)
+	}
+	if err := check.Checker(nil); err != nil {
+		t.Errorf("Checker() = %v, a token lacking permissions is not rejected", err)
+	}
+	var other customv1.Dashboard
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "other"}, &other); err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Status.Conditions) != 0 {
+		t.Errorf("conditions of a Dashboard not using the tenant = %v", other.Status.Conditions)
+	}
+
+	forbidden = false
+	if dashboard := reconcile(); meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionPermissionsMissing) != nil {
+		t.Errorf("conditions after the permission was granted = %v", dashboard.Status.Conditions)
+	}
+}
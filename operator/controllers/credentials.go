@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// namespaceCredentialsName is the Secret holding the Instana API token of the
+// Dashboards of a namespace with DashboardReconciler.NamespaceCredentials.
+const namespaceCredentialsName = "instana-dashboard-credentials"
+
+const (
+	// dashboardsPath is the custom dashboards API.
+	dashboardsPath = "/api/custom-dashboard"
+	// dashboardsKind is reported for tokens lacking the permission of the
+	// custom dashboards API.
+	dashboardsKind = "Dashboard"
+)
+
+// isAuthError returns true if Instana rejected the request with 401 or 403.
+func isAuthError(err error) bool {
+	var apiErr *InstanaApiError
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// isForbidden returns true if Instana denied the request with 403.
+func isForbidden(err error) bool {
+	var apiErr *InstanaApiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden
+}
+
+// setPermissionsStatus sets the PermissionsMissing condition of a resource
+// synced with the API of kind according to the result of the sync.
+func setPermissionsStatus(conditions *[]metav1.Condition, kind InstanaResourceKind, err error) {
+	if isForbidden(err) {
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    customv1.ConditionPermissionsMissing,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Forbidden",
+			Message: "The API token of " + instanaConfigName + " lacks the permission to manage " + kind.Kind + " (" + kind.Path + ")",
+		})
+		return
+	}
+	if err == nil {
+		removeStatusCondition(conditions, customv1.ConditionPermissionsMissing)
+	}
+}
+
+// setDashboardPermissionsStatus sets the PermissionsMissing condition of a
+// Dashboard to the tenants whose token lacks the permission to manage custom
+// dashboards, and returns true if it changed.
+func setDashboardPermissionsStatus(dashboard *customv1.Dashboard, tenants []string) bool {
+	current := meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionPermissionsMissing)
+	if len(tenants) == 0 {
+		if current == nil {
+			return false
+		}
+		removeStatusCondition(&dashboard.Status.Conditions, customv1.ConditionPermissionsMissing)
+		return true
+	}
+	condition := metav1.Condition{
+		Type:    customv1.ConditionPermissionsMissing,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Forbidden",
+		Message: "The API token of " + strings.Join(tenants, ", ") + " lacks the permission to manage custom dashboards (" + dashboardsPath + ")",
+	}
+	if current != nil && current.Status == condition.Status && current.Message == condition.Message {
+		return false
+	}
+	meta.SetStatusCondition(&dashboard.Status.Conditions, condition)
+	return true
+}
+
+// setCredentialsStatus sets the CredentialsInvalid condition according to the
+// result of a request, so users know the token needs fixing.
+func setCredentialsStatus(dashboard *customv1.Dashboard, err error, credentials string, recorder record.EventRecorder) {
+	if !isAuthError(err) {
+		if err == nil {
+			meta.SetStatusCondition(&dashboard.Status.Conditions, metav1.Condition{
+				Type:    customv1.ConditionCredentialsInvalid,
+				Status:  metav1.ConditionFalse,
+				Reason:  "CredentialsAccepted",
+				Message: "Instana accepted the API token",
+			})
+		}
+		return
+	}
+	var apiErr *InstanaApiError
+	errors.As(err, &apiErr)
+	reason, message := "Unauthorized", "Instana rejected the API token of "+credentials+" as invalid or expired"
+	if apiErr.StatusCode == http.StatusForbidden {
+		reason, message = "Forbidden", "The API token of "+credentials+" lacks the permission to manage custom dashboards"
+	}
+	message += " (" + apiErr.Status + ")"
+	recorder.Event(dashboard, corev1.EventTypeWarning, "CredentialsInvalid", message)
+	meta.SetStatusCondition(&dashboard.Status.Conditions, metav1.Condition{
+		Type:    customv1.ConditionCredentialsInvalid,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// apiReader returns the reader used to re-read credentials.
+func (r *DashboardReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// credentialsName returns the name of the config holding the API token of
+// the Dashboards.
+func (r *DashboardReconciler) credentialsName() string {
+	if r.NamespaceCredentials {
+		return "Secret " + namespaceCredentialsName
+	}
+	return instanaConfigName
+}
+
+// namespaceCredentials replaces the API token of the tenant config with the
+// one of the credentials Secret in the namespace, which may also override the
+// instana-base-url. The Secret is read bypassing the cache, so the operator
+// only needs get access to it. On errors the returned config has no token.
+func (r *DashboardReconciler) namespaceCredentials(ctx context.Context, namespace string, instanaApi InstanaApi) (InstanaApi, error) {
+	return loadNamespaceCredentials(ctx, r.apiReader(), namespace, instanaApi)
+}
+
+// loadNamespaceCredentials replaces the API token and base url of the tenant
+// config with the ones of the credentials Secret in the namespace.
+func loadNamespaceCredentials(ctx context.Context, c client.Reader, namespace string, instanaApi InstanaApi) (InstanaApi, error) {
+	instanaApi.ApiToken = ""
+	var secret corev1.Secret
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: namespaceCredentialsName}, &secret); err != nil {
+		return instanaApi, fmt.Errorf("unable to load credentials secret %s: %w", namespaceCredentialsName, err)
+	}
+	token := string(secret.Data["instana-api-token"])
+	if token == "" {
+		return instanaApi, fmt.Errorf("credentials secret %s has no instana-api-token", namespaceCredentialsName)
+	}
+	knownSecrets.add(token)
+	instanaApi.ApiToken = token
+	instanaApi.Vault = nil
+	if baseUrl := string(secret.Data["instana-base-url"]); baseUrl != "" {
+		instanaApi.BaseUrl = baseUrl
+	}
+	return instanaApi, nil
+}
+
+// reloadCredentials reads the credentials of a dashboard again, bypassing the
+// cache.
+func (r *DashboardReconciler) reloadCredentials(ctx context.Context, dashboard *customv1.Dashboard) (InstanaApi, error) {
+	_, instanaApi := loadInstanaConfig(ctx, r.apiReader())
+	if !r.NamespaceCredentials {
+		return instanaApi, nil
+	}
+	return r.namespaceCredentials(ctx, dashboard.Namespace, instanaApi)
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestNamespaceCredentials(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	config := &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop"}`)}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": "https://shared.instana.io", "instana-api-token": "shared-token"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: namespaceCredentialsName},
+			Data:       map[string][]byte{"instana-api-token": []byte("team-a-token")},
+		},
+		&customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"}, Spec: customv1.DashboardSpec{Config: config}},
+		&customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "shop"}, Spec: customv1.DashboardSpec{Config: config}},
+	).Build()
+	instana := newFakeInstanaClient()
+	var used []InstanaApi
+	r := &DashboardReconciler{
+		Client:               c,
+		Log:                  ctrl.Log.WithName("test"),
+		Scheme:               scheme,
+		Recorder:             record.NewFakeRecorder(100),
+		IdStore:              noopIdStore{},
+		NamespaceCredentials: true,
+		NewInstanaClient: func(api InstanaApi) InstanaClient {
+			used = append(used, api)
+			return instana
+		},
+	}
+
+	key := client.ObjectKey{Namespace: "team-a", Name: "shop"}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatal(err)
+	}
+	if len(used) == 0 || used[len(used)-1].ApiToken != "team-a-token" || used[len(used)-1].BaseUrl != "https://shared.instana.io" {
+		t.Errorf("synced with %+v, want the token of the namespace", used)
+	}
+
+	// namespaces without credentials are not synced
+	key = client.ObjectKey{Namespace: "team-b", Name: "shop"}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err == nil {
+		t.Error("expected an error for a namespace without credentials")
+	}
+	var dashboard customv1.Dashboard
+	if err := c.Get(ctx, key, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	if meta.IsStatusConditionTrue(dashboard.Status.Conditions, customv1.ConditionReady) || dashboard.Status.DashboardId != "" {
+		t.Errorf("status = %+v, want a dashboard which is not ready", dashboard.Status)
+	}
+}
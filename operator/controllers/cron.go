@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// parseCronSchedule parses a standard cron expression like "*/15 * * * *",
+// the shortcuts @hourly, @daily, @weekly, @monthly and @yearly, or
+// "@every <duration>". Times are in UTC unless the expression starts with
+// CRON_TZ=<time zone>.
+func parseCronSchedule(expr string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+	}
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok && every.Delay < time.Minute {
+		return nil, fmt.Errorf("invalid schedule %q: @every needs a duration of at least 1m", expr)
+	}
+	if schedule.Next(time.Now().UTC()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never matches", expr)
+	}
+	return schedule, nil
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronSchedule(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 7, 30, 0, time.UTC) // a Monday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2021, 3, 1, 10, 15, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"30 2 * * SUN", time.Date(2021, 3, 7, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2021, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// restricted day of month and day of week match either
+		{"0 0 20 * 3", time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", now.Add(90 * time.Minute)},
+	}
+	for _, tt := range tests {
+		schedule, err := parseCronSchedule(tt.expr)
+		if err != nil {
+			t.Errorf("parseCronSchedule(%q) = %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.Next(now); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a * * * *", "0 0 31 2 *", "@every 10s"} {
+		if _, err := parseCronSchedule(expr); err == nil {
+			t.Errorf("parseCronSchedule(%q) = nil, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=customeventspecifications,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=customeventspecifications/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=customeventspecifications/finalizers,verbs=update
+
+// customEventSpecificationKind syncs CustomEventSpecifications with the
+// custom event specifications of the Events settings API.
+var customEventSpecificationKind = InstanaResourceKind{
+	Kind:      "CustomEventSpecification",
+	New:       func() InstanaResource { return &customv1.CustomEventSpecification{} },
+	Path:      "/api/events/settings/event-specifications/custom",
+	ClientIds: true,
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		spec := obj.(*customv1.CustomEventSpecification).Spec
+		value, err := jsonValue(spec.Rule, nil)
+		if err != nil {
+			return nil, err
+		}
+		rule, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, stalledError{fmt.Errorf("rule must be an object")}
+		}
+		rule["severity"] = severities[spec.Severity]
+		payload := map[string]interface{}{
+			"name":        spec.Name,
+			"description": spec.Description,
+			"entityType":  spec.EntityType,
+			"triggering":  spec.Triggering,
+			"enabled":     !spec.Disabled,
+			"rules":       []interface{}{rule},
+		}
+		if spec.Query != "" {
+			payload["query"] = spec.Query
+		}
+		if spec.ExpirationTime != nil {
+			payload["expirationTime"] = spec.ExpirationTime.Milliseconds()
+		}
+		return payload, nil
+	},
+}
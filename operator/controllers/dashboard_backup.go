@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+const (
+	snapshotTimeFormat     = "20060102T150405Z"
+	defaultBackupRetention = 30 * 24 * time.Hour
+)
+
+// BackupSnapshot is the live config of a managed dashboard at a point in time.
+type BackupSnapshot struct {
+	Tenant      string `json:"tenant"`
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	DashboardId string `json:"dashboard-id"`
+	// Cluster or Variant of spec.clusters or spec.variants the dashboard is
+	// synced for, if any.
+	Cluster string          `json:"cluster,omitempty"`
+	Variant string          `json:"variant,omitempty"`
+	Taken   time.Time       `json:"taken"`
+	Config  json.RawMessage `json:"config"`
+}
+
+// snapshotKey returns "<tenant>/<namespace>/<name>/<timestamp>.json". The
+// snapshots of the dashboards of spec.clusters and spec.variants are below
+// "<name>/clusters/<cluster>" and "<name>/variants/<variant>".
+func snapshotKey(tenant string, namespace string, name string, taken time.Time) string {
+	return path.Join(tenant, namespace, name, taken.UTC().Format(snapshotTimeFormat)+".json")
+}
+
+// snapshotTime parses the timestamp of a snapshot key.
+func snapshotTime(key string) (time.Time, bool) {
+	t, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(path.Base(key), ".json"))
+	return t, err == nil
+}
+
+// DashboardBackup periodically stores the live config of every managed
+// dashboard in a BackupStore, so accidentally deleted or corrupted dashboards
+// can be recovered. The dashboards of spec.clusters and spec.variants are
+// stored as well, and dashboards replicated to a mirror tenant are stored for
+// both tenants.
+//
+// Snapshots are kept for the duration of the "backup-retention" key of the
+// tenant config map (default 720h). The latest snapshot of a dashboard is
+// never deleted.
+type DashboardBackup struct {
+	client.Client
+	Log      logr.Logger
+	Store    BackupStore
+	Interval time.Duration
+	// Shard limits the backup to dashboards of namespaces of this shard.
+	Shard Shard
+	// Instana creates the clients of the tenants, sharing their rate limit
+	// and circuit breaker with the reconcilers.
+	Instana *InstanaClients
+	// NamespaceCredentials reads the dashboards of the default tenant with
+	// the credentials Secret of their namespace, as with
+	// DashboardReconciler.NamespaceCredentials.
+	NamespaceCredentials bool
+	// APIReader reads the credentials Secrets bypassing the cache.
+	APIReader client.Reader
+}
+
+// Start takes a backup right away and then in every interval until the
+// context is cancelled.
+func (b *DashboardBackup) Start(ctx context.Context) error {
+	b.backup(ctx, time.Now())
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			b.backup(ctx, time.Now())
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leading manager takes backups.
+func (b *DashboardBackup) NeedLeaderElection() bool {
+	return true
+}
+
+type backupTenant struct {
+	config *corev1.ConfigMap
+	api    InstanaApi
+	failed bool
+	// namespaces are the configs of the namespaces with NamespaceCredentials,
+	// nil if the credentials can't be loaded.
+	namespaces map[string]*InstanaApi
+}
+
+// backupDashboard is one of the dashboards of a Dashboard in a tenant.
+type backupDashboard struct {
+	id      string
+	cluster string
+	variant string
+}
+
+// backupDashboards returns the dashboards of the status of a Dashboard in the
+// default tenant.
+func backupDashboards(dashboard customv1.Dashboard) []backupDashboard {
+	var dashboards []backupDashboard
+	if dashboard.Status.DashboardId != "" {
+		dashboards = append(dashboards, backupDashboard{id: dashboard.Status.DashboardId})
+	}
+	for _, cluster := range dashboard.Status.Clusters {
+		if cluster.DashboardId != "" {
+			dashboards = append(dashboards, backupDashboard{id: cluster.DashboardId, cluster: cluster.Cluster})
+		}
+	}
+	for _, variant := range dashboard.Status.Variants {
+		if variant.DashboardId != "" {
+			dashboards = append(dashboards, backupDashboard{id: variant.DashboardId, variant: variant.Name})
+		}
+	}
+	return dashboards
+}
+
+// name returns the name of the snapshots of the dashboard.
+func (d backupDashboard) name(dashboard customv1.Dashboard) string {
+	switch {
+	case d.cluster != "":
+		return path.Join(dashboard.Name, "clusters", d.cluster)
+	case d.variant != "":
+		return path.Join(dashboard.Name, "variants", d.variant)
+	}
+	return dashboard.Name
+}
+
+func (b *DashboardBackup) backup(ctx context.Context, now time.Time) {
+	var dashboards customv1.DashboardList
+	if err := b.List(ctx, &dashboards); err != nil {
+		b.Log.Error(err, "unable to list dashboards")
+		return
+	}
+	tenants := map[string]*backupTenant{}
+	for _, dashboard := range dashboards.Items {
+		if !b.Shard.OwnsDashboard(&dashboard) {
+			continue
+		}
+		if dashboards := backupDashboards(dashboard); len(dashboards) > 0 {
+			tenant := b.tenant(ctx, tenants, instanaConfigName)
+			if api := b.dashboardApi(ctx, tenant, dashboard.Namespace); api != nil {
+				for _, d := range dashboards {
+					b.snapshot(ctx, tenant, *api, instanaConfigName, dashboard, d, now)
+				}
+			}
+		}
+		if dashboard.Status.MirrorTenant != "" && dashboard.Status.MirrorDashboardId != "" {
+			tenant := b.tenant(ctx, tenants, dashboard.Status.MirrorTenant)
+			b.snapshot(ctx, tenant, tenant.api, dashboard.Status.MirrorTenant, dashboard, backupDashboard{id: dashboard.Status.MirrorDashboardId}, now)
+		}
+	}
+	for name, tenant := range tenants {
+		log := b.Log.WithValues("tenant", name)
+		if tenant.failed {
+			continue
+		}
+		backupLastSuccess.WithLabelValues(name).Set(float64(now.Unix()))
+		retention := defaultBackupRetention
+		if value := tenant.config.Data["backup-retention"]; value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				log.Error(err, "invalid backup-retention, keeping all snapshots")
+				continue
+			}
+			retention = d
+		}
+		if err := b.prune(ctx, name, now.Add(-retention), log); err != nil {
+			log.Error(err, "unable to delete expired snapshots")
+		}
+	}
+}
+
+func (b *DashboardBackup) tenant(ctx context.Context, tenants map[string]*backupTenant, name string) *backupTenant {
+	if tenant, ok := tenants[name]; ok {
+		return tenant
+	}
+	cm := &corev1.ConfigMap{}
+	if err := b.Get(ctx, client.ObjectKey{Namespace: instanaConfigNamespace, Name: name}, cm); err != nil {
+		b.Log.Error(err, "unable to load tenant config", "tenant", name)
+	}
+	tenant := &backupTenant{config: cm, api: instanaApiFromConfigMap(cm)}
+	tenants[name] = tenant
+	return tenant
+}
+
+// dashboardApi returns the config the dashboards of a namespace are read
+// with from the default tenant: with NamespaceCredentials the one of the
+// credentials Secret of the namespace. It returns nil if the credentials
+// can't be loaded, which fails the backup of the tenant.
+func (b *DashboardBackup) dashboardApi(ctx context.Context, tenant *backupTenant, namespace string) *InstanaApi {
+	if !b.NamespaceCredentials {
+		return &tenant.api
+	}
+	if api, ok := tenant.namespaces[namespace]; ok {
+		return api
+	}
+	if tenant.namespaces == nil {
+		tenant.namespaces = map[string]*InstanaApi{}
+	}
+	reader := b.APIReader
+	if reader == nil {
+		reader = b.Client
+	}
+	api, err := loadNamespaceCredentials(ctx, reader, namespace, tenant.api)
+	if err != nil {
+		b.Log.Error(err, "unable to load the credentials of the namespace, skipping its dashboards", "namespace", namespace)
+		tenant.failed = true
+		tenant.namespaces[namespace] = nil
+		return nil
+	}
+	tenant.namespaces[namespace] = &api
+	return &api
+}
+
+func (b *DashboardBackup) snapshot(ctx context.Context, tenant *backupTenant, api InstanaApi, tenantName string, dashboard customv1.Dashboard, d backupDashboard, now time.Time) {
+	log := b.Log.WithValues("tenant", tenantName, "dashboard", client.ObjectKeyFromObject(&dashboard))
+	config, err := b.Instana.Client(ctx, api).getDashboard(d.id, log)
+	if err != nil {
+		log.Error(err, "unable to read dashboard for backup", "id", d.id)
+		tenant.failed = true
+		return
+	}
+	data, err := json.Marshal(BackupSnapshot{
+		Tenant:      tenantName,
+		Namespace:   dashboard.Namespace,
+		Name:        dashboard.Name,
+		DashboardId: d.id,
+		Cluster:     d.cluster,
+		Variant:     d.variant,
+		Taken:       now.UTC(),
+		Config:      config,
+	})
+	if err == nil {
+		err = b.Store.Put(ctx, snapshotKey(tenantName, dashboard.Namespace, d.name(dashboard), now), data)
+	}
+	if err != nil {
+		log.Error(err, "unable to store dashboard backup", "id", d.id)
+		tenant.failed = true
+	}
+}
+
+// prune deletes the snapshots of the tenant taken before cutoff, except the
+// latest one of each dashboard.
+func (b *DashboardBackup) prune(ctx context.Context, tenant string, cutoff time.Time, log logr.Logger) error {
+	keys, err := b.Store.List(ctx, tenant+"/")
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		// keys are sorted, so the latest snapshot of a dashboard is the last one in its directory
+		if i == len(keys)-1 || path.Dir(keys[i+1]) != path.Dir(key) {
+			continue
+		}
+		if taken, ok := snapshotTime(key); ok && taken.Before(cutoff) {
+			log.Info("Deleting expired dashboard backup", "key", key)
+			if err := b.Store.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/instanatest"
+)
+
+func TestDashboardBackup(t *testing.T) {
+	ctx := context.Background()
+	instana := instanatest.NewServer("token")
+	defer instana.Close()
+	instana.PutDashboard("d1", map[string]interface{}{"title": "One"})
+
+	dir, err := ioutil.TempDir("", "backups-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := DirectoryBackupStore{Dir: dir}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": instana.URL, "instana-api-token": "token", "backup-retention": "48h"},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "one"},
+			Status:     customv1.DashboardStatus{DashboardId: "d1"},
+		},
+		&customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "not-synced"}},
+	).Build()
+	b := &DashboardBackup{Client: c, Log: ctrl.Log.WithName("test"), Store: store}
+
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for day := 0; day < 4; day++ {
+		b.backup(ctx, start.Add(time.Duration(day)*24*time.Hour))
+	}
+
+	keys, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"instana-custom-dashboard-config/team-a/one/20210602T120000Z.json",
+		"instana-custom-dashboard-config/team-a/one/20210603T120000Z.json",
+		"instana-custom-dashboard-config/team-a/one/20210604T120000Z.json",
+	}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("snapshots = %v, want %v", keys, want)
+	}
+	data, err := store.Get(ctx, want[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot BackupSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]interface{}
+	_ = json.Unmarshal(snapshot.Config, &config)
+	if snapshot.DashboardId != "d1" || snapshot.Name != "one" || config["title"] != "One" {
+		t.Errorf("unexpected snapshot %s", data)
+	}
+
+	// the latest snapshot of a dashboard is kept beyond the retention
+	if err := b.prune(ctx, instanaConfigName, start.Add(30*24*time.Hour), b.Log); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := store.List(ctx, ""); fmt.Sprint(keys) != fmt.Sprint(want[2:]) {
+		t.Errorf("snapshots after prune = %v, want %v", keys, want[2:])
+	}
+}
+
+func TestDashboardBackupClustersAndVariants(t *testing.T) {
+	ctx := context.Background()
+	instana := instanatest.NewServer("token")
+	defer instana.Close()
+	for _, id := range []string{"d-eu", "d-us", "d-dark"} {
+		instana.PutDashboard(id, map[string]interface{}{"title": id})
+	}
+	dir, err := ioutil.TempDir("", "backups-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := DirectoryBackupStore{Dir: dir}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": instana.URL, "instana-api-token": "token"},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+			Status: customv1.DashboardStatus{
+				Clusters: []customv1.ClusterDashboardStatus{{Cluster: "eu", DashboardId: "d-eu"}, {Cluster: "us", DashboardId: "d-us"}},
+			},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "themes"},
+			Status: customv1.DashboardStatus{
+				Variants: []customv1.VariantDashboardStatus{{Name: "dark", DashboardId: "d-dark"}},
+			},
+		},
+	).Build()
+	b := &DashboardBackup{Client: c, Log: ctrl.Log.WithName("test"), Store: store}
+
+	b.backup(ctx, time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+	keys, _ := store.List(ctx, "")
+	want := []string{
+		"instana-custom-dashboard-config/team-a/shop/clusters/eu/20210601T120000Z.json",
+		"instana-custom-dashboard-config/team-a/shop/clusters/us/20210601T120000Z.json",
+		"instana-custom-dashboard-config/team-a/themes/variants/dark/20210601T120000Z.json",
+	}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("snapshots = %v, want %v", keys, want)
+	}
+	data, _ := store.Get(ctx, want[0])
+	var snapshot BackupSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil || snapshot.DashboardId != "d-eu" || snapshot.Cluster != "eu" || snapshot.Name != "shop" {
+		t.Errorf("unexpected snapshot %s", data)
+	}
+	// the snapshots of the clusters are not snapshots of the single dashboard
+	if snapshots, err := ListSnapshots(ctx, store, "team-a", "shop"); err != nil || len(snapshots) != 0 {
+		t.Errorf("ListSnapshots() = %v, %v, want none", snapshots, err)
+	}
+}
+
+func TestDashboardBackupNamespaceCredentials(t *testing.T) {
+	ctx := context.Background()
+	instana := instanatest.NewServer("team-a-token")
+	defer instana.Close()
+	instana.PutDashboard("d1", map[string]interface{}{"title": "One"})
+	dir, err := ioutil.TempDir("", "backups-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := DirectoryBackupStore{Dir: dir}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": instana.URL},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "one"},
+			Status:     customv1.DashboardStatus{DashboardId: "d1"},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "two"},
+			Status:     customv1.DashboardStatus{DashboardId: "d2"},
+		},
+	).Build()
+	// the credentials Secret is only readable with the APIReader
+	apiReader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: namespaceCredentialsName},
+		Data:       map[string][]byte{"instana-api-token": []byte("team-a-token")},
+	}).Build()
+	b := &DashboardBackup{Client: c, Log: ctrl.Log.WithName("test"), Store: store, NamespaceCredentials: true, APIReader: apiReader}
+
+	b.backup(ctx, time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC))
+	keys, _ := store.List(ctx, "")
+	want := []string{"instana-custom-dashboard-config/team-a/one/20210601T120000Z.json"}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Errorf("snapshots = %v, want %v", keys, want)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "backups-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := DirectoryBackupStore{Dir: dir}
+	for _, title := range []string{"Old", "New"} {
+		taken := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		if title == "New" {
+			taken = taken.Add(time.Hour)
+		}
+		data, _ := json.Marshal(BackupSnapshot{Config: json.RawMessage(`{"id":"gone","title":"` + title + `","widgets":[]}`)})
+		if err := store.Put(ctx, snapshotKey(instanaConfigName, "team-a", "one", taken), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	dashboard := &customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "one",
+			Annotations: map[string]string{customv1.RestoreSnapshotAnnotation: "20210601T120000Z"}},
+		Spec:   customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Spec"}`)}},
+		Status: customv1.DashboardStatus{DashboardId: "gone", AppliedConfigHash: "hash"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dashboard).Build()
+	instana := newFakeInstanaClient()
+	ids := &countingIdStore{}
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          ids,
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+		BackupStore:      store,
+	}
+	restore := func(snapshot string) customv1.Dashboard {
+		t.Helper()
+		var got customv1.Dashboard
+		if err := c.Get(ctx, client.ObjectKeyFromObject(dashboard), &got); err != nil {
+			t.Fatal(err)
+		}
+		if snapshot != "" {
+			got.Annotations = map[string]string{customv1.RestoreSnapshotAnnotation: snapshot}
+			if err := c.Update(ctx, &got); err != nil {
+				t.Fatal(err)
+			}
+		}
+		instana.calls = nil
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(dashboard)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(dashboard), &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// the deleted dashboard is re-created
+	restore("")
+	if fmt.Sprint(instana.calls) != "[update gone create]" || fmt.Sprint(ids.saved) != "[fake-1]" {
+		t.Errorf("Instana calls = %v, saved ids = %v", instana.calls, ids.saved)
+	}
+	var restored map[string]interface{}
+	_ = json.Unmarshal(instana.dashboards["fake-1"], &restored)
+	if restored["title"] != "Old" {
+		t.Errorf("restored dashboard = %s, want the snapshot 20210601T120000Z", instana.dashboards["fake-1"])
+	}
+	var got customv1.Dashboard
+	if err := c.Get(ctx, client.ObjectKeyFromObject(dashboard), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.DashboardId != "fake-1" || got.Status.AppliedConfigHash != "" {
+		t.Errorf("status = %+v, want it bound to the restored dashboard", got.Status)
+	}
+	if _, ok := got.Annotations[customv1.RestoreSnapshotAnnotation]; ok {
+		t.Error("expected the restore annotation to be removed")
+	}
+
+	// an existing dashboard is updated in place
+	got = restore("latest")
+	if fmt.Sprint(instana.calls) != "[update fake-1]" || got.Status.DashboardId != "fake-1" || len(ids.saved) != 1 {
+		t.Errorf("Instana calls = %v, status = %+v, saved ids = %v", instana.calls, got.Status, ids.saved)
+	}
+	_ = json.Unmarshal(instana.dashboards["fake-1"], &restored)
+	if restored["title"] != "New" {
+		t.Errorf("restored dashboard = %s, want the latest snapshot", instana.dashboards["fake-1"])
+	}
+
+	// the dashboard re-created by an attempt whose status update failed is reused
+	got.Status.DashboardId = "gone"
+	if err := c.Status().Update(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	got = restore("latest")
+	if fmt.Sprint(instana.calls) != "[update gone update fake-1]" || got.Status.DashboardId != "fake-1" || len(instana.dashboards) != 1 {
+		t.Errorf("Instana calls = %v, status = %+v", instana.calls, got.Status)
+	}
+}
+
+func TestDashboardBackupStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir, err := ioutil.TempDir("", "backups-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := DirectoryBackupStore{Dir: dir}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": "https://backup-start.instana.io", "instana-api-token": "token"},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "one"},
+			Status:     customv1.DashboardStatus{DashboardId: "d1"},
+		},
+	).Build()
+	instana := newFakeInstanaClient()
+	instana.dashboards["d1"] = []byte(`{"title":"One"}`)
+	b := &DashboardBackup{
+		Client:   c,
+		Log:      ctrl.Log.WithName("test"),
+		Store:    store,
+		Interval: time.Hour,
+		Instana:  &InstanaClients{NewInstanaClient: func(InstanaApi) InstanaClient { return instana }},
+	}
+	go func() { _ = b.Start(ctx) }()
+
+	// the first backup doesn't wait for the interval
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if keys, _ := store.List(ctx, ""); len(keys) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no backup was taken at the start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDashboardBackupSharesCircuitBreaker(t *testing.T) {
+	const tenant = "https://backup-breaker.instana.io"
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": tenant, "instana-api-token": "token"},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "one"},
+			Status:     customv1.DashboardStatus{DashboardId: "d1"},
+		},
+	).Build()
+	instana := newFakeInstanaClient()
+	instana.dashboards["d1"] = []byte(`{"title":"One"}`)
+	breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Hour}
+	// a reconcile opened the breaker of the tenant
+	breaker.record(tenant, &InstanaApiError{Method: "GET", StatusCode: 503, Status: "503 Service Unavailable"})
+	dir, err := ioutil.TempDir("", "backups-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := &DashboardBackup{
+		Client:  c,
+		Log:     ctrl.Log.WithName("test"),
+		Store:   DirectoryBackupStore{Dir: dir},
+		Instana: &InstanaClients{NewInstanaClient: func(InstanaApi) InstanaClient { return instana }, CircuitBreaker: breaker},
+	}
+	b.backup(ctx, time.Now())
+	if len(instana.calls) != 0 {
+		t.Errorf("Instana calls = %v while the breaker is open", instana.calls)
+	}
+}
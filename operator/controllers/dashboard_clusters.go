@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// deleteSingleDashboard deletes the single dashboard of a Dashboard which was
+// replaced by the dashboards of its clusters or variants.
+func (r *DashboardReconciler) deleteSingleDashboard(ctx context.Context, dashboard *customv1.Dashboard, instanaClient InstanaClient, log logr.Logger) error {
+	id := dashboard.Status.DashboardId
+	if id == "" {
+		return nil
+	}
+	log.Info("Deleting single dashboard replaced by multiple dashboards", "id", id)
+	if err := instanaClient.deleteDashboard(id, log); err != nil && !isInstanaNotFound(err) {
+		return err
+	}
+	dashboard.Status.DashboardId = ""
+	dashboard.Status.DashboardTitle = ""
+	dashboard.Status.DashboardUrl = ""
+	dashboard.Status.AppliedConfigHash = ""
+	if err := r.IdStore.Delete(ctx, client.ObjectKeyFromObject(dashboard)); err != nil {
+		log.Error(err, "unable to delete dashboard id from id store")
+	}
+	return nil
+}
+
+// syncCluster renders the config for the cluster and syncs its dashboard.
+func (r *DashboardReconciler) syncCluster(ctx context.Context, dashboard customv1.Dashboard, instanaApi InstanaApi, cluster string, id string, log logr.Logger) (InstanaApiResponse, error) {
+	vars := r.Variables
+	vars.ClusterName = cluster
+	vars.Instana = r.instanaClient(ctx, instanaApi)
+	dashboard.Spec.Templated = true
+	config, err := renderConfig(ctx, r.renderReader(), vars, dashboard)
+	if err == nil {
+		config, err = qualifyTitle(config, cluster)
+	}
+	if err == nil {
+		config, err = applyTitlePolicy(config, instanaApi)
+	}
+	if err == nil {
+		config, err = injectManagedMarker(config, ManagedMarker{
+			Cluster:   r.ClusterName,
+			Namespace: dashboard.Namespace,
+			Name:      dashboard.Name,
+			UID:       string(dashboard.UID),
+			Tags:      dashboard.Spec.Tags,
+		})
+	}
+	if err != nil && searchFailure(err) == nil {
+		err = stalledError{err}
+	}
+	if err != nil {
+		return InstanaApiResponse{}, err
+	}
+	return syncDashboard(r.instanaClient(ctx, instanaApi), id, config, log)
+}
+
+// qualifyTitle appends the cluster or variant to the title of the config,
+// unless the title already contains it.
+func qualifyTitle(config []byte, qualifier string) ([]byte, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return nil, err
+	}
+	title, _ := payload["title"].(string)
+	if strings.Contains(title, qualifier) {
+		return config, nil
+	}
+	payload["title"] = fmt.Sprintf("%s (%s)", title, qualifier)
+	return json.Marshal(payload)
+}
+
+// deleteClusterDashboards deletes the dashboards of all clusters of the status.
+func deleteClusterDashboards(dashboard *customv1.Dashboard, instanaClient InstanaClient, log logr.Logger) error {
+	for len(dashboard.Status.Clusters) > 0 {
+		status := dashboard.Status.Clusters[0]
+		if status.DashboardId != "" {
+			log.Info("Deleting dashboard of cluster", "cluster", status.Cluster, "id", status.DashboardId)
+			if err := instanaClient.deleteDashboard(status.DashboardId, log); err != nil && !isInstanaNotFound(err) {
+				return err
+			}
+		}
+		dashboard.Status.Clusters = dashboard.Status.Clusters[1:]
+	}
+	dashboard.Status.Clusters = nil
+	return nil
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestReconcileClusters(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	dashboard := &customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec: customv1.DashboardSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(
+				`{"title":"Shop","widgets":[{"id":"w","config":{"value":"{{ .ClusterName }}"}}]}`)},
+			Clusters: []string{"prod-eu", "prod-us"},
+		},
+		Status: customv1.DashboardStatus{DashboardId: "fake-1"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dashboard).Build()
+	instana := newFakeInstanaClient()
+	instana.dashboards["fake-1"] = []byte(`{"title":"Shop"}`)
+	instana.nextId = 1
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	reconcile := func() customv1.Dashboard {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+		var got customv1.Dashboard
+		if err := c.Get(ctx, key, &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// the single dashboard is replaced by one dashboard per cluster
+	got := reconcile()
+	if got.Status.DashboardId != "" || len(got.Status.Clusters) != 2 {
+		t.Fatalf("status = %+v", got.Status)
+	}
+	for _, status := range got.Status.Clusters {
+		config := string(instana.dashboards[status.DashboardId])
+		if !strings.Contains(config, `"title":"Shop (`+status.Cluster+`)"`) || !strings.Contains(config, `"value":"`+status.Cluster+`"`) {
+			t.Errorf("dashboard of cluster %s = %s", status.Cluster, config)
+		}
+	}
+	if fmt.Sprint(instana.calls) != "[create create delete fake-1]" {
+		t.Errorf("Instana calls = %v", instana.calls)
+	}
+
+	// dashboards of removed clusters are deleted
+	got.Spec.Clusters = []string{"prod-us"}
+	if err := c.Update(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	instana.calls = nil
+	got = reconcile()
+	if len(got.Status.Clusters) != 1 || got.Status.Clusters[0].Cluster != "prod-us" || got.Status.Clusters[0].DashboardId != "fake-3" {
+		t.Errorf("status.clusters = %+v", got.Status.Clusters)
+	}
+	if fmt.Sprint(instana.calls) != "[get fake-3 delete fake-2]" {
+		t.Errorf("Instana calls = %v", instana.calls)
+	}
+}
+
+func TestLeftoverClusterDashboardsAfterDryRun(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	// spec.clusters was removed, the dashboards of the clusters are left over
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec: customv1.DashboardSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)},
+			DryRun: true,
+		},
+		Status: customv1.DashboardStatus{Clusters: []customv1.ClusterDashboardStatus{{Cluster: "prod-eu", DashboardId: "c1"}}},
+	}).Build()
+	instana := newFakeInstanaClient()
+	instana.dashboards["c1"] = []byte(`{"title":"Shop (prod-eu)"}`)
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	reconcile := func() customv1.Dashboard {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+		var got customv1.Dashboard
+		if err := c.Get(ctx, key, &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// a dry run keeps the dashboards of the clusters
+	got := reconcile()
+	if len(instana.calls) != 0 || instana.dashboards["c1"] == nil || len(got.Status.Clusters) != 1 {
+		t.Fatalf("dry run deleted the dashboards of the clusters, calls = %v, status = %+v", instana.calls, got.Status)
+	}
+
+	// they are deleted once the dry run is off
+	got.Spec.DryRun = false
+	if err := c.Update(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	got = reconcile()
+	if instana.dashboards["c1"] != nil || len(got.Status.Clusters) != 0 || got.Status.DashboardId == "" {
+		t.Errorf("status = %+v, Instana calls = %v", got.Status, instana.calls)
+	}
+	if len(instana.calls) == 0 || instana.calls[0] != "delete c1" {
+		t.Errorf("Instana calls = %v, want the dashboard of the cluster deleted first", instana.calls)
+	}
+}
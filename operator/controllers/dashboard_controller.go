@@ -18,14 +18,32 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	customv1 "github.com/luebken/custom-dashboards/api/v1"
 )
@@ -33,13 +51,72 @@ import (
 // DashboardReconciler reconciles a Dashboard object
 type DashboardReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// ClusterName is recorded in the managed marker of created dashboards.
+	ClusterName string
+	// IdStore keeps a copy of the dashboard ids outside of the status.
+	IdStore IdStore
+	// DriftCheckInterval is the interval in which dashboards are checked for
+	// changes done in Instana, if their sync policy requires it.
+	DriftCheckInterval time.Duration
+	// RateLimiter controls the backoff of failed syncs. Defaults to the
+	// controller-runtime rate limiter if nil.
+	RateLimiter ratelimiter.RateLimiter
+	// MaxConcurrentReconciles is the number of Dashboards synced in parallel.
+	MaxConcurrentReconciles int
+	// Shard limits the reconciler to the Dashboards in namespaces of this shard.
+	Shard Shard
+	// LoadShedder skips resyncs of unchanged Dashboards while the work queue is too deep.
+	LoadShedder *LoadShedder
+	// Capabilities caches the probed capabilities of self-hosted backends.
+	Capabilities *CapabilityCache
+	// ForceDeleteTimeout is the time after which the finalizer is removed
+	// although the deletion in Instana keeps failing. 0 retries forever.
+	ForceDeleteTimeout time.Duration
+	// APIReader reads the tenant config bypassing the cache.
+	APIReader client.Reader
+	// NewInstanaClient returns the client for a tenant config. Defaults to
+	// the HTTP client of InstanaApi if nil.
+	NewInstanaClient func(InstanaApi) InstanaClient
+	// TenantRateLimiter limits the requests per tenant across all reconciles. Optional.
+	TenantRateLimiter *TenantRateLimiter
+	// CircuitBreaker suspends syncs with tenants which keep failing. Optional.
+	CircuitBreaker *CircuitBreaker
+	// BackupStore holds the snapshots dashboards are restored from. Optional.
+	BackupStore BackupStore
+	// Variables are passed to templated configs.
+	Variables RenderVariables
+	// NamespaceCredentials reads the API token from the Secret
+	// instana-dashboard-credentials in the namespace of each Dashboard
+	// instead of the tenant config.
+	NamespaceCredentials bool
+	// Notifier notifies Degraded and repeatedly failing Dashboards if set.
+	Notifier *Notifier
+	// ListCache shares the dashboard lists of the tenants between reconciles.
+	ListCache *DashboardListCache
+	// DryRun makes the reconciler report the changes it would apply in
+	// Instana instead of applying them, for all Dashboards. Set by --dry-run.
+	DryRun bool
+}
+
+// NewRateLimiter returns a rate limiter for the Dashboard work queue. Failed
+// items are retried with an exponential backoff between baseDelay and
+// maxDelay, overall retries are limited to qps with the given burst.
+func NewRateLimiter(baseDelay time.Duration, maxDelay time.Duration, qps float64, burst int) ratelimiter.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(qps), burst)},
+	)
 }
 
 //+kubebuilder:rbac:groups=custom.instana.io,resources=dashboards,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=custom.instana.io,resources=dashboards/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=custom.instana.io,resources=dashboards/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -47,19 +124,14 @@ type DashboardReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.2/pkg/reconcile
 func (r *DashboardReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("dashboard", req.NamespacedName)
+	requestId := string(uuid.NewUUID())
+	ctx = withRequestId(ctx, requestId)
+	log := r.Log.WithValues("dashboard", req.NamespacedName, "requestId", requestId)
 	log.Info("Reconcile called for: " + req.NamespacedName.Name)
 
 	// Read Instana API Config from ConfigMap
-	cm := &corev1.ConfigMap{}
-	_ = r.Client.Get(context.Background(), client.ObjectKey{
-		Namespace: "default",
-		Name:      "instana-custom-dashboard-config",
-	}, cm)
-	var instanaApi = InstanaApi{
-		ApiToken: cm.Data["instana-api-token"],
-		BaseUrl:  cm.Data["instana-base-url"],
-	}
+	cm, instanaApi := loadInstanaConfig(ctx, r.Client)
+	instanaApi.RequestId = requestId
 	log.Info("Loaded InstanaApiConfig. BaseUrl: " + instanaApi.BaseUrl)
 
 	// Load Dashboard
@@ -69,46 +141,587 @@ func (r *DashboardReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 	log.Info("Loadad resource dashboard: '" + dashboard.Name + "' with ResourceVersion: " + dashboard.ObjectMeta.GetResourceVersion() + ".")
+	ctx = withActor(ctx, &dashboard)
+
+	// Read the API token of the namespace. Deletions go ahead without it and
+	// are retried as failed deletions.
+	if r.NamespaceCredentials {
+		var err error
+		instanaApi, err = r.namespaceCredentials(ctx, dashboard.Namespace, instanaApi)
+		instanaApi.RequestId = requestId
+		if err != nil && dashboard.DeletionTimestamp == nil {
+			log.Error(err, "unable to load namespace credentials")
+			r.Recorder.Event(&dashboard, corev1.EventTypeWarning, "CredentialsMissing", err.Error())
+			setReadyStatus(&dashboard, err)
+			if statusErr := r.Status().Update(ctx, &dashboard); statusErr != nil {
+				log.Error(statusErr, "unable to update dashboard status")
+			}
+			return ctrl.Result{}, err
+		}
+	}
 
 	// Check for deletion
 	finalizerName := "dashboard.custom.instana.io/finalizer"
 	if dashboard.ObjectMeta.DeletionTimestamp != nil {
 		log.Info("Found DeleteTimestamp. ", "DeletionTimestamp", dashboard.ObjectMeta.DeletionTimestamp)
 		log.Info("Found Finalizers. ", "Finalizers", dashboard.ObjectMeta.GetFinalizers())
-		instanaApi.deleteDashboard(dashboard, log)
+		if err := r.deleteRemoteDashboards(ctx, &dashboard, r.instanaClient(ctx, instanaApi), log); err != nil {
+			if !r.forceDeleteDue(dashboard) {
+				log.Error(err, "unable to delete dashboard in Instana. Retrying.")
+				r.Recorder.Event(&dashboard, corev1.EventTypeWarning, "DeleteFailed", err.Error())
+				return ctrl.Result{}, err
+			}
+			log.Error(err, "unable to delete dashboard in Instana. Force delete timeout exceeded, removing finalizer.")
+			r.Recorder.Event(&dashboard, corev1.EventTypeWarning, "ForceDeleted",
+				"Removed finalizer after the deletion in Instana failed for "+r.ForceDeleteTimeout.String()+": "+err.Error())
+		}
+		if err := r.IdStore.Delete(ctx, req.NamespacedName); err != nil {
+			log.Error(err, "unable to delete dashboard id from id store")
+		}
 		controllerutil.RemoveFinalizer(&dashboard, finalizerName)
+		valuesFromSecrets.set(req.NamespacedName, nil)
+		if err := r.Update(ctx, &dashboard); err != nil {
+			log.Error(err, "unable to update dashboard")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Refuse tenants the namespace may not use, in case the webhook is not deployed
+	if err := checkTenantAccess(ctx, r.Client, &dashboard); err != nil {
+		var accessErr tenantAccessError
+		if errors.As(err, &accessErr) {
+			return r.renderFailed(ctx, &dashboard, err, "dashboard may not use tenant", log)
+		}
+		log.Error(err, "unable to check tenant access")
+		return ctrl.Result{}, err
+	}
+
+	// Migrate configs stored as string
+	migrated, err := migrateLegacyConfig(&dashboard)
+	if err != nil {
+		return r.renderFailed(ctx, &dashboard, err, "unable to migrate dashboard config", log)
+	}
+	if migrated {
+		log.Info("Migrating Dashboard config from string to JSON")
 		if err := r.Update(ctx, &dashboard); err != nil {
 			log.Error(err, "unable to update dashboard")
 			return ctrl.Result{}, err
 		}
+		r.Recorder.Event(&dashboard, corev1.EventTypeNormal, "ConfigMigrated", "Migrated spec.config from a string to JSON")
 		return ctrl.Result{}, nil
 	}
-	if dashboard.Status.DashboardId != "" {
-		log.Info("Dashboard Status has a DashboardId: " + dashboard.Status.DashboardId + ". Skipping it.")
+
+	if err := validateTags(dashboard.Spec.Tags); err != nil {
+		return r.renderFailed(ctx, &dashboard, err, "invalid tags", log)
+	}
+
+	if err := validateVariants(&dashboard); err != nil {
+		return r.renderFailed(ctx, &dashboard, err, "invalid variants", log)
+	}
+
+	// Multi-cluster hub or variants: one dashboard per cluster or variant of the spec
+	if len(dashboard.Spec.Clusters) > 0 || len(dashboard.Spec.Variants) > 0 {
+		dashboards := r.clusterDashboards(ctx, &dashboard, instanaApi)
+		if len(dashboard.Spec.Variants) > 0 {
+			dashboards = r.variantDashboards(ctx, &dashboard, instanaApi)
+		}
+		if err := r.reconcileMultiDashboards(ctx, &dashboard, instanaApi, dashboards, log); err != nil {
+			return ctrl.Result{}, err
+		}
+		if _, syncRequested := dashboard.Annotations[customv1.SyncRequestedAnnotation]; !controllerutil.ContainsFinalizer(&dashboard, finalizerName) || syncRequested {
+			controllerutil.AddFinalizer(&dashboard, finalizerName)
+			delete(dashboard.Annotations, customv1.SyncRequestedAnnotation)
+			if err := r.Update(ctx, &dashboard); err != nil {
+				log.Error(err, "unable to update dashboard")
+				return ctrl.Result{}, err
+			}
+		}
 		return ctrl.Result{}, nil
 	}
 
-	// Create Dashboard in Instana and update dashboard CRD
+	if dashboard.Spec.SyncSchedule != "" {
+		if _, err := parseCronSchedule(dashboard.Spec.SyncSchedule); err != nil {
+			return r.renderFailed(ctx, &dashboard, err, "invalid sync schedule", log)
+		}
+	}
+
+	// Render the config and create or update the Dashboard in Instana
 	// TODO sync with actual state in Instana.
-	var apiResponse = instanaApi.createDashboard(dashboard, log)
+	vars := r.Variables
+	vars.Instana = r.instanaClient(ctx, instanaApi)
+	config, err := renderConfig(ctx, r.renderReader(), vars, dashboard)
+	if searchErr := searchFailure(err); searchErr != nil {
+		return r.searchFailed(ctx, &dashboard, instanaApi, searchErr, log)
+	}
+	if err != nil {
+		return r.renderFailed(ctx, &dashboard, err, "unable to render dashboard config", log)
+	}
+	config, err = injectManagedMarker(config, ManagedMarker{
+		Cluster:   r.ClusterName,
+		Namespace: dashboard.Namespace,
+		Name:      dashboard.Name,
+		UID:       string(dashboard.UID),
+		Tags:      dashboard.Spec.Tags,
+	})
+	if err != nil {
+		return r.renderFailed(ctx, &dashboard, err, "unable to add managed marker to dashboard config", log)
+	}
+	// the mirror tenant gets the config without the title policy of the default tenant
+	rendered := config
+	config, err = applyTitlePolicy(config, instanaApi)
+	if err != nil {
+		return r.renderFailed(ctx, &dashboard, err, "unable to apply title policy", log)
+	}
+	deprecations, err := loadWidgetDeprecations(cm.Data["widget-deprecations"])
+	if err != nil {
+		log.Error(err, "unable to load widget deprecations")
+	}
+	setDeprecationStatus(&dashboard, findDeprecatedWidgets(config, deprecations), r.Recorder)
+
+	if dashboard.Status.DashboardId == "" {
+		r.relinkDashboardId(ctx, &dashboard, log)
+	}
+	if dashboard.Status.DashboardId == "" && dashboard.Spec.ExistingDashboardId != "" {
+		if err := r.adoptExistingDashboard(&dashboard, cm, r.instanaClient(ctx, instanaApi), log); err != nil {
+			if errors.As(err, &stalledError{}) {
+				r.Recorder.Event(&dashboard, corev1.EventTypeWarning, "AdoptionRefused", err.Error())
+				setReadyStatus(&dashboard, err)
+				if statusErr := r.Status().Update(ctx, &dashboard); statusErr != nil {
+					log.Error(statusErr, "unable to update dashboard status")
+				}
+				return ctrl.Result{}, nil
+			}
+			log.Error(err, "unable to read the existing dashboard")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Nothing above changes Instana, so dry runs stop here
+	if r.dryRunEnabled(&dashboard) {
+		return r.dryRun(ctx, &dashboard, instanaApi, config, log)
+	}
+	removeStatusCondition(&dashboard.Status.Conditions, customv1.ConditionDryRun)
+
+	// Restore from a backup snapshot
+	if _, ok := dashboard.Annotations[customv1.RestoreSnapshotAnnotation]; ok {
+		previous := dashboard.Status.DashboardId
+		if err := r.restoreSnapshot(ctx, &dashboard, r.instanaClient(ctx, instanaApi), log); err != nil {
+			log.Error(err, "unable to restore dashboard from backup")
+			r.Recorder.Event(&dashboard, corev1.EventTypeWarning, "RestoreFailed", err.Error())
+			return ctrl.Result{}, err
+		}
+		// re-created dashboards are re-linked from the store if the status update fails
+		if dashboard.Status.DashboardId != previous {
+			if err := r.IdStore.Save(ctx, req.NamespacedName, dashboard.Status.DashboardId); err != nil {
+				log.Error(err, "unable to save dashboard id in id store")
+			}
+		}
+		if err := r.Status().Update(ctx, &dashboard); err != nil {
+			log.Error(err, "unable to update dashboard status")
+			return ctrl.Result{}, err
+		}
+		delete(dashboard.Annotations, customv1.RestoreSnapshotAnnotation)
+		if err := r.Update(ctx, &dashboard); err != nil {
+			log.Error(err, "unable to update dashboard")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// Delete the dashboards of the clusters or variants replaced by the single dashboard
+	if len(dashboard.Status.Clusters) > 0 {
+		if err := deleteClusterDashboards(&dashboard, r.instanaClient(ctx, instanaApi), log); err != nil {
+			log.Error(err, "unable to delete cluster dashboards in Instana")
+			return ctrl.Result{}, err
+		}
+	}
+	if len(dashboard.Status.Variants) > 0 {
+		if err := deleteVariantDashboards(&dashboard, r.instanaClient(ctx, instanaApi), log); err != nil {
+			log.Error(err, "unable to delete variant dashboards in Instana")
+			return ctrl.Result{}, err
+		}
+	}
+
+	_, syncRequested := dashboard.Annotations[customv1.SyncRequestedAnnotation]
+	shed := !syncRequested && dashboard.Status.DashboardId != "" && dashboard.Status.AppliedConfigHash == configHash(config) &&
+		dashboard.Status.DashboardUrl == desiredDashboardUrl(&dashboard, instanaApi) && r.LoadShedder.Shedding()
+	if setLoadShedStatus(&dashboard, shed) {
+		if err := r.Status().Update(ctx, &dashboard); err != nil {
+			log.Error(err, "unable to update dashboard status")
+			return ctrl.Result{}, err
+		}
+	}
+	if shed {
+		log.Info("Work queue is too deep. Skipping resync of unchanged dashboard.")
+		return ctrl.Result{RequeueAfter: r.requeueAfter(dashboard)}, nil
+	}
+	if !syncRequested && r.requeueAfter(dashboard) == 0 && configApplied(&dashboard, instanaApi, config) {
+		log.Info("Dashboard config is unchanged since the last sync. Skipping update.")
+		return ctrl.Result{}, nil
+	}
+
+	if dashboard.Spec.SyncPolicy == customv1.SyncPolicyImport {
+		imported, err := r.importDrift(ctx, &dashboard, instanaApi, config, log)
+		if err != nil {
+			log.Error(err, "unable to import changes from Instana")
+			r.Recorder.Event(&dashboard, corev1.EventTypeWarning, "ImportFailed", err.Error())
+			return ctrl.Result{}, err
+		}
+		if imported {
+			return ctrl.Result{}, nil
+		}
+	}
+
+	payload := config
+	if dashboard.Spec.SyncPolicy == customv1.SyncPolicyEnforce {
+		payload = r.enforce(&dashboard, r.instanaClient(ctx, instanaApi), config, log)
+	}
+
+	wasDegraded := meta.IsStatusConditionTrue(dashboard.Status.Conditions, customv1.ConditionDegraded)
+	apiResponse, err := r.syncPrimary(ctx, &dashboard, instanaApi, payload, log)
+	setSyncCondition(&dashboard, customv1.ConditionSynced, err)
+	setSyncAttempt(&dashboard, err)
+	setDegradedStatus(&dashboard, err)
+	r.Notifier.notify(&dashboard, wasDegraded, instanaApi.BaseUrl, log)
+	setReadyStatus(&dashboard, err)
+	if errors.Is(err, ErrCircuitOpen) {
+		log.Info("Instana API keeps failing. Suspending sync.")
+		if statusErr := r.Status().Update(ctx, &dashboard); statusErr != nil {
+			log.Error(statusErr, "unable to update dashboard status")
+		}
+		return ctrl.Result{RequeueAfter: r.CircuitBreaker.RetryAfter(instanaApi.BaseUrl)}, nil
+	}
+	if err != nil {
+		log.Error(err, "unable to sync dashboard with Instana")
+		r.Recorder.Event(&dashboard, corev1.EventTypeWarning, "SyncFailed", err.Error())
+		if statusErr := r.Status().Update(ctx, &dashboard); statusErr != nil {
+			log.Error(statusErr, "unable to update dashboard status")
+		}
+		return ctrl.Result{}, err
+	}
+	idChanged := dashboard.Status.DashboardId != apiResponse.Id
 	dashboard.Status.DashboardId = apiResponse.Id
-	dashboard.Status.DashboardTitle = apiResponse.Title
+	// the title may be rendered from the values of Secrets
+	dashboard.Status.DashboardTitle = knownSecrets.redact(apiResponse.Title)
+	dashboard.Status.DashboardUrl = dashboardUrl(instanaApi, apiResponse.Id) + timeRangeQuery(dashboard.Spec.TimeRange)
+	dashboard.Status.AppliedConfigHash = configHash(config)
+	setReadyStatus(&dashboard, nil)
+	if idChanged {
+		if err := r.IdStore.Save(ctx, req.NamespacedName, apiResponse.Id); err != nil {
+			log.Error(err, "unable to save dashboard id in id store")
+		}
+	}
+	setHotfixStatus(&dashboard, r.Recorder)
+	mirrorErr := r.syncMirror(ctx, &dashboard, rendered, log)
 	log.Info("Updating Dashboard Status CRD with Status.DashboardId: " + dashboard.Status.DashboardId)
 	if err := r.Status().Update(ctx, &dashboard); err != nil {
 		log.Error(err, "unable to update dashboard status")
 		return ctrl.Result{}, err
 	}
-	controllerutil.AddFinalizer(&dashboard, finalizerName)
-	if err := r.Update(ctx, &dashboard); err != nil {
-		log.Error(err, "unable to update dashboard")
-		return ctrl.Result{}, err
+	if !controllerutil.ContainsFinalizer(&dashboard, finalizerName) || syncRequested {
+		controllerutil.AddFinalizer(&dashboard, finalizerName)
+		delete(dashboard.Annotations, customv1.SyncRequestedAnnotation)
+		if err := r.Update(ctx, &dashboard); err != nil {
+			log.Error(err, "unable to update dashboard")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: r.requeueAfter(dashboard)}, mirrorErr
+}
+
+// configApplied reports whether the config was applied to the tenant by the
+// last sync of the current generation, so updating the dashboard in Instana
+// again can be skipped on resyncs. Mirrored dashboards are always synced, as
+// the config of the mirror tenant may have changed.
+func configApplied(dashboard *customv1.Dashboard, instanaApi InstanaApi, config []byte) bool {
+	status := dashboard.Status
+	if status.DashboardId == "" || status.AppliedConfigHash != configHash(config) || status.ObservedGeneration != dashboard.Generation {
+		return false
+	}
+	// a changed base url needs the dashboard to be synced with the new tenant,
+	// a changed time range the url in the status to be updated
+	if status.DashboardUrl != desiredDashboardUrl(dashboard, instanaApi) || dashboard.Spec.MirrorTenant != "" {
+		return false
+	}
+	return meta.IsStatusConditionTrue(status.Conditions, customv1.ConditionSynced)
+}
+
+// desiredDashboardUrl returns the url of the synced dashboard, opening the
+// time range of the spec.
+func desiredDashboardUrl(dashboard *customv1.Dashboard, instanaApi InstanaApi) string {
+	return dashboardUrl(instanaApi, dashboard.Status.DashboardId) + timeRangeQuery(dashboard.Spec.TimeRange)
+}
+
+// requeueAfter returns when a dashboard should be checked for drift again.
+// Dashboards with a sync schedule are synced at its next time.
+func (r *DashboardReconciler) requeueAfter(dashboard customv1.Dashboard) time.Duration {
+	if schedule, err := parseCronSchedule(dashboard.Spec.SyncSchedule); dashboard.Spec.SyncSchedule != "" && err == nil {
+		return time.Until(schedule.Next(time.Now().UTC()))
+	}
+	if dashboard.Spec.SyncPolicy == customv1.SyncPolicyImport || dashboard.Spec.SyncPolicy == customv1.SyncPolicyEnforce {
+		return r.DriftCheckInterval
+	}
+	return 0
+}
+
+// syncPrimary syncs the dashboard with the default tenant. If Instana rejects
+// the credentials they are read again, in case the token was rotated.
+func (r *DashboardReconciler) syncPrimary(ctx context.Context, dashboard *customv1.Dashboard, instanaApi InstanaApi, payload []byte, log logr.Logger) (InstanaApiResponse, error) {
+	if err := r.Capabilities.checkCapabilities(instanaApi, r.instanaClient(ctx, instanaApi), log); err != nil {
+		return InstanaApiResponse{}, err
+	}
+	apiResponse, err := syncDashboard(r.instanaClient(ctx, instanaApi), dashboard.Status.DashboardId, payload, log)
+	if isAuthError(err) && instanaApi.Vault != nil {
+		log.Info("Instana rejected the API token. Retrying with the token read again from Vault.")
+		instanaApi.Vault.Expire()
+		apiResponse, err = syncDashboard(r.instanaClient(ctx, instanaApi), dashboard.Status.DashboardId, payload, log)
+	} else if isAuthError(err) {
+		if reloaded, reloadErr := r.reloadCredentials(ctx, dashboard); reloadErr == nil && reloaded.ApiToken != instanaApi.ApiToken {
+			log.Info("Instana rejected the API token. Retrying with reloaded credentials.")
+			apiResponse, err = syncDashboard(r.instanaClient(ctx, reloaded), dashboard.Status.DashboardId, payload, log)
+		}
+	}
+	setCredentialsStatus(dashboard, err, r.credentialsName(), r.Recorder)
+	return apiResponse, err
+}
+
+// syncDashboard creates the dashboard in Instana if it has no id yet and
+// updates it otherwise. The update is skipped if the live dashboard already
+// matches the config, so its modification time in Instana stays meaningful.
+func syncDashboard(instanaClient InstanaClient, id string, config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	if id == "" {
+		existing, err := findCreatedDashboard(instanaClient, config, log)
+		if err != nil {
+			return InstanaApiResponse{}, err
+		}
+		if existing == "" {
+			return instanaClient.createDashboard(config, log)
+		}
+		log.Info("Found Instana dashboard " + existing + " created for the Dashboard before. Updating it instead of creating a duplicate.")
+		id = existing
+	}
+	if live, err := instanaClient.getDashboard(id, log); err == nil {
+		var response InstanaApiResponse
+		drift, err := configDrift(config, live)
+		if err == nil && len(drift) == 0 && json.Unmarshal(live, &response) == nil {
+			log.Info("Instana dashboard " + id + " is up to date. Skipping update.")
+			response.Id = id
+			return response, nil
+		}
+	}
+	return instanaClient.updateDashboard(id, config, log)
+}
+
+// findCreatedDashboard returns the id of a dashboard of the tenant with the
+// title and managed marker of the config, e.g. created by a sync whose status
+// update failed. The dashboard list is shared by all reconciles, so only
+// dashboards with the same title are read.
+func findCreatedDashboard(instanaClient InstanaClient, config []byte, log logr.Logger) (string, error) {
+	marker, ok := parseManagedMarker(config)
+	if !ok || marker.UID == "" {
+		return "", nil
+	}
+	var payload struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return "", err
+	}
+	list, err := instanaClient.listDashboards(log)
+	if err != nil {
+		return "", err
 	}
-	return ctrl.Result{}, nil
+	for _, d := range list {
+		if d.Title != payload.Title {
+			continue
+		}
+		live, err := instanaClient.getDashboard(d.Id, log)
+		if err != nil {
+			if isInstanaNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		if liveMarker, ok := parseManagedMarker(live); ok && liveMarker.UID == marker.UID &&
+			liveMarker.Cluster == marker.Cluster && liveMarker.Namespace == marker.Namespace && liveMarker.Name == marker.Name {
+			return d.Id, nil
+		}
+	}
+	return "", nil
+}
+
+// setSyncCondition sets the given sync condition according to the result of a sync.
+func setSyncCondition(dashboard *customv1.Dashboard, conditionType string, err error) {
+	condition := metav1.Condition{
+		Type:    conditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Synced",
+		Message: "Dashboard is in sync with Instana",
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "SyncFailed"
+		condition.Message = redactError(err)
+	}
+	meta.SetStatusCondition(&dashboard.Status.Conditions, condition)
+}
+
+// setSyncAttempt records the time and the outcome of a sync with Instana.
+func setSyncAttempt(dashboard *customv1.Dashboard, err error) {
+	now := metav1.Now()
+	dashboard.Status.LastSyncTime = &now
+	if err == nil {
+		dashboard.Status.SyncAttempts = 0
+		dashboard.Status.LastError = ""
+		return
+	}
+	dashboard.Status.SyncAttempts++
+	dashboard.Status.LastError = redactError(err)
+}
+
+// dashboardUrl returns the url of a dashboard in the Instana UI.
+func dashboardUrl(instanaApi InstanaApi, id string) string {
+	if id == "" {
+		return ""
+	}
+	return strings.TrimRight(instanaApi.BaseUrl, "/") + "/#/customDashboards/" + id
+}
+
+// timeRangeQuery returns the query of the dashboard url opening the time
+// range. Instana keeps the time window in the url, not in the dashboard.
+func timeRangeQuery(timeRange *customv1.TimeRange) string {
+	if timeRange == nil {
+		return ""
+	}
+	query := url.Values{}
+	if timeRange.Window != nil && timeRange.Window.Duration > 0 {
+		query.Set("timeline.ws", strconv.FormatInt(timeRange.Window.Milliseconds(), 10))
+	}
+	if timeRange.AutoRefresh != nil {
+		query.Set("timeline.ar", strconv.FormatBool(*timeRange.AutoRefresh))
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+// setDegradedStatus sets the Degraded condition while the circuit breaker of
+// the tenant is open or Vault is unavailable.
+func setDegradedStatus(dashboard *customv1.Dashboard, err error) {
+	reason := "CircuitOpen"
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+	case errors.Is(err, ErrVaultUnavailable):
+		reason = "VaultUnavailable"
+	default:
+		removeStatusCondition(&dashboard.Status.Conditions, customv1.ConditionDegraded)
+		return
+	}
+	meta.SetStatusCondition(&dashboard.Status.Conditions, metav1.Condition{
+		Type:    customv1.ConditionDegraded,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: redactError(err),
+	})
+}
+
+// removeStatusCondition removes a condition. meta.RemoveStatusCondition of
+// apimachinery v0.19 panics on an empty list.
+func removeStatusCondition(conditions *[]metav1.Condition, conditionType string) {
+	if meta.FindStatusCondition(*conditions, conditionType) != nil {
+		meta.RemoveStatusCondition(conditions, conditionType)
+	}
+}
+
+// relinkDashboardId restores a lost dashboard id from the id store, so the
+// existing Instana dashboard is updated instead of creating a duplicate.
+func (r *DashboardReconciler) relinkDashboardId(ctx context.Context, dashboard *customv1.Dashboard, log logr.Logger) {
+	id, err := r.IdStore.Load(ctx, client.ObjectKeyFromObject(dashboard))
+	if err != nil {
+		log.Error(err, "unable to load dashboard id from id store")
+		return
+	}
+	if id == "" {
+		return
+	}
+	log.Info("Re-linking Dashboard with DashboardId from id store: " + id)
+	dashboard.Status.DashboardId = id
+	r.Recorder.Event(dashboard, corev1.EventTypeNormal, "Relinked", "Restored dashboard id "+id+" from the id store")
+}
+
+// setHotfixStatus records the applied hotfix patch in the status. As long as
+// the annotation is present a warning is emitted on every sync.
+func setHotfixStatus(dashboard *customv1.Dashboard, recorder record.EventRecorder) {
+	patch := dashboard.Annotations[customv1.HotfixPatchAnnotation]
+	dashboard.Status.HotfixPatch = patch
+	if patch == "" {
+		removeStatusCondition(&dashboard.Status.Conditions, customv1.ConditionHotfixApplied)
+		return
+	}
+	recorder.Event(dashboard, corev1.EventTypeWarning, "HotfixPatchApplied",
+		"Hotfix patch from annotation "+customv1.HotfixPatchAnnotation+" is applied. Move it into the spec and remove the annotation.")
+	meta.SetStatusCondition(&dashboard.Status.Conditions, metav1.Condition{
+		Type:    customv1.ConditionHotfixApplied,
+		Status:  metav1.ConditionTrue,
+		Reason:  "AnnotationPresent",
+		Message: "Hotfix patch is applied on top of the spec config",
+	})
+}
+
+// setDeprecationStatus reports widgets which use deprecated types or fields
+// while the Instana backend still accepts them. The Warning event is only
+// emitted when the findings change.
+func setDeprecationStatus(dashboard *customv1.Dashboard, findings []string, recorder record.EventRecorder) {
+	if len(findings) == 0 {
+		removeStatusCondition(&dashboard.Status.Conditions, customv1.ConditionDeprecatedWidgets)
+		return
+	}
+	message := strings.Join(findings, "; ")
+	if current := meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionDeprecatedWidgets); current == nil || current.Message != message {
+		recorder.Event(dashboard, corev1.EventTypeWarning, "DeprecatedWidgets", message)
+	}
+	meta.SetStatusCondition(&dashboard.Status.Conditions, metav1.Condition{
+		Type:    customv1.ConditionDeprecatedWidgets,
+		Status:  metav1.ConditionTrue,
+		Reason:  "DeprecatedWidgetsFound",
+		Message: message,
+	})
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *DashboardReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &customv1.Dashboard{}, mirrorTenantIndex, indexMirrorTenant); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &customv1.Dashboard{}, valuesFromIndex, indexValuesFrom); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&customv1.Dashboard{}).
+		For(&customv1.Dashboard{}, builder.WithPredicates(
+			r.Shard.DashboardPredicate(),
+			// Skip the status updates done by the reconciler itself, labels
+			// select the shard
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}, labelChangedPredicate),
+		)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.dashboardsForTenant),
+			builder.WithPredicates(tenantReadyPredicate)).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.dashboardsForConfigMap)).
+		// only the metadata of Secrets is cached, their values are read with the APIReader
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.dashboardsForSecret),
+			builder.OnlyMetadata).
+		Watches(&source.Kind{Type: &customv1.WidgetLibrary{}},
+			handler.EnqueueRequestsFromMapFunc(r.dashboardsForLibrary)).
+		Watches(&source.Kind{Type: &customv1.Dashboard{}},
+			handler.EnqueueRequestsFromMapFunc(r.dashboardsForInclude),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{
+			RateLimiter:             r.RateLimiter,
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		}).
 		Complete(r)
 }
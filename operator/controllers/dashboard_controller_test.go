@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+var _ = Describe("Dashboard controller", func() {
+	const timeout = 10 * time.Second
+	const interval = 250 * time.Millisecond
+
+	It("creates, updates and deletes the dashboard in Instana", func() {
+		ctx := context.Background()
+		key := client.ObjectKey{Namespace: "default", Name: "lifecycle"}
+		dashboard := &customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec: customv1.DashboardSpec{
+				Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Lifecycle","widgets":[]}`)},
+			},
+		}
+		Expect(k8sClient.Create(ctx, dashboard)).To(Succeed())
+
+		By("storing the id of the created dashboard in the status")
+		Eventually(func() string {
+			if err := k8sClient.Get(ctx, key, dashboard); err != nil {
+				return ""
+			}
+			return dashboard.Status.DashboardId
+		}, timeout, interval).ShouldNot(BeEmpty())
+		id := dashboard.Status.DashboardId
+		live, _ := fakeInstana.Dashboard(id)
+		Expect(live).To(HaveKeyWithValue("title", "Lifecycle"))
+
+		By("updating the dashboard in place")
+		Eventually(func() error {
+			if err := k8sClient.Get(ctx, key, dashboard); err != nil {
+				return err
+			}
+			dashboard.Spec.Config = &apiextensionsv1.JSON{Raw: []byte(`{"title":"Renamed","widgets":[]}`)}
+			return k8sClient.Update(ctx, dashboard)
+		}, timeout, interval).Should(Succeed())
+		Eventually(func() interface{} {
+			live, _ := fakeInstana.Dashboard(id)
+			return live["title"]
+		}, timeout, interval).Should(Equal("Renamed"))
+		Expect(fakeInstana.DashboardCount()).To(Equal(1))
+
+		By("deleting the dashboard in Instana with the resource")
+		Expect(k8sClient.Delete(ctx, dashboard)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, dashboard))
+		}, timeout, interval).Should(BeTrue())
+		_, exists := fakeInstana.Dashboard(id)
+		Expect(exists).To(BeFalse())
+	})
+})
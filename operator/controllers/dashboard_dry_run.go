@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// ErrDryRun is returned for requests which would change Instana while
+// InstanaApi.DryRun is set.
+var ErrDryRun = errors.New("dry run")
+
+// dryRunEnabled returns true if the dashboard is not synced with Instana,
+// because of spec.dry-run or --dry-run.
+func (r *DashboardReconciler) dryRunEnabled(dashboard *customv1.Dashboard) bool {
+	return r.DryRun || dashboard.Spec.DryRun
+}
+
+// dryRun reports what a sync of the rendered config would change in Instana
+// without calling any of the mutating endpoints. The config is compared with
+// the live dashboard, so changes done in Instana are reported as well.
+func (r *DashboardReconciler) dryRun(ctx context.Context, dashboard *customv1.Dashboard, instanaApi InstanaApi, config []byte, log logr.Logger) (ctrl.Result, error) {
+	condition := metav1.Condition{Type: customv1.ConditionDryRun, Status: metav1.ConditionTrue}
+	id := dashboard.Status.DashboardId
+	var drift []string
+	if id != "" {
+		live, err := r.instanaClient(ctx, instanaApi).getDashboard(id, log)
+		if isInstanaNotFound(err) {
+			id = ""
+		} else if err != nil {
+			log.Error(err, "unable to read the live dashboard for the dry run")
+			return ctrl.Result{}, err
+		} else if drift, err = configDrift(config, live); err != nil {
+			log.Error(err, "unable to compare the live dashboard for the dry run")
+			return ctrl.Result{}, err
+		}
+	}
+	switch {
+	case id == "":
+		condition.Reason = "WouldCreate"
+		condition.Message = "Would create the dashboard in Instana"
+	case len(drift) == 0:
+		condition.Reason = "UpToDate"
+		condition.Message = "Dashboard " + id + " is up to date"
+	default:
+		condition.Reason = "WouldUpdate"
+		condition.Message = "Would update " + strings.Join(drift, ", ") + " of dashboard " + id + " in Instana"
+	}
+	log.Info("Dry run: " + condition.Message)
+	r.Recorder.Event(dashboard, corev1.EventTypeNormal, "DryRun", condition.Message)
+	meta.SetStatusCondition(&dashboard.Status.Conditions, condition)
+	setReadyStatus(dashboard, nil)
+	if err := r.Status().Update(ctx, dashboard); err != nil {
+		log.Error(err, "unable to update dashboard status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
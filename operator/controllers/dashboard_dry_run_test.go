@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestDryRunDoesNotChangeInstana(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name,
+			Annotations: map[string]string{customv1.RestoreSnapshotAnnotation: "latest"}},
+		Spec: customv1.DashboardSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)},
+			DryRun: true,
+		},
+		// left over from spec.clusters and spec.variants
+		Status: customv1.DashboardStatus{
+			Clusters: []customv1.ClusterDashboardStatus{{Cluster: "prod-eu", DashboardId: "c1"}},
+			Variants: []customv1.VariantDashboardStatus{{Name: "staging", DashboardId: "v1"}},
+		},
+	}).Build()
+	instana := newFakeInstanaClient()
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatal(err)
+	}
+	if len(instana.calls) != 0 {
+		t.Errorf("Instana calls = %v, want none", instana.calls)
+	}
+	var got customv1.Dashboard
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if condition := meta.FindStatusCondition(got.Status.Conditions, customv1.ConditionDryRun); condition == nil || condition.Reason != "WouldCreate" {
+		t.Errorf("DryRun condition = %+v, want WouldCreate", condition)
+	}
+	if fmt.Sprint(got.Status.Clusters, got.Status.Variants) != "[{prod-eu c1 }] [{staging v1 }]" {
+		t.Errorf("status = %+v, want the dashboards of the clusters and variants kept", got.Status)
+	}
+	if _, ok := got.Annotations[customv1.RestoreSnapshotAnnotation]; !ok {
+		t.Error("the restore annotation was removed by a dry run")
+	}
+}
+
+func TestOperatorDryRun(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec:       customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)}},
+	}).Build()
+	instana := newFakeInstanaClient()
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+		DryRun:           true,
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatal(err)
+	}
+	if len(instana.calls) != 0 {
+		t.Errorf("Instana calls = %v, want none", instana.calls)
+	}
+	var got customv1.Dashboard
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(got.Status.Conditions, customv1.ConditionDryRun) {
+		t.Errorf("conditions = %v, want DryRun", got.Status.Conditions)
+	}
+	if ready := meta.FindStatusCondition(got.Status.Conditions, customv1.ConditionReady); ready == nil || ready.Reason != "DryRun" {
+		t.Errorf("Ready condition = %+v, want DryRun", ready)
+	}
+}
+
+func TestDryRunComparesLiveDashboard(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec:       customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)}},
+	}).Build()
+	instana := newFakeInstanaClient()
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatal(err)
+	}
+	var dashboard customv1.Dashboard
+	if err := c.Get(ctx, key, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	id := dashboard.Status.DashboardId
+	// the hash differs, e.g. after an upgrade of the operator, but the live dashboard is the same
+	dashboard.Status.AppliedConfigHash = "stale"
+	if err := c.Status().Update(ctx, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	r.DryRun = true
+	dryRun := func() *metav1.Condition {
+		t.Helper()
+		instana.calls = nil
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(instana.calls) != "[get "+id+"]" {
+			t.Errorf("Instana calls = %v, want only the live dashboard read", instana.calls)
+		}
+		var got customv1.Dashboard
+		if err := c.Get(ctx, key, &got); err != nil {
+			t.Fatal(err)
+		}
+		return meta.FindStatusCondition(got.Status.Conditions, customv1.ConditionDryRun)
+	}
+
+	if condition := dryRun(); condition == nil || condition.Reason != "UpToDate" {
+		t.Errorf("DryRun condition = %+v, want UpToDate", condition)
+	}
+	instana.dashboards[id] = []byte(strings.Replace(string(instana.dashboards[id]), `"title":"Shop"`, `"title":"Shop (edited)"`, 1))
+	if condition := dryRun(); condition == nil || condition.Reason != "WouldUpdate" || !strings.Contains(condition.Message, "title") {
+		t.Errorf("DryRun condition = %+v, want WouldUpdate of the title", condition)
+	}
+}
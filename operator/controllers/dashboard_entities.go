@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"text/template"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// entityKind is the Instana plugin and dynamic focus tag of the name of a
+// Kubernetes workload kind.
+type entityKind struct {
+	plugin string
+	tag    string
+}
+
+var entityKinds = map[string]entityKind{
+	"Deployment":  {plugin: "kubernetesDeployment", tag: "entity.kubernetes.deployment.name"},
+	"StatefulSet": {plugin: "kubernetesStatefulSet", tag: "entity.kubernetes.statefulset.name"},
+	"DaemonSet":   {plugin: "kubernetesDaemonSet", tag: "entity.kubernetes.daemonset.name"},
+	"Pod":         {plugin: "kubernetesPod", tag: "entity.kubernetes.pod.name"},
+	"Service":     {plugin: "kubernetesService", tag: "entity.kubernetes.service.name"},
+}
+
+// entityQuery returns the dynamic focus query matching the workload.
+func entityQuery(kind, name, namespace string) (string, error) {
+	k, ok := entityKinds[kind]
+	if !ok {
+		return "", fmt.Errorf("unsupported entity kind %s", kind)
+	}
+	return fmt.Sprintf("%s:%q AND entity.kubernetes.namespace:%q", k.tag, name, namespace), nil
+}
+
+// entitySearchError is a failed search for the entity of a workload, e.g.
+// because Instana is unavailable. Unlike workloads without or with several
+// entities it is no error of the config, so the render returns it instead of
+// a render error and the sync is retried.
+type entitySearchError struct {
+	error
+}
+
+func (e entitySearchError) Unwrap() error {
+	return e.error
+}
+
+// entityResolver resolves workloads to the snapshot ids of their Instana
+// entities. Every workload is looked up once per render.
+type entityResolver struct {
+	instana   InstanaClient
+	namespace string
+	ids       map[string]string
+}
+
+func newEntityResolver(instana InstanaClient, namespace string) *entityResolver {
+	return &entityResolver{instana: instana, namespace: namespace, ids: map[string]string{}}
+}
+
+// resolve returns the snapshot id of the workload. The namespace defaults to
+// the namespace of the Dashboard. Workloads without or with more than one
+// matching entity are reported as error, failed searches as
+// entitySearchError.
+func (e *entityResolver) resolve(kind, name, namespace string) (string, error) {
+	if namespace == "" {
+		namespace = e.namespace
+	}
+	query, err := entityQuery(kind, name, namespace)
+	if err != nil {
+		return "", err
+	}
+	if id, ok := e.ids[query]; ok {
+		return id, nil
+	}
+	if e.instana == nil {
+		return "", errors.New("entity references can't be resolved without the Instana API")
+	}
+	snapshots, err := e.instana.searchSnapshots(entityKinds[kind].plugin, query, ctrl.Log.WithName("entities"))
+	if err != nil {
+		return "", entitySearchError{err}
+	}
+	switch len(snapshots) {
+	case 0:
+		return "", fmt.Errorf("no Instana entity found for %s %s/%s", kind, namespace, name)
+	case 1:
+		e.ids[query] = snapshots[0].SnapshotId
+		return snapshots[0].SnapshotId, nil
+	default:
+		return "", fmt.Errorf("%s %s/%s matches %d Instana entities", kind, namespace, name, len(snapshots))
+	}
+}
+
+// resolveRefs returns the snapshot ids of the entity references by name.
+func (e *entityResolver) resolveRefs(refs []customv1.EntityRef) (map[string]string, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	ids := map[string]string{}
+	for _, ref := range refs {
+		id, err := e.resolve(ref.Kind, ref.Workload, ref.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("entity reference %s: %w", ref.Name, err)
+		}
+		ids[ref.Name] = id
+	}
+	return ids, nil
+}
+
+// searchFailure returns the entitySearchError of a failed render, without
+// the context of the reference or template, or nil if the render failed
+// otherwise.
+func searchFailure(err error) error {
+	var searchErr entitySearchError
+	if errors.As(err, &searchErr) {
+		return searchErr
+	}
+	return nil
+}
+
+// funcs returns the template functions of templated configs:
+// entity returns the snapshot id and entityQuery the dynamic focus query of
+// a workload, e.g. {{ entity "Deployment" "payments" "prod" }}. The namespace
+// is optional.
+func (e *entityResolver) funcs() template.FuncMap {
+	namespaceOf := func(namespace []string) (string, error) {
+		switch len(namespace) {
+		case 0:
+			return e.namespace, nil
+		case 1:
+			return namespace[0], nil
+		default:
+			return "", errors.New("expected kind, name and an optional namespace")
+		}
+	}
+	return template.FuncMap{
+		"entity": func(kind, name string, namespace ...string) (string, error) {
+			ns, err := namespaceOf(namespace)
+			if err != nil {
+				return "", err
+			}
+			return e.resolve(kind, name, ns)
+		},
+		"entityQuery": func(kind, name string, namespace ...string) (string, error) {
+			ns, err := namespaceOf(namespace)
+			if err != nil {
+				return "", err
+			}
+			return entityQuery(kind, name, ns)
+		},
+	}
+}
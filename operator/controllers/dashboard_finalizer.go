@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// deleteRemoteDashboards deletes the dashboard, the dashboards of its
+// clusters and its mirror in Instana.
+// Dashboards which are already gone in Instana count as deleted.
+func (r *DashboardReconciler) deleteRemoteDashboards(ctx context.Context, dashboard *customv1.Dashboard, instanaClient InstanaClient, log logr.Logger) error {
+	if dashboard.Annotations[customv1.SkipRemoteDeleteAnnotation] == "true" {
+		log.Info("Skipping deletion in Instana as requested by annotation " + customv1.SkipRemoteDeleteAnnotation)
+		return nil
+	}
+	if r.dryRunEnabled(dashboard) {
+		log.Info("Dry run: skipping deletion in Instana")
+		r.Recorder.Event(dashboard, corev1.EventTypeNormal, "DryRun", "Would delete the dashboard in Instana")
+		return nil
+	}
+	if dashboard.Status.DashboardId != "" {
+		if err := instanaClient.deleteDashboard(dashboard.Status.DashboardId, log); err != nil && !isInstanaNotFound(err) {
+			return err
+		}
+	}
+	if err := deleteClusterDashboards(dashboard, instanaClient, log); err != nil {
+		return err
+	}
+	if err := deleteVariantDashboards(dashboard, instanaClient, log); err != nil {
+		return err
+	}
+	if dashboard.Status.MirrorDashboardId != "" {
+		mirrorApi, err := loadTenantConfig(ctx, r.Client, dashboard.Status.MirrorTenant)
+		if err != nil {
+			return err
+		}
+		if err := r.instanaClient(ctx, mirrorApi).deleteDashboard(dashboard.Status.MirrorDashboardId, log); err != nil && !isInstanaNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// forceDeleteDue returns true if the deletion in Instana failed for longer than
+// the force delete timeout, so the finalizer is removed anyway.
+func (r *DashboardReconciler) forceDeleteDue(dashboard customv1.Dashboard) bool {
+	if r.ForceDeleteTimeout <= 0 || dashboard.DeletionTimestamp == nil {
+		return false
+	}
+	return time.Since(dashboard.DeletionTimestamp.Time) > r.ForceDeleteTimeout
+}
+
+func isInstanaNotFound(err error) bool {
+	var apiErr *InstanaApiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestForceDeleteDue(t *testing.T) {
+	deletedAt := func(ago time.Duration) customv1.Dashboard {
+		timestamp := metav1.NewTime(time.Now().Add(-ago))
+		return customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &timestamp}}
+	}
+	for _, tc := range []struct {
+		name      string
+		timeout   time.Duration
+		dashboard customv1.Dashboard
+		want      bool
+	}{
+		{name: "timeout exceeded", timeout: time.Hour, dashboard: deletedAt(2 * time.Hour), want: true},
+		{name: "within timeout", timeout: time.Hour, dashboard: deletedAt(time.Minute), want: false},
+		{name: "disabled", timeout: 0, dashboard: deletedAt(48 * time.Hour), want: false},
+		{name: "not deleted", timeout: time.Hour, dashboard: customv1.Dashboard{}, want: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &DashboardReconciler{ForceDeleteTimeout: tc.timeout}
+			if got := r.forceDeleteDue(tc.dashboard); got != tc.want {
+				t.Errorf("forceDeleteDue() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReconcileForceDeletes(t *testing.T) {
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	for _, tc := range []struct {
+		name          string
+		deletedAgo    time.Duration
+		wantFinalizer bool
+	}{
+		{name: "retries within the timeout", deletedAgo: time.Minute, wantFinalizer: true},
+		{name: "removes the finalizer after the timeout", deletedAgo: 2 * time.Hour, wantFinalizer: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = customv1.AddToScheme(scheme)
+			deleted := metav1.NewTime(time.Now().Add(-tc.deletedAgo))
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:         key.Namespace,
+					Name:              key.Name,
+					DeletionTimestamp: &deleted,
+					Finalizers:        []string{"dashboard.custom.instana.io/finalizer"},
+				},
+				Status: customv1.DashboardStatus{DashboardId: "id-1"},
+			}).Build()
+			instana := newFakeInstanaClient()
+			instana.err = errors.New("connection refused")
+			r := &DashboardReconciler{
+				Client:             c,
+				Log:                ctrl.Log.WithName("test"),
+				Scheme:             scheme,
+				Recorder:           record.NewFakeRecorder(100),
+				IdStore:            noopIdStore{},
+				ForceDeleteTimeout: time.Hour,
+				NewInstanaClient:   func(InstanaApi) InstanaClient { return instana },
+			}
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if tc.wantFinalizer != (err != nil) {
+				t.Errorf("Reconcile() error = %v", err)
+			}
+			var dashboard customv1.Dashboard
+			if err := c.Get(context.Background(), key, &dashboard); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(dashboard.Finalizers) > 0; got != tc.wantFinalizer {
+				t.Errorf("finalizers = %v", dashboard.Finalizers)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+const (
+	gcModeDisabled = "disabled"
+	gcModeDryRun   = "dry-run"
+	gcModeEnabled  = "enabled"
+
+	orphanReportMetric    = "metric"
+	orphanReportConfigMap = "configmap"
+)
+
+// orphanReportName is the ConfigMap listing the orphaned dashboards of the
+// default tenant with orphan-report: configmap. The report of another tenant
+// config map has its name as suffix, the one of the credentials Secret of a
+// namespace is in the namespace.
+const orphanReportName = "instana-custom-dashboard-orphans"
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
+
+// DashboardGarbageCollector periodically deletes Instana dashboards which carry
+// the managed marker of this cluster but have no corresponding Dashboard
+// resource anymore, e.g. because the finalizer was removed by force.
+//
+// Every tenant is swept: the default tenant, or with NamespaceCredentials
+// the tenants of the credentials Secrets of the namespaces, and the other
+// tenant config maps, e.g. of mirror tenants. The credentials Secrets are
+// only read with the APIReader, like by the DashboardReconciler. The garbage collection is
+// opt-in via the "garbage-collection" key of the config map of the tenant,
+// the default config map for namespace credentials: "enabled" deletes
+// orphans, "dry-run" only reports them. Without ClusterName orphans are only
+// reported, as they may belong to another cluster. The
+// "garbage-collection-tags" key limits it to dashboards with all of the comma
+// separated tags.
+//
+// Independent of the garbage collection, the "orphan-report" key reports all
+// orphans: "metric" as instana_dashboards_orphaned, "configmap" in addition
+// in an orphan report ConfigMap.
+type DashboardGarbageCollector struct {
+	client.Client
+	Log         logr.Logger
+	ClusterName string
+	Interval    time.Duration
+	// Shard limits the garbage collection to dashboards of namespaces of this shard.
+	Shard Shard
+	// NamespaceCredentials sweeps the tenants of the credentials Secrets of
+	// the namespaces instead of the default tenant, as with
+	// DashboardReconciler.NamespaceCredentials.
+	NamespaceCredentials bool
+	// APIReader reads the credentials Secrets bypassing the cache.
+	APIReader client.Reader
+	// Instana creates the client of the tenant, so the sweeps share its rate
+	// limit, circuit breaker and dashboard list with the reconcilers.
+	Instana *InstanaClients
+}
+
+// Start runs the sweeper until the context is cancelled.
+func (gc *DashboardGarbageCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(gc.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			gc.sweep(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leading manager sweeps.
+func (gc *DashboardGarbageCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// gcTenant is a tenant swept by the garbage collector.
+type gcTenant struct {
+	// name is the tenant label of the metric: the name of the config map or
+	// namespaceCredentialsTenant.
+	name string
+	// settings holds the garbage collection keys of the tenant.
+	settings map[string]string
+	api      InstanaApi
+	// namespace limits the tenant of a credentials Secret to the dashboards
+	// of its namespace.
+	namespace string
+	// report is the ConfigMap of the orphan report.
+	report client.ObjectKey
+}
+
+// apiReader returns the reader of the credentials Secrets.
+func (gc *DashboardGarbageCollector) apiReader() client.Reader {
+	if gc.APIReader != nil {
+		return gc.APIReader
+	}
+	return gc.Client
+}
+
+func (gc *DashboardGarbageCollector) sweep(ctx context.Context) {
+	tenants, err := gc.tenants(ctx)
+	if err != nil {
+		gc.Log.Error(err, "unable to list the tenants")
+		return
+	}
+	for _, tenant := range tenants {
+		gc.sweepTenant(ctx, tenant)
+	}
+}
+
+// tenants returns the tenants to sweep, the default tenant first.
+func (gc *DashboardGarbageCollector) tenants(ctx context.Context) ([]gcTenant, error) {
+	var tenants []gcTenant
+	cm, instanaApi := loadInstanaConfig(ctx, gc.Client)
+	if gc.NamespaceCredentials {
+		var namespaces corev1.NamespaceList
+		if err := gc.List(ctx, &namespaces); err != nil {
+			return nil, err
+		}
+		for _, namespace := range namespaces.Items {
+			if !gc.Shard.Owns(namespace.Name) {
+				continue
+			}
+			namespaceApi, err := loadNamespaceCredentials(ctx, gc.apiReader(), namespace.Name, instanaApi)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				gc.Log.Error(err, "unable to load the credentials of the namespace, skipping it", "namespace", namespace.Name)
+				continue
+			}
+			tenants = append(tenants, gcTenant{
+				name:      namespaceCredentialsTenant(namespace.Name),
+				settings:  cm.Data,
+				api:       namespaceApi,
+				namespace: namespace.Name,
+				report:    client.ObjectKey{Namespace: namespace.Name, Name: orphanReportName},
+			})
+		}
+	} else if cm.Name != "" {
+		tenants = append(tenants, gcTenant{
+			name:     instanaConfigName,
+			settings: cm.Data,
+			api:      instanaApi,
+			report:   client.ObjectKey{Namespace: instanaConfigNamespace, Name: orphanReportName},
+		})
+	}
+	var configMaps corev1.ConfigMapList
+	if err := gc.List(ctx, &configMaps, client.InNamespace(instanaConfigNamespace)); err != nil {
+		return nil, err
+	}
+	for i := range configMaps.Items {
+		tenant := &configMaps.Items[i]
+		if tenant.Name == instanaConfigName || !tenantReady(tenant) {
+			continue
+		}
+		tenants = append(tenants, gcTenant{
+			name:     tenant.Name,
+			settings: tenant.Data,
+			api:      instanaApiFromConfigMap(tenant),
+			report:   client.ObjectKey{Namespace: instanaConfigNamespace, Name: orphanReportName + "-" + tenant.Name},
+		})
+	}
+	return tenants, nil
+}
+
+func (gc *DashboardGarbageCollector) sweepTenant(ctx context.Context, tenant gcTenant) {
+	mode := tenant.settings["garbage-collection"]
+	report := tenant.settings["orphan-report"]
+	collect := mode == gcModeEnabled || mode == gcModeDryRun
+	reported := report == orphanReportMetric || report == orphanReportConfigMap
+	log := gc.Log.WithValues("tenant", tenant.name)
+	if !reported {
+		// don't keep reporting the orphans of the last sweep with the report
+		orphanedDashboards.DeleteLabelValues(tenant.name)
+	}
+	if !collect && !reported {
+		return
+	}
+	if gc.Instana != nil && gc.Instana.DryRun {
+		mode = gcModeDryRun
+	}
+	if mode == gcModeEnabled && gc.ClusterName == "" {
+		// the dashboards of all clusters without a name carry the same marker
+		log.Info("Warning: not deleting orphaned dashboards without --cluster-name, only reporting them")
+		mode = gcModeDryRun
+	}
+	log = log.WithValues("mode", mode)
+	instanaClient := gc.Instana.Client(ctx, tenant.api)
+	found, err := gc.findOrphans(ctx, instanaClient, log)
+	if err != nil {
+		log.Error(err, "unable to find orphaned dashboards")
+		return
+	}
+	var orphans []orphan
+	for _, o := range found {
+		// the token of a namespace may see the dashboards of other namespaces
+		if tenant.namespace == "" || o.Marker.Namespace == tenant.namespace {
+			orphans = append(orphans, o)
+		}
+	}
+	if reported {
+		orphanedDashboards.WithLabelValues(tenant.name).Set(float64(len(orphans)))
+	}
+	if report == orphanReportConfigMap {
+		if err := gc.writeReport(ctx, tenant.report, orphans, time.Now()); err != nil {
+			log.Error(err, "unable to write the orphan report")
+		}
+	}
+	if !collect {
+		return
+	}
+	tags := splitList(tenant.settings["garbage-collection-tags"])
+	log.Info("Finished garbage collection sweep", "orphans", len(orphans))
+	for _, orphan := range orphans {
+		if !orphan.Marker.HasTags(tags) {
+			continue
+		}
+		if mode == gcModeDryRun {
+			log.Info("Would delete orphaned dashboard", "id", orphan.Id, "title", orphan.Title)
+			continue
+		}
+		if err := instanaClient.deleteDashboard(orphan.Id, log); err != nil {
+			log.Error(err, "unable to delete orphaned dashboard", "id", orphan.Id)
+		}
+	}
+}
+
+// orphan is a dashboard with the managed marker of a Dashboard which does
+// not exist anymore.
+type orphan struct {
+	InstanaApiResponse
+	Marker ManagedMarker
+}
+
+// findOrphans returns the orphaned dashboards of the tenant. Dashboards which
+// can't be read are skipped, so a single failure doesn't stop the sweep.
+func (gc *DashboardGarbageCollector) findOrphans(ctx context.Context, instanaClient InstanaClient, log logr.Logger) ([]orphan, error) {
+	dashboards, err := instanaClient.listDashboards(log)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []orphan
+	for _, d := range dashboards {
+		config, err := instanaClient.getDashboard(d.Id, log)
+		if err != nil {
+			log.Error(err, "unable to read dashboard, skipping it", "id", d.Id)
+			continue
+		}
+		marker, ok := parseManagedMarker(config)
+		if !ok || marker.Cluster != gc.ClusterName || !gc.Shard.Owns(marker.Namespace) {
+			continue
+		}
+		var dashboard customv1.Dashboard
+		err = gc.Get(ctx, client.ObjectKey{Namespace: marker.Namespace, Name: marker.Name}, &dashboard)
+		if apierrors.IsNotFound(err) || (err == nil && string(dashboard.UID) != marker.UID) {
+			orphans = append(orphans, orphan{InstanaApiResponse: d, Marker: marker})
+		} else if err != nil {
+			log.Error(err, "unable to read the Dashboard of the marker, skipping it", "id", d.Id, "namespace", marker.Namespace, "name", marker.Name)
+		}
+	}
+	return orphans, nil
+}
+
+// writeReport lists the orphans of a tenant in its report ConfigMap, so they
+// can be reviewed before enabling the garbage collection or imported again.
+func (gc *DashboardGarbageCollector) writeReport(ctx context.Context, key client.ObjectKey, orphans []orphan, now time.Time) error {
+	type entry struct {
+		Id        string   `json:"id"`
+		Title     string   `json:"title"`
+		Namespace string   `json:"namespace"`
+		Name      string   `json:"name"`
+		Tags      []string `json:"tags,omitempty"`
+	}
+	entries := []entry{}
+	for _, o := range orphans {
+		entries = append(entries, entry{Id: o.Id, Title: o.Title, Namespace: o.Marker.Namespace, Name: o.Marker.Name, Tags: o.Marker.Tags})
+	}
+	data, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+	_, err = controllerutil.CreateOrUpdate(ctx, gc.Client, cm, func() error {
+		cm.Data = map[string]string{
+			"orphans.yaml": string(data),
+			"orphans":      strconv.Itoa(len(orphans)),
+			"last-sweep":   now.UTC().Format(time.RFC3339),
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/instanatest"
+)
+
+// putMarkedDashboard adds a dashboard with the managed marker to Instana.
+func putMarkedDashboard(t *testing.T, instana *instanatest.Server, id string, marker ManagedMarker) {
+	config, err := injectManagedMarker([]byte(`{"title":"`+marker.Name+`","widgets":[]}`), marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dashboard map[string]interface{}
+	_ = json.Unmarshal(config, &dashboard)
+	instana.PutDashboard(id, dashboard)
+}
+
+func TestOrphanReport(t *testing.T) {
+	ctx := context.Background()
+	instana := instanatest.NewServer("token")
+	defer instana.Close()
+	putMarkedDashboard(t, instana, "d1", ManagedMarker{Cluster: "prod", Namespace: "team-a", Name: "kept", UID: "uid-1"})
+	putMarkedDashboard(t, instana, "d2", ManagedMarker{Cluster: "prod", Namespace: "team-a", Name: "deleted", UID: "uid-2"})
+	putMarkedDashboard(t, instana, "d3", ManagedMarker{Cluster: "staging", Namespace: "team-a", Name: "other-cluster", UID: "uid-3"})
+	mirror := instanatest.NewServer("mirror-token")
+	defer mirror.Close()
+	putMarkedDashboard(t, mirror, "m1", ManagedMarker{Cluster: "prod", Namespace: "team-a", Name: "deleted", UID: "uid-2"})
+	putMarkedDashboard(t, mirror, "m2", ManagedMarker{Cluster: "prod", Namespace: "team-a", Name: "gone", UID: "uid-4"})
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": instana.URL, "instana-api-token": "token", "orphan-report": "configmap"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: "mirror"},
+			Data:       map[string]string{"instana-base-url": mirror.URL, "instana-api-token": "mirror-token", "orphan-report": "metric"},
+		},
+		&customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "kept", UID: "uid-1"}},
+	).Build()
+	gc := &DashboardGarbageCollector{Client: c, Log: ctrl.Log.WithName("test"), ClusterName: "prod"}
+
+	gc.sweep(ctx)
+	if instana.DashboardCount() != 3 {
+		t.Error("the report deleted dashboards without garbage-collection: enabled")
+	}
+	for tenant, want := range map[string]float64{instanaConfigName: 1, "mirror": 2} {
+		if n := testutil.ToFloat64(orphanedDashboards.WithLabelValues(tenant)); n != want {
+			t.Errorf("instana_dashboards_orphaned of %s = %v, want %v", tenant, n, want)
+		}
+	}
+	report := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: instanaConfigNamespace, Name: orphanReportName}, report); err != nil {
+		t.Fatal(err)
+	}
+	if orphans := report.Data["orphans.yaml"]; !strings.Contains(orphans, "id: d2") || strings.Contains(orphans, "d1") || strings.Contains(orphans, "d3") {
+		t.Errorf("orphans.yaml = %s, want d2", orphans)
+	}
+	if count := report.Data["orphans"]; count != "1" {
+		t.Errorf("orphans = %q, want 1", count)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: instanaConfigNamespace, Name: orphanReportName + "-mirror"}, report); !apierrors.IsNotFound(err) {
+		t.Errorf("orphan report of the mirror tenant with orphan-report: metric, err = %v", err)
+	}
+
+	mirrorConfig := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: instanaConfigNamespace, Name: "mirror"}, mirrorConfig); err != nil {
+		t.Fatal(err)
+	}
+	delete(mirrorConfig.Data, "orphan-report")
+	if err := c.Update(ctx, mirrorConfig); err != nil {
+		t.Fatal(err)
+	}
+	gc.sweep(ctx)
+	if orphanedDashboards.DeleteLabelValues("mirror") {
+		t.Error("instana_dashboards_orphaned of the mirror tenant is still reported after disabling the report")
+	}
+}
+
+func TestOrphanReportNamespaceCredentials(t *testing.T) {
+	ctx := context.Background()
+	instana := instanatest.NewServer("team-a-token")
+	defer instana.Close()
+	putMarkedDashboard(t, instana, "d1", ManagedMarker{Cluster: "prod", Namespace: "team-a", Name: "deleted", UID: "uid-1"})
+	putMarkedDashboard(t, instana, "d2", ManagedMarker{Cluster: "prod", Namespace: "team-b", Name: "deleted", UID: "uid-2"})
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": instana.URL, "orphan-report": "configmap"},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+	).Build()
+	// the credentials Secret is only readable with the APIReader
+	apiReader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: namespaceCredentialsName},
+		Data:       map[string][]byte{"instana-api-token": []byte("team-a-token")},
+	}).Build()
+	gc := &DashboardGarbageCollector{Client: c, Log: ctrl.Log.WithName("test"), ClusterName: "prod", NamespaceCredentials: true, APIReader: apiReader}
+
+	gc.sweep(ctx)
+	tenant := namespaceCredentialsTenant("team-a")
+	if n := testutil.ToFloat64(orphanedDashboards.WithLabelValues(tenant)); n != 1 {
+		t.Errorf("instana_dashboards_orphaned of %s = %v, want 1", tenant, n)
+	}
+	if orphanedDashboards.DeleteLabelValues(namespaceCredentialsTenant("team-b")) {
+		t.Error("the namespace without credentials Secret was swept")
+	}
+	report := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: orphanReportName}, report); err != nil {
+		t.Fatal(err)
+	}
+	if orphans := report.Data["orphans.yaml"]; !strings.Contains(orphans, "id: d1") || strings.Contains(orphans, "d2") {
+		t.Errorf("orphans.yaml = %s, want only the orphan of team-a", orphans)
+	}
+	if count := report.Data["orphans"]; count != "1" {
+		t.Errorf("orphans = %q, want 1", count)
+	}
+}
+
+// brokenDashboardClient fails to read one dashboard.
+type brokenDashboardClient struct {
+	*fakeInstanaClient
+	broken string
+}
+
+func (c *brokenDashboardClient) getDashboard(id string, log logr.Logger) ([]byte, error) {
+	if id == c.broken {
+		return nil, &InstanaApiError{Method: "GET", StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"}
+	}
+	return c.fakeInstanaClient.getDashboard(id, log)
+}
+
+func TestFindOrphans(t *testing.T) {
+	instana := newFakeInstanaClient()
+	for id, marker := range map[string]ManagedMarker{
+		"d1": {Cluster: "prod", Namespace: "team-a", Name: "kept", UID: "uid-1"},
+		"d2": {Cluster: "prod", Namespace: "team-a", Name: "deleted", UID: "uid-2"},
+		"d3": {Cluster: "prod", Namespace: "team-a", Name: "broken", UID: "uid-3"},
+		"d4": {Cluster: "prod", Namespace: "team-a", Name: "kept", UID: "uid-old"},
+	} {
+		config, err := injectManagedMarker([]byte(`{"title":"`+marker.Name+`","widgets":[]}`), marker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		instana.dashboards[id] = config
+	}
+	instana.dashboards["unmanaged"] = []byte(`{"title":"Unmanaged","widgets":[]}`)
+	scheme := runtime.NewScheme()
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "kept", UID: "uid-1"}},
+	).Build()
+	gc := &DashboardGarbageCollector{Client: c, Log: ctrl.Log.WithName("test"), ClusterName: "prod"}
+
+	orphans, err := gc.findOrphans(context.Background(), &brokenDashboardClient{fakeInstanaClient: instana, broken: "d3"}, gc.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, o := range orphans {
+		ids = append(ids, o.Id)
+	}
+	sort.Strings(ids)
+	if fmt.Sprint(ids) != "[d2 d4]" {
+		t.Errorf("orphans = %v, want the deleted and the recreated Dashboard", ids)
+	}
+}
+
+func TestGarbageCollection(t *testing.T) {
+	for _, mode := range []string{gcModeDryRun, gcModeEnabled} {
+		t.Run(mode, func(t *testing.T) {
+			instana := instanatest.NewServer("token")
+			defer instana.Close()
+			for id, marker := range map[string]ManagedMarker{
+				"d1": {Cluster: "prod", Namespace: "team-a", Name: "kept", UID: "uid-1"},
+				"d2": {Cluster: "prod", Namespace: "team-a", Name: "deleted", UID: "uid-2", Tags: []string{"team:a"}},
+				"d3": {Cluster: "prod", Namespace: "team-a", Name: "untagged", UID: "uid-3"},
+			} {
+				putMarkedDashboard(t, instana, id, marker)
+			}
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = customv1.AddToScheme(scheme)
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+					Data: map[string]string{"instana-base-url": instana.URL, "instana-api-token": "token",
+						"garbage-collection": mode, "garbage-collection-tags": "team:a"},
+				},
+				&customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "kept", UID: "uid-1"}},
+			).Build()
+			gc := &DashboardGarbageCollector{Client: c, Log: ctrl.Log.WithName("test"), ClusterName: "prod"}
+
+			gc.sweep(context.Background())
+			_, deleted := instana.Dashboard("d2")
+			deleted = !deleted
+			if deleted != (mode == gcModeEnabled) {
+				t.Errorf("orphan d2 deleted = %v in mode %s", deleted, mode)
+			}
+			if _, ok := instana.Dashboard("d3"); !ok {
+				t.Error("orphans without the tags of garbage-collection-tags must be kept")
+			}
+			if _, ok := instana.Dashboard("d1"); !ok {
+				t.Error("dashboards of existing resources must be kept")
+			}
+		})
+	}
+}
+
+func TestGarbageCollectionWithoutClusterName(t *testing.T) {
+	instana := instanatest.NewServer("token")
+	defer instana.Close()
+	// the dashboard of another cluster without --cluster-name
+	putMarkedDashboard(t, instana, "d1", ManagedMarker{Namespace: "team-a", Name: "elsewhere", UID: "uid-1"})
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data: map[string]string{"instana-base-url": instana.URL, "instana-api-token": "token",
+				"garbage-collection": gcModeEnabled, "orphan-report": orphanReportMetric},
+		},
+	).Build()
+	gc := &DashboardGarbageCollector{Client: c, Log: ctrl.Log.WithName("test")}
+
+	gc.sweep(context.Background())
+	if _, ok := instana.Dashboard("d1"); !ok {
+		t.Error("an orphan was deleted without --cluster-name")
+	}
+	if n := testutil.ToFloat64(orphanedDashboards.WithLabelValues(instanaConfigName)); n != 1 {
+		t.Errorf("instana_dashboards_orphaned = %v, want the orphan reported", n)
+	}
+}
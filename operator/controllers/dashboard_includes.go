@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// includeWidgets adds the widgets of the resources of spec.includes as blocks
+// below the widgets of the config. Each block keeps its own layout and the ids
+// of its widgets are prefixed with the name of the include. Included
+// Dashboards bring their spec.widgets-from and spec.includes along.
+func includeWidgets(ctx context.Context, c client.Reader, dashboard customv1.Dashboard, config []byte) ([]byte, error) {
+	return includeWidgetsOf(ctx, c, dashboard, config, map[string]bool{dashboard.Name: true})
+}
+
+// includeWidgetsOf includes the widgets of the dashboard, which is included by
+// the Dashboards of including itself.
+func includeWidgetsOf(ctx context.Context, c client.Reader, dashboard customv1.Dashboard, config []byte, including map[string]bool) ([]byte, error) {
+	if len(dashboard.Spec.Includes) == 0 {
+		return config, nil
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return nil, err
+	}
+	widgets, _ := payload["widgets"].([]interface{})
+	ids := map[interface{}]bool{}
+	bottom := 0.0
+	for _, w := range widgets {
+		if widget, ok := w.(map[string]interface{}); ok {
+			ids[widget["id"]] = true
+			bottom = math.Max(bottom, widgetBottom(widget))
+		}
+	}
+
+	for _, include := range dashboard.Spec.Includes {
+		block, err := includedWidgets(ctx, c, dashboard.Namespace, include, including)
+		if err != nil {
+			return nil, err
+		}
+		if len(block) == 0 {
+			continue
+		}
+		top := math.Inf(1)
+		for _, widget := range block {
+			y, _ := widget["y"].(float64)
+			top = math.Min(top, y)
+		}
+		for _, widget := range block {
+			if id, ok := widget["id"]; ok {
+				widget["id"] = fmt.Sprintf("%s-%v", include.Name, id)
+				if ids[widget["id"]] {
+					return nil, fmt.Errorf("widget %v of include %s has the id of another widget", id, include.Name)
+				}
+				ids[widget["id"]] = true
+			}
+			y, _ := widget["y"].(float64)
+			widget["y"] = y - top + bottom
+		}
+		for _, widget := range block {
+			bottom = math.Max(bottom, widgetBottom(widget))
+			widgets = append(widgets, widget)
+		}
+	}
+	payload["widgets"] = widgets
+	return json.Marshal(payload)
+}
+
+// includedWidgets returns the widgets of the include, with the widgets
+// without a position placed below the widgets before them.
+func includedWidgets(ctx context.Context, c client.Reader, namespace string, include customv1.DashboardInclude, including map[string]bool) ([]map[string]interface{}, error) {
+	var widgets []map[string]interface{}
+	switch include.Kind {
+	case "", "Dashboard":
+		if including[include.Name] {
+			return nil, fmt.Errorf("dashboard %s includes itself", include.Name)
+		}
+		var included customv1.Dashboard
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: include.Name}, &included); err != nil {
+			return nil, fmt.Errorf("unable to load included dashboard %s: %w", include.Name, err)
+		}
+		config, err := dashboardConfig(ctx, c, included)
+		if err == nil {
+			config, err = composeWidgets(ctx, c, included, config)
+		}
+		if err == nil {
+			including[include.Name] = true
+			config, err = includeWidgetsOf(ctx, c, included, config, including)
+			delete(including, include.Name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("included dashboard %s: %w", include.Name, err)
+		}
+		var payload struct {
+			Widgets []map[string]interface{} `json:"widgets"`
+		}
+		if err := json.Unmarshal(config, &payload); err != nil {
+			return nil, fmt.Errorf("included dashboard %s: %w", include.Name, err)
+		}
+		widgets = payload.Widgets
+	case "WidgetLibrary":
+		var library customv1.WidgetLibrary
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: include.Name}, &library); err != nil {
+			return nil, fmt.Errorf("unable to load widget library %s: %w", include.Name, err)
+		}
+		params := map[string]string{}
+		for k, v := range library.Spec.Params {
+			params[k] = v
+		}
+		for k, v := range include.Params {
+			params[k] = v
+		}
+		for _, w := range library.Spec.Widgets {
+			widget, err := libraryWidget(library, w.Name, params)
+			if err != nil {
+				return nil, err
+			}
+			widgets = append(widgets, widget)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kind %s of include %s", include.Kind, include.Name)
+	}
+
+	bottom := 0.0
+	for _, widget := range widgets {
+		if _, ok := widget["y"]; !ok {
+			widget["x"] = 0
+			widget["y"] = bottom
+		}
+		bottom = math.Max(bottom, widgetBottom(widget))
+	}
+	return widgets, nil
+}
+
+// includes reports whether the dashboard includes the resource of the kind.
+func includes(dashboard customv1.Dashboard, kind, name string) bool {
+	for _, include := range dashboard.Spec.Includes {
+		if include.Name == name && (include.Kind == kind || include.Kind == "" && kind == "Dashboard") {
+			return true
+		}
+	}
+	return false
+}
+
+// dashboardsForInclude maps a Dashboard to the Dashboards including it,
+// directly or through other included Dashboards.
+func (r *DashboardReconciler) dashboardsForInclude(obj client.Object) []reconcile.Request {
+	var dashboards customv1.DashboardList
+	if err := r.List(context.Background(), &dashboards, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "unable to list dashboards")
+		return nil
+	}
+	var requests []reconcile.Request
+	changed := []string{obj.GetName()}
+	found := map[string]bool{obj.GetName(): true}
+	for len(changed) > 0 {
+		name := changed[0]
+		changed = changed[1:]
+		for _, dashboard := range dashboards.Items {
+			if found[dashboard.Name] || !includes(dashboard, "Dashboard", name) {
+				continue
+			}
+			found[dashboard.Name] = true
+			changed = append(changed, dashboard.Name)
+			if r.Shard.OwnsDashboard(&dashboard) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&dashboard)})
+			}
+		}
+	}
+	return requests
+}
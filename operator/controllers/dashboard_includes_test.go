@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestIncludeWidgets(t *testing.T) {
+	library := &customv1.WidgetLibrary{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "golden-signals"},
+		Spec: customv1.WidgetLibrarySpec{
+			Params: map[string]string{"service": "shop"},
+			Widgets: []customv1.LibraryWidget{
+				{Name: "calls", Widget: apiextensionsv1.JSON{Raw: []byte(`{"id":"${service}-calls","height":10}`)}},
+				{Name: "latency", Widget: apiextensionsv1.JSON{Raw: []byte(`{"id":"${service}-latency","height":10,"x":6,"y":2}`)}},
+			},
+		},
+	}
+	checkout := &customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "checkout"},
+		Spec: customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Checkout","widgets":[` +
+			`{"id":"a","y":4,"height":5},{"id":"b","x":6,"y":4,"height":5},{"id":"c","y":9,"height":3}]}`)}},
+	}
+	scheme := runtime.NewScheme()
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(library, checkout).Build()
+	overview := customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "overview"},
+		Spec: customv1.DashboardSpec{Includes: []customv1.DashboardInclude{
+			{Name: "checkout"},
+			{Kind: "WidgetLibrary", Name: "golden-signals", Params: map[string]string{"service": "pay"}},
+		}},
+	}
+
+	config, err := includeWidgets(context.Background(), c, overview, []byte(`{"title":"Overview","widgets":[{"id":"intro","y":0,"height":2}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Title   string
+		Widgets []struct {
+			Id string
+			X  float64
+			Y  float64
+		}
+	}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Title != "Overview" || len(payload.Widgets) != 6 {
+		t.Fatalf("config = %s", config)
+	}
+	expected := []struct {
+		Id   string
+		X, Y float64
+	}{
+		{"intro", 0, 0},
+		{"checkout-a", 0, 2},
+		{"checkout-b", 6, 2},
+		{"checkout-c", 0, 7},
+		{"golden-signals-pay-calls", 0, 10},
+		{"golden-signals-pay-latency", 6, 12},
+	}
+	for i, e := range expected {
+		if w := payload.Widgets[i]; w.Id != e.Id || w.X != e.X || w.Y != e.Y {
+			t.Errorf("widget %d = %+v, expected %+v", i, w, e)
+		}
+	}
+
+	// dashboards must not include themselves
+	checkout.Spec.Includes = []customv1.DashboardInclude{{Name: "overview"}}
+	if err := c.Update(context.Background(), checkout); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := includeWidgets(context.Background(), c, overview, []byte(`{"widgets":[]}`)); err == nil {
+		t.Error("expected an error for an include cycle")
+	}
+}
+
+func TestDashboardsForInclude(t *testing.T) {
+	dashboard := func(name string, includes ...string) *customv1.Dashboard {
+		d := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name}}
+		for _, include := range includes {
+			d.Spec.Includes = append(d.Spec.Includes, customv1.DashboardInclude{Name: include})
+		}
+		return d
+	}
+	scheme := runtime.NewScheme()
+	_ = customv1.AddToScheme(scheme)
+	r := &DashboardReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			dashboard("block"), dashboard("service", "block"), dashboard("overview", "service"), dashboard("other"),
+		).Build(),
+		Log: ctrl.Log.WithName("test"),
+	}
+	requests := r.dashboardsForInclude(dashboard("block"))
+	if len(requests) != 2 || requests[0].Name != "service" || requests[1].Name != "overview" {
+		t.Errorf("requests = %v", requests)
+	}
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// evaluateJsonnet evaluates the Jsonnet program of a dashboard to its config,
+// with the values of the dashboard as external variables. The filename is
+// used in error messages.
+func evaluateJsonnet(ctx context.Context, c client.Reader, dashboard customv1.Dashboard, filename, program string) ([]byte, error) {
+	values, err := dashboardValues(ctx, c, dashboard)
+	if err != nil {
+		return nil, err
+	}
+	vm := jsonnet.MakeVM()
+	vm.Importer(&configMapImporter{ctx: ctx, client: c, namespace: dashboard.Namespace})
+	for name, value := range values {
+		vm.ExtVar(name, value)
+	}
+	config, err := vm.EvaluateAnonymousSnippet(filename, program)
+	if err != nil {
+		return nil, fmt.Errorf("unable to evaluate %s: %w", filename, err)
+	}
+	return []byte(config), nil
+}
+
+// configMapImporter resolves the Jsonnet imports "<configmap>/<key>" to the
+// key of a ConfigMap in the namespace. Files of the operator can't be
+// imported.
+type configMapImporter struct {
+	ctx       context.Context
+	client    client.Reader
+	namespace string
+}
+
+func (i *configMapImporter) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
+	parts := strings.SplitN(importedPath, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return jsonnet.Contents{}, "", fmt.Errorf("import %q is not of the form <configmap>/<key>", importedPath)
+	}
+	var configMap corev1.ConfigMap
+	if err := i.client.Get(i.ctx, client.ObjectKey{Namespace: i.namespace, Name: parts[0]}, &configMap); err != nil {
+		return jsonnet.Contents{}, "", fmt.Errorf("unable to read the ConfigMap of import %q: %w", importedPath, err)
+	}
+	data, ok := configMap.Data[parts[1]]
+	if !ok {
+		return jsonnet.Contents{}, "", fmt.Errorf("ConfigMap %s has no key %s", parts[0], parts[1])
+	}
+	return jsonnet.MakeContents(data), importedPath, nil
+}
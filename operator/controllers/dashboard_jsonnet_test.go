@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestRenderJsonnet(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "widgets"},
+			Data: map[string]string{
+				"lib.libsonnet": `{ markdown(id, text):: { id: id, type: "markdown", config: text } }`,
+				"shop.jsonnet":  `local w = import "widgets/lib.libsonnet"; { title: "Shop " + std.extVar("stage"), widgets: [w.markdown("a", "A")] }`,
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "customer"},
+			Data:       map[string][]byte{"id": []byte("c-42")},
+		},
+	).Build()
+	dashboard := customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{
+			Jsonnet: `local w = import "widgets/lib.libsonnet";
+				{ title: "Shop " + std.extVar("stage"), widgets: [w.markdown("a", std.extVar("customer"))] }`,
+			Values: map[string]string{"stage": "prod", "customer": "overridden"},
+			ValuesFrom: []customv1.ValueSource{
+				{Name: "customer", SecretKeyRef: customv1.SecretKeyReference{Name: "customer", Key: "id"}},
+			},
+		},
+	}
+
+	config, err := renderSpecConfig(ctx, c, RenderVariables{}, dashboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, config); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"title":"Shop prod","widgets":[{"config":"c-42","id":"a","type":"markdown"}]}`; compact.String() != want {
+		t.Errorf("config = %s, want %s", compact.String(), want)
+	}
+
+	dashboard.Spec.ConfigFrom = &customv1.ConfigMapKeyReference{Name: "widgets", Key: "shop.jsonnet"}
+	config, err = renderSpecConfig(ctx, c, RenderVariables{}, dashboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(config), `"title":"Shop prod"`) {
+		t.Errorf("config of spec.config-from = %s", config)
+	}
+
+	dashboard.Spec.ConfigFrom = nil
+	for _, program := range []string{`import "/etc/passwd"`, `import "widgets/missing.libsonnet"`, `{ title: std.extVar("unknown") }`} {
+		dashboard.Spec.Jsonnet = program
+		if _, err := renderSpecConfig(ctx, c, RenderVariables{}, dashboard); err == nil {
+			t.Errorf("expected an error for %s", program)
+		}
+	}
+}
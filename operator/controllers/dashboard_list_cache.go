@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// DashboardListCache keeps the dashboard list of each Instana tenant for
+// TTL, shared by all reconciles, so a full resync checks the existence of
+// hundreds of dashboards with a single list request. Concurrent reconciles
+// wait for the list request in flight instead of sending their own.
+// Tenants are identified by their base url and a hash of the API token, so
+// tokens of different namespaces of one tenant don't share a list.
+type DashboardListCache struct {
+	// TTL is the time a list is used. 0 disables the cache.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	tenants map[string]*cachedDashboardList
+}
+
+type cachedDashboardList struct {
+	// mu is held while the list is requested
+	mu      sync.Mutex
+	fetched time.Time
+	list    []InstanaApiResponse
+}
+
+// listCacheKey identifies the lists of a tenant by its base url and token.
+// Tokens read from Vault are identified by their secret.
+func listCacheKey(apiConfig InstanaApi) string {
+	token := apiConfig.ApiToken
+	if apiConfig.Vault != nil {
+		token = apiConfig.Vault.Config.Address + "/" + apiConfig.Vault.Config.SecretPath + "#" + apiConfig.Vault.Config.SecretKey
+	}
+	hash := sha256.Sum256([]byte(token))
+	return apiConfig.BaseUrl + " " + hex.EncodeToString(hash[:8])
+}
+
+func (c *DashboardListCache) tenant(apiConfig InstanaApi) *cachedDashboardList {
+	key := listCacheKey(apiConfig)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tenants == nil {
+		c.tenants = map[string]*cachedDashboardList{}
+	}
+	tenant, ok := c.tenants[key]
+	if !ok {
+		tenant = &cachedDashboardList{}
+		c.tenants[key] = tenant
+	}
+	return tenant
+}
+
+// List returns the cached dashboard list of the tenant, requesting it with
+// instanaClient if it expired.
+func (c *DashboardListCache) List(apiConfig InstanaApi, instanaClient InstanaClient, log logr.Logger) ([]InstanaApiResponse, error) {
+	if c == nil || c.TTL <= 0 {
+		return instanaClient.listDashboards(log)
+	}
+	baseUrl := apiConfig.BaseUrl
+	tenant := c.tenant(apiConfig)
+	tenant.mu.Lock()
+	defer tenant.mu.Unlock()
+	if tenant.list != nil && time.Since(tenant.fetched) < c.TTL {
+		dashboardListCacheRequests.WithLabelValues(baseUrl, "hit").Inc()
+		return append([]InstanaApiResponse(nil), tenant.list...), nil
+	}
+	dashboardListCacheRequests.WithLabelValues(baseUrl, "miss").Inc()
+	list, err := instanaClient.listDashboards(log)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []InstanaApiResponse{}
+	}
+	tenant.list, tenant.fetched = list, time.Now()
+	return append([]InstanaApiResponse(nil), list...), nil
+}
+
+// Exists reports whether the tenant has a dashboard with the id according to
+// the cached list.
+func (c *DashboardListCache) Exists(apiConfig InstanaApi, instanaClient InstanaClient, id string, log logr.Logger) (bool, error) {
+	list, err := c.List(apiConfig, instanaClient, log)
+	if err != nil {
+		return false, err
+	}
+	for _, d := range list {
+		if d.Id == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Invalidate drops the list of the tenant.
+func (c *DashboardListCache) Invalidate(apiConfig InstanaApi) {
+	c.apply(apiConfig, func([]InstanaApiResponse) []InstanaApiResponse { return nil })
+}
+
+// apply changes the cached list of the tenant, if any.
+func (c *DashboardListCache) apply(apiConfig InstanaApi, change func([]InstanaApiResponse) []InstanaApiResponse) {
+	if c == nil {
+		return
+	}
+	tenant := c.tenant(apiConfig)
+	tenant.mu.Lock()
+	defer tenant.mu.Unlock()
+	if tenant.list != nil {
+		tenant.list = change(tenant.list)
+	}
+}
+
+// Wrap returns a client which lists the dashboards from the cache. Creates,
+// updates and deletes through the client are applied to the cached list, a
+// failed change drops it. Reads of single dashboards are not cached, as the
+// list does not contain the widgets.
+func (c *DashboardListCache) Wrap(apiConfig InstanaApi, instanaClient InstanaClient) InstanaClient {
+	if c == nil || c.TTL <= 0 {
+		return instanaClient
+	}
+	return &cachedListClient{cache: c, tenant: apiConfig, next: instanaClient}
+}
+
+type cachedListClient struct {
+	cache  *DashboardListCache
+	tenant InstanaApi
+	next   InstanaClient
+}
+
+func (c *cachedListClient) createDashboard(config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	response, err := c.next.createDashboard(config, log)
+	c.saved(response, err)
+	return response, err
+}
+
+func (c *cachedListClient) updateDashboard(id string, config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	response, err := c.next.updateDashboard(id, config, log)
+	c.saved(response, err)
+	return response, err
+}
+
+func (c *cachedListClient) saved(response InstanaApiResponse, err error) {
+	if err != nil || response.Id == "" {
+		c.cache.Invalidate(c.tenant)
+		return
+	}
+	c.cache.apply(c.tenant, func(list []InstanaApiResponse) []InstanaApiResponse {
+		updated := make([]InstanaApiResponse, 0, len(list)+1)
+		for _, d := range list {
+			if d.Id != response.Id {
+				updated = append(updated, d)
+			}
+		}
+		return append(updated, response)
+	})
+}
+
+func (c *cachedListClient) deleteDashboard(id string, log logr.Logger) error {
+	err := c.next.deleteDashboard(id, log)
+	if err != nil && !isInstanaNotFound(err) {
+		c.cache.Invalidate(c.tenant)
+		return err
+	}
+	c.cache.apply(c.tenant, func(list []InstanaApiResponse) []InstanaApiResponse {
+		updated := make([]InstanaApiResponse, 0, len(list))
+		for _, d := range list {
+			if d.Id != id {
+				updated = append(updated, d)
+			}
+		}
+		return updated
+	})
+	return err
+}
+
+func (c *cachedListClient) getDashboard(id string, log logr.Logger) ([]byte, error) {
+	return c.next.getDashboard(id, log)
+}
+
+func (c *cachedListClient) listDashboards(log logr.Logger) ([]InstanaApiResponse, error) {
+	return c.cache.List(c.tenant, c.next, log)
+}
+
+func (c *cachedListClient) searchSnapshots(plugin string, query string, log logr.Logger) ([]InstanaSnapshot, error) {
+	return c.next.searchSnapshots(plugin, query, log)
+}
+
+func (c *cachedListClient) getSnapshot(id string, log logr.Logger) (InstanaSnapshot, error) {
+	return c.next.getSnapshot(id, log)
+}
+
+func (c *cachedListClient) do(method string, path string, body []byte, log logr.Logger) ([]byte, error) {
+	return c.next.do(method, path, body, log)
+}
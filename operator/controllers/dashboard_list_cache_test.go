@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestDashboardListCache(t *testing.T) {
+	log := ctrl.Log.WithName("test")
+	instana := newFakeInstanaClient()
+	instana.dashboards["a"] = []byte(`{"title":"A"}`)
+	cache := &DashboardListCache{TTL: time.Minute}
+	tenant := InstanaApi{BaseUrl: "https://tenant.instana.io", ApiToken: "token"}
+	cached := cache.Wrap(tenant, instana)
+
+	ids := func() string {
+		list, err := cached.listDashboards(log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, d := range list {
+			ids = append(ids, d.Id+"="+d.Title)
+		}
+		sort.Strings(ids)
+		return strings.Join(ids, ",")
+	}
+
+	if got := ids(); got != "a=A" {
+		t.Errorf("list = %s", got)
+	}
+	created, err := cached.createDashboard([]byte(`{"title":"B"}`), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.updateDashboard("a", []byte(`{"title":"A2"}`), log); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(); got != "a=A2,"+created.Id+"=B" {
+		t.Errorf("list = %s after create and update", got)
+	}
+	if err := cached.deleteDashboard("a", log); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := cache.Exists(tenant, instana, "a", log); err != nil || exists {
+		t.Errorf("Exists() = %v, %v after delete", exists, err)
+	}
+	if got := strings.Join(instana.calls, ","); got != "list,create,update a,delete a" {
+		t.Errorf("calls = %s, want a single list", got)
+	}
+
+	// failed changes drop the list
+	instana.err = errors.New("connection refused")
+	if _, err := cached.updateDashboard(created.Id, []byte(`{"title":"B2"}`), log); err == nil {
+		t.Fatal("expected the update to fail")
+	}
+	instana.err = nil
+	instana.calls = nil
+	ids()
+	if got := strings.Join(instana.calls, ","); got != "list" {
+		t.Errorf("calls = %s, want the list to be requested again", got)
+	}
+
+	// the tokens of other namespaces of the tenant don't share the list
+	other := cache.Wrap(InstanaApi{BaseUrl: tenant.BaseUrl, ApiToken: "team-token"}, instana)
+	instana.calls = nil
+	if _, err := other.listDashboards(log); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(instana.calls, ","); got != "list" {
+		t.Errorf("calls = %s, want a list for the other token", got)
+	}
+}
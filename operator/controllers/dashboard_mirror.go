@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// syncMirror replicates the dashboard to the mirror tenant of the spec. The
+// mirror has its own dashboard id and sync condition. A mirror which is no
+// longer part of the spec is deleted.
+func (r *DashboardReconciler) syncMirror(ctx context.Context, dashboard *customv1.Dashboard, config []byte, log logr.Logger) error {
+	tenant := dashboard.Spec.MirrorTenant
+	if dashboard.Status.MirrorDashboardId != "" && dashboard.Status.MirrorTenant != tenant {
+		log.Info("Removing dashboard from previous mirror tenant " + dashboard.Status.MirrorTenant)
+		mirrorApi, err := loadTenantConfig(ctx, r.Client, dashboard.Status.MirrorTenant)
+		if err == nil {
+			err = r.instanaClient(ctx, mirrorApi).deleteDashboard(dashboard.Status.MirrorDashboardId, log)
+		}
+		if err != nil && !isInstanaNotFound(err) {
+			log.Error(err, "unable to delete dashboard in previous mirror tenant")
+			return err
+		}
+		dashboard.Status.MirrorDashboardId = ""
+	}
+	dashboard.Status.MirrorTenant = tenant
+	if tenant == "" {
+		removeStatusCondition(&dashboard.Status.Conditions, customv1.ConditionMirrorSynced)
+		return nil
+	}
+
+	mirrorApi, err := loadTenantConfig(ctx, r.Client, tenant)
+	if err == nil {
+		config, err = applyTitlePolicy(config, mirrorApi)
+	}
+	if err == nil {
+		var apiResponse InstanaApiResponse
+		apiResponse, err = syncDashboard(r.instanaClient(ctx, mirrorApi), dashboard.Status.MirrorDashboardId, config, log.WithValues("tenant", tenant))
+		if err == nil {
+			dashboard.Status.MirrorDashboardId = apiResponse.Id
+		}
+	}
+	setSyncCondition(dashboard, customv1.ConditionMirrorSynced, err)
+	if err != nil {
+		log.Error(err, "unable to sync dashboard with mirror tenant "+tenant)
+		r.Recorder.Event(dashboard, corev1.EventTypeWarning, "MirrorSyncFailed", err.Error())
+	}
+	return err
+}
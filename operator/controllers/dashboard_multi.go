@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// multiDashboardStatus is the status of one of the dashboards of a Dashboard
+// with spec.clusters or spec.variants.
+type multiDashboardStatus struct {
+	// Name of the cluster or variant.
+	Name        string
+	DashboardId string
+	Error       string
+}
+
+// multiDashboards are the dashboards of a Dashboard synced once per cluster
+// of spec.clusters or variant of spec.variants.
+type multiDashboards struct {
+	// kind is "cluster" or "variant", used in logs, events and errors.
+	kind string
+	// names are the clusters or variants of the spec.
+	names []string
+	// status returns the dashboards of the status.
+	status func() []multiDashboardStatus
+	// setStatus replaces the dashboards of the status.
+	setStatus func([]multiDashboardStatus)
+	// sync renders and syncs the dashboard of a cluster or variant.
+	sync func(name, id string, log logr.Logger) (InstanaApiResponse, error)
+	// deleteReplaced deletes the dashboards of the other kind.
+	deleteReplaced func(dashboard *customv1.Dashboard, instanaClient InstanaClient, log logr.Logger) error
+}
+
+// clusterDashboards returns the dashboards of spec.clusters.
+func (r *DashboardReconciler) clusterDashboards(ctx context.Context, dashboard *customv1.Dashboard, instanaApi InstanaApi) multiDashboards {
+	return multiDashboards{
+		kind:  "cluster",
+		names: dashboard.Spec.Clusters,
+		status: func() []multiDashboardStatus {
+			var statuses []multiDashboardStatus
+			for _, status := range dashboard.Status.Clusters {
+				statuses = append(statuses, multiDashboardStatus{Name: status.Cluster, DashboardId: status.DashboardId, Error: status.Error})
+			}
+			return statuses
+		},
+		setStatus: func(statuses []multiDashboardStatus) {
+			var clusters []customv1.ClusterDashboardStatus
+			for _, status := range statuses {
+				clusters = append(clusters, customv1.ClusterDashboardStatus{Cluster: status.Name, DashboardId: status.DashboardId, Error: status.Error})
+			}
+			dashboard.Status.Clusters = clusters
+		},
+		sync: func(cluster, id string, log logr.Logger) (InstanaApiResponse, error) {
+			return r.syncCluster(ctx, *dashboard, instanaApi, cluster, id, log)
+		},
+		deleteReplaced: deleteVariantDashboards,
+	}
+}
+
+// variantDashboards returns the dashboards of spec.variants.
+func (r *DashboardReconciler) variantDashboards(ctx context.Context, dashboard *customv1.Dashboard, instanaApi InstanaApi) multiDashboards {
+	variants := map[string]customv1.DashboardVariant{}
+	var names []string
+	for _, variant := range dashboard.Spec.Variants {
+		variants[variant.Name] = variant
+		names = append(names, variant.Name)
+	}
+	return multiDashboards{
+		kind:  "variant",
+		names: names,
+		status: func() []multiDashboardStatus {
+			var statuses []multiDashboardStatus
+			for _, status := range dashboard.Status.Variants {
+				statuses = append(statuses, multiDashboardStatus{Name: status.Name, DashboardId: status.DashboardId, Error: status.Error})
+			}
+			return statuses
+		},
+		setStatus: func(statuses []multiDashboardStatus) {
+			var variants []customv1.VariantDashboardStatus
+			for _, status := range statuses {
+				variants = append(variants, customv1.VariantDashboardStatus{Name: status.Name, DashboardId: status.DashboardId, Error: status.Error})
+			}
+			dashboard.Status.Variants = variants
+		},
+		sync: func(name, id string, log logr.Logger) (InstanaApiResponse, error) {
+			return r.syncVariant(ctx, *dashboard, instanaApi, variants[name], id, log)
+		},
+		deleteReplaced: deleteClusterDashboards,
+	}
+}
+
+// reconcileMultiDashboards syncs the dashboards of the clusters or variants
+// and updates the status.
+func (r *DashboardReconciler) reconcileMultiDashboards(ctx context.Context, dashboard *customv1.Dashboard, instanaApi InstanaApi, dashboards multiDashboards, log logr.Logger) error {
+	dashboard.Status.ObservedGeneration = dashboard.Generation
+	if r.dryRunEnabled(dashboard) {
+		message := fmt.Sprintf("Would sync the dashboards of the %ss %s", dashboards.kind, strings.Join(dashboards.names, ", "))
+		log.Info("Dry run: " + message)
+		r.Recorder.Event(dashboard, corev1.EventTypeNormal, "DryRun", message)
+		meta.SetStatusCondition(&dashboard.Status.Conditions, metav1.Condition{
+			Type:    customv1.ConditionDryRun,
+			Status:  metav1.ConditionTrue,
+			Reason:  "WouldSync",
+			Message: message,
+		})
+		setReadyConditions(&dashboard.Status.Conditions, "DryRun", "Dry run, the dashboards are not synced with Instana", nil)
+		return r.Status().Update(ctx, dashboard)
+	}
+	removeStatusCondition(&dashboard.Status.Conditions, customv1.ConditionDryRun)
+
+	wasDegraded := meta.IsStatusConditionTrue(dashboard.Status.Conditions, customv1.ConditionDegraded)
+	err := r.syncMultiDashboards(ctx, dashboard, instanaApi, dashboards, log)
+	setSyncCondition(dashboard, customv1.ConditionSynced, err)
+	setSyncAttempt(dashboard, err)
+	setDegradedStatus(dashboard, err)
+	r.Notifier.notify(dashboard, wasDegraded, instanaApi.BaseUrl, log)
+	setReadyConditions(&dashboard.Status.Conditions, "Synced",
+		fmt.Sprintf("Dashboards of %d %ss are in sync with Instana", len(dashboards.names), dashboards.kind), err)
+	if err != nil {
+		log.Error(err, "unable to sync "+dashboards.kind+" dashboards with Instana")
+		r.Recorder.Event(dashboard, corev1.EventTypeWarning, "SyncFailed", err.Error())
+	}
+	if statusErr := r.Status().Update(ctx, dashboard); statusErr != nil {
+		log.Error(statusErr, "unable to update dashboard status")
+		return statusErr
+	}
+	return err
+}
+
+// syncMultiDashboards creates or updates a dashboard in Instana for every
+// cluster or variant and deletes the dashboards of those which were removed.
+// Once all of them are synced, the single dashboard and the dashboards of the
+// other kind of a Dashboard which was split up are deleted, so it is never
+// left without a dashboard.
+func (r *DashboardReconciler) syncMultiDashboards(ctx context.Context, dashboard *customv1.Dashboard, instanaApi InstanaApi, dashboards multiDashboards, log logr.Logger) error {
+	instanaClient := r.instanaClient(ctx, instanaApi)
+	ids := map[string]string{}
+	for _, status := range dashboards.status() {
+		ids[status.Name] = status.DashboardId
+	}
+	synced := map[string]bool{}
+	var statuses []multiDashboardStatus
+	var failed []string
+	for _, name := range dashboards.names {
+		if synced[name] {
+			continue
+		}
+		synced[name] = true
+		status := multiDashboardStatus{Name: name, DashboardId: ids[name]}
+		apiResponse, err := dashboards.sync(name, status.DashboardId, log.WithValues(dashboards.kind, name))
+		if err != nil {
+			status.Error = redactError(err)
+			failed = append(failed, name+": "+err.Error())
+		} else {
+			status.DashboardId = apiResponse.Id
+		}
+		statuses = append(statuses, status)
+		delete(ids, name)
+	}
+	for name, id := range ids {
+		if id == "" {
+			continue
+		}
+		log.Info("Deleting dashboard of removed "+dashboards.kind, dashboards.kind, name, "id", id)
+		if err := instanaClient.deleteDashboard(id, log); err != nil && !isInstanaNotFound(err) {
+			statuses = append(statuses, multiDashboardStatus{Name: name, DashboardId: id, Error: err.Error()})
+			failed = append(failed, name+": "+err.Error())
+		}
+	}
+	dashboards.setStatus(statuses)
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to sync the dashboards of the %ss %s", dashboards.kind, strings.Join(failed, ", "))
+	}
+	if err := r.deleteSingleDashboard(ctx, dashboard, instanaClient, log); err != nil {
+		return err
+	}
+	return dashboards.deleteReplaced(dashboard, instanaClient, log)
+}
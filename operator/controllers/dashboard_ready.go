@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// stalledError marks errors which are not resolved by retrying the sync.
+type stalledError struct {
+	error
+}
+
+func (e stalledError) Unwrap() error {
+	return e.error
+}
+
+// isStalled returns true for errors which require a change of the spec or
+// the tenant config, i.e. render failures and requests rejected by Instana.
+func isStalled(err error) bool {
+	if errors.As(err, &stalledError{}) {
+		return true
+	}
+	var apiErr *InstanaApiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 &&
+		apiErr.StatusCode != 408 && apiErr.StatusCode != 429
+}
+
+// setReadyStatus sets the kstatus conditions after the current generation
+// was processed: Ready once the dashboard exists in Instana, Reconciling
+// while a failed sync is retried and Stalled if retrying will not help.
+func setReadyStatus(dashboard *customv1.Dashboard, err error) {
+	dashboard.Status.ObservedGeneration = dashboard.Generation
+	reason, message := "Synced", "Dashboard "+dashboard.Status.DashboardId+" is in sync with Instana"
+	if meta.IsStatusConditionTrue(dashboard.Status.Conditions, customv1.ConditionDryRun) {
+		reason, message = "DryRun", "Dry run, the dashboard is not synced with Instana"
+	}
+	setReadyConditions(&dashboard.Status.Conditions, reason, message, err)
+}
+
+// setReadyConditions sets Ready with the given reason and message if err is
+// nil, and otherwise Reconciling or Stalled depending on the error.
+func setReadyConditions(conditions *[]metav1.Condition, reason string, message string, err error) {
+	if err == nil {
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    customv1.ConditionReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  reason,
+			Message: message,
+		})
+		removeStatusCondition(conditions, customv1.ConditionReconciling)
+		removeStatusCondition(conditions, customv1.ConditionStalled)
+		return
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    customv1.ConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  "SyncFailed",
+		Message: redactError(err),
+	})
+	failed, other := customv1.ConditionReconciling, customv1.ConditionStalled
+	reason = "Retrying"
+	if isStalled(err) {
+		failed, other = other, failed
+		reason = "SyncFailed"
+	}
+	removeStatusCondition(conditions, other)
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    failed,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: redactError(err),
+	})
+}
+
+// renderFailed reports a config which cannot be rendered. The dashboard is
+// stalled until the spec is fixed.
+func (r *DashboardReconciler) renderFailed(ctx context.Context, dashboard *customv1.Dashboard, err error, msg string, log logr.Logger) (ctrl.Result, error) {
+	log.Error(err, msg)
+	r.Recorder.Event(dashboard, corev1.EventTypeWarning, "RenderFailed", err.Error())
+	setReadyStatus(dashboard, stalledError{err})
+	if statusErr := r.Status().Update(ctx, dashboard); statusErr != nil {
+		log.Error(statusErr, "unable to update dashboard status")
+	}
+	return ctrl.Result{}, err
+}
+
+// searchFailed reports a config which cannot be rendered because the search
+// of its entities failed. Like a failed sync it is retried, once the circuit
+// breaker of the tenant lets requests through again if it is open.
+func (r *DashboardReconciler) searchFailed(ctx context.Context, dashboard *customv1.Dashboard, instanaApi InstanaApi, err error, log logr.Logger) (ctrl.Result, error) {
+	log.Error(err, "unable to search the Instana entities of the dashboard")
+	r.Recorder.Event(dashboard, corev1.EventTypeWarning, "SyncFailed", err.Error())
+	setReadyStatus(dashboard, err)
+	if statusErr := r.Status().Update(ctx, dashboard); statusErr != nil {
+		log.Error(statusErr, "unable to update dashboard status")
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return ctrl.Result{RequeueAfter: r.CircuitBreaker.RetryAfter(instanaApi.BaseUrl)}, nil
+	}
+	return ctrl.Result{}, err
+}
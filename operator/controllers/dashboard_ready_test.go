@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestSetReadyStatus(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		wantReady       metav1.ConditionStatus
+		wantReconciling bool
+		wantStalled     bool
+	}{
+		{name: "synced", wantReady: metav1.ConditionTrue},
+		{
+			name:            "retries server errors",
+			err:             &InstanaApiError{Method: "PUT", StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"},
+			wantReady:       metav1.ConditionFalse,
+			wantReconciling: true,
+		},
+		{
+			name:            "retries throttled requests",
+			err:             &InstanaApiError{Method: "PUT", StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"},
+			wantReady:       metav1.ConditionFalse,
+			wantReconciling: true,
+		},
+		{
+			name:        "stalls on rejected configs",
+			err:         &InstanaApiError{Method: "PUT", StatusCode: http.StatusBadRequest, Status: "400 Bad Request"},
+			wantReady:   metav1.ConditionFalse,
+			wantStalled: true,
+		},
+		{
+			name:        "stalls on render failures",
+			err:         stalledError{errors.New("invalid patch")},
+			wantReady:   metav1.ConditionFalse,
+			wantStalled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Generation: 3}}
+			// conditions of a previous sync are replaced
+			setReadyStatus(dashboard, stalledError{errors.New("previous")})
+			setReadyStatus(dashboard, &InstanaApiError{StatusCode: http.StatusBadGateway})
+			setReadyStatus(dashboard, tt.err)
+
+			if dashboard.Status.ObservedGeneration != 3 {
+				t.Errorf("observedGeneration = %d, want 3", dashboard.Status.ObservedGeneration)
+			}
+			if ready := meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionReady); ready == nil || ready.Status != tt.wantReady {
+				t.Errorf("Ready condition = %v, want status %s", ready, tt.wantReady)
+			}
+			if got := meta.IsStatusConditionTrue(dashboard.Status.Conditions, customv1.ConditionReconciling); got != tt.wantReconciling {
+				t.Errorf("Reconciling = %v, want %v", got, tt.wantReconciling)
+			}
+			if got := meta.IsStatusConditionTrue(dashboard.Status.Conditions, customv1.ConditionStalled); got != tt.wantStalled {
+				t.Errorf("Stalled = %v, want %v", got, tt.wantStalled)
+			}
+		})
+	}
+}
+
+func TestSetSyncAttempt(t *testing.T) {
+	dashboard := &customv1.Dashboard{}
+	setSyncAttempt(dashboard, &InstanaApiError{Method: "PUT", StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"})
+	setSyncAttempt(dashboard, errors.New("connection refused"))
+	if dashboard.Status.SyncAttempts != 2 || dashboard.Status.LastError != "connection refused" || dashboard.Status.LastSyncTime == nil {
+		t.Errorf("status = %+v, want 2 attempts with the last error", dashboard.Status)
+	}
+
+	setSyncAttempt(dashboard, nil)
+	if dashboard.Status.SyncAttempts != 0 || dashboard.Status.LastError != "" {
+		t.Errorf("status = %+v, want a reset after a successful sync", dashboard.Status)
+	}
+
+	if url := dashboardUrl(InstanaApi{BaseUrl: "https://tenant.instana.io/"}, "abc"); url != "https://tenant.instana.io/#/customDashboards/abc" {
+		t.Errorf("url = %s", url)
+	}
+	autoRefresh := false
+	timeRange := &customv1.TimeRange{Window: &metav1.Duration{Duration: time.Hour}, AutoRefresh: &autoRefresh}
+	if query := timeRangeQuery(timeRange); query != "?timeline.ar=false&timeline.ws=3600000" {
+		t.Errorf("query = %s", query)
+	}
+	if query := timeRangeQuery(&customv1.TimeRange{}); query != "" {
+		t.Errorf("query = %s for an empty time range", query)
+	}
+}
+
+func TestConfigApplied(t *testing.T) {
+	config := []byte(`{"title":"Shop"}`)
+	tenant := InstanaApi{BaseUrl: "https://tenant-a.instana.io"}
+	dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+	dashboard.Status.DashboardId = "abc"
+	dashboard.Status.DashboardUrl = dashboardUrl(tenant, "abc")
+	dashboard.Status.AppliedConfigHash = configHash(config)
+	dashboard.Status.ObservedGeneration = 2
+	setSyncCondition(dashboard, customv1.ConditionSynced, nil)
+	if !configApplied(dashboard, tenant, config) {
+		t.Error("unchanged config should be skipped")
+	}
+	if configApplied(dashboard, tenant, []byte(`{"title":"Checkout"}`)) {
+		t.Error("changed config should be applied")
+	}
+	if configApplied(dashboard, InstanaApi{BaseUrl: "https://tenant-b.instana.io"}, config) {
+		t.Error("config should be applied to a new tenant")
+	}
+
+	dashboard.Spec.MirrorTenant = "tenant-b"
+	if configApplied(dashboard, tenant, config) {
+		t.Error("mirrored config should be applied")
+	}
+	dashboard.Spec.MirrorTenant = ""
+
+	dashboard.Spec.TimeRange = &customv1.TimeRange{Window: &metav1.Duration{Duration: time.Hour}}
+	if configApplied(dashboard, tenant, config) {
+		t.Error("a changed time range should update the url in the status")
+	}
+	dashboard.Status.DashboardUrl = desiredDashboardUrl(dashboard, tenant)
+	if !configApplied(dashboard, tenant, config) {
+		t.Error("unchanged time range should be skipped")
+	}
+
+	setSyncCondition(dashboard, customv1.ConditionSynced, errors.New("connection refused"))
+	if configApplied(dashboard, tenant, config) {
+		t.Error("config should be applied again after a failed sync")
+	}
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// RenderVariables are the built-in variables of templated configs which are
+// set for the operator.
+type RenderVariables struct {
+	// ClusterName is the name of the cluster of the operator.
+	ClusterName string
+	// Zone is the zone or region of the cluster.
+	Zone string
+	// Vars are additional variables, e.g. the stage of the cluster.
+	Vars map[string]string
+	// Instana is searched for the entities of entity references. Templated
+	// configs with entity references fail to render without it.
+	Instana InstanaClient
+}
+
+// configTemplateData is passed to the templates of a templated config.
+type configTemplateData struct {
+	RenderVariables
+	Namespace string
+	Name      string
+	// Values are the values of spec.values-from.
+	Values map[string]string
+	// Entities are the snapshot ids of spec.entity-refs.
+	Entities map[string]string
+}
+
+// renderConfig returns the payload which is sent to Instana for the given
+// dashboard. A failed search of the entities of the dashboard is returned as
+// entitySearchError.
+func renderConfig(ctx context.Context, c client.Reader, vars RenderVariables, dashboard customv1.Dashboard) ([]byte, error) {
+	config, err := renderSpecConfig(ctx, c, vars, dashboard)
+	if err != nil {
+		return nil, err
+	}
+	return applyHotfixPatch(dashboard, config)
+}
+
+// renderSpecConfig renders the config of the spec of the dashboard, without
+// the hotfix patch of its annotation.
+func renderSpecConfig(ctx context.Context, c client.Reader, vars RenderVariables, dashboard customv1.Dashboard) ([]byte, error) {
+	config, err := dashboardConfig(ctx, c, dashboard)
+	if err != nil {
+		return nil, err
+	}
+	config, err = composeWidgets(ctx, c, dashboard, config)
+	if err != nil {
+		return nil, err
+	}
+	config, err = includeWidgets(ctx, c, dashboard, config)
+	if err != nil {
+		return nil, err
+	}
+	if dashboard.Spec.Templated || len(dashboard.Spec.ValuesFrom) > 0 || len(dashboard.Spec.EntityRefs) > 0 {
+		values, err := dashboardValues(ctx, c, dashboard)
+		if err != nil {
+			return nil, err
+		}
+		entities := newEntityResolver(vars.Instana, dashboard.Namespace)
+		ids, err := entities.resolveRefs(dashboard.Spec.EntityRefs)
+		if searchErr := searchFailure(err); searchErr != nil {
+			return nil, searchErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		data := configTemplateData{RenderVariables: vars, Namespace: dashboard.Namespace, Name: dashboard.Name, Values: values, Entities: ids}
+		config, err = renderTemplates(config, data, entities.funcs())
+		if searchErr := searchFailure(err); searchErr != nil {
+			return nil, searchErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(dashboard.Spec.AccessRules) > 0 {
+		config, err = applyAccessRules(config, dashboard.Spec.AccessRules)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// applyTitlePolicy adds the title prefix and suffix of the tenant to the
+// title of the config.
+func applyTitlePolicy(config []byte, tenant InstanaApi) ([]byte, error) {
+	if tenant.TitlePrefix == "" && tenant.TitleSuffix == "" {
+		return config, nil
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return nil, err
+	}
+	title, _ := payload["title"].(string)
+	payload["title"] = tenant.TitlePrefix + title + tenant.TitleSuffix
+	return json.Marshal(payload)
+}
+
+// stripTitlePolicy removes the title prefix and suffix of the tenant from the
+// title of a live config.
+func stripTitlePolicy(config []byte, tenant InstanaApi) ([]byte, error) {
+	if tenant.TitlePrefix == "" && tenant.TitleSuffix == "" {
+		return config, nil
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return nil, err
+	}
+	title, _ := payload["title"].(string)
+	payload["title"] = strings.TrimSuffix(strings.TrimPrefix(title, tenant.TitlePrefix), tenant.TitleSuffix)
+	return json.Marshal(payload)
+}
+
+// applyAccessRules replaces the accessRules of the config.
+func applyAccessRules(config []byte, rules []customv1.AccessRule) ([]byte, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return nil, err
+	}
+	accessRules := make([]interface{}, 0, len(rules))
+	for _, rule := range rules {
+		accessRules = append(accessRules, map[string]interface{}{
+			"accessType":   rule.AccessType,
+			"relationType": rule.RelationType,
+			"relatedId":    rule.RelatedId,
+		})
+	}
+	payload["accessRules"] = accessRules
+	return json.Marshal(payload)
+}
+
+// renderTemplates renders the string values of the config which contain a
+// template action.
+func renderTemplates(config []byte, data configTemplateData, funcs template.FuncMap) ([]byte, error) {
+	var payload interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return nil, err
+	}
+	var render func(value interface{}) (interface{}, error)
+	render = func(value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case string:
+			if !strings.Contains(v, "{{") {
+				return v, nil
+			}
+			tmpl, err := template.New("config").Funcs(funcs).Option("missingkey=error").Parse(v)
+			if err != nil {
+				return nil, err
+			}
+			var rendered strings.Builder
+			if err := tmpl.Execute(&rendered, data); err != nil {
+				return nil, err
+			}
+			return rendered.String(), nil
+		case map[string]interface{}:
+			for key, item := range v {
+				rendered, err := render(item)
+				if err != nil {
+					return nil, err
+				}
+				v[key] = rendered
+			}
+		case []interface{}:
+			for i, item := range v {
+				rendered, err := render(item)
+				if err != nil {
+					return nil, err
+				}
+				v[i] = rendered
+			}
+		}
+		return value, nil
+	}
+	payload, err := render(payload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render templated config: %w", err)
+	}
+	return json.Marshal(payload)
+}
+
+// specConfig returns the config of the spec as JSON. The second return value
+// is true if the config is stored in the legacy format, a string containing
+// the JSON.
+func specConfig(dashboard customv1.Dashboard) ([]byte, bool, error) {
+	if dashboard.Spec.Config == nil || len(dashboard.Spec.Config.Raw) == 0 {
+		return nil, false, errors.New("spec.config is empty")
+	}
+	raw := dashboard.Spec.Config.Raw
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte(`"`)) {
+		var legacy string
+		if err := json.Unmarshal(raw, &legacy); err != nil {
+			return nil, true, err
+		}
+		return []byte(legacy), true, nil
+	}
+	return raw, false, nil
+}
+
+// migrateLegacyConfig replaces a config stored as string with the JSON it
+// contains. Returns false if the config is not in the legacy format.
+func migrateLegacyConfig(dashboard *customv1.Dashboard) (bool, error) {
+	if dashboard.Spec.ConfigFrom != nil || dashboard.Spec.Jsonnet != "" {
+		return false, nil
+	}
+	config, legacy, err := specConfig(*dashboard)
+	if !legacy || err != nil {
+		return false, err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, config); err != nil {
+		return false, err
+	}
+	dashboard.Spec.Config = &apiextensionsv1.JSON{Raw: compact.Bytes()}
+	return true, nil
+}
+
+// applyConfigPatches applies the patches of spec.patches in order.
+func applyConfigPatches(dashboard customv1.Dashboard, config []byte) ([]byte, error) {
+	for i, p := range dashboard.Spec.Patches {
+		var err error
+		switch p.Type {
+		case "", customv1.ConfigPatchJSONPatch:
+			var patch jsonpatch.Patch
+			patch, err = jsonpatch.DecodePatch(p.Patch.Raw)
+			if err == nil {
+				config, err = patch.Apply(config)
+			}
+		case customv1.ConfigPatchMerge:
+			config, err = jsonpatch.MergePatch(config, p.Patch.Raw)
+		default:
+			err = fmt.Errorf("unsupported type %s", p.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to apply spec.patches[%d]: %w", i, err)
+		}
+	}
+	return config, nil
+}
+
+// applyHotfixPatch applies the JSON Patch of the hotfix annotation, if any.
+func applyHotfixPatch(dashboard customv1.Dashboard, config []byte) ([]byte, error) {
+	patchJson := dashboard.Annotations[customv1.HotfixPatchAnnotation]
+	if patchJson == "" {
+		return config, nil
+	}
+	patch, err := jsonpatch.DecodePatch([]byte(patchJson))
+	if err != nil {
+		return nil, err
+	}
+	return patch.Apply(config)
+}
@@ -0,0 +1,375 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestRenderTemplatedConfig(t *testing.T) {
+	dashboard := customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{
+			Templated: true,
+			Config: &apiextensionsv1.JSON{Raw: []byte(
+				`{"title":"Shop {{ .Vars.stage }} {{ .Zone }}","widgets":[{"id":"w","config":{"value":"{{ .ClusterName }}/{{ .Namespace }}/{{ .Name }}","other":"{ not a template }"}}]}`)},
+		},
+	}
+	vars := RenderVariables{ClusterName: "prod-eu", Zone: "eu-west-1", Vars: map[string]string{"stage": "prod"}}
+	c := fake.NewClientBuilder().Build()
+
+	config, err := renderConfig(context.Background(), c, vars, dashboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"title":"Shop prod eu-west-1","widgets":[{"config":{"other":"{ not a template }","value":"prod-eu/team-a/shop"},"id":"w"}]}`
+	if string(config) != want {
+		t.Errorf("config = %s, want %s", config, want)
+	}
+
+	// configs are only rendered if templated
+	dashboard.Spec.Templated = false
+	if config, _ := renderConfig(context.Background(), c, vars, dashboard); string(config) != string(dashboard.Spec.Config.Raw) {
+		t.Errorf("config = %s", config)
+	}
+
+	// unknown variables are reported
+	dashboard.Spec.Templated = true
+	dashboard.Spec.Config.Raw = []byte(`{"title":"{{ .Vars.missing }}"}`)
+	if _, err := renderConfig(context.Background(), c, vars, dashboard); err == nil {
+		t.Error("expected an error for an unknown variable")
+	}
+}
+
+func TestRenderAccessRules(t *testing.T) {
+	dashboard := customv1.Dashboard{Spec: customv1.DashboardSpec{
+		Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","accessRules":[{"accessType":"READ_WRITE","relationType":"GLOBAL","relatedId":""}]}`)},
+		AccessRules: []customv1.AccessRule{
+			{AccessType: "READ", RelationType: "GLOBAL"},
+			{AccessType: "READ_WRITE", RelationType: "TEAM", RelatedId: "team-a"},
+		},
+	}}
+	config, err := renderConfig(context.Background(), fake.NewClientBuilder().Build(), RenderVariables{}, dashboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"accessRules":[{"accessType":"READ","relatedId":"","relationType":"GLOBAL"},{"accessType":"READ_WRITE","relatedId":"team-a","relationType":"TEAM"}],"title":"Shop"}`
+	if string(config) != want {
+		t.Errorf("config = %s, want %s", config, want)
+	}
+}
+
+func TestTitlePolicy(t *testing.T) {
+	tenant := InstanaApi{TitlePrefix: "[prod-eu] ", TitleSuffix: " (managed)"}
+	config, err := applyTitlePolicy([]byte(`{"title":"Shop","widgets":[]}`), tenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"title":"[prod-eu] Shop (managed)","widgets":[]}`; string(config) != want {
+		t.Errorf("config = %s, want %s", config, want)
+	}
+	config, err = stripTitlePolicy(config, tenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"title":"Shop","widgets":[]}`; string(config) != want {
+		t.Errorf("config = %s, want %s", config, want)
+	}
+
+	// configs are unchanged without a policy
+	if config, _ := applyTitlePolicy([]byte(`{"title": "Shop"}`), InstanaApi{}); string(config) != `{"title": "Shop"}` {
+		t.Errorf("config = %s", config)
+	}
+}
+
+func TestRenderConfigFrom(t *testing.T) {
+	dashboard := customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{
+			Config:     &apiextensionsv1.JSON{Raw: []byte(`{"title":"ignored"}`)},
+			ConfigFrom: &customv1.ConfigMapKeyReference{Name: "dashboards", Key: "shop.json"},
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "dashboards"},
+		Data:       map[string]string{"shop.json": `{"title":"Shop","widgets":[]}`, "broken.json": `{`},
+	}
+	c := fake.NewClientBuilder().WithObjects(configMap).Build()
+
+	config, err := renderConfig(context.Background(), c, RenderVariables{}, dashboard)
+	if err != nil || string(config) != `{"title":"Shop","widgets":[]}` {
+		t.Errorf("renderConfig() = %s, %v", config, err)
+	}
+	for _, key := range []string{"missing.json", "broken.json"} {
+		dashboard.Spec.ConfigFrom.Key = key
+		if _, err := renderConfig(context.Background(), c, RenderVariables{}, dashboard); err == nil {
+			t.Errorf("expected an error for the key %s", key)
+		}
+	}
+}
+
+func TestRenderPatches(t *testing.T) {
+	dashboard := customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{
+			ConfigFrom: &customv1.ConfigMapKeyReference{Name: "dashboards", Key: "shop.json"},
+			Templated:  true,
+			Patches: []customv1.ConfigPatch{
+				{Patch: apiextensionsv1.JSON{Raw: []byte(`[{"op":"replace","path":"/widgets/0/title","value":"Errors ({{ .ClusterName }})"}]`)}},
+				{Type: customv1.ConfigPatchMerge, Patch: apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop (prod)","writable":null}`)}},
+			},
+		},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "dashboards"},
+		Data:       map[string]string{"shop.json": `{"title":"Shop","widgets":[{"id":"a","title":"Errors"}],"writable":true}`},
+	}
+	c := fake.NewClientBuilder().WithObjects(configMap).Build()
+
+	config, err := renderConfig(context.Background(), c, RenderVariables{ClusterName: "eu"}, dashboard)
+	if err != nil || string(config) != `{"title":"Shop (prod)","widgets":[{"id":"a","title":"Errors (eu)"}]}` {
+		t.Errorf("renderConfig() = %s, %v", config, err)
+	}
+
+	// patches of paths which don't exist fail the sync
+	dashboard.Spec.Patches[0].Patch.Raw = []byte(`[{"op":"replace","path":"/widgets/1/title","value":"x"}]`)
+	if _, err := renderConfig(context.Background(), c, RenderVariables{}, dashboard); err == nil {
+		t.Error("expected an error for a patch of a missing widget")
+	}
+}
+
+func TestRenderValuesFrom(t *testing.T) {
+	dashboard := customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[{"id":"a","config":{"entity":"{{ .Values.entity }}"}}]}`)},
+			ValuesFrom: []customv1.ValueSource{
+				{Name: "entity", SecretKeyRef: customv1.SecretKeyReference{Name: "shop-ids", Key: "entity-id"}},
+			},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop-ids"},
+		Data:       map[string][]byte{"entity-id": []byte("customer-4711-entity\n")},
+	}
+	c := fake.NewClientBuilder().WithObjects(secret).Build()
+
+	config, err := renderConfig(context.Background(), c, RenderVariables{}, dashboard)
+	if err != nil || string(config) != `{"title":"Shop","widgets":[{"config":{"entity":"customer-4711-entity"},"id":"a"}]}` {
+		t.Errorf("renderConfig() = %s, %v", config, err)
+	}
+	if msg := Redact("invalid entity customer-4711-entity"); msg != "invalid entity "+redacted {
+		t.Errorf("values should be redacted, got %s", msg)
+	}
+
+	dashboard.Spec.ValuesFrom[0].SecretKeyRef.Key = "missing"
+	if _, err := renderConfig(context.Background(), c, RenderVariables{}, dashboard); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+}
+
+func TestStatusTitleRedactsValuesFrom(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "team-a", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec: customv1.DashboardSpec{
+				Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop {{ .Values.customer }}","widgets":[]}`)},
+				ValuesFrom: []customv1.ValueSource{
+					{Name: "customer", SecretKeyRef: customv1.SecretKeyReference{Name: "customer", Key: "name"}},
+				},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: "customer"},
+			Data:       map[string][]byte{"name": []byte("acme-corporation")},
+		},
+	).Build()
+	instana := newFakeInstanaClient()
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatal(err)
+	}
+	var got customv1.Dashboard
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.DashboardTitle != "Shop "+redacted {
+		t.Errorf("status.dashboard-title = %q, want the value of the Secret redacted", got.Status.DashboardTitle)
+	}
+	if !strings.Contains(string(instana.dashboards[got.Status.DashboardId]), "Shop acme-corporation") {
+		t.Errorf("dashboard = %s, want the title with the value", instana.dashboards[got.Status.DashboardId])
+	}
+}
+
+func TestRenderEntityRefs(t *testing.T) {
+	dashboard := customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[` +
+				`{"id":"a","config":{"entity":"{{ .Entities.payments }}"}},` +
+				`{"id":"b","config":{"entity":"{{ entity \"Deployment\" \"payments\" \"prod\" }}","query":"{{ entityQuery \"StatefulSet\" \"db\" }}"}}]}`)},
+			EntityRefs: []customv1.EntityRef{{Name: "payments", Kind: "Deployment", Workload: "payments", Namespace: "prod"}},
+		},
+	}
+	instana := newFakeInstanaClient()
+	instana.snapshots[`entity.kubernetes.deployment.name:"payments" AND entity.kubernetes.namespace:"prod"`] = []InstanaSnapshot{{SnapshotId: "snap-1"}}
+	c := fake.NewClientBuilder().Build()
+
+	config, err := renderConfig(context.Background(), c, RenderVariables{Instana: instana}, dashboard)
+	expected := `{"title":"Shop","widgets":[{"config":{"entity":"snap-1"},"id":"a"},` +
+		`{"config":{"entity":"snap-1","query":"entity.kubernetes.statefulset.name:\"db\" AND entity.kubernetes.namespace:\"team-a\""},"id":"b"}]}`
+	if err != nil || string(config) != expected {
+		t.Errorf("renderConfig() = %s, %v", config, err)
+	}
+	if len(instana.calls) != 1 {
+		t.Errorf("each workload should be searched once, calls = %v", instana.calls)
+	}
+
+	// workloads without or with several entities can't be resolved
+	instana.snapshots[`entity.kubernetes.deployment.name:"payments" AND entity.kubernetes.namespace:"prod"`] = []InstanaSnapshot{{SnapshotId: "snap-1"}, {SnapshotId: "snap-2"}}
+	if _, err := renderConfig(context.Background(), c, RenderVariables{Instana: instana}, dashboard); err == nil || searchFailure(err) != nil {
+		t.Errorf("expected a render error for an ambiguous entity, got %v", err)
+	}
+	dashboard.Spec.EntityRefs[0].Workload = "missing"
+	if _, err := renderConfig(context.Background(), c, RenderVariables{Instana: instana}, dashboard); err == nil || searchFailure(err) != nil {
+		t.Errorf("expected a render error for a missing entity, got %v", err)
+	}
+
+	// failed searches are returned as the error of the Instana API
+	instana.err = ErrCircuitOpen
+	_, err = renderConfig(context.Background(), c, RenderVariables{Instana: instana}, dashboard)
+	if searchFailure(err) == nil || err.Error() != ErrCircuitOpen.Error() {
+		t.Errorf("renderConfig() = %v, want the entity search error", err)
+	}
+	dashboard.Spec.EntityRefs = nil
+	dashboard.Spec.Templated = true
+	dashboard.Spec.Config = &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[{"id":"b","config":{"entity":"{{ entity \"Deployment\" \"payments\" }}"}}]}`)}
+	_, err = renderConfig(context.Background(), c, RenderVariables{Instana: instana}, dashboard)
+	if searchFailure(err) == nil || !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("renderConfig() of the entity template function = %v, want the entity search error", err)
+	}
+}
+
+func TestEntitySearchFailureIsRetried(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "team-a", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec: customv1.DashboardSpec{
+			Config:     &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[{"id":"a","config":{"entity":"{{ .Entities.payments }}"}}]}`)},
+			EntityRefs: []customv1.EntityRef{{Name: "payments", Kind: "Deployment", Workload: "payments"}},
+		},
+	}).Build()
+	instana := newFakeInstanaClient()
+	instana.err = &InstanaApiError{Method: "POST", StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err == nil {
+		t.Error("expected the failed search to be retried")
+	}
+	var got customv1.Dashboard
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(got.Status.Conditions, customv1.ConditionReconciling) || meta.IsStatusConditionTrue(got.Status.Conditions, customv1.ConditionStalled) {
+		t.Errorf("conditions = %+v, want Reconciling instead of Stalled", got.Status.Conditions)
+	}
+}
+
+func TestStatusRedactsShortValuesFrom(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "team-a", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec: customv1.DashboardSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop {{ .Values.customer }}","widgets":[]}`)},
+			ValuesFrom: []customv1.ValueSource{
+				{Name: "customer", SecretKeyRef: customv1.SecretKeyReference{Name: "customer", Key: "name"}},
+			},
+		},
+	}).Build()
+	// the Secret is only readable with the APIReader
+	apiReader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: "customer"},
+		Data:       map[string][]byte{"name": []byte("acme")},
+	}).Build()
+	instana := newFakeInstanaClient()
+	// Instana rejects the dashboard, quoting its title
+	instana.err = errors.New(`invalid title "Shop acme"`)
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+		APIReader:        apiReader,
+	}
+	_, syncErr := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	var got customv1.Dashboard
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if syncErr == nil {
+		t.Fatal("Reconcile() succeeded, want the rejected dashboard to fail the sync")
+	}
+	if !strings.Contains(got.Status.LastError, redacted) || strings.Contains(got.Status.LastError, "acme") {
+		t.Errorf("status.last-error = %q, want the value of the Secret redacted", got.Status.LastError)
+	}
+	for _, condition := range got.Status.Conditions {
+		if strings.Contains(condition.Message, "acme") {
+			t.Errorf("condition %s = %q, want the value of the Secret redacted", condition.Type, condition.Message)
+		}
+	}
+
+	instana.err = nil
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatal(err)
+	}
+	got = customv1.Dashboard{}
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.DashboardTitle != "Shop "+redacted {
+		t.Errorf("status.dashboard-title = %q, want the value of the Secret redacted", got.Status.DashboardTitle)
+	}
+}
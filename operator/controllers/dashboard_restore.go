@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// ListSnapshots returns the timestamps of the backup snapshots of a
+// dashboard in the default tenant, oldest first. The snapshots of its
+// clusters and variants are not included.
+func ListSnapshots(ctx context.Context, store BackupStore, namespace string, name string) ([]string, error) {
+	dir := path.Join(instanaConfigName, namespace, name)
+	keys, err := store.List(ctx, dir+"/")
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []string
+	for _, key := range keys {
+		if path.Dir(key) != dir {
+			continue
+		}
+		if _, ok := snapshotTime(key); ok {
+			snapshots = append(snapshots, strings.TrimSuffix(path.Base(key), ".json"))
+		}
+	}
+	return snapshots, nil
+}
+
+// restoreSnapshot restores the dashboard in Instana from the snapshot of the
+// restore annotation. A dashboard still existing under the id of the status
+// is updated in place, so a retry after a failed restore doesn't leave
+// duplicates behind. Otherwise the dashboard is re-created and the status is
+// bound to the new dashboard.
+func (r *DashboardReconciler) restoreSnapshot(ctx context.Context, dashboard *customv1.Dashboard, instanaClient InstanaClient, log logr.Logger) error {
+	if r.BackupStore == nil {
+		return fmt.Errorf("restoring a snapshot requires --backup-location")
+	}
+	requested := dashboard.Annotations[customv1.RestoreSnapshotAnnotation]
+	snapshots, err := ListSnapshots(ctx, r.BackupStore, dashboard.Namespace, dashboard.Name)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no backup snapshot found for %s/%s", dashboard.Namespace, dashboard.Name)
+	}
+	timestamp := snapshots[len(snapshots)-1]
+	if requested != "latest" {
+		timestamp = ""
+		for _, s := range snapshots {
+			if s == requested {
+				timestamp = s
+			}
+		}
+		if timestamp == "" {
+			return fmt.Errorf("backup snapshot %s not found, available: %s", requested, strings.Join(snapshots, ", "))
+		}
+	}
+
+	data, err := r.BackupStore.Get(ctx, path.Join(instanaConfigName, dashboard.Namespace, dashboard.Name, timestamp+".json"))
+	if err != nil {
+		return err
+	}
+	var snapshot BackupSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("unable to parse backup snapshot %s: %w", timestamp, err)
+	}
+	config, err := importableConfig(snapshot.Config)
+	if err != nil {
+		return fmt.Errorf("unable to parse backup snapshot %s: %w", timestamp, err)
+	}
+	config, err = injectManagedMarker(config, ManagedMarker{
+		Cluster:   r.ClusterName,
+		Namespace: dashboard.Namespace,
+		Name:      dashboard.Name,
+		UID:       string(dashboard.UID),
+		Tags:      dashboard.Spec.Tags,
+	})
+	if err != nil {
+		return err
+	}
+	ids := []string{dashboard.Status.DashboardId}
+	// the dashboard re-created by an earlier attempt whose status update failed
+	if stored, err := r.IdStore.Load(ctx, client.ObjectKeyFromObject(dashboard)); err == nil && stored != ids[0] {
+		ids = append(ids, stored)
+	}
+	var apiResponse InstanaApiResponse
+	for _, id := range ids {
+		if id == "" || apiResponse.Id != "" {
+			continue
+		}
+		apiResponse, err = instanaClient.updateDashboard(id, config, log)
+		if err != nil && !isInstanaNotFound(err) {
+			return fmt.Errorf("unable to restore dashboard %s: %w", id, err)
+		}
+	}
+	if apiResponse.Id == "" {
+		apiResponse, err = instanaClient.createDashboard(config, log)
+		if err != nil {
+			return err
+		}
+	}
+	log.Info("Restored dashboard from backup snapshot " + timestamp + " as " + apiResponse.Id)
+	r.Recorder.Event(dashboard, corev1.EventTypeNormal, "Restored",
+		"Restored dashboard from backup snapshot "+timestamp+" as "+apiResponse.Id)
+	dashboard.Status.DashboardId = apiResponse.Id
+	dashboard.Status.DashboardTitle = knownSecrets.redact(apiResponse.Title)
+	// the restored config differs from spec.config, so the next sync applies it again
+	dashboard.Status.AppliedConfigHash = ""
+	return nil
+}
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// detectDrift returns the paths in which the live dashboard differs from the
+// desired config. Only changes done outside of the operator count as drift,
+// so nothing is reported while the desired config differs from the last applied one.
+func detectDrift(dashboard *customv1.Dashboard, instanaClient InstanaClient, desired []byte, log logr.Logger) ([]string, []byte, error) {
+	if dashboard.Status.DashboardId == "" || dashboard.Status.AppliedConfigHash != configHash(desired) {
+		return nil, nil, nil
+	}
+	live, err := instanaClient.getDashboard(dashboard.Status.DashboardId, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	drift, err := configDrift(desired, live)
+	return drift, live, err
+}
+
+// enforce checks the live dashboard for changes done in Instana and returns
+// the payload which reverts them. Changes of advisory widgets are only
+// reported and kept in the payload.
+func (r *DashboardReconciler) enforce(dashboard *customv1.Dashboard, instanaClient InstanaClient, desired []byte, log logr.Logger) []byte {
+	drift, live, err := detectDrift(dashboard, instanaClient, desired, log)
+	if err != nil {
+		log.Error(err, "unable to check dashboard for changes done in Instana")
+		return desired
+	}
+	payload := desired
+	var advisoryDrift []string
+	if len(drift) > 0 && len(dashboard.Spec.AdvisoryWidgets) > 0 {
+		merged, err := mergeAdvisoryWidgets(desired, live, dashboard.Spec.AdvisoryWidgets)
+		if err != nil {
+			log.Error(err, "unable to keep changes of advisory widgets")
+		} else if enforcedDrift, err := configDrift(merged, live); err == nil {
+			payload = merged
+			advisoryDrift = subtractPaths(drift, enforcedDrift)
+			drift = enforcedDrift
+		}
+	}
+	setAdvisoryDriftStatus(dashboard, advisoryDrift)
+	if len(drift) > 0 {
+		log.Info("Reverting changes done in Instana", "drift", drift)
+		r.Recorder.Event(dashboard, corev1.EventTypeWarning, "Reverted", "Reverted changes of "+strings.Join(drift, ", ")+" done in Instana")
+	}
+	return payload
+}
+
+// mergeAdvisoryWidgets replaces the advisory widgets of the desired config
+// with their live version.
+func mergeAdvisoryWidgets(desired []byte, live []byte, advisory []string) ([]byte, error) {
+	var d, l map[string]interface{}
+	if err := json.Unmarshal(desired, &d); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(live, &l); err != nil {
+		return nil, err
+	}
+	liveWidgets := map[interface{}]interface{}{}
+	if widgets, ok := l["widgets"].([]interface{}); ok {
+		for _, w := range widgets {
+			if widget, ok := w.(map[string]interface{}); ok {
+				liveWidgets[widget["id"]] = widget
+			}
+		}
+	}
+	if widgets, ok := d["widgets"].([]interface{}); ok {
+		for i, w := range widgets {
+			widget, ok := w.(map[string]interface{})
+			if !ok || !containsString(advisory, widget["id"]) {
+				continue
+			}
+			if liveWidget, ok := liveWidgets[widget["id"]]; ok {
+				widgets[i] = liveWidget
+			}
+		}
+	}
+	return json.Marshal(d)
+}
+
+// setAdvisoryDriftStatus reports changes of advisory widgets which are kept.
+func setAdvisoryDriftStatus(dashboard *customv1.Dashboard, drift []string) {
+	if len(drift) == 0 {
+		removeStatusCondition(&dashboard.Status.Conditions, customv1.ConditionAdvisoryDrift)
+		return
+	}
+	meta.SetStatusCondition(&dashboard.Status.Conditions, metav1.Condition{
+		Type:    customv1.ConditionAdvisoryDrift,
+		Status:  metav1.ConditionTrue,
+		Reason:  "AdvisoryWidgetsChanged",
+		Message: "Advisory widgets were changed in Instana: " + strings.Join(drift, ", "),
+	})
+}
+
+func containsString(values []string, value interface{}) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func subtractPaths(paths []string, remove []string) []string {
+	var result []string
+	for _, p := range paths {
+		if !containsString(remove, p) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// importDrift writes the live config of a drifted dashboard back into the
+// spec. It returns true if the spec was updated.
+func (r *DashboardReconciler) importDrift(ctx context.Context, dashboard *customv1.Dashboard, instanaApi InstanaApi, desired []byte, log logr.Logger) (bool, error) {
+	drift, live, err := detectDrift(dashboard, r.instanaClient(ctx, instanaApi), desired, log)
+	if err != nil || len(drift) == 0 {
+		return false, err
+	}
+	if dashboard.Spec.ConfigFrom != nil {
+		log.Info("Not importing changes from Instana as the config is read from spec.config-from", "drift", drift)
+		return false, nil
+	}
+	if dashboard.Spec.Jsonnet != "" {
+		log.Info("Not importing changes from Instana as the config is rendered from spec.jsonnet", "drift", drift)
+		return false, nil
+	}
+	if dashboard.Spec.Templated {
+		// the live config contains the rendered templates
+		log.Info("Not importing changes from Instana as the config is templated", "drift", drift)
+		return false, nil
+	}
+	if _, ok := dashboard.Annotations[customv1.HotfixPatchAnnotation]; ok {
+		// the live config contains the hotfix, which is meant to be temporary
+		log.Info("Not importing changes from Instana while a hotfix patch is applied", "drift", drift)
+		return false, nil
+	}
+	if len(dashboard.Spec.WidgetsFrom) > 0 {
+		// the live config contains the widgets of the libraries, which would be added again
+		log.Info("Not importing changes from Instana as the config uses spec.widgets-from", "drift", drift)
+		return false, nil
+	}
+	if len(dashboard.Spec.Includes) > 0 {
+		// the live config contains the included widgets, which would be included again
+		log.Info("Not importing changes from Instana as the config uses spec.includes", "drift", drift)
+		return false, nil
+	}
+	if len(dashboard.Spec.Patches) > 0 {
+		log.Info("Not importing changes from Instana as the config is patched by spec.patches", "drift", drift)
+		return false, nil
+	}
+	if len(dashboard.Spec.ValuesFrom) > 0 {
+		// the live config contains the values of the Secrets
+		log.Info("Not importing changes from Instana as the config uses spec.values-from", "drift", drift)
+		return false, nil
+	}
+	if len(dashboard.Spec.EntityRefs) > 0 {
+		// the live config contains the resolved snapshot ids
+		log.Info("Not importing changes from Instana as the config uses spec.entity-refs", "drift", drift)
+		return false, nil
+	}
+	config, err := importableConfig(live)
+	if err == nil {
+		config, err = stripTitlePolicy(config, instanaApi)
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Info("Importing changes from Instana into the spec", "drift", drift)
+	dashboard.Spec.Config = &apiextensionsv1.JSON{Raw: config}
+	if err := r.Update(ctx, dashboard); err != nil {
+		return false, err
+	}
+	r.Recorder.Event(dashboard, corev1.EventTypeNormal, "Imported", "Imported changes of "+strings.Join(drift, ", ")+" from Instana")
+	return true, nil
+}
+
+// serverSideFields are set by Instana and not part of a dashboard config.
+var serverSideFields = []string{"id", "ownerId", "writable", "created", "lastUpdated"}
+
+// importableConfig strips server side fields and the managed marker from a
+// live dashboard config.
+func importableConfig(live []byte) ([]byte, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(live, &payload); err != nil {
+		return nil, err
+	}
+	for _, field := range serverSideFields {
+		delete(payload, field)
+	}
+	if widgets, ok := payload["widgets"].([]interface{}); ok {
+		result := []interface{}{}
+		for _, w := range widgets {
+			if widget, ok := w.(map[string]interface{}); ok && widget["id"] == managedMarkerWidgetId {
+				continue
+			}
+			result = append(result, w)
+		}
+		payload["widgets"] = result
+	}
+	return json.Marshal(payload)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// importAndRender syncs the dashboard, changes its title in Instana and
+// imports the change with the Import sync policy. It returns whether the
+// import updated the spec and the error of rendering the spec again.
+func importAndRender(t *testing.T, dashboard *customv1.Dashboard, objects ...client.Object) (bool, error) {
+	t.Helper()
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(objects, dashboard)...).Build()
+	desired, err := renderConfig(ctx, c, RenderVariables{}, *dashboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instana := newFakeInstanaClient()
+	instana.dashboards["d1"] = []byte(strings.Replace(string(desired), `"title":"Shop"`, `"title":"Shop (edited)"`, 1))
+	dashboard.Status.DashboardId = "d1"
+	dashboard.Status.AppliedConfigHash = configHash(desired)
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	imported, err := r.importDrift(ctx, dashboard, InstanaApi{}, desired, r.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got customv1.Dashboard
+	if err := c.Get(ctx, client.ObjectKeyFromObject(dashboard), &got); err != nil {
+		t.Fatal(err)
+	}
+	_, err = renderConfig(ctx, c, RenderVariables{}, got)
+	return imported, err
+}
+
+func TestImportSkipsWidgetsFrom(t *testing.T) {
+	library := &customv1.WidgetLibrary{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "golden-signals"},
+		Spec: customv1.WidgetLibrarySpec{Widgets: []customv1.LibraryWidget{
+			{Name: "calls", Widget: apiextensionsv1.JSON{Raw: []byte(`{"id":"calls","height":10}`)}},
+			{Name: "notes", Widget: apiextensionsv1.JSON{Raw: []byte(`{"height":2}`)}},
+		}},
+	}
+	dashboard := &customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{
+			Config:      &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)},
+			SyncPolicy:  customv1.SyncPolicyImport,
+			WidgetsFrom: []customv1.WidgetSource{{Library: "golden-signals"}},
+		},
+	}
+	imported, err := importAndRender(t, dashboard, library)
+	if imported {
+		t.Error("the widgets of spec.widgets-from were imported into spec.config")
+	}
+	if err != nil {
+		t.Errorf("the dashboard can't be rendered after the import: %v", err)
+	}
+}
+
+func TestImportSkipsIncludes(t *testing.T) {
+	checkout := &customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "checkout"},
+		Spec:       customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Checkout","widgets":[{"id":"latency","height":5}]}`)}},
+	}
+	dashboard := &customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{
+			Config:     &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)},
+			SyncPolicy: customv1.SyncPolicyImport,
+			Includes:   []customv1.DashboardInclude{{Name: "checkout"}},
+		},
+	}
+	imported, err := importAndRender(t, dashboard, checkout)
+	if imported {
+		t.Error("the widgets of spec.includes were imported into spec.config")
+	}
+	if err != nil {
+		t.Errorf("the dashboard can't be rendered after the import: %v", err)
+	}
+}
+
+func TestImportSkipsTemplatedConfigs(t *testing.T) {
+	dashboard := &customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{
+			Config:     &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[{"id":"a","config":"{{ .Namespace }}"}]}`)},
+			SyncPolicy: customv1.SyncPolicyImport,
+			Templated:  true,
+		},
+	}
+	if imported, _ := importAndRender(t, dashboard); imported {
+		t.Error("the rendered templates were imported into spec.config")
+	}
+}
+
+func TestImportSkipsHotfixes(t *testing.T) {
+	dashboard := &customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop",
+			Annotations: map[string]string{customv1.HotfixPatchAnnotation: `[{"op":"add","path":"/widgets/-","value":{"id":"fix"}}]`}},
+		Spec: customv1.DashboardSpec{
+			Config:     &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)},
+			SyncPolicy: customv1.SyncPolicyImport,
+		},
+	}
+	if imported, _ := importAndRender(t, dashboard); imported {
+		t.Error("the hotfix patch was imported into spec.config")
+	}
+}
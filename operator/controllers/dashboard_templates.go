@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// dashboardTemplatesName is the ConfigMap in the namespace of the tenant
+// config holding the dashboard templates of the generators, by name. Its
+// templates take precedence over the built-in ones.
+const dashboardTemplatesName = "instana-dashboard-templates"
+
+// TemplateData is passed to dashboard templates. The variables have the
+// names of those of templated configs.
+type TemplateData struct {
+	// RenderVariables are the .ClusterName, .Zone and .Vars of the
+	// operator. The params of the annotations are added to the .Vars.
+	RenderVariables
+	// Namespace of the generated dashboard.
+	Namespace string
+	// Kind and Name of the workload, empty for namespace dashboards.
+	Kind string
+	Name string
+}
+
+var templateFuncs = template.FuncMap{
+	// json quotes a value, e.g. {{ json .Vars.title }}
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"lower": strings.ToLower,
+}
+
+// ParseKeyValues parses key=value pairs separated by commas, e.g. of
+// annotations and flags.
+func ParseKeyValues(value string) map[string]string {
+	params := map[string]string{}
+	for _, pair := range splitList(value) {
+		if i := strings.Index(pair, "="); i > 0 {
+			params[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+		}
+	}
+	return params
+}
+
+// applyGeneratedDashboard renders the template selected by the annotations of
+// the owner into the Dashboard and creates or updates it, controlled by the
+// owner. Without custom.instana.io/generate-dashboard: "true" on the owner the
+// Dashboard is deleted instead.
+func applyGeneratedDashboard(ctx context.Context, c client.Client, scheme *runtime.Scheme, owner client.Object, dashboard *customv1.Dashboard, generator string, defaultTemplate string, data TemplateData, log logr.Logger) error {
+	annotations := owner.GetAnnotations()
+	if annotations[customv1.GenerateDashboardAnnotation] != "true" {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(dashboard), dashboard); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if !metav1.IsControlledBy(dashboard, owner) {
+			return nil
+		}
+		log.Info("Deleting generated dashboard", "dashboard", dashboard.Name)
+		return client.IgnoreNotFound(c.Delete(ctx, dashboard))
+	}
+
+	name := annotations[customv1.DashboardTemplateAnnotation]
+	if name == "" {
+		name = defaultTemplate
+	}
+	vars := map[string]string{}
+	for k, v := range data.Vars {
+		vars[k] = v
+	}
+	for k, v := range ParseKeyValues(annotations[customv1.DashboardParamsAnnotation]) {
+		vars[k] = v
+	}
+	data.Vars = vars
+	config, err := renderDashboardTemplate(ctx, c, name, data)
+	if err != nil {
+		return err
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, c, dashboard, func() error {
+		if dashboard.Labels == nil {
+			dashboard.Labels = map[string]string{}
+		}
+		dashboard.Labels[customv1.GeneratorLabel] = generator
+		dashboard.Spec.Config = &apiextensionsv1.JSON{Raw: config}
+		return controllerutil.SetControllerReference(owner, dashboard, scheme)
+	})
+	if err != nil {
+		return err
+	}
+	if result != controllerutil.OperationResultNone {
+		log.Info("Applied generated dashboard", "dashboard", dashboard.Name, "template", name, "result", result)
+	}
+	return nil
+}
+
+// renderDashboardTemplate renders the named template, YAML or JSON, into the
+// JSON config of a dashboard.
+func renderDashboardTemplate(ctx context.Context, c client.Client, name string, data TemplateData) ([]byte, error) {
+	source, ok := builtinTemplates[name]
+	var cm corev1.ConfigMap
+	err := c.Get(ctx, client.ObjectKey{Namespace: instanaConfigNamespace, Name: dashboardTemplatesName}, &cm)
+	switch {
+	case err == nil:
+		if custom, found := cm.Data[name]; found {
+			source, ok = custom, true
+		}
+	case !apierrors.IsNotFound(err):
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("dashboard template %s not found", name)
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dashboard template %s: %w", name, err)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("unable to render dashboard template %s: %w", name, err)
+	}
+	config, err := yaml.YAMLToJSON(rendered.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("dashboard template %s rendered invalid YAML: %w", name, err)
+	}
+	return config, nil
+}
+
+// builtinTemplates are used unless the templates ConfigMap overrides them.
+var builtinTemplates = map[string]string{
+	"namespace": `
+title: {{ json (printf "Namespace %s (%s)" .Namespace .ClusterName) }}
+accessRules:
+- accessType: READ_WRITE
+  relationType: GLOBAL
+  relatedId: ""
+widgets:
+- id: namespace-info
+  title: Info
+  width: 12
+  height: 4
+  x: 0
+  y: 0
+  type: markdown
+  config: {{ json (printf "Generated for the namespace %s. Don't edit, changes are overwritten." .Namespace) }}
+- id: namespace-cpu
+  title: Pod CPU Requests
+  width: 6
+  height: 13
+  x: 0
+  y: 4
+  type: chart
+  config:
+    type: TIME_SERIES
+    y1:
+      formatter: number.detailed
+      renderer: line
+      metrics:
+      - metric: cpuRequests
+        aggregation: SUM
+        label: CPU Requests
+        source: INFRASTRUCTURE_METRICS
+        type: kubernetesPod
+        timeShift: 0
+        tagFilterExpression: {{ template "namespaceFilter" . }}
+    y2:
+      formatter: number.detailed
+      renderer: line
+      metrics: []
+- id: namespace-memory
+  title: Pod Memory Requests
+  width: 6
+  height: 13
+  x: 6
+  y: 4
+  type: chart
+  config:
+    type: TIME_SERIES
+    y1:
+      formatter: bytes.detailed
+      renderer: line
+      metrics:
+      - metric: memoryRequests
+        aggregation: SUM
+        label: Memory Requests
+        source: INFRASTRUCTURE_METRICS
+        type: kubernetesPod
+        timeShift: 0
+        tagFilterExpression: {{ template "namespaceFilter" . }}
+    y2:
+      formatter: number.detailed
+      renderer: line
+      metrics: []
+{{- define "namespaceFilter" }}
+          type: EXPRESSION
+          logicalOperator: AND
+          elements:
+          - type: TAG_FILTER
+            name: kubernetes.namespace.name
+            operator: EQUALS
+            value: {{ json .Namespace }}
+          - type: TAG_FILTER
+            name: kubernetes.cluster.name
+            operator: EQUALS
+            value: {{ json .ClusterName }}
+{{- end }}
+`,
+	"workload": `
+title: {{ with .Vars.title }}{{ json . }}{{ else }}{{ json (printf "%s %s/%s (%s)" .Kind .Namespace .Name .ClusterName) }}{{ end }}
+accessRules:
+- accessType: READ_WRITE
+  relationType: GLOBAL
+  relatedId: ""
+widgets:
+- id: workload-info
+  title: Info
+  width: 12
+  height: 4
+  x: 0
+  y: 0
+  type: markdown
+  config: {{ json (printf "Generated for the %s %s/%s. Don't edit, changes are overwritten." .Kind .Namespace .Name) }}
+- id: workload-calls
+  title: Calls
+  width: 4
+  height: 13
+  x: 0
+  y: 4
+  type: chart
+  config:
+    type: TIME_SERIES
+    y1:
+      formatter: number.detailed
+      renderer: line
+      metrics:
+      - metric: calls
+        aggregation: SUM
+        label: Calls
+        source: APPLICATION
+        timeShift: 0
+        tagFilterExpression: {{ template "workloadFilter" . }}
+    y2:
+      formatter: number.detailed
+      renderer: line
+      metrics: []
+- id: workload-latency
+  title: Latency
+  width: 4
+  height: 13
+  x: 4
+  y: 4
+  type: chart
+  config:
+    type: TIME_SERIES
+    y1:
+      formatter: millis.detailed
+      renderer: line
+      metrics:
+      - metric: latency
+        aggregation: MEAN
+        label: Latency
+        source: APPLICATION
+        timeShift: 0
+        tagFilterExpression: {{ template "workloadFilter" . }}
+    y2:
+      formatter: number.detailed
+      renderer: line
+      metrics: []
+- id: workload-errors
+  title: Error Rate
+  width: 4
+  height: 13
+  x: 8
+  y: 4
+  type: chart
+  config:
+    type: TIME_SERIES
+    y1:
+      formatter: percentage.detailed
+      renderer: line
+      metrics:
+      - metric: errors
+        aggregation: MEAN
+        label: Error Rate
+        source: APPLICATION
+        timeShift: 0
+        tagFilterExpression: {{ template "workloadFilter" . }}
+    y2:
+      formatter: number.detailed
+      renderer: line
+      metrics: []
+{{- define "workloadFilter" }}
+          type: EXPRESSION
+          logicalOperator: AND
+          elements:
+          - type: TAG_FILTER
+            name: {{ printf "kubernetes.%s.name" (lower .Kind) }}
+            entity: DESTINATION
+            operator: EQUALS
+            value: {{ json .Name }}
+          - type: TAG_FILTER
+            name: kubernetes.namespace.name
+            entity: DESTINATION
+            operator: EQUALS
+            value: {{ json .Namespace }}
+          - type: TAG_FILTER
+            name: kubernetes.cluster.name
+            entity: DESTINATION
+            operator: EQUALS
+            value: {{ json .ClusterName }}
+{{- end }}
+`,
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// valuesFromIndex indexes Dashboards by the Secrets of their spec.values-from.
+const valuesFromIndex = "spec.values-from.secret"
+
+func indexValuesFrom(obj client.Object) []string {
+	dashboard := obj.(*customv1.Dashboard)
+	var names []string
+	seen := map[string]bool{}
+	for _, source := range dashboard.Spec.ValuesFrom {
+		if name := source.SecretKeyRef.Name; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// dashboardValues returns the values of spec.values and those of
+// spec.values-from read from their Secrets. The values of Secrets are
+// redacted in logs, events and status messages.
+func dashboardValues(ctx context.Context, c client.Reader, dashboard customv1.Dashboard) (map[string]string, error) {
+	if len(dashboard.Spec.Values) == 0 && len(dashboard.Spec.ValuesFrom) == 0 {
+		return nil, nil
+	}
+	values := map[string]string{}
+	for name, value := range dashboard.Spec.Values {
+		values[name] = value
+	}
+	var secrets []string
+	for _, source := range dashboard.Spec.ValuesFrom {
+		ref := source.SecretKeyRef
+		value, err := secretValue(ctx, c, dashboard.Namespace, &ref)
+		if err != nil {
+			return nil, err
+		}
+		knownSecrets.add(value)
+		secrets = append(secrets, value)
+		values[source.Name] = value
+	}
+	valuesFromSecrets.set(client.ObjectKeyFromObject(&dashboard), secrets)
+	return values, nil
+}
+
+// secretsReader reads Secrets with the APIReader and everything else with
+// the cached client, so the Secrets of spec.values-from are not cached.
+type secretsReader struct {
+	client.Reader
+	apiReader client.Reader
+}
+
+func (r secretsReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if _, ok := obj.(*corev1.Secret); ok {
+		return r.apiReader.Get(ctx, key, obj)
+	}
+	return r.Reader.Get(ctx, key, obj)
+}
+
+// renderReader returns the reader of the resources a config is rendered from.
+func (r *DashboardReconciler) renderReader() client.Reader {
+	return secretsReader{Reader: r.Client, apiReader: r.apiReader()}
+}
+
+// Status returns the status writer of the client, redacting the status of
+// the Dashboards.
+func (r *DashboardReconciler) Status() client.StatusWriter {
+	return redactingStatusWriter{StatusWriter: r.Client.Status()}
+}
+
+// valuesFromSecrets are the values of spec.values-from of each Dashboard.
+// Unlike knownSecrets they are redacted whatever their length, but only in
+// the status of their Dashboard.
+var valuesFromSecrets = &dashboardSecrets{values: map[types.NamespacedName][]string{}}
+
+type dashboardSecrets struct {
+	mu     sync.RWMutex
+	values map[types.NamespacedName][]string
+}
+
+// set replaces the values of a Dashboard.
+func (s *dashboardSecrets) set(key types.NamespacedName, values []string) {
+	var secrets []string
+	for _, value := range values {
+		if value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	// longer values first, so a value containing another one is redacted as a whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(secrets) == 0 {
+		delete(s.values, key)
+		return
+	}
+	s.values[key] = secrets
+}
+
+func (s *dashboardSecrets) redact(key types.NamespacedName, text string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, secret := range s.values[key] {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	return text
+}
+
+// redactStatus redacts the title and the messages of the status of a
+// Dashboard, which may contain the values of its Secrets.
+func redactStatus(dashboard *customv1.Dashboard) {
+	key := client.ObjectKeyFromObject(dashboard)
+	message := func(text string) string {
+		return Redact(valuesFromSecrets.redact(key, text))
+	}
+	status := &dashboard.Status
+	// the patterns are not applied to titles, they are no error messages
+	status.DashboardTitle = knownSecrets.redact(valuesFromSecrets.redact(key, status.DashboardTitle))
+	status.LastError = message(status.LastError)
+	for i := range status.Clusters {
+		status.Clusters[i].Error = message(status.Clusters[i].Error)
+	}
+	for i := range status.Variants {
+		status.Variants[i].Error = message(status.Variants[i].Error)
+	}
+	for i := range status.Conditions {
+		status.Conditions[i].Message = message(status.Conditions[i].Message)
+	}
+}
+
+// dashboardsForSecret maps a Secret to the Dashboards reading values from it.
+func (r *DashboardReconciler) dashboardsForSecret(obj client.Object) []reconcile.Request {
+	var dashboards customv1.DashboardList
+	if err := r.List(context.Background(), &dashboards, client.InNamespace(obj.GetNamespace()), client.MatchingFields{valuesFromIndex: obj.GetName()}); err != nil {
+		r.Log.Error(err, "unable to list dashboards")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, dashboard := range dashboards.Items {
+		if !r.Shard.OwnsDashboard(&dashboard) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&dashboard)})
+	}
+	return requests
+}
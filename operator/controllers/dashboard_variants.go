@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// validateVariants rejects variants without or with duplicate names and
+// variants combined with spec.clusters.
+func validateVariants(dashboard *customv1.Dashboard) error {
+	if len(dashboard.Spec.Variants) > 0 && len(dashboard.Spec.Clusters) > 0 {
+		return errors.New("spec.variants can't be combined with spec.clusters")
+	}
+	names := map[string]bool{}
+	for _, variant := range dashboard.Spec.Variants {
+		if variant.Name == "" {
+			return errors.New("variants need a name")
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("duplicate variant %s", variant.Name)
+		}
+		names[variant.Name] = true
+	}
+	return nil
+}
+
+// syncVariant renders the config of the variant and syncs its dashboard.
+func (r *DashboardReconciler) syncVariant(ctx context.Context, dashboard customv1.Dashboard, instanaApi InstanaApi, variant customv1.DashboardVariant, id string, log logr.Logger) (InstanaApiResponse, error) {
+	vars := r.Variables
+	vars.Vars = map[string]string{}
+	for k, v := range r.Variables.Vars {
+		vars.Vars[k] = v
+	}
+	for k, v := range variant.Vars {
+		vars.Vars[k] = v
+	}
+	vars.Instana = r.instanaClient(ctx, instanaApi)
+	dashboard.Spec.Templated = true
+	dashboard.Spec.Patches = append(append([]customv1.ConfigPatch{}, dashboard.Spec.Patches...), variant.Patches...)
+	config, err := renderConfig(ctx, r.renderReader(), vars, dashboard)
+	if err == nil {
+		config, err = qualifyTitle(config, variant.Name)
+	}
+	if err == nil {
+		config, err = applyTitlePolicy(config, instanaApi)
+	}
+	if err == nil {
+		config, err = injectManagedMarker(config, ManagedMarker{
+			Cluster:   r.ClusterName,
+			Namespace: dashboard.Namespace,
+			Name:      dashboard.Name,
+			UID:       string(dashboard.UID),
+			Tags:      dashboard.Spec.Tags,
+		})
+	}
+	if err != nil && searchFailure(err) == nil {
+		err = stalledError{err}
+	}
+	if err != nil {
+		return InstanaApiResponse{}, err
+	}
+	return syncDashboard(r.instanaClient(ctx, instanaApi), id, config, log)
+}
+
+// deleteVariantDashboards deletes the dashboards of all variants of the status.
+func deleteVariantDashboards(dashboard *customv1.Dashboard, instanaClient InstanaClient, log logr.Logger) error {
+	for len(dashboard.Status.Variants) > 0 {
+		status := dashboard.Status.Variants[0]
+		if status.DashboardId != "" {
+			log.Info("Deleting dashboard of variant", "variant", status.Name, "id", status.DashboardId)
+			if err := instanaClient.deleteDashboard(status.DashboardId, log); err != nil && !isInstanaNotFound(err) {
+				return err
+			}
+		}
+		dashboard.Status.Variants = dashboard.Status.Variants[1:]
+	}
+	dashboard.Status.Variants = nil
+	return nil
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestReconcileVariants(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	dashboard := &customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec: customv1.DashboardSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(
+				`{"title":"Shop","widgets":[{"id":"w","config":{"value":"{{ .Vars.namespace }}","threshold":100}}]}`)},
+			Variants: []customv1.DashboardVariant{
+				{Name: "staging", Vars: map[string]string{"namespace": "shop-staging"}},
+				{Name: "prod", Vars: map[string]string{"namespace": "shop-prod"}, Patches: []customv1.ConfigPatch{
+					{Patch: apiextensionsv1.JSON{Raw: []byte(`[{"op":"replace","path":"/widgets/0/config/threshold","value":500}]`)}},
+				}},
+			},
+		},
+		Status: customv1.DashboardStatus{DashboardId: "fake-1"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dashboard).Build()
+	instana := newFakeInstanaClient()
+	instana.dashboards["fake-1"] = []byte(`{"title":"Shop"}`)
+	instana.nextId = 1
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	reconcile := func() customv1.Dashboard {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+		var got customv1.Dashboard
+		if err := c.Get(ctx, key, &got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// the single dashboard is replaced by one dashboard per variant
+	got := reconcile()
+	if got.Status.DashboardId != "" || len(got.Status.Variants) != 2 {
+		t.Fatalf("status = %+v", got.Status)
+	}
+	thresholds := map[string]string{"staging": `"threshold":100`, "prod": `"threshold":500`}
+	for _, status := range got.Status.Variants {
+		config := string(instana.dashboards[status.DashboardId])
+		if !strings.Contains(config, `"title":"Shop (`+status.Name+`)"`) || !strings.Contains(config, `"value":"shop-`+status.Name+`"`) ||
+			!strings.Contains(config, thresholds[status.Name]) {
+			t.Errorf("dashboard of variant %s = %s", status.Name, config)
+		}
+	}
+	if fmt.Sprint(instana.calls) != "[create create delete fake-1]" {
+		t.Errorf("Instana calls = %v", instana.calls)
+	}
+
+	// dashboards of removed variants are deleted
+	got.Spec.Variants = got.Spec.Variants[1:]
+	if err := c.Update(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	instana.calls = nil
+	got = reconcile()
+	if len(got.Status.Variants) != 1 || got.Status.Variants[0].Name != "prod" || got.Status.Variants[0].DashboardId != "fake-3" {
+		t.Errorf("status.variants = %+v", got.Status.Variants)
+	}
+	if fmt.Sprint(instana.calls) != "[get fake-3 delete fake-2]" {
+		t.Errorf("Instana calls = %v", instana.calls)
+	}
+
+	// variants can't be combined with clusters
+	got.Spec.Clusters = []string{"prod-eu"}
+	if err := c.Update(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err == nil {
+		t.Error("expected an error for variants combined with clusters")
+	}
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if stalled := meta.FindStatusCondition(got.Status.Conditions, customv1.ConditionStalled); stalled == nil || stalled.Status != metav1.ConditionTrue {
+		t.Errorf("conditions = %+v", got.Status.Conditions)
+	}
+}
+
+func TestVariantsKeepSingleDashboardUntilSynced(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec: customv1.DashboardSpec{
+			Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)},
+			Variants: []customv1.DashboardVariant{
+				{Name: "staging"},
+				{Name: "prod", Patches: []customv1.ConfigPatch{
+					{Patch: apiextensionsv1.JSON{Raw: []byte(`[{"op":"test","path":"/title","value":"Other"}]`)}},
+				}},
+			},
+		},
+		Status: customv1.DashboardStatus{DashboardId: "fake-1"},
+	}).Build()
+	instana := newFakeInstanaClient()
+	instana.dashboards["fake-1"] = []byte(`{"title":"Shop"}`)
+	instana.nextId = 1
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err == nil {
+		t.Error("expected an error for the broken patch of the variant")
+	}
+	var got customv1.Dashboard
+	if err := c.Get(ctx, key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.DashboardId != "fake-1" || instana.dashboards["fake-1"] == nil {
+		t.Errorf("status = %+v, want the single dashboard kept while a variant fails", got.Status)
+	}
+	if fmt.Sprint(instana.calls) != "[create]" {
+		t.Errorf("Instana calls = %v", instana.calls)
+	}
+}
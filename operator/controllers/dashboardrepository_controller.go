@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/yaml"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+const (
+	defaultRepositoryInterval = 5 * time.Minute
+	defaultRepositoryTimeout  = 2 * time.Minute
+)
+
+// DashboardRepositoryReconciler reconciles a DashboardRepository object. It
+// clones the repository and creates a Dashboard for every dashboard file.
+type DashboardRepositoryReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Shard limits the reconciler to the repositories in namespaces of this shard.
+	Shard Shard
+	// Timeout limits the clone of a repository. Defaults to 2 minutes.
+	Timeout time.Duration
+}
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=dashboardrepositories,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=dashboardrepositories/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=dashboardrepositories/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Reconcile checks out the repository and creates, updates and deletes the
+// Dashboards of the repository. The repository is polled in spec.interval.
+func (r *DashboardRepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("dashboardrepository", req.NamespacedName)
+
+	var repository customv1.DashboardRepository
+	if err := r.Get(ctx, req.NamespacedName, &repository); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	interval := defaultRepositoryInterval
+	if repository.Spec.Interval != nil && repository.Spec.Interval.Duration > 0 {
+		interval = repository.Spec.Interval.Duration
+	}
+
+	commit, files, err := r.checkout(ctx, repository, log)
+	if err == nil {
+		err = r.materialize(ctx, &repository, files, log)
+	}
+	condition := metav1.Condition{
+		Type:    customv1.ConditionSynced,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Synced",
+		Message: "Dashboards are in sync with commit " + commit,
+	}
+	if err != nil {
+		log.Error(err, "unable to sync dashboard repository")
+		r.Recorder.Event(&repository, corev1.EventTypeWarning, "SyncFailed", err.Error())
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "SyncFailed"
+		condition.Message = redactError(err)
+	} else {
+		if commit != repository.Status.LastCommit {
+			r.Recorder.Event(&repository, corev1.EventTypeNormal, "Synced", "Synced dashboards from commit "+commit)
+		}
+		repository.Status.LastCommit = commit
+		repository.Status.ObservedGeneration = repository.Generation
+	}
+	meta.SetStatusCondition(&repository.Status.Conditions, condition)
+	if statusErr := r.Status().Update(ctx, &repository); statusErr != nil {
+		log.Error(statusErr, "unable to update dashboard repository status")
+		return ctrl.Result{}, statusErr
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// checkout clones the branch of the repository into a temporary directory
+// and returns the head commit and the dashboard files below spec.path,
+// keyed by their path relative to spec.path. Only regular files inside the
+// checkout are read, symbolic links are skipped.
+func (r *DashboardRepositoryReconciler) checkout(ctx context.Context, repository customv1.DashboardRepository, log logr.Logger) (string, map[string][]byte, error) {
+	if err := validateRepositoryUrl(repository.Spec.URL); err != nil {
+		return "", nil, err
+	}
+	auth, err := r.auth(ctx, repository)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := ioutil.TempDir("", "dashboard-repository-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return "", nil, err
+	}
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultRepositoryTimeout
+	}
+	cloneCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	log.Info("Cloning dashboard repository", "url", repository.Spec.URL, "branch", repository.Spec.Branch)
+	repo, err := gogit.PlainCloneContext(cloneCtx, dir, false, &gogit.CloneOptions{
+		URL:           repository.Spec.URL,
+		Auth:          auth,
+		ReferenceName: plumbing.NewBranchReferenceName(repository.Spec.Branch),
+		SingleBranch:  true,
+		Depth:         1,
+		Tags:          gogit.NoTags,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to clone branch %s of %s: %w", repository.Spec.Branch, repository.Spec.URL, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", nil, err
+	}
+
+	root := filepath.Join(dir, filepath.FromSlash(repository.Spec.Path))
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	if rel, err := filepath.Rel(dir, root); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", nil, fmt.Errorf("path %s is outside of the repository", repository.Spec.Path)
+	}
+	files := map[string][]byte{}
+	// Walk doesn't follow symbolic links, the file info is the one of Lstat
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".json", ".yaml", ".yml":
+		default:
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			log.Info("Skipping dashboard file which is no regular file", "file", path[len(dir)+1:])
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		config, err := yaml.YAMLToJSON(content)
+		if err != nil {
+			return fmt.Errorf("unable to parse %s: %w", path[len(dir)+1:], err)
+		}
+		rel, _ := filepath.Rel(root, path)
+		files[filepath.ToSlash(rel)] = config
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return head.Hash().String(), files, nil
+}
+
+// validateRepositoryUrl only accepts remote repositories, so the checkout of
+// a repository can't read the file system of the operator.
+func validateRepositoryUrl(repositoryUrl string) error {
+	u, err := url.Parse(repositoryUrl)
+	if err != nil {
+		return fmt.Errorf("invalid repository url: %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("repository url %s is not a http or https url", repositoryUrl)
+	}
+	return nil
+}
+
+// auth returns the credentials of spec.secret-ref, if set.
+func (r *DashboardRepositoryReconciler) auth(ctx context.Context, repository customv1.DashboardRepository) (transport.AuthMethod, error) {
+	if repository.Spec.SecretRef == "" {
+		return nil, nil
+	}
+	var secret corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: repository.Namespace, Name: repository.Spec.SecretRef}, &secret); err != nil {
+		return nil, fmt.Errorf("unable to load secret %s: %w", repository.Spec.SecretRef, err)
+	}
+	return &githttp.BasicAuth{Username: string(secret.Data["username"]), Password: string(secret.Data["password"])}, nil
+}
+
+// materialize creates or updates a Dashboard for every file and deletes the
+// Dashboards of files which were removed from the repository.
+func (r *DashboardRepositoryReconciler) materialize(ctx context.Context, repository *customv1.DashboardRepository, files map[string][]byte, log logr.Logger) error {
+	wanted := map[string]bool{}
+	for path, config := range files {
+		name := resourceName(repository.Name+"-"+strings.TrimSuffix(path, filepath.Ext(path)), path)
+		if wanted[name] {
+			return fmt.Errorf("file %s maps to the dashboard name %s of another file", path, name)
+		}
+		wanted[name] = true
+		dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: repository.Namespace, Name: name}}
+		result, err := controllerutil.CreateOrUpdate(ctx, r.Client, dashboard, func() error {
+			if dashboard.Labels == nil {
+				dashboard.Labels = map[string]string{}
+			}
+			dashboard.Labels[customv1.RepositoryLabel] = repository.Name
+			dashboard.Spec.InstanaApiTokenRelationId = repository.Spec.InstanaApiTokenRelationId
+			dashboard.Spec.InstanaUserId = repository.Spec.InstanaUserId
+			dashboard.Spec.Config = &apiextensionsv1.JSON{Raw: config}
+			return controllerutil.SetControllerReference(repository, dashboard, r.Scheme)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to apply dashboard %s from %s: %w", name, path, err)
+		}
+		if result != controllerutil.OperationResultNone {
+			log.Info("Applied dashboard from repository", "dashboard", name, "file", path, "result", result)
+		}
+	}
+
+	var dashboards customv1.DashboardList
+	if err := r.List(ctx, &dashboards, client.InNamespace(repository.Namespace), client.MatchingLabels{customv1.RepositoryLabel: repository.Name}); err != nil {
+		return err
+	}
+	for i := range dashboards.Items {
+		dashboard := &dashboards.Items[i]
+		if wanted[dashboard.Name] || !metav1.IsControlledBy(dashboard, repository) {
+			continue
+		}
+		log.Info("Deleting dashboard removed from repository", "dashboard", dashboard.Name)
+		if err := r.Delete(ctx, dashboard); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("unable to delete dashboard %s: %w", dashboard.Name, err)
+		}
+	}
+
+	repository.Status.Dashboards = make([]string, 0, len(wanted))
+	for name := range wanted {
+		repository.Status.Dashboards = append(repository.Status.Dashboards, name)
+	}
+	sort.Strings(repository.Status.Dashboards)
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DashboardRepositoryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&customv1.DashboardRepository{}, builder.WithPredicates(
+			r.Shard.Predicate(),
+			// Skip the status updates done by the reconciler itself
+			predicate.GenerationChangedPredicate{},
+		)).
+		// Restore Dashboards which were edited or deleted by hand
+		Owns(&customv1.Dashboard{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
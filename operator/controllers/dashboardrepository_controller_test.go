@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestReconcileDashboardRepository(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "dashboards-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile := func(name string, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit := func() {
+		for _, args := range [][]string{{"add", "-A"}, {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "test"}} {
+			if _, err := git(ctx, dir, args...); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := git(ctx, dir, "init", "-q", "-b", "main"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile("team-a/hosts.json", `{"title": "Hosts", "widgets": []}`)
+	writeFile("team-a/k8s/pods.yaml", "title: Pods\nwidgets: []\n")
+	writeFile("team-a/README.md", "ignored")
+	writeFile("other.json", `{"title": "Other"}`)
+	writeFile("secret.json", `{"title": "Secret"}`)
+	// links to files outside of spec.path are not followed
+	if err := os.Symlink(filepath.Join(dir, "secret.json"), filepath.Join(dir, "team-a/link.json")); err != nil {
+		t.Fatal(err)
+	}
+	commit()
+	// serve the repository like a Git server over the smart HTTP protocol
+	server := httptest.NewServer(&cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + dir, "GIT_HTTP_EXPORT_ALL=1"},
+	})
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	repository := &customv1.DashboardRepository{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dashboards", UID: "repository-uid"},
+		Spec:       customv1.DashboardRepositorySpec{URL: server.URL + "/.git", Branch: "main", Path: "team-a", InstanaUserId: "user"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(repository).Build()
+	r := &DashboardRepositoryReconciler{
+		Client:   c,
+		Log:      ctrl.Log.WithName("test"),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+	}
+	reconcile := func() customv1.DashboardRepository {
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(repository)}); err != nil {
+			t.Fatal(err)
+		}
+		var result customv1.DashboardRepository
+		if err := c.Get(ctx, client.ObjectKeyFromObject(repository), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	result := reconcile()
+	if got := result.Status.Dashboards; len(got) != 2 || got[0] != "dashboards-hosts" || got[1] != "dashboards-k8s-pods" {
+		t.Fatalf("unexpected dashboards %v, conditions %v", got, result.Status.Conditions)
+	}
+	if result.Status.LastCommit == "" {
+		t.Error("expected last commit in status")
+	}
+	var pods customv1.Dashboard
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "dashboards-k8s-pods"}, &pods); err != nil {
+		t.Fatal(err)
+	}
+	if string(pods.Spec.Config.Raw) != `{"title":"Pods","widgets":[]}` || pods.Spec.InstanaUserId != "user" || pods.Labels[customv1.RepositoryLabel] != "dashboards" {
+		t.Errorf("unexpected dashboard %s %v", pods.Spec.Config.Raw, pods.Labels)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "team-a/hosts.json")); err != nil {
+		t.Fatal(err)
+	}
+	commit()
+	result = reconcile()
+	if got := result.Status.Dashboards; len(got) != 1 || got[0] != "dashboards-k8s-pods" {
+		t.Fatalf("unexpected dashboards %v", got)
+	}
+	var hosts customv1.Dashboard
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "dashboards-hosts"}, &hosts); err == nil {
+		t.Error("expected dashboard of removed file to be deleted")
+	}
+}
+
+func TestValidateRepositoryUrl(t *testing.T) {
+	for _, repositoryUrl := range []string{"https://github.com/example/dashboards.git", "http://gitea.gitea:3000/team/dashboards.git"} {
+		if err := validateRepositoryUrl(repositoryUrl); err != nil {
+			t.Errorf("validateRepositoryUrl(%s) = %v", repositoryUrl, err)
+		}
+	}
+	for _, repositoryUrl := range []string{"file:///etc", "/etc", "git@github.com:example/dashboards.git", "ssh://github.com/example/dashboards.git"} {
+		if err := validateRepositoryUrl(repositoryUrl); err == nil {
+			t.Errorf("validateRepositoryUrl(%s) succeeded, want an error", repositoryUrl)
+		}
+	}
+}
+
+// git runs a git command without prompting for credentials.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// DebugState is the in-memory state of the operator dumped by DebugHandler.
+type DebugState struct {
+	Tenants         []DebugTenant         `json:"tenants"`
+	Dashboards      []DebugDashboard      `json:"dashboards"`
+	CircuitBreakers []DebugCircuitBreaker `json:"circuit-breakers"`
+}
+
+// DebugTenant is a tenant config map.
+type DebugTenant struct {
+	Name    string `json:"name"`
+	BaseUrl string `json:"base-url"`
+	Ready   bool   `json:"ready"`
+	Vault   bool   `json:"vault,omitempty"`
+	// Rejected is the error of the credential check if Instana rejected the config.
+	Rejected          string `json:"rejected,omitempty"`
+	AllowedNamespaces string `json:"allowed-namespaces,omitempty"`
+	// MissingPermissions are the kinds the token lacks the permissions for.
+	MissingPermissions []string `json:"missing-permissions,omitempty"`
+}
+
+// DebugDashboard is a Dashboard of the shard of this replica.
+type DebugDashboard struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	DashboardId  string `json:"dashboard-id,omitempty"`
+	MirrorTenant string `json:"mirror-tenant,omitempty"`
+	Synced       string `json:"synced"`
+	SyncAttempts int32  `json:"sync-attempts,omitempty"`
+	LastError    string `json:"last-error,omitempty"`
+	Deleting     bool   `json:"deleting,omitempty"`
+}
+
+// DebugCircuitBreaker is the circuit breaker of a tenant. OpenUntil is only
+// set while the breaker is open, HalfOpen once its cooldown is over until a
+// probe succeeded.
+type DebugCircuitBreaker struct {
+	Tenant    string     `json:"tenant"`
+	Failures  int        `json:"failures"`
+	OpenUntil *time.Time `json:"open-until,omitempty"`
+	HalfOpen  bool       `json:"half-open,omitempty"`
+}
+
+// DebugHandler serves the tenants loaded, the dashboards tracked and the
+// circuit breaker states of the operator as JSON, to diagnose stuck
+// reconciles. The dashboards are read from the cache of the manager.
+type DebugHandler struct {
+	client.Reader
+	Log             logr.Logger
+	Shard           Shard
+	CircuitBreaker  *CircuitBreaker
+	CredentialCheck *CredentialCheck
+}
+
+func (h *DebugHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	state, err := h.state(req)
+	if err != nil {
+		h.Log.Error(err, "unable to collect debug state")
+		http.Error(w, redactError(err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(state); err != nil {
+		h.Log.Error(err, "unable to write debug state")
+	}
+}
+
+func (h *DebugHandler) state(req *http.Request) (DebugState, error) {
+	ctx := req.Context()
+	state := DebugState{
+		Tenants:         []DebugTenant{},
+		Dashboards:      []DebugDashboard{},
+		CircuitBreakers: h.CircuitBreaker.states(),
+	}
+	var configMaps corev1.ConfigMapList
+	if err := h.List(ctx, &configMaps, client.InNamespace(instanaConfigNamespace)); err != nil {
+		return state, err
+	}
+	rejected := h.CredentialCheck.rejectedTenants()
+	for i := range configMaps.Items {
+		cm := &configMaps.Items[i]
+		if tenantBaseUrl(cm.Data) == "" {
+			continue
+		}
+		tenant := DebugTenant{
+			Name:              cm.Name,
+			BaseUrl:           tenantBaseUrl(cm.Data),
+			Ready:             tenantReady(cm),
+			Vault:             cm.Data["vault-address"] != "",
+			AllowedNamespaces: cm.Data["allowed-namespaces"],
+		}
+		if err, ok := rejected[cm.Name]; ok {
+			tenant.Rejected = redactError(err)
+		}
+		tenant.MissingPermissions = h.CredentialCheck.MissingPermissions(cm.Name)
+		state.Tenants = append(state.Tenants, tenant)
+	}
+
+	var dashboards customv1.DashboardList
+	if err := h.List(ctx, &dashboards); err != nil {
+		return state, err
+	}
+	for i := range dashboards.Items {
+		dashboard := &dashboards.Items[i]
+		if !h.Shard.OwnsDashboard(dashboard) {
+			continue
+		}
+		synced := "Unknown"
+		if condition := meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionSynced); condition != nil {
+			synced = string(condition.Status)
+		}
+		state.Dashboards = append(state.Dashboards, DebugDashboard{
+			Namespace:    dashboard.Namespace,
+			Name:         dashboard.Name,
+			DashboardId:  dashboard.Status.DashboardId,
+			MirrorTenant: dashboard.Status.MirrorTenant,
+			Synced:       synced,
+			SyncAttempts: dashboard.Status.SyncAttempts,
+			LastError:    Redact(dashboard.Status.LastError),
+			Deleting:     !dashboard.DeletionTimestamp.IsZero(),
+		})
+	}
+	return state, nil
+}
+
+// states returns the breaker state of every tenant, sorted by tenant.
+func (b *CircuitBreaker) states() []DebugCircuitBreaker {
+	states := []DebugCircuitBreaker{}
+	if b == nil {
+		return states
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for tenant, state := range b.tenants {
+		breaker := DebugCircuitBreaker{Tenant: tenant, Failures: state.failures}
+		if time.Now().Before(state.openUntil) {
+			openUntil := state.openUntil
+			breaker.OpenUntil = &openUntil
+		} else if state.failures >= b.Threshold && b.Threshold > 0 {
+			breaker.HalfOpen = true
+		}
+		states = append(states, breaker)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Tenant < states[j].Tenant })
+	return states
+}
+
+// rejectedTenants returns a copy of the tenants whose config Instana rejected.
+func (c *CredentialCheck) rejectedTenants() map[string]error {
+	rejected := map[string]error{}
+	if c == nil {
+		return rejected
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for tenant, err := range c.rejected {
+		rejected[tenant] = err
+	}
+	return rejected
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestDebugHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": "https://a.instana.io", "instana-api-token": "secret-api-token"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: "unrelated"},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "overview"},
+			Status: customv1.DashboardStatus{
+				DashboardId:  "1",
+				SyncAttempts: 3,
+				LastError:    "Authorization: apiToken secret-api-token",
+				Conditions:   []metav1.Condition{{Type: customv1.ConditionSynced, Status: metav1.ConditionFalse, Reason: "SyncFailed"}},
+			},
+		},
+	).Build()
+	breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Minute}
+	breaker.record("https://a.instana.io", &InstanaApiError{StatusCode: http.StatusBadGateway})
+	check := &CredentialCheck{}
+	check.record(instanaConfigName, errors.New("Instana rejected the API token"))
+	handler := &DebugHandler{Reader: c, Log: ctrl.Log.WithName("test"), CircuitBreaker: breaker, CredentialCheck: check}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/state", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret-api-token") {
+		t.Errorf("debug state contains the API token: %s", w.Body.String())
+	}
+	var state DebugState
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Tenants) != 1 || !state.Tenants[0].Ready || state.Tenants[0].Rejected == "" {
+		t.Errorf("tenants = %+v", state.Tenants)
+	}
+	if len(state.Dashboards) != 1 || state.Dashboards[0].Synced != "False" || state.Dashboards[0].SyncAttempts != 3 {
+		t.Errorf("dashboards = %+v", state.Dashboards)
+	}
+	if len(state.CircuitBreakers) != 1 || state.CircuitBreakers[0].OpenUntil == nil {
+		t.Errorf("circuit breakers = %+v", state.CircuitBreakers)
+	}
+}
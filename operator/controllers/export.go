@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// ExportFilter selects the dashboards to export. Empty fields match all dashboards.
+type ExportFilter struct {
+	// Title matches the title of the dashboard.
+	Title *regexp.Regexp
+	// AccessRule is "<relationType>" or "<relationType>:<relatedId>", e.g.
+	// "GLOBAL" or "USER:5f3a...", matched against the access rules of the dashboard.
+	AccessRule string
+	// Tags are matched against the tags of the managed marker. Dashboards
+	// need all of them.
+	Tags []string
+}
+
+// ExportOptions are set in the spec of exported dashboards.
+type ExportOptions struct {
+	Namespace                 string
+	InstanaApiTokenRelationId string
+}
+
+// ExportedDashboard is a dashboard of the tenant as Dashboard resource,
+// together with its id in Instana.
+type ExportedDashboard struct {
+	customv1.Dashboard
+	Id string
+}
+
+// ExportDashboards returns the dashboards of the tenant which match the
+// filter as Dashboard resources. The owner of the dashboard becomes the
+// instana-user-id. The resources have no status, their ids are returned next
+// to them.
+func ExportDashboards(apiConfig InstanaApi, filter ExportFilter, options ExportOptions, log logr.Logger) ([]ExportedDashboard, error) {
+	list, err := apiConfig.listDashboards(log)
+	if err != nil {
+		return nil, err
+	}
+	var dashboards []ExportedDashboard
+	names := map[string]int{}
+	for _, d := range list {
+		if filter.Title != nil && !filter.Title.MatchString(d.Title) {
+			continue
+		}
+		live, err := apiConfig.getDashboard(d.Id, log)
+		if err != nil {
+			return nil, err
+		}
+		if filter.AccessRule != "" && !hasAccessRule(live, filter.AccessRule) {
+			continue
+		}
+		marker, _ := parseManagedMarker(live)
+		if !marker.HasTags(filter.Tags) {
+			continue
+		}
+		config, err := importableConfig(live)
+		if err != nil {
+			return nil, fmt.Errorf("unable to export dashboard %s: %w", d.Id, err)
+		}
+		name := resourceName(d.Title, d.Id)
+		names[name]++
+		if names[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, names[name])
+		}
+		dashboards = append(dashboards, ExportedDashboard{
+			Dashboard: customv1.Dashboard{
+				TypeMeta:   metav1.TypeMeta{APIVersion: customv1.GroupVersion.String(), Kind: "Dashboard"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: options.Namespace, Name: name},
+				Spec: customv1.DashboardSpec{
+					InstanaApiTokenRelationId: options.InstanaApiTokenRelationId,
+					InstanaUserId:             ownerId(live),
+					Config:                    &apiextensionsv1.JSON{Raw: config},
+					Tags:                      marker.Tags,
+				},
+			},
+			Id: d.Id,
+		})
+	}
+	return dashboards, nil
+}
+
+// ConvertExport wraps a dashboard exported as JSON from the Instana UI into a
+// Dashboard resource. The name is derived from the title if empty. The
+// server side fields of the export, e.g. its id, are dropped.
+func ConvertExport(export []byte, name string, options ExportOptions) (customv1.Dashboard, error) {
+	config, err := importableConfig(export)
+	if err != nil {
+		return customv1.Dashboard{}, fmt.Errorf("unable to parse dashboard export: %w", err)
+	}
+	var payload struct {
+		Id    string `json:"id"`
+		Title string `json:"title"`
+	}
+	_ = json.Unmarshal(export, &payload)
+	if payload.Title == "" {
+		return customv1.Dashboard{}, fmt.Errorf("dashboard export has no title")
+	}
+	if name == "" {
+		name = resourceName(payload.Title, payload.Id)
+	}
+	return customv1.Dashboard{
+		TypeMeta:   metav1.TypeMeta{APIVersion: customv1.GroupVersion.String(), Kind: "Dashboard"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: options.Namespace, Name: name},
+		Spec: customv1.DashboardSpec{
+			InstanaApiTokenRelationId: options.InstanaApiTokenRelationId,
+			InstanaUserId:             ownerId(export),
+			Config:                    &apiextensionsv1.JSON{Raw: config},
+		},
+	}, nil
+}
+
+func ownerId(live []byte) string {
+	var payload struct {
+		OwnerId string `json:"ownerId"`
+	}
+	_ = json.Unmarshal(live, &payload)
+	return payload.OwnerId
+}
+
+func hasAccessRule(live []byte, rule string) bool {
+	var payload struct {
+		AccessRules []struct {
+			RelationType string `json:"relationType"`
+			RelatedId    string `json:"relatedId"`
+		} `json:"accessRules"`
+	}
+	if err := json.Unmarshal(live, &payload); err != nil {
+		return false
+	}
+	relationType, relatedId := rule, ""
+	if i := strings.Index(rule, ":"); i >= 0 {
+		relationType, relatedId = rule[:i], rule[i+1:]
+	}
+	for _, r := range payload.AccessRules {
+		if strings.EqualFold(r.RelationType, relationType) && (relatedId == "" || r.RelatedId == relatedId) {
+			return true
+		}
+	}
+	return false
+}
+
+var nonNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// resourceName derives a valid resource name from the dashboard title,
+// falling back to the id.
+func resourceName(title string, id string) string {
+	name := strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if name == "" {
+		name = strings.Trim(nonNameChars.ReplaceAllString(strings.ToLower(id), "-"), "-")
+	}
+	if len(name) > 60 {
+		name = strings.TrimRight(name[:60], "-")
+	}
+	return name
+}
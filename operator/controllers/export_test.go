@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/luebken/custom-dashboards/instanatest"
+)
+
+func TestExportDashboards(t *testing.T) {
+	instana := instanatest.NewServer("token")
+	defer instana.Close()
+	instana.PutDashboard("d1", map[string]interface{}{"title": "Team A Shop", "ownerId": "user-1", "widgets": []interface{}{}, "accessRules": []interface{}{}})
+	instana.PutDashboard("d2", map[string]interface{}{"title": "Team B", "widgets": []interface{}{}})
+
+	dashboards, err := ExportDashboards(InstanaApi{BaseUrl: instana.URL, ApiToken: "token"},
+		ExportFilter{Title: regexp.MustCompile("^Team A")}, ExportOptions{Namespace: "team-a"}, ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dashboards) != 1 {
+		t.Fatalf("exported %d dashboards, want 1", len(dashboards))
+	}
+	d := dashboards[0]
+	if d.Id != "d1" || d.Namespace != "team-a" || d.Name != "team-a-shop" || d.Spec.InstanaUserId != "user-1" {
+		t.Errorf("exported dashboard = %s %s/%s owned by %q", d.Id, d.Namespace, d.Name, d.Spec.InstanaUserId)
+	}
+	if d.Status.DashboardId != "" || d.Status.DashboardTitle != "" {
+		t.Errorf("status = %+v, want none", d.Status)
+	}
+	assertNoServerSideFields(t, d.Spec.Config.Raw)
+}
+
+func TestConvertExport(t *testing.T) {
+	export := []byte(`{"id":"ui-1","title":"Shop","ownerId":"user-1","widgets":[]}`)
+	d, err := ConvertExport(export, "", ExportOptions{Namespace: "team-a", InstanaApiTokenRelationId: "token-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "shop" || d.Namespace != "team-a" || d.Spec.InstanaApiTokenRelationId != "token-1" || d.Spec.InstanaUserId != "user-1" {
+		t.Errorf("converted dashboard = %s/%s %+v", d.Namespace, d.Name, d.Spec)
+	}
+	if d.Status.DashboardId != "" || d.Status.DashboardTitle != "" {
+		t.Errorf("status = %+v, want none", d.Status)
+	}
+	assertNoServerSideFields(t, d.Spec.Config.Raw)
+
+	if _, err := ConvertExport([]byte(`{"widgets":[]}`), "", ExportOptions{}); err == nil {
+		t.Error("expected an error for an export without title")
+	}
+}
+
+func assertNoServerSideFields(t *testing.T, config []byte) {
+	t.Helper()
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"id", "ownerId"} {
+		if _, ok := payload[field]; ok {
+			t.Errorf("config %s has the server side field %s", config, field)
+		}
+	}
+	if payload["title"] == nil {
+		t.Errorf("config %s has no title", config)
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IdStore persists the mapping of Dashboard resources to Instana dashboard ids
+// in addition to the status. It allows to re-link resources after the status
+// got lost, e.g. with an etcd restore from an older backup.
+type IdStore interface {
+	// Load returns the stored dashboard id or an empty string.
+	Load(ctx context.Context, key types.NamespacedName) (string, error)
+	Save(ctx context.Context, key types.NamespacedName, id string) error
+	Delete(ctx context.Context, key types.NamespacedName) error
+}
+
+// NewIdStore returns the store of the given kind: "configmap",
+// "namespace-annotation" or "none".
+func NewIdStore(kind string, c client.Client) (IdStore, error) {
+	switch kind {
+	case "configmap":
+		return &configMapIdStore{Client: c, Key: client.ObjectKey{Namespace: instanaConfigNamespace, Name: "instana-custom-dashboard-ids"}}, nil
+	case "namespace-annotation":
+		return &namespaceIdStore{Client: c}, nil
+	case "none", "":
+		return noopIdStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown id store %q, must be configmap, namespace-annotation or none", kind)
+	}
+}
+
+type noopIdStore struct{}
+
+func (noopIdStore) Load(context.Context, types.NamespacedName) (string, error) { return "", nil }
+func (noopIdStore) Save(context.Context, types.NamespacedName, string) error   { return nil }
+func (noopIdStore) Delete(context.Context, types.NamespacedName) error         { return nil }
+
+// configMapIdStore keeps all ids in a single ConfigMap. Keys are
+// "<namespace>_<name>" since underscores are not valid in either.
+type configMapIdStore struct {
+	client.Client
+	Key client.ObjectKey
+}
+
+func (s *configMapIdStore) Load(ctx context.Context, key types.NamespacedName) (string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.Get(ctx, s.Key, cm); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	return cm.Data[key.Namespace+"_"+key.Name], nil
+}
+
+func (s *configMapIdStore) Save(ctx context.Context, key types.NamespacedName, id string) error {
+	return s.update(ctx, func(data map[string]string) { data[key.Namespace+"_"+key.Name] = id })
+}
+
+func (s *configMapIdStore) Delete(ctx context.Context, key types.NamespacedName) error {
+	return s.update(ctx, func(data map[string]string) { delete(data, key.Namespace+"_"+key.Name) })
+}
+
+func (s *configMapIdStore) update(ctx context.Context, mutate func(map[string]string)) error {
+	cm := &corev1.ConfigMap{}
+	err := s.Get(ctx, s.Key, cm)
+	if apierrors.IsNotFound(err) {
+		cm.Namespace, cm.Name = s.Key.Namespace, s.Key.Name
+		cm.Data = map[string]string{}
+		mutate(cm.Data)
+		return s.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	mutate(cm.Data)
+	return s.Update(ctx, cm)
+}
+
+// namespaceIdStore keeps the ids of a namespace as JSON in an annotation of
+// the namespace itself.
+type namespaceIdStore struct {
+	client.Client
+}
+
+const namespaceIdsAnnotation = "custom.instana.io/dashboard-ids"
+
+func (s *namespaceIdStore) Load(ctx context.Context, key types.NamespacedName) (string, error) {
+	ns := &corev1.Namespace{}
+	if err := s.Get(ctx, client.ObjectKey{Name: key.Namespace}, ns); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	ids := map[string]string{}
+	if data := ns.Annotations[namespaceIdsAnnotation]; data != "" {
+		if err := json.Unmarshal([]byte(data), &ids); err != nil {
+			return "", err
+		}
+	}
+	return ids[key.Name], nil
+}
+
+func (s *namespaceIdStore) Save(ctx context.Context, key types.NamespacedName, id string) error {
+	return s.update(ctx, key.Namespace, func(ids map[string]string) { ids[key.Name] = id })
+}
+
+func (s *namespaceIdStore) Delete(ctx context.Context, key types.NamespacedName) error {
+	return s.update(ctx, key.Namespace, func(ids map[string]string) { delete(ids, key.Name) })
+}
+
+func (s *namespaceIdStore) update(ctx context.Context, namespace string, mutate func(map[string]string)) error {
+	ns := &corev1.Namespace{}
+	if err := s.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return err
+	}
+	ids := map[string]string{}
+	if data := ns.Annotations[namespaceIdsAnnotation]; data != "" {
+		if err := json.Unmarshal([]byte(data), &ids); err != nil {
+			return err
+		}
+	}
+	mutate(ids)
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[namespaceIdsAnnotation] = string(data)
+	return s.Update(ctx, ns)
+}
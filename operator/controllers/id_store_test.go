@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestIdStores(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "team-a", Name: "shop"}
+	for _, kind := range []string{"configmap", "namespace-annotation"} {
+		t.Run(kind, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}).Build()
+			store, err := NewIdStore(kind, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id, err := store.Load(ctx, key); err != nil || id != "" {
+				t.Errorf("Load() of an unknown dashboard = %q, %v", id, err)
+			}
+			if err := store.Save(ctx, key, "id-1"); err != nil {
+				t.Fatal(err)
+			}
+			if id, err := store.Load(ctx, key); err != nil || id != "id-1" {
+				t.Errorf("Load() = %q, %v, want id-1", id, err)
+			}
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			if id, err := store.Load(ctx, key); err != nil || id != "" {
+				t.Errorf("Load() of a deleted dashboard = %q, %v", id, err)
+			}
+		})
+	}
+	if _, err := NewIdStore("config-map", fake.NewClientBuilder().Build()); err == nil {
+		t.Error("expected an error for an unknown id store")
+	}
+}
+
+// countingIdStore records the saved ids and loads the last one.
+type countingIdStore struct {
+	noopIdStore
+	saved []string
+}
+
+func (s *countingIdStore) Load(context.Context, types.NamespacedName) (string, error) {
+	if len(s.saved) == 0 {
+		return "", nil
+	}
+	return s.saved[len(s.saved)-1], nil
+}
+
+func (s *countingIdStore) Save(_ context.Context, _ types.NamespacedName, id string) error {
+	s.saved = append(s.saved, id)
+	return nil
+}
+
+func TestReconcileSavesChangedIds(t *testing.T) {
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec:       customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)}},
+	}).Build()
+	store := &countingIdStore{}
+	instana := newFakeInstanaClient()
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          store,
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(store.saved) != "[fake-1]" {
+		t.Errorf("saved ids = %v, want only the id of the created dashboard", store.saved)
+	}
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=infraalertconfigs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=infraalertconfigs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=infraalertconfigs/finalizers,verbs=update
+
+// infraAlertConfigKind syncs InfraAlertConfigs with the infrastructure
+// Smart Alerts of the Events settings API.
+var infraAlertConfigKind = InstanaResourceKind{
+	Kind:         "InfraAlertConfig",
+	New:          func() InstanaResource { return &customv1.InfraAlertConfig{} },
+	Path:         "/api/events/settings/infra-alert-configs",
+	UpdateMethod: http.MethodPost,
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		alert := obj.(*customv1.InfraAlertConfig)
+		spec := alert.Spec
+		channelIds, err := alertChannelIds(ctx, c, alert.Namespace, spec.AlertChannelRefs, spec.AlertChannelIds)
+		if err != nil {
+			return nil, err
+		}
+		tagFilterExpression, err := jsonValue(spec.TagFilterExpression, map[string]interface{}{
+			"type": "EXPRESSION", "logicalOperator": "AND", "elements": []interface{}{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		thresholds := map[string]interface{}{}
+		alertChannels := map[string]interface{}{}
+		for severity, threshold := range map[string]*apiextensionsv1.JSON{"WARNING": spec.WarningThreshold, "CRITICAL": spec.CriticalThreshold} {
+			if threshold == nil {
+				continue
+			}
+			value, err := jsonValue(threshold, nil)
+			if err != nil {
+				return nil, err
+			}
+			thresholds[severity] = value
+			alertChannels[severity] = channelIds
+		}
+		if len(thresholds) == 0 {
+			return nil, stalledError{fmt.Errorf("either warning-threshold or critical-threshold is required")}
+		}
+		rule := map[string]interface{}{
+			"alertType":   "genericRule",
+			"entityType":  spec.EntityType,
+			"metricName":  spec.MetricName,
+			"aggregation": spec.Aggregation,
+		}
+		if spec.CrossSeriesAggregation != "" {
+			rule["crossSeriesAggregation"] = spec.CrossSeriesAggregation
+		}
+		timeWindow := spec.TimeWindow.Milliseconds()
+		return map[string]interface{}{
+			"name":                spec.Name,
+			"description":         spec.Description,
+			"tagFilterExpression": tagFilterExpression,
+			"groupBy":             nonNil(spec.GroupBy),
+			"alertChannels":       alertChannels,
+			"granularity":         timeWindow,
+			"timeThreshold":       map[string]interface{}{"type": "violationsInSequence", "timeWindow": timeWindow},
+			"evaluationType":      "CUSTOM",
+			"rules": []interface{}{map[string]interface{}{
+				"thresholdOperator": spec.ThresholdOperator,
+				"rule":              rule,
+				"thresholds":        thresholds,
+			}},
+		}, nil
+	},
+}
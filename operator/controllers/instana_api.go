@@ -6,99 +6,268 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-logr/logr"
-	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// Version of the operator, sent in the User-Agent header. Set at build time
+// with -ldflags "-X github.com/luebken/custom-dashboards/controllers.Version=<version>".
+var Version = "dev"
+
 type InstanaApiResponse struct {
 	Id    string `json:"id"`
 	Title string `json:"title"`
 }
 
+// InstanaSnapshot is an infrastructure entity returned by a snapshot search.
+type InstanaSnapshot struct {
+	SnapshotId string `json:"snapshotId"`
+	Label      string `json:"label"`
+}
+
 type InstanaApi struct {
 	ApiToken string
 	BaseUrl  string
+	// BackendFlavor is either "saas" (default) or "onprem".
+	BackendFlavor string
+	// AuthScheme is the scheme of the authorization header. Defaults to "apiToken".
+	AuthScheme string
+	// ApiPath replaces the "/api" prefix of the request paths for gateways
+	// which serve the API under another path, e.g. "/instana/api".
+	ApiPath string
+	// RequestId is sent as X-Request-Id header to correlate requests with the
+	// logs of a reconcile.
+	RequestId string
+	// TitlePrefix and TitleSuffix are added to the titles of the dashboards
+	// of the tenant, e.g. "[prod-eu] ".
+	TitlePrefix string
+	TitleSuffix string
+	// Vault reads the API token from HashiCorp Vault instead of ApiToken if set.
+	Vault *VaultTokenSource
+	// Actor is the custom resource on whose behalf requests are sent, recorded
+	// in the audit trail.
+	Actor client.Object
+	// RequestsPerSecond and RequestsBurst override the request rate of the
+	// TenantRateLimiter for the tenant if greater than 0.
+	RequestsPerSecond float64
+	RequestsBurst     int
+	// MaxConcurrentRequests limits the requests in flight against the tenant
+	// if greater than 0.
+	MaxConcurrentRequests int
+	// DryRun makes requests which would change Instana fail with ErrDryRun.
+	DryRun bool
 }
 
-func (apiConfig InstanaApi) createDashboard(dashboard customv1.Dashboard, log logr.Logger) InstanaApiResponse {
-	log.Info("Creating Instana dashboard")
+// InstanaApiError is returned for requests which Instana answered with a non 2xx status.
+type InstanaApiError struct {
+	Method     string
+	Path       string
+	Status     string
+	StatusCode int
+	Body       []byte
+}
 
-	instanaUrl := apiConfig.BaseUrl + "/api/custom-dashboard"
-	var jsonStr = []byte(dashboard.Spec.Config)
-	client := &http.Client{}
-	req2, err := http.NewRequest("POST", instanaUrl, bytes.NewBuffer(jsonStr))
+func (e *InstanaApiError) Error() string {
+	msg := fmt.Sprintf("%s %s failed with status %s", e.Method, e.Path, e.Status)
+	if detail := errorDetail(e.Body); detail != "" {
+		msg += ": " + detail
+	}
+	return msg
+}
+
+// maxErrorDetail is the number of bytes of the response body included in
+// the message of an InstanaApiError.
+const maxErrorDetail = 500
+
+// errorDetail returns the messages of an Instana error body, e.g.
+// {"errors":["..."]} or {"message":"..."}, or the body itself if it has no
+// messages, truncated to maxErrorDetail bytes. The caller redacts secrets.
+func errorDetail(body []byte) string {
+	var parsed struct {
+		Errors  []string `json:"errors"`
+		Message string   `json:"message"`
+		Error   string   `json:"error"`
+	}
+	detail := ""
+	if json.Unmarshal(body, &parsed) == nil {
+		messages := parsed.Errors
+		for _, m := range []string{parsed.Message, parsed.Error} {
+			if m != "" {
+				messages = append(messages, m)
+			}
+		}
+		detail = strings.Join(messages, "; ")
+	}
+	if detail == "" {
+		detail = string(body)
+	}
+	detail = strings.Join(strings.Fields(detail), " ")
+	if len(detail) <= maxErrorDetail {
+		return detail
+	}
+	cut := maxErrorDetail
+	for cut > 0 && !utf8.RuneStart(detail[cut]) {
+		cut--
+	}
+	return detail[:cut] + "..."
+}
+
+func (apiConfig InstanaApi) authorization() (string, error) {
+	scheme := apiConfig.AuthScheme
+	if scheme == "" {
+		scheme = "apiToken"
+	}
+	token := apiConfig.ApiToken
+	if apiConfig.Vault != nil {
+		var err error
+		if token, err = apiConfig.Vault.Token(); err != nil {
+			return "", err
+		}
+	}
+	return scheme + " " + token, nil
+}
+
+// requestUrl returns the url of the API path, with "/api" replaced by the
+// ApiPath of the tenant.
+func (apiConfig InstanaApi) requestUrl(path string) string {
+	if apiConfig.ApiPath != "" && (path == "/api" || strings.HasPrefix(path, "/api/")) {
+		path = strings.TrimSuffix("/"+strings.Trim(apiConfig.ApiPath, "/"), "/") + strings.TrimPrefix(path, "/api")
+	}
+	return strings.TrimSuffix(apiConfig.BaseUrl, "/") + path
+}
+
+// do sends a request against the Instana API and returns the response body.
+// Non 2xx responses are reported as error.
+func (apiConfig InstanaApi) do(method string, path string, body []byte, log logr.Logger) ([]byte, error) {
+	if apiConfig.DryRun && method != http.MethodGet && method != http.MethodHead {
+		log.Info("Dry run: skipping Instana request", "method", method, "path", path, "body", Redact(string(body)))
+		return nil, fmt.Errorf("%w: would send %s %s", ErrDryRun, method, path)
+	}
+	authorization, err := apiConfig.authorization()
 	if err != nil {
-		log.Info(err.Error())
+		return nil, err
 	}
-	req2.Header.Add("Accept", "application/json")
-	req2.Header.Set("Content-Type", "application/json")
-	req2.Header.Add("authorization", "apiToken "+apiConfig.ApiToken)
-	resp, err := client.Do(req2)
+	instanaUrl := apiConfig.requestUrl(path)
+	req, err := http.NewRequest(method, instanaUrl, bytes.NewBuffer(body))
 	if err != nil {
-		log.Info(err.Error())
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Add("authorization", authorization)
+	req.Header.Set("User-Agent", "instana-dashboards-operator/"+Version)
+	if apiConfig.RequestId != "" {
+		req.Header.Set("X-Request-Id", apiConfig.RequestId)
+	}
+	log.V(1).Info("Sending Instana request", "method", method, "url", instanaUrl, "headers", redactHeader(req.Header), "body", Redact(string(body)))
+	resp, err := apiConfig.httpClient().Do(req)
+	if err != nil {
+		Audit.record(apiConfig, method, path, 0, nil, err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Info(err.Error())
+		Audit.record(apiConfig, method, path, resp.StatusCode, nil, err)
+		return nil, err
+	}
+	log.Info(method + " Response.Status:" + resp.Status)
+	recordRateLimit(apiConfig.BaseUrl, resp.Header, time.Now())
+	log.V(1).Info("Received Instana response", "status", resp.Status, "headers", redactHeader(resp.Header), "body", Redact(string(bodyBytes)))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := &InstanaApiError{Method: method, Path: path, Status: resp.Status, StatusCode: resp.StatusCode, Body: bodyBytes}
+		Audit.record(apiConfig, method, path, resp.StatusCode, bodyBytes, err)
+		return bodyBytes, err
 	}
-	log.Info("POST Response.Status:" + resp.Status)
-	//fmt.Printf("response bodyBytes:%+v\n", string(bodyBytes))
+	Audit.record(apiConfig, method, path, resp.StatusCode, bodyBytes, nil)
+	return bodyBytes, nil
+}
+
+func (apiConfig InstanaApi) createDashboard(config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	log.Info("Creating Instana dashboard")
 
 	var r InstanaApiResponse
-	json.Unmarshal(bodyBytes, &r)
-	return r
+	bodyBytes, err := apiConfig.do("POST", "/api/custom-dashboard", config, log)
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(bodyBytes, &r)
+	return r, err
 }
 
-func (apiConfig InstanaApi) deleteDashboard(dashboard customv1.Dashboard, log logr.Logger) InstanaApiResponse {
-	log.Info("Deleting Instana dashboard")
+// updateDashboard replaces the dashboard with the given id. Instana expects
+// the id to be part of the payload as well.
+func (apiConfig InstanaApi) updateDashboard(id string, config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	log.Info("Updating Instana dashboard " + id)
 
-	instanaUrl := apiConfig.BaseUrl + "/api/custom-dashboard/" + dashboard.Status.DashboardId
-	client := &http.Client{}
-	req2, err := http.NewRequest("DELETE", instanaUrl, nil)
-	if err != nil {
-		log.Info(err.Error())
+	var r InstanaApiResponse
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return r, err
 	}
-	req2.Header.Add("Accept", "application/json")
-	req2.Header.Set("Content-Type", "application/json")
-	req2.Header.Add("authorization", "apiToken "+apiConfig.ApiToken)
-	resp, err := client.Do(req2)
+	payload["id"] = id
+	body, err := json.Marshal(payload)
 	if err != nil {
-		log.Info(err.Error())
+		return r, err
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	bodyBytes, err := apiConfig.do("PUT", "/api/custom-dashboard/"+id, body, log)
 	if err != nil {
-		log.Info(err.Error())
+		return r, err
 	}
-	log.Info("DELETE Response.Status:" + resp.Status)
-	//fmt.Printf("response bodyBytes:%+v\n", string(bodyBytes))
+	err = json.Unmarshal(bodyBytes, &r)
+	return r, err
+}
 
-	var r InstanaApiResponse
-	json.Unmarshal(bodyBytes, &r)
-	return r
+func (apiConfig InstanaApi) deleteDashboard(id string, log logr.Logger) error {
+	log.Info("Deleting Instana dashboard " + id)
+
+	_, err := apiConfig.do("DELETE", "/api/custom-dashboard/"+id, nil, log)
+	return err
 }
 
-func getInstanaDashboards(apiConfig InstanaApi, log logr.Logger) {
-	instanaUrl := apiConfig.BaseUrl + "/api/custom-dashboard"
-	client := &http.Client{}
-	req2, err := http.NewRequest("GET", instanaUrl, nil)
+// getDashboard returns the full config of the dashboard with the given id.
+func (apiConfig InstanaApi) getDashboard(id string, log logr.Logger) ([]byte, error) {
+	return apiConfig.do("GET", "/api/custom-dashboard/"+id, nil, log)
+}
+
+// listDashboards returns the id and title of all dashboards visible to the api token.
+func (apiConfig InstanaApi) listDashboards(log logr.Logger) ([]InstanaApiResponse, error) {
+	var r []InstanaApiResponse
+	bodyBytes, err := apiConfig.do("GET", "/api/custom-dashboard", nil, log)
 	if err != nil {
-		log.Info(err.Error())
+		return r, err
 	}
-	req2.Header.Add("Accept", "application/json")
-	req2.Header.Set("Content-Type", "application/json")
-	req2.Header.Add("authorization", "apiToken "+apiConfig.ApiToken)
-	resp, err := client.Do(req2)
+	err = json.Unmarshal(bodyBytes, &r)
+	return r, err
+}
+
+// searchSnapshots returns the snapshots of the plugin which match the dynamic
+// focus query.
+func (apiConfig InstanaApi) searchSnapshots(plugin string, query string, log logr.Logger) ([]InstanaSnapshot, error) {
+	params := url.Values{"plugin": {plugin}, "query": {query}}
+	bodyBytes, err := apiConfig.do("GET", "/api/infrastructure-monitoring/snapshots?"+params.Encode(), nil, log)
 	if err != nil {
-		log.Info(err.Error())
+		return nil, err
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	var r struct {
+		Items []InstanaSnapshot `json:"items"`
+	}
+	err = json.Unmarshal(bodyBytes, &r)
+	return r.Items, err
+}
+
+// getSnapshot returns the snapshot with the given id.
+func (apiConfig InstanaApi) getSnapshot(id string, log logr.Logger) (InstanaSnapshot, error) {
+	var r InstanaSnapshot
+	bodyBytes, err := apiConfig.do("GET", "/api/infrastructure-monitoring/snapshots/"+url.PathEscape(id), nil, log)
 	if err != nil {
-		log.Info(err.Error())
+		return r, err
 	}
-	log.Info("Response.Status:" + resp.Status)
-	fmt.Printf("response bodyBytes:%+v\n", string(bodyBytes))
+	err = json.Unmarshal(bodyBytes, &r)
+	return r, err
 }
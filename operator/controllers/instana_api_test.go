@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestInstanaApiErrorDetail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"errors":["widgets[2].type: unknown widget type 'pie'","token: s3cr3t-value"]}`))
+	}))
+	defer server.Close()
+
+	_, err := InstanaApi{BaseUrl: server.URL, ApiToken: "token"}.createDashboard([]byte(`{}`), ctrl.Log.WithName("test"))
+	if err == nil {
+		t.Fatal("no error")
+	}
+	msg := redactError(err)
+	if !strings.Contains(msg, "failed with status 400 Bad Request: widgets[2].type: unknown widget type 'pie'; token: [REDACTED]") {
+		t.Errorf("message = %q", msg)
+	}
+
+	long := errorDetail([]byte(strings.Repeat("ä", maxErrorDetail)))
+	if len(long) > maxErrorDetail+3 || !strings.HasSuffix(long, "...") {
+		t.Errorf("detail of %d bytes not truncated: %d bytes", 2*maxErrorDetail, len(long))
+	}
+}
+
+func TestInstanaApiPath(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	log := ctrl.Log.WithName("test")
+	for _, apiPath := range []string{"", "/gateway/instana-api/", "/"} {
+		if _, err := (InstanaApi{BaseUrl: server.URL + "/", ApiPath: apiPath}).getDashboard("1", log); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"/api/custom-dashboard/1", "/gateway/instana-api/custom-dashboard/1", "/custom-dashboard/1"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("requested %v, want %v", paths, want)
+	}
+}
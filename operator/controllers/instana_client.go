@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+)
+
+// InstanaClient is the part of the Instana API used to sync dashboards.
+// InstanaApi implements it via HTTP, tests may replace it with a fake.
+type InstanaClient interface {
+	createDashboard(config []byte, log logr.Logger) (InstanaApiResponse, error)
+	updateDashboard(id string, config []byte, log logr.Logger) (InstanaApiResponse, error)
+	deleteDashboard(id string, log logr.Logger) error
+	getDashboard(id string, log logr.Logger) ([]byte, error)
+	listDashboards(log logr.Logger) ([]InstanaApiResponse, error)
+	searchSnapshots(plugin string, query string, log logr.Logger) ([]InstanaSnapshot, error)
+	getSnapshot(id string, log logr.Logger) (InstanaSnapshot, error)
+	// do sends a request to any other endpoint, e.g. the settings APIs.
+	do(method string, path string, body []byte, log logr.Logger) ([]byte, error)
+}
+
+var _ InstanaClient = InstanaApi{}
+
+// instanaClient returns the client for the given tenant config. Requests
+// carry the request id and actor of the reconcile and are subject to the rate
+// limit and circuit breaker of the tenant. Dashboard lists are shared by all
+// reconciles.
+func (r *DashboardReconciler) instanaClient(ctx context.Context, apiConfig InstanaApi) InstanaClient {
+	clients := InstanaClients{
+		NewInstanaClient:  r.NewInstanaClient,
+		TenantRateLimiter: r.TenantRateLimiter,
+		CircuitBreaker:    r.CircuitBreaker,
+		ListCache:         r.ListCache,
+		DryRun:            r.DryRun,
+	}
+	return clients.Client(ctx, apiConfig)
+}
+
+// InstanaClients creates the clients of Instana tenants for the components
+// running besides the reconcilers, so their requests share the rate limit,
+// circuit breaker and dashboard list cache of each tenant.
+type InstanaClients struct {
+	// NewInstanaClient replaces the client of a tenant, e.g. in tests.
+	NewInstanaClient  func(InstanaApi) InstanaClient
+	TenantRateLimiter *TenantRateLimiter
+	CircuitBreaker    *CircuitBreaker
+	ListCache         *DashboardListCache
+	// DryRun makes requests which would change Instana fail with ErrDryRun,
+	// as with --dry-run.
+	DryRun bool
+}
+
+// Client returns the client for the given tenant config. Without
+// InstanaClients requests are sent to the tenant right away.
+func (c *InstanaClients) Client(ctx context.Context, apiConfig InstanaApi) InstanaClient {
+	apiConfig.RequestId = requestIdFrom(ctx)
+	apiConfig.Actor = actorFrom(ctx)
+	if c == nil {
+		return apiConfig
+	}
+	apiConfig.DryRun = c.DryRun
+	var instanaClient InstanaClient = apiConfig
+	if c.NewInstanaClient != nil {
+		instanaClient = c.NewInstanaClient(apiConfig)
+	}
+	instanaClient = c.CircuitBreaker.Wrap(apiConfig, c.TenantRateLimiter.Wrap(ctx, apiConfig, instanaClient))
+	return c.ListCache.Wrap(apiConfig, instanaClient)
+}
+
+type requestIdKey struct{}
+
+// withRequestId returns a context carrying the id which correlates the
+// Instana requests of a reconcile.
+func withRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+func requestIdFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
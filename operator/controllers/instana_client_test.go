@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// fakeInstanaClient keeps dashboards in memory and returns the snapshots of
+// a search by query, also by their id. Other requests are answered with the
+// responses by "<method> <path>", 404 for unknown ones. If err is set, every
+// call fails with it.
+type fakeInstanaClient struct {
+	dashboards map[string][]byte
+	snapshots  map[string][]InstanaSnapshot
+	responses  map[string][]byte
+	nextId     int
+	err        error
+	calls      []string
+}
+
+func newFakeInstanaClient() *fakeInstanaClient {
+	return &fakeInstanaClient{dashboards: map[string][]byte{}, snapshots: map[string][]InstanaSnapshot{}, responses: map[string][]byte{}}
+}
+
+func (f *fakeInstanaClient) createDashboard(config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	f.calls = append(f.calls, "create")
+	if f.err != nil {
+		return InstanaApiResponse{}, f.err
+	}
+	f.nextId++
+	id := fmt.Sprintf("fake-%d", f.nextId)
+	f.dashboards[id] = config
+	return f.response(id, config)
+}
+
+func (f *fakeInstanaClient) updateDashboard(id string, config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	f.calls = append(f.calls, "update "+id)
+	if f.err != nil {
+		return InstanaApiResponse{}, f.err
+	}
+	if _, ok := f.dashboards[id]; !ok {
+		return InstanaApiResponse{}, &InstanaApiError{Method: "PUT", StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+	}
+	f.dashboards[id] = config
+	return f.response(id, config)
+}
+
+func (f *fakeInstanaClient) deleteDashboard(id string, log logr.Logger) error {
+	f.calls = append(f.calls, "delete "+id)
+	if f.err != nil {
+		return f.err
+	}
+	if _, ok := f.dashboards[id]; !ok {
+		return &InstanaApiError{Method: "DELETE", StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+	}
+	delete(f.dashboards, id)
+	return nil
+}
+
+func (f *fakeInstanaClient) getDashboard(id string, log logr.Logger) ([]byte, error) {
+	f.calls = append(f.calls, "get "+id)
+	if f.err != nil {
+		return nil, f.err
+	}
+	config, ok := f.dashboards[id]
+	if !ok {
+		return nil, &InstanaApiError{Method: "GET", StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+	}
+	return config, nil
+}
+
+func (f *fakeInstanaClient) listDashboards(log logr.Logger) ([]InstanaApiResponse, error) {
+	f.calls = append(f.calls, "list")
+	if f.err != nil {
+		return nil, f.err
+	}
+	var list []InstanaApiResponse
+	for id, config := range f.dashboards {
+		r, _ := f.response(id, config)
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+func (f *fakeInstanaClient) searchSnapshots(plugin string, query string, log logr.Logger) ([]InstanaSnapshot, error) {
+	f.calls = append(f.calls, "search "+plugin)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.snapshots[query], nil
+}
+
+func (f *fakeInstanaClient) getSnapshot(id string, log logr.Logger) (InstanaSnapshot, error) {
+	f.calls = append(f.calls, "snapshot "+id)
+	if f.err != nil {
+		return InstanaSnapshot{}, f.err
+	}
+	for _, snapshots := range f.snapshots {
+		for _, snapshot := range snapshots {
+			if snapshot.SnapshotId == id {
+				return snapshot, nil
+			}
+		}
+	}
+	return InstanaSnapshot{}, &InstanaApiError{Method: "GET", StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+}
+
+func (f *fakeInstanaClient) do(method string, path string, body []byte, log logr.Logger) ([]byte, error) {
+	f.calls = append(f.calls, method+" "+path)
+	if f.err != nil {
+		return nil, f.err
+	}
+	response, ok := f.responses[method+" "+path]
+	if !ok {
+		return nil, &InstanaApiError{Method: method, Path: path, StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+	}
+	return response, nil
+}
+
+func (f *fakeInstanaClient) response(id string, config []byte) (InstanaApiResponse, error) {
+	r := InstanaApiResponse{Id: id}
+	var payload struct {
+		Title string `json:"title"`
+	}
+	err := json.Unmarshal(config, &payload)
+	r.Title = payload.Title
+	return r, err
+}
+
+func TestReconcileWithFakeInstanaClient(t *testing.T) {
+	key := client.ObjectKey{Namespace: "default", Name: "test"}
+	deleted := metav1.Now()
+	tests := []struct {
+		name       string
+		dashboard  customv1.Dashboard
+		existing   []string
+		clientErr  error
+		wantErr    bool
+		wantCalls  []string
+		wantId     string
+		wantSynced metav1.ConditionStatus
+		wantTrue   string
+		wantGone   bool
+	}{
+		{
+			name:       "creates a new dashboard",
+			dashboard:  customv1.Dashboard{},
+			wantCalls:  []string{"create"},
+			wantId:     "fake-1",
+			wantSynced: metav1.ConditionTrue,
+		},
+		{
+			name: "applies the hotfix patch to a new dashboard",
+			dashboard: customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				customv1.HotfixPatchAnnotation: `[{"op":"replace","path":"/title","value":"Hotfix"}]`,
+			}}},
+			wantCalls:  []string{"create"},
+			wantId:     "fake-1",
+			wantSynced: metav1.ConditionTrue,
+			wantTrue:   customv1.ConditionHotfixApplied,
+		},
+		{
+			name:       "updates an existing dashboard",
+			dashboard:  customv1.Dashboard{Status: customv1.DashboardStatus{DashboardId: "fake-1"}},
+			existing:   []string{"fake-1"},
+			wantCalls:  []string{"get fake-1", "update fake-1"},
+			wantId:     "fake-1",
+			wantSynced: metav1.ConditionTrue,
+		},
+		{
+			name:       "adopts the existing dashboard of the spec",
+			dashboard:  customv1.Dashboard{Spec: customv1.DashboardSpec{ExistingDashboardId: "fake-1"}},
+			existing:   []string{"fake-1"},
+			wantCalls:  []string{"get fake-1", "get fake-1", "update fake-1"},
+			wantId:     "fake-1",
+			wantSynced: metav1.ConditionTrue,
+		},
+		{
+			name:       "reports a failed sync",
+			dashboard:  customv1.Dashboard{},
+			clientErr:  &InstanaApiError{Method: "POST", StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"},
+			wantErr:    true,
+			wantCalls:  []string{"create"},
+			wantSynced: metav1.ConditionFalse,
+		},
+		{
+			name:      "does not call Instana in dry run",
+			dashboard: customv1.Dashboard{Spec: customv1.DashboardSpec{DryRun: true}},
+		},
+		{
+			name: "deletes the dashboard with the resource",
+			dashboard: customv1.Dashboard{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleted, Finalizers: []string{"dashboard.custom.instana.io/finalizer"}},
+				Status:     customv1.DashboardStatus{DashboardId: "fake-1"},
+			},
+			existing:  []string{"fake-1"},
+			wantCalls: []string{"delete fake-1"},
+			wantGone:  true,
+		},
+		{
+			name: "removes the finalizer if the dashboard is already gone",
+			dashboard: customv1.Dashboard{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleted, Finalizers: []string{"dashboard.custom.instana.io/finalizer"}},
+				Status:     customv1.DashboardStatus{DashboardId: "fake-1"},
+			},
+			wantCalls: []string{"delete fake-1"},
+			wantGone:  true,
+		},
+		{
+			name: "keeps the finalizer if the deletion fails",
+			dashboard: customv1.Dashboard{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleted, Finalizers: []string{"dashboard.custom.instana.io/finalizer"}},
+				Status:     customv1.DashboardStatus{DashboardId: "fake-1"},
+			},
+			existing:  []string{"fake-1"},
+			clientErr: &InstanaApiError{Method: "DELETE", StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"},
+			wantErr:   true,
+			wantCalls: []string{"delete fake-1"},
+			wantId:    "fake-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = customv1.AddToScheme(scheme)
+
+			dashboard := tt.dashboard.DeepCopy()
+			dashboard.Namespace, dashboard.Name = key.Namespace, key.Name
+			dashboard.Spec.Config = &apiextensionsv1.JSON{Raw: []byte(`{"title":"Test","widgets":[]}`)}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dashboard).Build()
+
+			instana := newFakeInstanaClient()
+			for _, id := range tt.existing {
+				instana.dashboards[id] = []byte(`{"title":"Test"}`)
+				instana.nextId++
+			}
+			instana.err = tt.clientErr
+			r := &DashboardReconciler{
+				Client:           c,
+				Log:              ctrl.Log.WithName("test"),
+				Scheme:           scheme,
+				Recorder:         record.NewFakeRecorder(100),
+				IdStore:          noopIdStore{},
+				NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+			}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(instana.calls) != fmt.Sprint(tt.wantCalls) {
+				t.Errorf("Instana calls = %v, want %v", instana.calls, tt.wantCalls)
+			}
+
+			var got customv1.Dashboard
+			if err := c.Get(context.Background(), key, &got); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantGone {
+				if len(got.Finalizers) != 0 {
+					t.Errorf("finalizers = %v, want none", got.Finalizers)
+				}
+				return
+			}
+			if got.Status.DashboardId != tt.wantId {
+				t.Errorf("status.dashboard-id = %q, want %q", got.Status.DashboardId, tt.wantId)
+			}
+			if tt.wantSynced != "" {
+				synced := meta.FindStatusCondition(got.Status.Conditions, customv1.ConditionSynced)
+				if synced == nil || synced.Status != tt.wantSynced {
+					t.Errorf("Synced condition = %v, want status %s", synced, tt.wantSynced)
+				}
+			}
+			if tt.wantTrue != "" && !meta.IsStatusConditionTrue(got.Status.Conditions, tt.wantTrue) {
+				t.Errorf("conditions = %+v, want %s", got.Status.Conditions, tt.wantTrue)
+			}
+		})
+	}
+}
+
+func TestSyncDashboardSkipsUnchanged(t *testing.T) {
+	log := ctrl.Log.WithName("test")
+	instana := newFakeInstanaClient()
+	instana.dashboards["a"] = []byte(`{"id":"a","title":"A","widgets":[],"ownerId":"user-1","lastUpdated":1}`)
+
+	response, err := syncDashboard(instana, "a", []byte(`{"widgets":[],"title":"A"}`), log)
+	if err != nil || response.Id != "a" || response.Title != "A" {
+		t.Errorf("syncDashboard() = %+v, %v", response, err)
+	}
+	if _, err := syncDashboard(instana, "a", []byte(`{"title":"B","widgets":[]}`), log); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(instana.calls) != "[get a get a update a]" {
+		t.Errorf("Instana calls = %v, want a single update", instana.calls)
+	}
+
+	// fields added in Instana are drift as well
+	drift, err := configDrift([]byte(`{"title":"A","widgets":[]}`), []byte(`{"id":"a","title":"A","widgets":[],"writers":["user-2"]}`))
+	if err != nil || fmt.Sprint(drift) != "[/writers]" {
+		t.Errorf("configDrift() = %v, %v, want the field added in Instana", drift, err)
+	}
+}
+
+func TestSyncDashboardFindsCreatedDashboard(t *testing.T) {
+	log := ctrl.Log.WithName("test")
+	instana := newFakeInstanaClient()
+	marker := ManagedMarker{Cluster: "prod", Namespace: "team-a", Name: "shop", UID: "uid-1"}
+	config, err := injectManagedMarker([]byte(`{"title":"Shop","widgets":[]}`), marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// created by a sync whose status update failed
+	instana.dashboards["a"] = config
+	other, _ := injectManagedMarker([]byte(`{"title":"Shop","widgets":[]}`), ManagedMarker{Cluster: "prod", Namespace: "team-b", Name: "shop", UID: "uid-2"})
+	instana.dashboards["b"] = other
+
+	response, err := syncDashboard(instana, "", config, log)
+	if err != nil || response.Id != "a" {
+		t.Errorf("syncDashboard() = %+v, %v, want the created dashboard", response, err)
+	}
+	if len(instana.dashboards) != 2 {
+		t.Errorf("dashboards = %d, want no duplicate", len(instana.dashboards))
+	}
+
+	marker.UID = "uid-3"
+	config, _ = injectManagedMarker([]byte(`{"title":"Shop","widgets":[]}`), marker)
+	if response, err := syncDashboard(instana, "", config, log); err != nil || response.Id == "a" || response.Id == "b" {
+		t.Errorf("syncDashboard() = %+v, %v, want a new dashboard", response, err)
+	}
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+const (
+	instanaConfigNamespace = "default"
+	instanaConfigName      = "instana-custom-dashboard-config"
+)
+
+// loadInstanaConfig reads the ConfigMap holding the Instana API config. A
+// missing ConfigMap results in an empty config.
+func loadInstanaConfig(ctx context.Context, c client.Reader) (*corev1.ConfigMap, InstanaApi) {
+	cm := &corev1.ConfigMap{}
+	_ = c.Get(ctx, client.ObjectKey{
+		Namespace: instanaConfigNamespace,
+		Name:      instanaConfigName,
+	}, cm)
+	return cm, instanaApiFromConfigMap(cm)
+}
+
+// loadTenantConfig reads the Instana API config of an additional tenant from
+// the ConfigMap with the given name. It uses the same keys as the default config.
+func loadTenantConfig(ctx context.Context, c client.Client, name string) (InstanaApi, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: instanaConfigNamespace, Name: name}, cm); err != nil {
+		return InstanaApi{}, fmt.Errorf("unable to load tenant config %s: %w", name, err)
+	}
+	return instanaApiFromConfigMap(cm), nil
+}
+
+// LoadTenantConfig reads the Instana API config of the tenant with the given
+// name, the default tenant if empty.
+func LoadTenantConfig(ctx context.Context, c client.Client, name string) (InstanaApi, error) {
+	if name == "" {
+		name = instanaConfigName
+	}
+	return loadTenantConfig(ctx, c, name)
+}
+
+// LoadDashboardTenant reads the Instana API config the operator syncs the
+// Dashboard with: the default tenant, with the API token and base url of the
+// credentials Secret of the namespace if namespaceCredentials is set, as with
+// --namespace-credentials of the operator.
+func LoadDashboardTenant(ctx context.Context, c client.Client, dashboard customv1.Dashboard, namespaceCredentials bool) (InstanaApi, error) {
+	instanaApi, err := loadTenantConfig(ctx, c, instanaConfigName)
+	if err != nil || !namespaceCredentials {
+		return instanaApi, err
+	}
+	return loadNamespaceCredentials(ctx, c, dashboard.Namespace, instanaApi)
+}
+
+func instanaApiFromConfigMap(cm *corev1.ConfigMap) InstanaApi {
+	knownSecrets.add(cm.Data["instana-api-token"])
+	api := InstanaApi{
+		ApiToken:      cm.Data["instana-api-token"],
+		BaseUrl:       tenantBaseUrl(cm.Data),
+		BackendFlavor: cm.Data["instana-backend-flavor"],
+		AuthScheme:    cm.Data["instana-auth-scheme"],
+		ApiPath:       cm.Data["instana-api-path"],
+		TitlePrefix:   cm.Data["title-prefix"],
+		TitleSuffix:   cm.Data["title-suffix"],
+	}
+	// invalid limits are ignored, the defaults of the operator apply
+	api.RequestsPerSecond, _ = strconv.ParseFloat(cm.Data["requests-per-second"], 64)
+	api.RequestsBurst, _ = strconv.Atoi(cm.Data["requests-burst"])
+	api.MaxConcurrentRequests, _ = strconv.Atoi(cm.Data["max-concurrent-requests"])
+	if vault, ok := vaultConfigFromConfigMap(cm); ok {
+		api.Vault = vaultTokenSource(vault)
+	}
+	return api
+}
+
+// defaultSaasDomain is the domain of the Instana SaaS tenants.
+const defaultSaasDomain = "instana.io"
+
+// tenantBaseUrl returns the instana-base-url of a tenant config map, or the
+// url of the SaaS tenant https://<unit>-<tenant>.<domain> if instana-unit and
+// instana-tenant are set instead.
+func tenantBaseUrl(data map[string]string) string {
+	if baseUrl := data["instana-base-url"]; baseUrl != "" {
+		return baseUrl
+	}
+	unit := strings.ToLower(strings.TrimSpace(data["instana-unit"]))
+	tenant := strings.ToLower(strings.TrimSpace(data["instana-tenant"]))
+	if unit == "" || tenant == "" {
+		return ""
+	}
+	domain := strings.Trim(strings.TrimSpace(data["instana-saas-domain"]), ".")
+	if domain == "" {
+		domain = defaultSaasDomain
+	}
+	return "https://" + unit + "-" + tenant + "." + domain
+}
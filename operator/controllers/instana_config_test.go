@@ -0,0 +1,20 @@
+package controllers
+
+import "testing"
+
+func TestTenantBaseUrl(t *testing.T) {
+	tests := []struct {
+		data map[string]string
+		want string
+	}{
+		{map[string]string{"instana-base-url": "https://instana.internal", "instana-unit": "shop", "instana-tenant": "acme"}, "https://instana.internal"},
+		{map[string]string{"instana-unit": "Shop", "instana-tenant": " acme"}, "https://shop-acme.instana.io"},
+		{map[string]string{"instana-unit": "shop", "instana-tenant": "acme", "instana-saas-domain": "eu.instana.io."}, "https://shop-acme.eu.instana.io"},
+		{map[string]string{"instana-unit": "shop"}, ""},
+	}
+	for _, test := range tests {
+		if got := tenantBaseUrl(test.data); got != test.want {
+			t.Errorf("tenantBaseUrl(%v) = %q, want %q", test.data, got, test.want)
+		}
+	}
+}
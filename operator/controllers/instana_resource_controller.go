@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+const instanaResourceFinalizer = "custom.instana.io/finalizer"
+
+// InstanaResource is a resource which is synced with an Instana settings API.
+type InstanaResource interface {
+	client.Object
+	InstanaStatus() *customv1.InstanaResourceStatus
+}
+
+// InstanaResourceKind describes how the resources of a kind are synced with
+// their Instana settings API.
+type InstanaResourceKind struct {
+	// Kind of the resource, e.g. "ApplicationPerspective".
+	Kind string
+	// New returns an empty resource of the kind.
+	New func() InstanaResource
+	// Path of the collection endpoint, e.g. "/api/application-monitoring/settings/application".
+	Path string
+	// ClientIds is set for APIs which create resources with PUT <path>/<id>
+	// and an id chosen by the client. The UID of the resource is used then.
+	// Otherwise resources are created with POST <path>.
+	ClientIds bool
+	// Immutable is set for APIs which do not support updates. Changed
+	// resources are deleted in Instana and created again with a new id.
+	Immutable bool
+	// QueryPayload is set for APIs which take the payload as query
+	// parameters instead of a JSON body. The payload must have string values.
+	QueryPayload bool
+	// UpdateMethod is the method of PUT <path>/<id> equivalent updates. Defaults to PUT.
+	UpdateMethod string
+	// Payload returns the Instana representation of the spec.
+	Payload func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error)
+	// Synced is called after the resource was synced, with the response of
+	// Instana if it was created or updated and nil otherwise. Optional.
+	Synced func(ctx context.Context, r *InstanaResourceReconciler, obj InstanaResource, response []byte) error
+	// Report reads the observed state of a synced resource from Instana into
+	// its status, on every reconcile. It returns the interval of the next
+	// report, 0 for none. Optional.
+	Report func(ctx context.Context, obj InstanaResource, instanaClient InstanaClient, log logr.Logger) (time.Duration, error)
+}
+
+// InstanaResourceKinds are the kinds of resources synced with Instana
+// settings APIs by an InstanaResourceReconciler.
+var InstanaResourceKinds = []InstanaResourceKind{
+	applicationPerspectiveKind,
+	alertChannelKind,
+	applicationAlertConfigKind,
+	infraAlertConfigKind,
+	sliConfigKind,
+	sloKind,
+	syntheticTestKind,
+	websiteMonitoringConfigKind,
+	maintenanceWindowKind,
+	customEventSpecificationKind,
+	apiTokenKind,
+	instanaGroupKind,
+	automationActionKind,
+	releaseKind,
+	applicationConfigKind,
+}
+
+// InstanaResourceReconciler reconciles the resources of an
+// InstanaResourceKind with the default Instana tenant. Like Dashboards they
+// carry a finalizer deleting them in Instana, the id in the status and
+// kstatus conditions. Changes done in Instana are reverted every
+// DriftCheckInterval.
+type InstanaResourceReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Kind     InstanaResourceKind
+	// Shard limits the reconciler to the resources in namespaces of this shard.
+	Shard Shard
+	// DriftCheckInterval is the interval in which resources are compared with
+	// their live state in Instana. 0 disables the drift check.
+	DriftCheckInterval time.Duration
+	// Instana creates the client of the tenant, so the requests share its
+	// rate limit and circuit breaker with the Dashboards.
+	Instana *InstanaClients
+}
+
+// Reconcile creates, updates or deletes the resource in Instana.
+func (r *InstanaResourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	requestId := string(uuid.NewUUID())
+	log := r.Log.WithValues(r.Kind.Kind, req.NamespacedName, "requestId", requestId)
+
+	obj := r.Kind.New()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	ctx = withActor(withRequestId(ctx, requestId), obj)
+	_, instanaApi := loadInstanaConfig(ctx, r.Client)
+	instanaClient := r.Instana.Client(ctx, instanaApi)
+	status := obj.InstanaStatus()
+
+	if obj.GetDeletionTimestamp() != nil {
+		if !controllerutil.ContainsFinalizer(obj, instanaResourceFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if status.Id != "" && obj.GetAnnotations()[customv1.SkipRemoteDeleteAnnotation] != "true" {
+			if _, err := instanaClient.do(http.MethodDelete, r.Kind.Path+"/"+status.Id, nil, log); errors.Is(err, ErrDryRun) {
+				r.Recorder.Event(obj, corev1.EventTypeNormal, "DryRun", "Would delete "+r.Kind.Kind+" "+status.Id+" in Instana")
+			} else if err != nil && !isInstanaNotFound(err) {
+				log.Error(err, "unable to delete "+r.Kind.Kind+" in Instana. Retrying.")
+				r.Recorder.Event(obj, corev1.EventTypeWarning, "DeleteFailed", err.Error())
+				return ctrl.Result{}, err
+			}
+		}
+		controllerutil.RemoveFinalizer(obj, instanaResourceFinalizer)
+		return ctrl.Result{}, r.Update(ctx, obj)
+	}
+
+	response, err := r.sync(ctx, obj, instanaClient, log)
+	if err == nil && r.Kind.Synced != nil {
+		err = r.Kind.Synced(ctx, r, obj, response)
+	}
+	var reportInterval time.Duration
+	if err == nil && r.Kind.Report != nil {
+		var reportErr error
+		if reportInterval, reportErr = r.Kind.Report(ctx, obj, instanaClient, log); reportErr != nil {
+			log.Error(reportErr, "unable to read the "+r.Kind.Kind+" report")
+			r.Recorder.Event(obj, corev1.EventTypeWarning, "ReportFailed", reportErr.Error())
+		}
+	}
+	status.ObservedGeneration = obj.GetGeneration()
+	if errors.Is(err, ErrDryRun) {
+		return r.dryRun(ctx, obj, err, log)
+	}
+	removeStatusCondition(&status.Conditions, customv1.ConditionDryRun)
+	setInstanaSyncedCondition(&status.Conditions, err)
+	setPermissionsStatus(&status.Conditions, r.Kind, err)
+	setReadyConditions(&status.Conditions, "Synced", r.Kind.Kind+" "+status.Id+" is in sync with Instana", err)
+	if err != nil {
+		log.Error(err, "unable to sync "+r.Kind.Kind+" with Instana")
+		r.Recorder.Event(obj, corev1.EventTypeWarning, "SyncFailed", err.Error())
+	}
+	if statusErr := r.Status().Update(ctx, obj); statusErr != nil {
+		log.Error(statusErr, "unable to update status")
+		return ctrl.Result{}, statusErr
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !controllerutil.ContainsFinalizer(obj, instanaResourceFinalizer) {
+		controllerutil.AddFinalizer(obj, instanaResourceFinalizer)
+		if err := r.Update(ctx, obj); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	requeueAfter := r.DriftCheckInterval
+	if reportInterval > 0 && (requeueAfter == 0 || reportInterval < requeueAfter) {
+		requeueAfter = reportInterval
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// dryRun reports the change which a sync skipped because of --dry-run.
+func (r *InstanaResourceReconciler) dryRun(ctx context.Context, obj InstanaResource, err error, log logr.Logger) (ctrl.Result, error) {
+	status := obj.InstanaStatus()
+	message := strings.TrimPrefix(err.Error(), ErrDryRun.Error()+": ")
+	log.Info("Dry run: " + message)
+	r.Recorder.Event(obj, corev1.EventTypeNormal, "DryRun", message)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    customv1.ConditionDryRun,
+		Status:  metav1.ConditionTrue,
+		Reason:  "WouldSync",
+		Message: message,
+	})
+	setReadyConditions(&status.Conditions, "DryRun", "Dry run, the "+r.Kind.Kind+" is not synced with Instana", nil)
+	if err := r.Status().Update(ctx, obj); err != nil {
+		log.Error(err, "unable to update status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.DriftCheckInterval}, nil
+}
+
+// sync creates the resource in Instana if it has no id yet, and updates it
+// if the payload changed or the live state differs. It returns the response
+// of Instana, nil if nothing was changed.
+func (r *InstanaResourceReconciler) sync(ctx context.Context, obj InstanaResource, instanaClient InstanaClient, log logr.Logger) ([]byte, error) {
+	status := obj.InstanaStatus()
+	payload, err := r.Kind.Payload(ctx, r.Client, obj)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	hash := configHash(body)
+
+	if status.Id != "" {
+		live, err := instanaClient.do(http.MethodGet, r.Kind.Path+"/"+status.Id, nil, log)
+		switch {
+		case isInstanaNotFound(err):
+			log.Info(r.Kind.Kind + " " + status.Id + " was deleted in Instana. Recreating it.")
+			r.Recorder.Event(obj, corev1.EventTypeWarning, "Recreated", r.Kind.Kind+" "+status.Id+" was deleted in Instana")
+			status.Id = ""
+		case err != nil:
+			return nil, err
+		default:
+			drift, err := resourceDrift(body, live)
+			if err != nil {
+				return nil, err
+			}
+			if len(drift) == 0 {
+				status.AppliedConfigHash = hash
+				return nil, nil
+			}
+			if status.AppliedConfigHash == hash {
+				r.Recorder.Event(obj, corev1.EventTypeNormal, "Reverted", fmt.Sprintf("Reverted changes done in Instana: %v", drift))
+			}
+			if r.Kind.Immutable {
+				if _, err := instanaClient.do(http.MethodDelete, r.Kind.Path+"/"+status.Id, nil, log); err != nil && !isInstanaNotFound(err) {
+					return nil, err
+				}
+				r.Recorder.Event(obj, corev1.EventTypeNormal, "Replaced", r.Kind.Kind+" "+status.Id+" was deleted in Instana to apply changes of "+fmt.Sprint(drift))
+				status.Id = ""
+			}
+		}
+	}
+
+	var response []byte
+	switch {
+	case status.Id != "":
+		payload["id"] = status.Id
+		method := r.Kind.UpdateMethod
+		if method == "" {
+			method = http.MethodPut
+		}
+		response, err = r.do(instanaClient, method, r.Kind.Path+"/"+status.Id, payload, log)
+	case r.Kind.ClientIds:
+		id := string(obj.GetUID())
+		payload["id"] = id
+		if response, err = r.do(instanaClient, http.MethodPut, r.Kind.Path+"/"+id, payload, log); err == nil {
+			status.Id = id
+		}
+	default:
+		if response, err = r.do(instanaClient, http.MethodPost, r.Kind.Path, payload, log); err == nil {
+			var created struct {
+				Id string `json:"id"`
+			}
+			if err = json.Unmarshal(response, &created); err == nil && created.Id == "" {
+				err = fmt.Errorf("Instana returned no id for the created %s", r.Kind.Kind)
+			}
+			status.Id = created.Id
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.AppliedConfigHash = hash
+	if response == nil {
+		response = []byte("{}")
+	}
+	return response, nil
+}
+
+func (r *InstanaResourceReconciler) do(instanaClient InstanaClient, method string, path string, payload map[string]interface{}, log logr.Logger) ([]byte, error) {
+	if r.Kind.QueryPayload {
+		query := url.Values{}
+		for k, v := range payload {
+			// the id is part of the path
+			if k != "id" {
+				query.Set(k, fmt.Sprint(v))
+			}
+		}
+		return instanaClient.do(method, path+"?"+query.Encode(), nil, log)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return instanaClient.do(method, path, body, log)
+}
+
+// setInstanaSyncedCondition sets the Synced condition according to the result of a sync.
+func setInstanaSyncedCondition(conditions *[]metav1.Condition, err error) {
+	condition := metav1.Condition{
+		Type:    customv1.ConditionSynced,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Synced",
+		Message: "Resource is in sync with Instana",
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "SyncFailed"
+		condition.Message = redactError(err)
+	}
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// instanaIdOf returns the Instana id of the named resource of the namespace.
+// Resources which were not synced yet are reported as an error, so the
+// referencing resource is retried.
+func instanaIdOf(ctx context.Context, c client.Client, namespace string, name string, obj InstanaResource) (string, error) {
+	kind := reflect.TypeOf(obj).Elem().Name()
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, obj); err != nil {
+		return "", fmt.Errorf("unable to load %s %s: %w", kind, name, err)
+	}
+	if obj.InstanaStatus().Id == "" {
+		return "", fmt.Errorf("%s %s is not synced with Instana yet", kind, name)
+	}
+	return obj.InstanaStatus().Id, nil
+}
+
+// jsonValue returns the decoded value of an optional JSON field, or the
+// fallback if it is not set.
+func jsonValue(value *apiextensionsv1.JSON, fallback interface{}) (interface{}, error) {
+	if value == nil || len(value.Raw) == 0 {
+		return fallback, nil
+	}
+	var v interface{}
+	err := json.Unmarshal(value.Raw, &v)
+	return v, err
+}
+
+// nonNil returns an empty slice for nil, which Instana expects for empty lists.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InstanaResourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(r.Kind.New(), builder.WithPredicates(
+			r.Shard.Predicate(),
+			// Skip the status updates done by the reconciler itself
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}),
+		)).
+		Complete(r)
+}
@@ -0,0 +1,534 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/instanatest"
+)
+
+// newInstanaResourceTest returns a reconciler of the kind against a fake
+// Instana API serving the settings API of the kind.
+func newInstanaResourceTest(t *testing.T, kind InstanaResourceKind, objects ...client.Object) (*InstanaResourceReconciler, *instanatest.Server) {
+	instana := instanatest.NewServer("token")
+	t.Cleanup(instana.Close)
+	instana.HandleSettings(kind.Path)
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	objects = append(objects, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+		Data:       map[string]string{"instana-base-url": instana.URL, "instana-api-token": "token"},
+	})
+	return &InstanaResourceReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Log:      ctrl.Log.WithName("test"),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+		Kind:     kind,
+	}, instana
+}
+
+func reconcileInstanaResource(t *testing.T, r *InstanaResourceReconciler, obj InstanaResource) {
+	t.Helper()
+	key := client.ObjectKeyFromObject(obj)
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := r.Get(context.Background(), key, obj); client.IgnoreNotFound(err) != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstanaResourceReconciler(t *testing.T) {
+	ctx := context.Background()
+	perspective := &customv1.ApplicationPerspective{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.ApplicationPerspectiveSpec{
+			Label:               "Shop",
+			Scope:               "INCLUDE_NO_DOWNSTREAM",
+			BoundaryScope:       "DEFAULT",
+			TagFilterExpression: &apiextensionsv1.JSON{Raw: []byte(`{"type":"TAG_FILTER","name":"kubernetes.namespace.name","operator":"EQUALS","value":"team-a"}`)},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, applicationPerspectiveKind, perspective)
+	path := applicationPerspectiveKind.Path
+
+	reconcileInstanaResource(t, r, perspective)
+	if perspective.Status.Id != "fake-1" || !meta.IsStatusConditionTrue(perspective.Status.Conditions, customv1.ConditionReady) {
+		t.Fatalf("status = %+v, want id fake-1 and Ready", perspective.Status)
+	}
+	if live, ok := instana.Setting(path, "fake-1"); !ok || live["label"] != "Shop" || live["boundaryScope"] != "DEFAULT" {
+		t.Errorf("live perspective = %v", live)
+	}
+	if len(perspective.Finalizers) != 1 {
+		t.Errorf("finalizers = %v, want the finalizer", perspective.Finalizers)
+	}
+
+	// changes done in Instana are reverted
+	live, _ := instana.Setting(path, "fake-1")
+	live["label"] = "Changed in UI"
+	instana.PutSetting(path, "fake-1", live)
+	reconcileInstanaResource(t, r, perspective)
+	if live, _ := instana.Setting(path, "fake-1"); live["label"] != "Shop" {
+		t.Errorf("label = %v, want the change reverted", live["label"])
+	}
+
+	// perspectives deleted in Instana are recreated
+	instana.DeleteSetting(path, "fake-1")
+	reconcileInstanaResource(t, r, perspective)
+	if _, ok := instana.Setting(path, perspective.Status.Id); !ok || perspective.Status.Id == "fake-1" {
+		t.Errorf("status.id = %s, want a recreated perspective", perspective.Status.Id)
+	}
+
+	// the fake client deletes right away, so the deletion is marked by hand
+	id := perspective.Status.Id
+	now := metav1.Now()
+	perspective.DeletionTimestamp = &now
+	if err := r.Update(ctx, perspective); err != nil {
+		t.Fatal(err)
+	}
+	reconcileInstanaResource(t, r, perspective)
+	if _, ok := instana.Setting(path, id); ok {
+		t.Errorf("expected perspective %s to be deleted in Instana", id)
+	}
+}
+
+func TestInstanaResourceDryRun(t *testing.T) {
+	perspective := &customv1.ApplicationPerspective{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec:       customv1.ApplicationPerspectiveSpec{Label: "Shop"},
+	}
+	r, instana := newInstanaResourceTest(t, applicationPerspectiveKind, perspective)
+	r.Instana = &InstanaClients{DryRun: true}
+
+	reconcileInstanaResource(t, r, perspective)
+	if _, ok := instana.Setting(applicationPerspectiveKind.Path, "fake-1"); ok || perspective.Status.Id != "" {
+		t.Error("perspective was created in Instana with --dry-run")
+	}
+	dryRun := meta.FindStatusCondition(perspective.Status.Conditions, customv1.ConditionDryRun)
+	if dryRun == nil || dryRun.Message != "would send POST "+applicationPerspectiveKind.Path {
+		t.Errorf("DryRun condition = %+v", dryRun)
+	}
+	if !meta.IsStatusConditionTrue(perspective.Status.Conditions, customv1.ConditionReady) {
+		t.Errorf("conditions = %v, want Ready", perspective.Status.Conditions)
+	}
+}
+
+func TestInstanaResourceCircuitOpen(t *testing.T) {
+	perspective := &customv1.ApplicationPerspective{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec:       customv1.ApplicationPerspectiveSpec{Label: "Shop"},
+	}
+	r, instana := newInstanaResourceTest(t, applicationPerspectiveKind, perspective)
+	breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Hour}
+	// a Dashboard reconcile opened the breaker of the tenant
+	breaker.record(instana.URL, &InstanaApiError{Method: "GET", StatusCode: 503, Status: "503 Service Unavailable"})
+	r.Instana = &InstanaClients{CircuitBreaker: breaker}
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(perspective)})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("err = %v, want %v", err, ErrCircuitOpen)
+	}
+	if _, ok := instana.Setting(applicationPerspectiveKind.Path, "fake-1"); ok {
+		t.Error("perspective was created in Instana while the breaker is open")
+	}
+}
+
+func TestAlertChannelPayload(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "slack"},
+		Data:       map[string][]byte{"url": []byte("https://hooks.slack.com/services/T0/B0/x\n")},
+	}
+	channel := &customv1.AlertChannel{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "on-call", UID: "4f5c2d1e"},
+		Spec: customv1.AlertChannelSpec{
+			Name:                "On-call",
+			Kind:                "SLACK",
+			Channel:             "#on-call",
+			WebhookUrlSecretRef: &customv1.SecretKeyReference{Name: "slack", Key: "url"},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, alertChannelKind, secret, channel)
+
+	reconcileInstanaResource(t, r, channel)
+	live, ok := instana.Setting(alertChannelKind.Path, "4f5c2d1e")
+	if !ok || live["webhookUrl"] != "https://hooks.slack.com/services/T0/B0/x" || live["channel"] != "#on-call" {
+		t.Errorf("live channel = %v, want it created with the id of the resource", live)
+	}
+
+	channel.Spec = customv1.AlertChannelSpec{Name: "On-call", Kind: "EMAIL"}
+	if err := r.Update(context.Background(), channel); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(channel)}); err == nil {
+		t.Fatal("expected an EMAIL channel without emails to fail")
+	}
+	_ = r.Get(context.Background(), client.ObjectKeyFromObject(channel), channel)
+	if !meta.IsStatusConditionTrue(channel.Status.Conditions, customv1.ConditionStalled) {
+		t.Errorf("conditions = %v, want Stalled", channel.Status.Conditions)
+	}
+}
+
+func TestApplicationAlertConfigReferences(t *testing.T) {
+	ctx := context.Background()
+	perspective := &customv1.ApplicationPerspective{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Status:     customv1.InstanaResourceStatus{Id: "app-1"},
+	}
+	channel := &customv1.AlertChannel{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "on-call"},
+	}
+	alert := &customv1.ApplicationAlertConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "errors"},
+		Spec: customv1.ApplicationAlertConfigSpec{
+			Name:                      "Shop errors",
+			ApplicationPerspectiveRef: "shop",
+			Rule:                      &apiextensionsv1.JSON{Raw: []byte(`{"alertType":"errorRate","metricName":"errors","aggregation":"MEAN"}`)},
+			Threshold:                 &apiextensionsv1.JSON{Raw: []byte(`{"type":"staticThreshold","operator":">=","value":0.05}`)},
+			TimeWindow:                metav1.Duration{Duration: 10 * time.Minute},
+			Severity:                  "CRITICAL",
+			AlertChannelRefs:          []string{"on-call"},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, applicationAlertConfigKind, perspective, channel, alert)
+
+	// the alert waits for the alert channel to be synced
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(alert)}); err == nil {
+		t.Fatal("expected the unsynced alert channel to fail the sync")
+	}
+	channel.Status.Id = "channel-1"
+	if err := r.Status().Update(ctx, channel); err != nil {
+		t.Fatal(err)
+	}
+	reconcileInstanaResource(t, r, alert)
+	live, ok := instana.Setting(applicationAlertConfigKind.Path, alert.Status.Id)
+	if !ok {
+		t.Fatalf("alert %s was not created", alert.Status.Id)
+	}
+	if live["applicationId"] != "app-1" || live["severity"] != float64(10) || live["granularity"] != float64(600000) {
+		t.Errorf("live alert = %v", live)
+	}
+	if ids, _ := live["alertChannelIds"].([]interface{}); len(ids) != 1 || ids[0] != "channel-1" {
+		t.Errorf("alertChannelIds = %v, want [channel-1]", live["alertChannelIds"])
+	}
+}
+
+func TestInfraAlertConfigDrift(t *testing.T) {
+	alert := &customv1.InfraAlertConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "memory"},
+		Spec: customv1.InfraAlertConfigSpec{
+			Name:              "Pod memory",
+			EntityType:        "kubernetesPod",
+			MetricName:        "memoryRequests",
+			Aggregation:       "MAX",
+			ThresholdOperator: ">=",
+			WarningThreshold:  &apiextensionsv1.JSON{Raw: []byte(`{"type":"staticThreshold","value":80}`)},
+			TimeWindow:        metav1.Duration{Duration: 5 * time.Minute},
+			AlertChannelIds:   []string{"channel-1"},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, infraAlertConfigKind, alert)
+	path := infraAlertConfigKind.Path
+
+	reconcileInstanaResource(t, r, alert)
+	live, ok := instana.Setting(path, alert.Status.Id)
+	if !ok {
+		t.Fatalf("alert %s was not created", alert.Status.Id)
+	}
+	if channels, _ := live["alertChannels"].(map[string]interface{}); len(channels) != 1 || channels["WARNING"] == nil {
+		t.Errorf("alertChannels = %v, want the channels of the WARNING threshold", live["alertChannels"])
+	}
+
+	live["rules"] = []interface{}{}
+	instana.PutSetting(path, alert.Status.Id, live)
+	reconcileInstanaResource(t, r, alert)
+	if live, _ := instana.Setting(path, alert.Status.Id); len(live["rules"].([]interface{})) != 1 {
+		t.Errorf("rules = %v, want the change reverted", live["rules"])
+	}
+}
+
+func TestSLIConfigReplacedOnChange(t *testing.T) {
+	ctx := context.Background()
+	sli := &customv1.SLIConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "latency"},
+		Spec: customv1.SLIConfigSpec{
+			Name:          "Shop latency",
+			MetricName:    "latency",
+			Aggregation:   "P90",
+			Threshold:     resource.MustParse("500"),
+			ApplicationId: "app-1",
+			BoundaryScope: "INBOUND",
+		},
+	}
+	r, instana := newInstanaResourceTest(t, sliConfigKind, sli)
+	path := sliConfigKind.Path
+
+	reconcileInstanaResource(t, r, sli)
+	first := sli.Status.Id
+	live, ok := instana.Setting(path, first)
+	if !ok {
+		t.Fatalf("sli %s was not created", first)
+	}
+	if metric, _ := live["metricConfiguration"].(map[string]interface{}); metric["threshold"] != float64(500) {
+		t.Errorf("metricConfiguration = %v, want threshold 500", live["metricConfiguration"])
+	}
+
+	sli.Spec.Threshold = resource.MustParse("250")
+	if err := r.Update(ctx, sli); err != nil {
+		t.Fatal(err)
+	}
+	reconcileInstanaResource(t, r, sli)
+	if _, ok := instana.Setting(path, first); ok || sli.Status.Id == first {
+		t.Errorf("status.id = %s, want sli %s replaced", sli.Status.Id, first)
+	}
+}
+
+func TestSLOReport(t *testing.T) {
+	slo := &customv1.SLO{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "latency"},
+		Spec: customv1.SLOSpec{
+			Name:           "Shop latency",
+			SLIId:          "sli-1",
+			Target:         resource.MustParse("99.9"),
+			TimeWindow:     metav1.Duration{Duration: 720 * time.Hour},
+			ReportInterval: &metav1.Duration{Duration: time.Minute},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, sloKind, slo)
+	instana.Handle(sloReportPath+"/", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"sli":0.9995,"totalErrorBudget":1000,"errorBudgetRemaining":500}`))
+	})
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(slo)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != time.Minute {
+		t.Errorf("RequeueAfter = %v, want the report interval", result.RequeueAfter)
+	}
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(slo), slo); err != nil {
+		t.Fatal(err)
+	}
+	if live, _ := instana.Setting(sloKind.Path, slo.Status.Id); live["target"] != 0.999 || live["sliId"] != "sli-1" {
+		t.Errorf("live slo = %v", live)
+	}
+	if slo.Status.Compliance != "99.95%" || slo.Status.ErrorBudgetRemaining != "50.00%" || slo.Status.Reported == nil {
+		t.Errorf("status = %+v, want the report", slo.Status)
+	}
+}
+
+func TestSyntheticTestPayload(t *testing.T) {
+	test := &customv1.SyntheticTest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "health"},
+		Spec: customv1.SyntheticTestSpec{
+			Label:     "Shop health",
+			Paused:    true,
+			Locations: []string{"pop-1"},
+			Frequency: metav1.Duration{Duration: 5 * time.Minute},
+			HTTP:      &customv1.SyntheticHttpAction{URL: "https://shop.example.com/health", Method: "GET", Timeout: &metav1.Duration{Duration: 10 * time.Second}},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, syntheticTestKind, test)
+
+	reconcileInstanaResource(t, r, test)
+	live, ok := instana.Setting(syntheticTestKind.Path, test.Status.Id)
+	if !ok || live["active"] != false || live["testFrequency"] != float64(5) {
+		t.Fatalf("live test = %v", live)
+	}
+	if configuration, _ := live["configuration"].(map[string]interface{}); configuration["syntheticType"] != "HTTPAction" || configuration["timeout"] != "10000ms" {
+		t.Errorf("configuration = %v", live["configuration"])
+	}
+}
+
+func TestWebsiteMonitoringConfigSecret(t *testing.T) {
+	ctx := context.Background()
+	website := &customv1.WebsiteMonitoringConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec:       customv1.WebsiteMonitoringConfigSpec{Name: "shop.example.com", EumKeySecret: "shop-eum"},
+	}
+	r, instana := newInstanaResourceTest(t, websiteMonitoringConfigKind, website)
+
+	reconcileInstanaResource(t, r, website)
+	if live, ok := instana.Setting(websiteMonitoringConfigKind.Path, website.Status.Id); !ok || live["name"] != "shop.example.com" {
+		t.Fatalf("live website = %v", live)
+	}
+	var secret corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "shop-eum"}, &secret); err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["eum-key"]) != website.Status.Id || !metav1.IsControlledBy(&secret, website) {
+		t.Errorf("secret = %v, want the EUM key %s", secret.Data, website.Status.Id)
+	}
+}
+
+func TestMaintenanceWindowPayload(t *testing.T) {
+	window := &customv1.MaintenanceWindow{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "weekly", UID: "7d1e"},
+		Spec: customv1.MaintenanceWindowSpec{
+			Name:       "Weekly",
+			Query:      "entity.kubernetes.namespace.name:team-a",
+			Start:      metav1.NewTime(time.Date(2021, 6, 5, 2, 0, 0, 0, time.UTC)),
+			Duration:   metav1.Duration{Duration: 2 * time.Hour},
+			Recurrence: "FREQ=WEEKLY;BYDAY=SA",
+		},
+	}
+	r, instana := newInstanaResourceTest(t, maintenanceWindowKind, window)
+
+	reconcileInstanaResource(t, r, window)
+	live, ok := instana.Setting(maintenanceWindowKind.Path, "7d1e")
+	if !ok {
+		t.Fatal("expected the window to be created with the id of the resource")
+	}
+	scheduling, _ := live["scheduling"].(map[string]interface{})
+	if scheduling["type"] != "RECURRENT" || scheduling["start"] != float64(1622858400000) || scheduling["rrule"] != "FREQ=WEEKLY;BYDAY=SA" {
+		t.Errorf("scheduling = %v", scheduling)
+	}
+}
+
+func TestCustomEventSpecificationPayload(t *testing.T) {
+	event := &customv1.CustomEventSpecification{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "cpu", UID: "9a2b"},
+		Spec: customv1.CustomEventSpecificationSpec{
+			Name:           "CPU",
+			EntityType:     "host",
+			Rule:           &apiextensionsv1.JSON{Raw: []byte(`{"ruleType":"threshold","metricName":"cpu.used","conditionOperator":">","conditionValue":0.9}`)},
+			Severity:       "CRITICAL",
+			ExpirationTime: &metav1.Duration{Duration: time.Hour},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, customEventSpecificationKind, event)
+
+	reconcileInstanaResource(t, r, event)
+	live, ok := instana.Setting(customEventSpecificationKind.Path, "9a2b")
+	if !ok || live["enabled"] != true || live["expirationTime"] != float64(3600000) {
+		t.Fatalf("live event = %v", live)
+	}
+	if rules, _ := live["rules"].([]interface{}); len(rules) != 1 || rules[0].(map[string]interface{})["severity"] != float64(10) {
+		t.Errorf("rules = %v, want the rule with severity 10", live["rules"])
+	}
+}
+
+func TestAPITokenSecret(t *testing.T) {
+	ctx := context.Background()
+	apiToken := &customv1.APIToken{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "dashboards", UID: "3c4d"},
+		Spec: customv1.APITokenSpec{
+			Name:        "team-a dashboards",
+			Permissions: []string{"canCreatePublicCustomDashboards"},
+			SecretName:  "instana-token",
+		},
+	}
+	r, instana := newInstanaResourceTest(t, apiTokenKind, apiToken)
+
+	reconcileInstanaResource(t, r, apiToken)
+	var secret corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "instana-token"}, &secret); err != nil {
+		t.Fatal(err)
+	}
+	token := string(secret.Data["token"])
+	live, ok := instana.Setting(apiTokenKind.Path, "3c4d")
+	if !ok || token == "" || live["accessGrantingToken"] != token || live["canCreatePublicCustomDashboards"] != true {
+		t.Fatalf("live token = %v, secret token = %q", live, token)
+	}
+
+	// the token is kept on later syncs
+	reconcileInstanaResource(t, r, apiToken)
+	if live, _ := instana.Setting(apiTokenKind.Path, "3c4d"); live["accessGrantingToken"] != token {
+		t.Errorf("accessGrantingToken = %v, want %s", live["accessGrantingToken"], token)
+	}
+}
+
+func TestInstanaGroupScope(t *testing.T) {
+	perspective := &customv1.ApplicationPerspective{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Status:     customv1.InstanaResourceStatus{Id: "app-1"},
+	}
+	group := &customv1.InstanaGroup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "developers"},
+		Spec: customv1.InstanaGroupSpec{
+			Name:                       "Shop developers",
+			Permissions:                []string{"CAN_CONFIGURE_APPLICATIONS"},
+			ApplicationPerspectiveRefs: []string{"shop"},
+			Members:                    []customv1.InstanaGroupMember{{UserId: "u-1", Email: "dev@example.com"}},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, instanaGroupKind, perspective, group)
+
+	reconcileInstanaResource(t, r, group)
+	live, ok := instana.Setting(instanaGroupKind.Path, group.Status.Id)
+	if !ok {
+		t.Fatalf("group %s was not created", group.Status.Id)
+	}
+	permissionSet, _ := live["permissionSet"].(map[string]interface{})
+	if ids, _ := permissionSet["applicationIds"].([]interface{}); len(ids) != 1 || ids[0] != "app-1" {
+		t.Errorf("permissionSet = %v, want application app-1", permissionSet)
+	}
+	if members, _ := live["members"].([]interface{}); len(members) != 1 {
+		t.Errorf("members = %v", live["members"])
+	}
+}
+
+func TestAutomationActionPayload(t *testing.T) {
+	action := &customv1.AutomationAction{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "restart"},
+		Spec: customv1.AutomationActionSpec{
+			Name:   "Restart",
+			Script: &customv1.AutomationScript{Interpreter: "bash", Source: "echo @@name@@"},
+			Inputs: []customv1.AutomationInput{{Name: "name", Type: "static", Required: true}},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, automationActionKind, action)
+
+	reconcileInstanaResource(t, r, action)
+	live, ok := instana.Setting(automationActionKind.Path, action.Status.Id)
+	if !ok || live["type"] != "SCRIPT" {
+		t.Fatalf("live action = %v", live)
+	}
+	fields, _ := live["fields"].([]interface{})
+	if len(fields) != 2 || fields[0].(map[string]interface{})["value"] != "ZWNobyBAQG5hbWVAQA==" {
+		t.Errorf("fields = %v, want the base64 encoded script", fields)
+	}
+	if inputs, _ := live["inputParameters"].([]interface{}); len(inputs) != 1 {
+		t.Errorf("inputParameters = %v", live["inputParameters"])
+	}
+}
+
+func TestApplicationConfigPayload(t *testing.T) {
+	config := &customv1.ApplicationConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "containers", UID: "5e6f"},
+		Spec: customv1.ApplicationConfigSpec{
+			Name:  "Containers",
+			Label: "{kubernetes.container.name}",
+			Match: []customv1.ServiceMatchRule{{Key: "kubernetes.namespace.name", Value: "team-a"}},
+		},
+	}
+	r, instana := newInstanaResourceTest(t, applicationConfigKind, config)
+
+	reconcileInstanaResource(t, r, config)
+	live, ok := instana.Setting(applicationConfigKind.Path, "5e6f")
+	if !ok || live["enabled"] != true || live["label"] != "{kubernetes.container.name}" {
+		t.Fatalf("live rule = %v", live)
+	}
+	if match, _ := live["matchSpecification"].([]interface{}); len(match) != 1 {
+		t.Errorf("matchSpecification = %v", live["matchSpecification"])
+	}
+}
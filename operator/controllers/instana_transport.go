@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TransportConfig tunes the HTTP connections to the Instana tenants. Each
+// tenant has its own connection pool which is shared by all requests, so
+// connections are kept alive between reconciles.
+type TransportConfig struct {
+	// MaxIdleConnsPerHost is the number of idle connections kept per tenant.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections per tenant. 0 means no limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout is the time an idle connection is kept.
+	IdleConnTimeout time.Duration
+	// Timeout limits a request including reading the response. 0 means no timeout.
+	Timeout time.Duration
+	// HTTP2 is negotiated with tenants supporting it if true.
+	HTTP2 bool
+}
+
+// DefaultTransportConfig is used until SetTransportConfig is called.
+var DefaultTransportConfig = TransportConfig{
+	MaxIdleConnsPerHost: 10,
+	IdleConnTimeout:     90 * time.Second,
+	Timeout:             60 * time.Second,
+	HTTP2:               true,
+}
+
+var instanaHttpClients = struct {
+	sync.Mutex
+	config  TransportConfig
+	clients map[string]*http.Client
+}{config: DefaultTransportConfig}
+
+// SetTransportConfig replaces the transport config of all tenants. Existing
+// connections are closed once idle.
+func SetTransportConfig(config TransportConfig) {
+	instanaHttpClients.Lock()
+	defer instanaHttpClients.Unlock()
+	for _, c := range instanaHttpClients.clients {
+		c.CloseIdleConnections()
+	}
+	instanaHttpClients.config = config
+	instanaHttpClients.clients = nil
+}
+
+// httpClient returns the client of the tenant.
+func (apiConfig InstanaApi) httpClient() *http.Client {
+	instanaHttpClients.Lock()
+	defer instanaHttpClients.Unlock()
+	if instanaHttpClients.clients == nil {
+		instanaHttpClients.clients = map[string]*http.Client{}
+	}
+	c, ok := instanaHttpClients.clients[apiConfig.BaseUrl]
+	if !ok {
+		c = newInstanaHttpClient(instanaHttpClients.config)
+		instanaHttpClients.clients[apiConfig.BaseUrl] = c
+	}
+	return c
+}
+
+func newInstanaHttpClient(config TransportConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = config.MaxConnsPerHost
+	transport.IdleConnTimeout = config.IdleConnTimeout
+	transport.ForceAttemptHTTP2 = config.HTTP2
+	if !config.HTTP2 {
+		// a non-nil empty map disables HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return &http.Client{Transport: transport, Timeout: config.Timeout}
+}
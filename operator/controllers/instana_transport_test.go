@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestInstanaConnectionReuse(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	instanaApi := InstanaApi{BaseUrl: server.URL, ApiToken: "token"}
+	for i := 0; i < 3; i++ {
+		if _, err := instanaApi.listDashboards(ctrl.Log.WithName("test")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("opened %d connections for 3 requests, want 1", n)
+	}
+	if instanaApi.httpClient() == (InstanaApi{BaseUrl: "https://other.instana.io"}).httpClient() {
+		t.Error("tenants share a connection pool")
+	}
+}
+
+func TestTransportConfig(t *testing.T) {
+	c := newInstanaHttpClient(TransportConfig{MaxIdleConnsPerHost: 2, MaxConnsPerHost: 4, Timeout: 5 * time.Second})
+	transport := c.Transport.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != 2 || transport.MaxConnsPerHost != 4 || c.Timeout != 5*time.Second {
+		t.Errorf("transport = %+v", transport)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("HTTP/2 is not disabled")
+	}
+}
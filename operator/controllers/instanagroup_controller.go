@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=instanagroups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=instanagroups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=instanagroups/finalizers,verbs=update
+
+// instanaGroupKind syncs InstanaGroups with the groups of the RBAC settings API.
+var instanaGroupKind = InstanaResourceKind{
+	Kind: "InstanaGroup",
+	New:  func() InstanaResource { return &customv1.InstanaGroup{} },
+	Path: "/api/settings/rbac/groups",
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		group := obj.(*customv1.InstanaGroup)
+		spec := group.Spec
+		applicationIds := append([]string{}, spec.ApplicationIds...)
+		for _, name := range spec.ApplicationPerspectiveRefs {
+			id, err := instanaIdOf(ctx, c, group.Namespace, name, &customv1.ApplicationPerspective{})
+			if err != nil {
+				return nil, err
+			}
+			applicationIds = append(applicationIds, id)
+		}
+		websiteIds := append([]string{}, spec.WebsiteIds...)
+		for _, name := range spec.WebsiteRefs {
+			id, err := instanaIdOf(ctx, c, group.Namespace, name, &customv1.WebsiteMonitoringConfig{})
+			if err != nil {
+				return nil, err
+			}
+			websiteIds = append(websiteIds, id)
+		}
+		members := []interface{}{}
+		for _, member := range spec.Members {
+			members = append(members, map[string]interface{}{"userId": member.UserId, "email": member.Email})
+		}
+		return map[string]interface{}{
+			"name":    spec.Name,
+			"members": members,
+			"permissionSet": map[string]interface{}{
+				"permissions":             nonNil(spec.Permissions),
+				"applicationIds":          applicationIds,
+				"websiteIds":              websiteIds,
+				"kubernetesClusterUUIDs":  nonNil(spec.KubernetesClusterUUIDs),
+				"kubernetesNamespaceUIDs": nonNil(spec.KubernetesNamespaceUIDs),
+				"mobileAppIds":            []string{},
+				"infraDfqFilter":          spec.InfraDfqFilter,
+			},
+		}, nil
+	},
+}
@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+var (
+	linkPattern = regexp.MustCompile(`https?://[^\s"'<>()\[\]\\]+`)
+	// dashboardLinkPattern matches links to other custom dashboards of the tenant.
+	dashboardLinkPattern = regexp.MustCompile(`/#/customDashboards/([A-Za-z0-9_-]+)`)
+)
+
+// LinkChecker periodically verifies the links embedded in the rendered
+// dashboards, e.g. runbooks in markdown widgets or links to other dashboards,
+// and the snapshot ids of the entities they show. Dead links are reported in
+// the BrokenLinks condition.
+type LinkChecker struct {
+	client.Client
+	Log      logr.Logger
+	Interval time.Duration
+	Shard    Shard
+	// HttpClient is used to check external links with HEAD requests.
+	HttpClient *http.Client
+	// AllowedHosts are the hosts besides the Instana tenant whose links are
+	// requested. Links to other hosts are not checked, so dashboards can't
+	// make the operator send requests to arbitrary hosts, e.g. in the
+	// cluster network.
+	AllowedHosts []string
+	// Variables are passed to templated configs.
+	Variables RenderVariables
+	// Instana creates the client of the tenant, so the checks share its rate
+	// limit and circuit breaker with the reconcilers. With a ListCache links
+	// to dashboards of the tenant are checked against the cached dashboard
+	// list instead of reading every dashboard.
+	Instana *InstanaClients
+	// APIReader reads the Secrets of spec.values-from bypassing the cache.
+	APIReader client.Reader
+}
+
+// Status returns the status writer of the client, redacting the status of
+// the Dashboards.
+func (lc *LinkChecker) Status() client.StatusWriter {
+	return redactingStatusWriter{StatusWriter: lc.Client.Status()}
+}
+
+// Start runs the checker until the context is cancelled.
+func (lc *LinkChecker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(lc.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			lc.checkAll(ctx)
+		}
+	}
+}
+
+// renderReader returns the reader of the resources a config is rendered from.
+func (lc *LinkChecker) renderReader() client.Reader {
+	if lc.APIReader == nil {
+		return lc.Client
+	}
+	return secretsReader{Reader: lc.Client, apiReader: lc.APIReader}
+}
+
+// NeedLeaderElection makes sure only the leading manager checks links.
+func (lc *LinkChecker) NeedLeaderElection() bool {
+	return true
+}
+
+func (lc *LinkChecker) checkAll(ctx context.Context) {
+	_, instanaApi := loadInstanaConfig(ctx, lc.Client)
+	instanaClient := lc.Instana.Client(ctx, instanaApi)
+	vars := lc.Variables
+	vars.Instana = instanaClient
+	var dashboards customv1.DashboardList
+	if err := lc.List(ctx, &dashboards); err != nil {
+		lc.Log.Error(err, "unable to list dashboards")
+		return
+	}
+	for i := range dashboards.Items {
+		dashboard := &dashboards.Items[i]
+		if !lc.Shard.OwnsDashboard(dashboard) || dashboard.DeletionTimestamp != nil {
+			continue
+		}
+		config, err := renderConfig(ctx, lc.renderReader(), vars, *dashboard)
+		if err != nil {
+			continue
+		}
+		broken := lc.brokenLinks(config, instanaApi, instanaClient)
+		if !setBrokenLinksStatus(dashboard, broken) {
+			continue
+		}
+		if err := lc.Status().Update(ctx, dashboard); err != nil {
+			lc.Log.Error(err, "unable to update dashboard status", "dashboard", client.ObjectKeyFromObject(dashboard))
+		}
+	}
+}
+
+// brokenLinks returns the links and snapshot ids of the config which could
+// not be resolved.
+func (lc *LinkChecker) brokenLinks(config []byte, instanaApi InstanaApi, instanaClient InstanaClient) []string {
+	seen := map[string]bool{}
+	var broken []string
+	for _, link := range linkPattern.FindAllString(string(config), -1) {
+		if seen[link] {
+			continue
+		}
+		seen[link] = true
+		if err := lc.checkLink(link, instanaApi, instanaClient); err != nil {
+			broken = append(broken, fmt.Sprintf("%s (%s)", link, err.Error()))
+		}
+	}
+	for _, id := range snapshotIds(config) {
+		if _, err := instanaClient.getSnapshot(id, lc.Log); isInstanaNotFound(err) {
+			broken = append(broken, fmt.Sprintf("entity %s (does not exist)", id))
+		} else if err != nil {
+			broken = append(broken, fmt.Sprintf("entity %s (%s)", id, err.Error()))
+		}
+	}
+	return broken
+}
+
+func (lc *LinkChecker) checkLink(link string, instanaApi InstanaApi, instanaClient InstanaClient) error {
+	if match := dashboardLinkPattern.FindStringSubmatch(link); match != nil && instanaApi.BaseUrl != "" && strings.HasPrefix(link, instanaApi.BaseUrl) {
+		// The Instana UI answers every path, so ask the API whether the dashboard exists
+		if lc.Instana == nil || lc.Instana.ListCache == nil || lc.Instana.ListCache.TTL <= 0 {
+			_, err := instanaClient.getDashboard(match[1], lc.Log)
+			return err
+		}
+		// the client lists the dashboards from the cache
+		list, err := instanaClient.listDashboards(lc.Log)
+		if err != nil {
+			return err
+		}
+		for _, d := range list {
+			if d.Id == match[1] {
+				return nil
+			}
+		}
+		return fmt.Errorf("dashboard %s does not exist", match[1])
+	}
+	allowed := lc.allowedHost(instanaApi)
+	if u, err := url.Parse(link); err != nil || !allowed(u) {
+		lc.Log.V(1).Info("Not checking link to a host which is not allowed", "link", link)
+		return nil
+	}
+	httpClient := *lc.HttpClient
+	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !allowed(req.URL) {
+			return fmt.Errorf("redirect to %s is not allowed", req.URL.Host)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	resp, err := httpClient.Head(link)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		resp, err = httpClient.Get(link)
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// allowedHost returns whether links to the host of a url may be requested:
+// the host of the tenant and the allowed hosts, with or without port.
+func (lc *LinkChecker) allowedHost(instanaApi InstanaApi) func(u *url.URL) bool {
+	hosts := map[string]bool{}
+	if tenant, err := url.Parse(instanaApi.BaseUrl); err == nil && tenant.Host != "" {
+		hosts[strings.ToLower(tenant.Host)] = true
+	}
+	for _, host := range lc.AllowedHosts {
+		hosts[strings.ToLower(host)] = true
+	}
+	return func(u *url.URL) bool {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return false
+		}
+		return hosts[strings.ToLower(u.Host)] || hosts[strings.ToLower(u.Hostname())]
+	}
+}
+
+// snapshotIds returns the sorted snapshot ids of the entities the config
+// refers to in "snapshotId" and "snapshotIds" fields.
+func snapshotIds(config []byte) []string {
+	var value interface{}
+	if err := json.Unmarshal(config, &value); err != nil {
+		return nil
+	}
+	ids := map[string]bool{}
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, field := range v {
+				switch id := field.(type) {
+				case string:
+					if key == "snapshotId" && id != "" {
+						ids[id] = true
+					}
+				case []interface{}:
+					if key == "snapshotIds" {
+						for _, item := range id {
+							if s, ok := item.(string); ok && s != "" {
+								ids[s] = true
+							}
+						}
+					}
+				}
+				walk(field)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// setBrokenLinksStatus sets the BrokenLinks condition and returns true if it
+// changed, so the status is only written on changes.
+func setBrokenLinksStatus(dashboard *customv1.Dashboard, broken []string) bool {
+	condition := metav1.Condition{
+		Type:    customv1.ConditionBrokenLinks,
+		Status:  metav1.ConditionFalse,
+		Reason:  "LinksValid",
+		Message: "All links could be resolved",
+	}
+	if len(broken) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "LinksUnresolvable"
+		// the links may be rendered from the values of Secrets
+		condition.Message = Redact(valuesFromSecrets.redact(client.ObjectKeyFromObject(dashboard), strings.Join(broken, "; ")))
+	}
+	current := meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionBrokenLinks)
+	if current != nil && current.Status == condition.Status && current.Reason == condition.Reason && current.Message == condition.Message {
+		return false
+	}
+	meta.SetStatusCondition(&dashboard.Status.Conditions, condition)
+	return true
+}
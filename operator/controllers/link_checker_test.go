@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestBrokenLinks(t *testing.T) {
+	var internalRequests int
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalRequests++
+	}))
+	defer internal.Close()
+	runbooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/moved":
+			http.Redirect(w, r, internal.URL+"/secret", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer runbooks.Close()
+	runbooksUrl, _ := url.Parse(runbooks.URL)
+
+	instanaApi := InstanaApi{BaseUrl: "https://tenant.instana.io"}
+	instana := newFakeInstanaClient()
+	instana.dashboards["d1"] = []byte(`{"title":"Other"}`)
+	instana.snapshots["payments"] = []InstanaSnapshot{{SnapshotId: "s1"}}
+	lc := &LinkChecker{Log: ctrl.Log.WithName("test"), HttpClient: http.DefaultClient, AllowedHosts: []string{runbooksUrl.Host}}
+
+	config := fmt.Sprintf(`{"widgets":[
+		{"config":{"text":"[ok](%[1]s/ok) [missing](%[1]s/missing) [moved](%[1]s/moved) [internal](%[2]s/secret)"}},
+		{"config":{"text":"https://tenant.instana.io/#/customDashboards/d1 https://tenant.instana.io/#/customDashboards/d2"}},
+		{"config":{"snapshotId":"s1","snapshotIds":["s1","s2"]}}
+	]}`, runbooks.URL, internal.URL)
+	broken := lc.brokenLinks([]byte(config), instanaApi, instana)
+
+	want := []string{runbooks.URL + "/missing", runbooks.URL + "/moved", "customDashboards/d2", "entity s2"}
+	if len(broken) != len(want) {
+		t.Fatalf("broken links = %v, want %v", broken, want)
+	}
+	for i, link := range want {
+		if !strings.Contains(broken[i], link) {
+			t.Errorf("broken link %d = %q, want %q", i, broken[i], link)
+		}
+	}
+	if internalRequests != 0 {
+		t.Errorf("%d requests to a host which is not allowed", internalRequests)
+	}
+}
+
+func TestSnapshotIds(t *testing.T) {
+	ids := snapshotIds([]byte(`{"widgets":[{"config":{"snapshotId":"b","nested":[{"snapshotIds":["a","b"]}]}},{"snapshotId":""}]}`))
+	if fmt.Sprint(ids) != "[a b]" {
+		t.Errorf("snapshotIds() = %v, want [a b]", ids)
+	}
+}
+
+func TestLinkCheckerWritesChangedStatus(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "team-a", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": "https://tenant.instana.io", "instana-api-token": "token"},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec:       customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[{"config":{"snapshotId":"s1"}}]}`)}},
+		},
+	).Build()
+	instana := newFakeInstanaClient()
+	lc := &LinkChecker{
+		Client:     c,
+		Log:        ctrl.Log.WithName("test"),
+		HttpClient: http.DefaultClient,
+		Instana:    &InstanaClients{NewInstanaClient: func(InstanaApi) InstanaClient { return instana }},
+	}
+	check := func() customv1.Dashboard {
+		t.Helper()
+		lc.checkAll(ctx)
+		var dashboard customv1.Dashboard
+		if err := c.Get(ctx, key, &dashboard); err != nil {
+			t.Fatal(err)
+		}
+		return dashboard
+	}
+
+	first := check()
+	if !meta.IsStatusConditionTrue(first.Status.Conditions, customv1.ConditionBrokenLinks) {
+		t.Fatalf("conditions = %v, want BrokenLinks", first.Status.Conditions)
+	}
+	if second := check(); second.ResourceVersion != first.ResourceVersion {
+		t.Error("the unchanged status was written again")
+	}
+	instana.snapshots["shop"] = []InstanaSnapshot{{SnapshotId: "s1"}}
+	if third := check(); meta.IsStatusConditionTrue(third.Status.Conditions, customv1.ConditionBrokenLinks) {
+		t.Errorf("conditions after the entity appeared = %v", third.Status.Conditions)
+	}
+}
+
+func TestLinkCheckerRedactsValuesFrom(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "team-a", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": "https://tenant.instana.io", "instana-api-token": "token"},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec: customv1.DashboardSpec{
+				Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[{"config":{"snapshotId":"{{ .Values.snapshot }}"}}]}`)},
+				ValuesFrom: []customv1.ValueSource{
+					{Name: "snapshot", SecretKeyRef: customv1.SecretKeyReference{Name: "ids", Key: "snapshot"}},
+				},
+			},
+		},
+	).Build()
+	// the Secret is only readable with the APIReader
+	apiReader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: "ids"},
+		Data:       map[string][]byte{"snapshot": []byte("q7z")},
+	}).Build()
+	lc := &LinkChecker{
+		Client:     c,
+		Log:        ctrl.Log.WithName("test"),
+		HttpClient: http.DefaultClient,
+		Instana:    &InstanaClients{NewInstanaClient: func(InstanaApi) InstanaClient { return newFakeInstanaClient() }},
+		APIReader:  apiReader,
+	}
+	check := func() customv1.Dashboard {
+		t.Helper()
+		lc.checkAll(ctx)
+		var dashboard customv1.Dashboard
+		if err := c.Get(ctx, key, &dashboard); err != nil {
+			t.Fatal(err)
+		}
+		return dashboard
+	}
+
+	first := check()
+	condition := meta.FindStatusCondition(first.Status.Conditions, customv1.ConditionBrokenLinks)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Fatalf("conditions = %v, want BrokenLinks", first.Status.Conditions)
+	}
+	if strings.Contains(condition.Message, "q7z") || !strings.Contains(condition.Message, redacted) {
+		t.Errorf("message = %q, want the value of the Secret redacted", condition.Message)
+	}
+	if second := check(); second.ResourceVersion != first.ResourceVersion {
+		t.Error("the unchanged status was written again")
+	}
+}
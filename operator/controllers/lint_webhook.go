@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	"github.com/luebken/custom-dashboards/lint"
+)
+
+const lintPath = "/validate-custom-instana-io-v1-dashboard-lint"
+
+//+kubebuilder:webhook:path=/validate-custom-instana-io-v1-dashboard-lint,mutating=false,failurePolicy=ignore,sideEffects=None,groups=custom.instana.io,resources=dashboards,verbs=create;update,versions=v1,name=vdashboardlint.kb.io,admissionReviewVersions={v1,v1beta1}
+
+// LintWebhook returns the lint findings of the config of a created or
+// updated Dashboard as warnings, so kubectl apply prints them. It never
+// denies a Dashboard.
+type LintWebhook struct {
+	// WidgetTypes are known in addition to lint.WidgetTypes.
+	WidgetTypes []string
+	decoder     *admission.Decoder
+}
+
+// Handle lints the config of the Dashboard.
+func (w *LintWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	dashboard := &customv1.Dashboard{}
+	if err := w.decoder.Decode(req, dashboard); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	// configs from ConfigMaps are not part of the request
+	if dashboard.DeletionTimestamp != nil || dashboard.Spec.Config == nil || dashboard.Spec.ConfigFrom != nil {
+		return admission.Allowed("")
+	}
+	findings, err := lint.Config(dashboard.Spec.Config.Raw, lint.Options{WidgetTypes: w.WidgetTypes})
+	if err != nil {
+		return admission.Allowed("").WithWarnings(err.Error())
+	}
+	var warnings []string
+	for _, f := range findings {
+		warnings = append(warnings, string(f.Severity)+": spec.config"+f.Path+": "+f.Message)
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (w *LintWebhook) InjectDecoder(decoder *admission.Decoder) error {
+	w.decoder = decoder
+	return nil
+}
+
+// SetupWithManager registers the webhook with the webhook server of the Manager.
+func (w *LintWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(lintPath, &webhook.Admission{Handler: w})
+	return nil
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestLintWebhook(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = customv1.AddToScheme(scheme)
+	decoder, _ := admission.NewDecoder(scheme)
+	webhook := &LintWebhook{}
+	_ = webhook.InjectDecoder(decoder)
+
+	dashboard := &customv1.Dashboard{
+		TypeMeta:   metav1.TypeMeta{APIVersion: customv1.GroupVersion.String(), Kind: "Dashboard"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "shop"},
+		Spec: customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(
+			`{"title":"Shop","widgets":[{"id":"a","type":"chart","x":0,"y":0,"width":8,"height":2},{"id":"b","type":"chart","x":4,"y":0,"width":8,"height":2}]}`,
+		)}},
+	}
+	raw, _ := json.Marshal(dashboard)
+	resp := webhook.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}})
+	if !resp.Allowed {
+		t.Fatalf("denied: %v", resp.Result)
+	}
+	want := `error: spec.config/widgets/1: widget overlaps /widgets/0 ("a")`
+	if len(resp.Warnings) != 1 || resp.Warnings[0] != want {
+		t.Errorf("warnings = %q, want %q", resp.Warnings, want)
+	}
+}
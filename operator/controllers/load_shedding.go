@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// LoadShedder detects when the Dashboard work queue is deeper than a
+// threshold. While shedding, resyncs of unchanged dashboards are skipped, so
+// creates, updates and deletes stay responsive.
+//
+// The queue depth is sampled once per interval by Start, so the metrics
+// registry isn't gathered on every reconcile and the metric follows the
+// queue also while no dashboard is reconciled.
+type LoadShedder struct {
+	// Threshold is the queue depth above which load is shed. 0 disables shedding.
+	Threshold int
+	// QueueName is the name of the controller work queue.
+	QueueName string
+	// Interval between two samples of the queue depth. Defaults to 10 seconds.
+	Interval time.Duration
+
+	// depth reads the queue depth, workQueueDepth if nil.
+	depth    func(name string) float64
+	shedding int32
+}
+
+// Start samples the queue depth until the context is cancelled.
+func (l *LoadShedder) Start(ctx context.Context) error {
+	interval := l.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		l.sample()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection lets every replica sample its own work queue.
+func (l *LoadShedder) NeedLeaderElection() bool {
+	return false
+}
+
+// sample reads the queue depth and updates the shedding state and metric.
+func (l *LoadShedder) sample() {
+	depth := l.depth
+	if depth == nil {
+		depth = workQueueDepth
+	}
+	var shedding int32
+	if l.Threshold > 0 && depth(l.QueueName) > float64(l.Threshold) {
+		shedding = 1
+	}
+	atomic.StoreInt32(&l.shedding, shedding)
+	loadSheddingActive.Set(float64(shedding))
+}
+
+// Shedding returns true if low priority work should be skipped right now.
+func (l *LoadShedder) Shedding() bool {
+	return l != nil && l.Threshold > 0 && atomic.LoadInt32(&l.shedding) == 1
+}
+
+// setLoadShedStatus sets the LoadShed condition while the resync of the
+// dashboard is skipped and removes it otherwise. It returns true if the
+// condition changed.
+func setLoadShedStatus(dashboard *customv1.Dashboard, shed bool) bool {
+	current := meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionLoadShed)
+	if !shed {
+		removeStatusCondition(&dashboard.Status.Conditions, customv1.ConditionLoadShed)
+		return current != nil
+	}
+	if current != nil && current.Status == metav1.ConditionTrue {
+		return false
+	}
+	meta.SetStatusCondition(&dashboard.Status.Conditions, metav1.Condition{
+		Type:    customv1.ConditionLoadShed,
+		Status:  metav1.ConditionTrue,
+		Reason:  "QueueTooDeep",
+		Message: "The resync is skipped while the work queue of the operator is too deep",
+	})
+	return true
+}
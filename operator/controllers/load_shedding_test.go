@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestLoadShedder(t *testing.T) {
+	depth := 0.0
+	shedder := &LoadShedder{Threshold: 10, QueueName: "dashboard", depth: func(string) float64 { return depth }}
+	for _, tc := range []struct {
+		depth float64
+		want  bool
+	}{
+		{depth: 5, want: false},
+		{depth: 11, want: true},
+		{depth: 10, want: false},
+	} {
+		depth = tc.depth
+		shedder.sample()
+		if got := shedder.Shedding(); got != tc.want {
+			t.Errorf("Shedding() at depth %v = %v, want %v", tc.depth, got, tc.want)
+		}
+		if got, want := testutil.ToFloat64(loadSheddingActive), map[bool]float64{true: 1}[tc.want]; got != want {
+			t.Errorf("metric at depth %v = %v, want %v", tc.depth, got, want)
+		}
+	}
+
+	var disabled *LoadShedder
+	if disabled.Shedding() {
+		t.Error("a nil load shedder must not shed")
+	}
+}
+
+func TestReconcileShedsUnchangedDashboards(t *testing.T) {
+	key := client.ObjectKey{Namespace: "default", Name: "shop"}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec:       customv1.DashboardSpec{Config: &apiextensionsv1.JSON{Raw: []byte(`{"title":"Shop","widgets":[]}`)}},
+	}).Build()
+	depth := 100.0
+	instana := newFakeInstanaClient()
+	r := &DashboardReconciler{
+		Client:           c,
+		Log:              ctrl.Log.WithName("test"),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(100),
+		IdStore:          noopIdStore{},
+		LoadShedder:      &LoadShedder{Threshold: 10, depth: func(string) float64 { return depth }},
+		NewInstanaClient: func(InstanaApi) InstanaClient { return instana },
+	}
+	r.LoadShedder.sample()
+	reconcile := func() customv1.Dashboard {
+		t.Helper()
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+		var dashboard customv1.Dashboard
+		if err := c.Get(context.Background(), key, &dashboard); err != nil {
+			t.Fatal(err)
+		}
+		return dashboard
+	}
+
+	// new dashboards are created although the queue is too deep
+	if dashboard := reconcile(); dashboard.Status.DashboardId != "fake-1" {
+		t.Fatalf("dashboard id = %q, want fake-1", dashboard.Status.DashboardId)
+	}
+	instana.calls = nil
+	dashboard := reconcile()
+	if len(instana.calls) != 0 {
+		t.Errorf("Instana calls while shedding = %v", instana.calls)
+	}
+	if !meta.IsStatusConditionTrue(dashboard.Status.Conditions, customv1.ConditionLoadShed) {
+		t.Errorf("conditions while shedding = %v", dashboard.Status.Conditions)
+	}
+
+	depth = 0
+	r.LoadShedder.sample()
+	if dashboard := reconcile(); meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionLoadShed) != nil {
+		t.Errorf("conditions after the queue drained = %v", dashboard.Status.Conditions)
+	}
+}
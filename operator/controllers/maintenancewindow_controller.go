@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=maintenancewindows,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=maintenancewindows/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=maintenancewindows/finalizers,verbs=update
+
+// maintenanceWindowKind syncs MaintenanceWindows with the maintenance
+// configurations of the settings API.
+var maintenanceWindowKind = InstanaResourceKind{
+	Kind:      "MaintenanceWindow",
+	New:       func() InstanaResource { return &customv1.MaintenanceWindow{} },
+	Path:      "/api/settings/v2/maintenance",
+	ClientIds: true,
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		spec := obj.(*customv1.MaintenanceWindow).Spec
+		scheduling := map[string]interface{}{
+			"start":    spec.Start.UnixNano() / int64(time.Millisecond),
+			"duration": map[string]interface{}{"amount": int64(spec.Duration.Duration / time.Minute), "unit": "MINUTES"},
+			"type":     "ONE_TIME",
+		}
+		if spec.Recurrence != "" {
+			scheduling["type"] = "RECURRENT"
+			scheduling["rrule"] = spec.Recurrence
+		}
+		return map[string]interface{}{
+			"name":                       spec.Name,
+			"query":                      spec.Query,
+			"scheduling":                 scheduling,
+			"paused":                     spec.Paused,
+			"tagFilterExpressionEnabled": false,
+		}, nil
+	},
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// managedMarkerWidgetId is the id of the markdown widget which marks a
+// dashboard as created by the operator.
+const managedMarkerWidgetId = "managed-by-operator"
+
+var managedMarkerPattern = regexp.MustCompile(`cluster=(\S*) namespace=(\S+) name=(\S+) uid=(\S+)(?: tags=(\S+))?`)
+
+// tagPattern matches the tags of spec.tags. Whitespace and commas are
+// excluded as the tags are stored comma separated in the marker.
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]*$`)
+
+// ManagedMarker identifies the Dashboard resource which created an Instana dashboard.
+type ManagedMarker struct {
+	Cluster   string
+	Namespace string
+	Name      string
+	UID       string
+	// Tags are the tags of the spec.
+	Tags []string
+}
+
+// parseManagedMarker looks for the marker widget in a dashboard config.
+// Returns false for dashboards which are not managed by the operator.
+func parseManagedMarker(config []byte) (ManagedMarker, bool) {
+	var dashboard struct {
+		Widgets []struct {
+			Id     string      `json:"id"`
+			Type   string      `json:"type"`
+			Config interface{} `json:"config"`
+		} `json:"widgets"`
+	}
+	if err := json.Unmarshal(config, &dashboard); err != nil {
+		return ManagedMarker{}, false
+	}
+	for _, widget := range dashboard.Widgets {
+		text, ok := widget.Config.(string)
+		if widget.Id != managedMarkerWidgetId || widget.Type != "markdown" || !ok {
+			continue
+		}
+		if match := managedMarkerPattern.FindStringSubmatch(text); match != nil {
+			marker := ManagedMarker{Cluster: match[1], Namespace: match[2], Name: match[3], UID: match[4]}
+			if match[5] != "" {
+				marker.Tags = strings.Split(match[5], ",")
+			}
+			return marker, true
+		}
+	}
+	return ManagedMarker{}, false
+}
+
+// injectManagedMarker adds the marker widget below all other widgets of the
+// config. An existing marker widget is replaced.
+func injectManagedMarker(config []byte, marker ManagedMarker) ([]byte, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return nil, err
+	}
+	widgets, _ := payload["widgets"].([]interface{})
+	var result []interface{}
+	bottom := 0.0
+	for _, w := range widgets {
+		widget, ok := w.(map[string]interface{})
+		if ok && widget["id"] == managedMarkerWidgetId {
+			continue
+		}
+		if ok {
+			y, _ := widget["y"].(float64)
+			height, _ := widget["height"].(float64)
+			bottom = math.Max(bottom, y+height)
+		}
+		result = append(result, w)
+	}
+	text := fmt.Sprintf("Managed by the Instana dashboards operator. Changes in the UI may be overwritten.\n\ncluster=%s namespace=%s name=%s uid=%s",
+		marker.Cluster, marker.Namespace, marker.Name, marker.UID)
+	if len(marker.Tags) > 0 {
+		text += " tags=" + strings.Join(marker.Tags, ",")
+	}
+	payload["widgets"] = append(result, map[string]interface{}{
+		"id":     managedMarkerWidgetId,
+		"title":  "Managed Dashboard",
+		"type":   "markdown",
+		"width":  12,
+		"height": 2,
+		"x":      0,
+		"y":      bottom,
+		"config": text,
+	})
+	return json.Marshal(payload)
+}
+
+// HasTags reports whether the marker carries all the tags.
+func (m ManagedMarker) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !containsString(m.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateTags checks the tags of spec.tags.
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if !tagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q, tags consist of letters, digits and \".\", \"_\", \"-\", \":\" or \"/\"", tag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManagedMarkerTags(t *testing.T) {
+	for _, tags := range [][]string{nil, {"team:shop", "service/checkout"}} {
+		marker := ManagedMarker{Cluster: "prod", Namespace: "team-a", Name: "shop", UID: "uid", Tags: tags}
+		config, err := injectManagedMarker([]byte(`{"title":"Shop","widgets":[]}`), marker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := parseManagedMarker(config); !ok || !reflect.DeepEqual(got, marker) {
+			t.Errorf("parseManagedMarker() = %+v, %v, want %+v", got, ok, marker)
+		}
+	}
+
+	marker := ManagedMarker{Tags: []string{"team:shop", "service/checkout"}}
+	if !marker.HasTags(nil) || !marker.HasTags([]string{"team:shop"}) || marker.HasTags([]string{"team:shop", "team:ops"}) {
+		t.Error("HasTags() doesn't require all tags")
+	}
+	if err := validateTags([]string{"team:shop", "v1.2_beta"}); err != nil {
+		t.Error(err)
+	}
+	for _, tag := range []string{"", "team shop", "a,b", "-shop"} {
+		if err := validateTags([]string{tag}); err == nil {
+			t.Errorf("validateTags(%q) = nil, want an error", tag)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	loadSheddingActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "instana_dashboards_load_shedding_active",
+		Help: "1 while low priority dashboard resyncs are skipped because the work queue is too deep.",
+	})
+	circuitBreakerOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "instana_dashboards_circuit_breaker_open",
+		Help: "1 while requests against the Instana tenant are suspended after repeated failures.",
+	}, []string{"tenant"})
+	backupLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "instana_dashboards_backup_last_success_timestamp_seconds",
+		Help: "The time of the last backup in which all dashboards of the Instana tenant were stored.",
+	}, []string{"tenant"})
+	dashboardListCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "instana_dashboards_list_cache_requests_total",
+		Help: "The number of dashboard lists of the Instana tenant read from the cache (hit) or requested from Instana (miss).",
+	}, []string{"tenant", "result"})
+	apiRateLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "instana_dashboards_api_rate_limit",
+		Help: "The number of requests per hour the Instana tenant allows, from the X-RateLimit-Limit header.",
+	}, []string{"tenant"})
+	apiRateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "instana_dashboards_api_rate_limit_remaining",
+		Help: "The number of requests left until the rate limit of the Instana tenant resets, from the X-RateLimit-Remaining header.",
+	}, []string{"tenant"})
+	apiRateLimitReset = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "instana_dashboards_api_rate_limit_reset_timestamp_seconds",
+		Help: "The time the rate limit of the Instana tenant resets, from the X-RateLimit-Reset header.",
+	}, []string{"tenant"})
+	orphanedDashboards = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "instana_dashboards_orphaned",
+		Help: "The number of dashboards of the Instana tenant with the managed marker of a Dashboard which does not exist anymore, as of the last sweep.",
+	}, []string{"tenant"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(loadSheddingActive, circuitBreakerOpen, backupLastSuccess, dashboardListCacheRequests,
+		apiRateLimit, apiRateLimitRemaining, apiRateLimitReset, orphanedDashboards)
+}
+
+// recordRateLimit exports the rate limit headers of an Instana response.
+// Responses without the headers leave the metrics unchanged.
+func recordRateLimit(tenant string, header http.Header, now time.Time) {
+	if limit, err := strconv.ParseFloat(header.Get("X-RateLimit-Limit"), 64); err == nil {
+		apiRateLimit.WithLabelValues(tenant).Set(limit)
+	}
+	if remaining, err := strconv.ParseFloat(header.Get("X-RateLimit-Remaining"), 64); err == nil {
+		apiRateLimitRemaining.WithLabelValues(tenant).Set(remaining)
+	}
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		// the reset is a unix timestamp, small values are seconds from now
+		if reset < 1e9 {
+			reset += now.Unix()
+		}
+		apiRateLimitReset.WithLabelValues(tenant).Set(float64(reset))
+	}
+}
+
+// workQueueDepth reads the depth of a controller work queue from the
+// workqueue metrics of controller-runtime.
+func workQueueDepth(name string) float64 {
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		return 0
+	}
+	for _, family := range families {
+		if family.GetName() != "workqueue_depth" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "name" && label.GetValue() == name {
+					return metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestRecordRateLimit(t *testing.T) {
+	reset := time.Now().Add(30 * time.Minute).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	if _, err := (InstanaApi{BaseUrl: server.URL}).do(http.MethodGet, "/api/custom-dashboard", nil, ctrl.Log); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(apiRateLimit.WithLabelValues(server.URL)); got != 5000 {
+		t.Errorf("limit = %v", got)
+	}
+	if got := testutil.ToFloat64(apiRateLimitRemaining.WithLabelValues(server.URL)); got != 4321 {
+		t.Errorf("remaining = %v", got)
+	}
+	if got := testutil.ToFloat64(apiRateLimitReset.WithLabelValues(server.URL)); got != float64(reset) {
+		t.Errorf("reset = %v, want %d", got, reset)
+	}
+
+	// resets given in seconds are relative to now
+	now := time.Unix(1600000000, 0)
+	recordRateLimit("relative", http.Header{"X-Ratelimit-Reset": {"60"}}, now)
+	if got := testutil.ToFloat64(apiRateLimitReset.WithLabelValues("relative")); got != 1600000060 {
+		t.Errorf("reset = %v", got)
+	}
+}
+
+func TestTenantMetrics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = customv1.AddToScheme(scheme)
+	synced := metav1.Unix(1600000000, 0)
+	failed := metav1.Unix(1600000100, 0)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "synced"},
+			Spec:       customv1.DashboardSpec{MirrorTenant: "dr-tenant"},
+			Status: customv1.DashboardStatus{LastSyncTime: &synced, Conditions: []metav1.Condition{
+				{Type: customv1.ConditionSynced, Status: metav1.ConditionTrue},
+				{Type: customv1.ConditionMirrorSynced, Status: metav1.ConditionFalse},
+			}},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "failed"},
+			Status: customv1.DashboardStatus{LastSyncTime: &failed, SyncAttempts: 2, Conditions: []metav1.Condition{
+				{Type: customv1.ConditionSynced, Status: metav1.ConditionFalse},
+			}},
+		},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "clusters"},
+			Spec:       customv1.DashboardSpec{Clusters: []string{"prod", "staging"}},
+			Status: customv1.DashboardStatus{Clusters: []customv1.ClusterDashboardStatus{
+				{Cluster: "prod", DashboardId: "a"},
+				{Cluster: "staging", Error: "502 Bad Gateway"},
+			}},
+		},
+	).Build()
+
+	want := `
+# HELP instana_dashboards_failing The number of dashboards of the Instana tenant whose last sync failed.
+# TYPE instana_dashboards_failing gauge
+instana_dashboards_failing{tenant="dr-tenant"} 1
+instana_dashboards_failing{tenant="instana-custom-dashboard-config"} 2
+# HELP instana_dashboards_last_successful_sync_timestamp_seconds The time of the latest successful sync of a dashboard of the Instana tenant.
+# TYPE instana_dashboards_last_successful_sync_timestamp_seconds gauge
+instana_dashboards_last_successful_sync_timestamp_seconds{tenant="instana-custom-dashboard-config"} 1.6e+09
+# HELP instana_dashboards_managed The number of dashboards the operator manages in the Instana tenant.
+# TYPE instana_dashboards_managed gauge
+instana_dashboards_managed{tenant="dr-tenant"} 1
+instana_dashboards_managed{tenant="instana-custom-dashboard-config"} 4
+`
+	if err := testutil.CollectAndCompare(&TenantMetrics{Client: c, Log: ctrl.Log}, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// standby replicas export nothing
+	elected := make(chan struct{})
+	metrics := &TenantMetrics{Client: c, Log: ctrl.Log, Elected: elected}
+	if n := testutil.CollectAndCount(metrics); n != 0 {
+		t.Errorf("standby replica exported %d metrics", n)
+	}
+	close(elected)
+	if n := testutil.CollectAndCount(metrics); n == 0 {
+		t.Error("leader exported no metrics")
+	}
+
+	// the dashboards of namespace credentials count for the tenant of their namespace
+	metrics.NamespaceCredentials = true
+	want = `
+# HELP instana_dashboards_managed The number of dashboards the operator manages in the Instana tenant.
+# TYPE instana_dashboards_managed gauge
+instana_dashboards_managed{tenant="dr-tenant"} 1
+instana_dashboards_managed{tenant="team-a/instana-dashboard-credentials"} 4
+`
+	if err := testutil.CollectAndCompare(metrics, strings.NewReader(want), "instana_dashboards_managed"); err != nil {
+		t.Error(err)
+	}
+}
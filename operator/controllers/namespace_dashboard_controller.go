@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+const (
+	// namespaceDashboardName is the name of the generated Dashboard in its namespace.
+	namespaceDashboardName = "instana-namespace"
+
+	defaultNamespaceTemplate = "namespace"
+)
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// NamespaceDashboardReconciler generates a Dashboard in every Namespace
+// annotated with custom.instana.io/generate-dashboard: "true", from the
+// template of custom.instana.io/dashboard-template. The Dashboard is owned by
+// the Namespace and deleted when the annotation is removed.
+type NamespaceDashboardReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Variables are passed to the templates.
+	Variables RenderVariables
+	// Shard limits the reconciler to the namespaces of this shard.
+	Shard Shard
+}
+
+// Reconcile creates, updates or deletes the Dashboard of the Namespace.
+func (r *NamespaceDashboardReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("namespace", req.Name)
+
+	var namespace corev1.Namespace
+	if err := r.Get(ctx, req.NamespacedName, &namespace); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if namespace.DeletionTimestamp != nil {
+		// the dashboard is deleted with the namespace
+		return ctrl.Result{}, nil
+	}
+	dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: namespace.Name, Name: namespaceDashboardName}}
+	data := TemplateData{RenderVariables: r.Variables, Namespace: namespace.Name}
+	if err := applyGeneratedDashboard(ctx, r.Client, r.Scheme, &namespace, dashboard, "namespace", defaultNamespaceTemplate, data, log); err != nil {
+		log.Error(err, "unable to apply namespace dashboard")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// namespacesForTemplates maps the templates ConfigMap to the namespaces with
+// a generated dashboard.
+func (r *NamespaceDashboardReconciler) namespacesForTemplates(obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != instanaConfigNamespace || obj.GetName() != dashboardTemplatesName {
+		return nil
+	}
+	var namespaces corev1.NamespaceList
+	if err := r.List(context.Background(), &namespaces); err != nil {
+		r.Log.Error(err, "unable to list namespaces")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, namespace := range namespaces.Items {
+		if namespace.Annotations[customv1.GenerateDashboardAnnotation] == "true" && r.Shard.Owns(namespace.Name) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Name: namespace.Name}})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NamespaceDashboardReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("namespacedashboard").
+		For(&corev1.Namespace{}, builder.WithPredicates(
+			// namespaces are sharded by their own name
+			predicate.NewPredicateFuncs(func(obj client.Object) bool { return r.Shard.Owns(obj.GetName()) }),
+			predicate.AnnotationChangedPredicate{},
+		)).
+		// Restore generated Dashboards which were edited or deleted by hand
+		Owns(&customv1.Dashboard{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.namespacesForTemplates)).
+		Complete(r)
+}
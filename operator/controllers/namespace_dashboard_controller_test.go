@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestNamespaceDashboardReconciler(t *testing.T) {
+	ctx := context.Background()
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team-a",
+		UID:         "ns-1",
+		Annotations: map[string]string{customv1.GenerateDashboardAnnotation: "true"},
+	}}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	r := &NamespaceDashboardReconciler{
+		Client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespace).Build(),
+		Log:       ctrl.Log.WithName("test"),
+		Scheme:    scheme,
+		Variables: RenderVariables{ClusterName: "prod", Zone: "eu", Vars: map[string]string{"team": "A"}},
+	}
+	reconcile := func() {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(namespace)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := client.ObjectKey{Namespace: "team-a", Name: namespaceDashboardName}
+
+	reconcile()
+	var dashboard customv1.Dashboard
+	if err := r.Get(ctx, key, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Title   string
+		Widgets []map[string]interface{}
+	}
+	if err := json.Unmarshal(dashboard.Spec.Config.Raw, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Title != "Namespace team-a (prod)" || len(config.Widgets) != 3 {
+		t.Errorf("config = %s", dashboard.Spec.Config.Raw)
+	}
+	if !metav1.IsControlledBy(&dashboard, namespace) || dashboard.Labels[customv1.GeneratorLabel] != "namespace" {
+		t.Errorf("dashboard should be owned by the namespace and labelled, got %+v", dashboard.ObjectMeta)
+	}
+
+	// templates of the config map take precedence
+	if err := r.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: dashboardTemplatesName},
+		Data:       map[string]string{"small": "title: {{ json (printf \"%s %s %s %s\" .Namespace .ClusterName .Zone .Vars.team) }}\nwidgets: []\n"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	namespace.Annotations[customv1.DashboardTemplateAnnotation] = "small"
+	if err := r.Update(ctx, namespace); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if err := r.Get(ctx, key, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	if string(dashboard.Spec.Config.Raw) != `{"title":"team-a prod eu A","widgets":[]}` {
+		t.Errorf("config = %s", dashboard.Spec.Config.Raw)
+	}
+
+	// the dashboard is deleted with the annotation
+	delete(namespace.Annotations, customv1.GenerateDashboardAnnotation)
+	if err := r.Update(ctx, namespace); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if err := r.Get(ctx, key, &dashboard); !apierrors.IsNotFound(err) {
+		t.Errorf("dashboard should have been deleted, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+const (
+	NotificationFormatJSON  = "json"
+	NotificationFormatSlack = "slack"
+
+	defaultNotificationInterval = time.Minute
+	// slackDashboardLimit is the number of dashboards listed in a Slack message.
+	slackDashboardLimit = 10
+)
+
+// Notifier posts a notification to a Slack incoming webhook or a generic
+// webhook when Dashboards become Degraded or their sync failed
+// FailureThreshold times in a row, so broken dashboards don't go unnoticed.
+// Every transition is notified once. The notifications are collected per
+// tenant and sent at most once per Interval and tenant, so an outage of a
+// tenant is one notification instead of one per Dashboard.
+type Notifier struct {
+	URL string
+	// Format of the request body, NotificationFormatSlack or NotificationFormatJSON (default).
+	Format string
+	// FailureThreshold is the number of consecutive sync failures which are
+	// notified. 0 only notifies Degraded Dashboards.
+	FailureThreshold int32
+	ClusterName      string
+	// Interval in which the collected notifications are sent, 1m if 0.
+	Interval time.Duration
+	Log      logr.Logger
+	// HttpClient defaults to a client with a timeout of 10s if nil.
+	HttpClient *http.Client
+
+	mu      sync.Mutex
+	pending map[string][]DashboardNotification
+}
+
+// Notification is the request body of the json format.
+type Notification struct {
+	Cluster    string                  `json:"cluster,omitempty"`
+	Tenant     string                  `json:"tenant"`
+	Dashboards []DashboardNotification `json:"dashboards"`
+	Time       time.Time               `json:"time"`
+}
+
+// DashboardNotification is a Dashboard of a Notification.
+type DashboardNotification struct {
+	Reason       string `json:"reason"`
+	Message      string `json:"message"`
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	DashboardId  string `json:"dashboard-id,omitempty"`
+	DashboardUrl string `json:"dashboard-url,omitempty"`
+	SyncAttempts int32  `json:"sync-attempts"`
+}
+
+// notify collects a notification for the tenant if the sync made the
+// dashboard Degraded or reached the failure threshold. wasDegraded is the
+// Degraded condition before the sync.
+func (n *Notifier) notify(dashboard *customv1.Dashboard, wasDegraded bool, tenant string, log logr.Logger) {
+	if n == nil || n.URL == "" {
+		return
+	}
+	notification := DashboardNotification{
+		Namespace:    dashboard.Namespace,
+		Name:         dashboard.Name,
+		DashboardId:  dashboard.Status.DashboardId,
+		DashboardUrl: dashboard.Status.DashboardUrl,
+		SyncAttempts: dashboard.Status.SyncAttempts,
+	}
+	degraded := meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionDegraded)
+	switch {
+	case degraded != nil && degraded.Status == metav1.ConditionTrue && !wasDegraded:
+		notification.Reason = customv1.ConditionDegraded
+		notification.Message = degraded.Message
+	case n.FailureThreshold > 0 && dashboard.Status.SyncAttempts == n.FailureThreshold:
+		notification.Reason = "SyncFailing"
+		notification.Message = fmt.Sprintf("%d consecutive syncs failed: %s", dashboard.Status.SyncAttempts, dashboard.Status.LastError)
+	default:
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.pending == nil {
+		n.pending = map[string][]DashboardNotification{}
+	}
+	pending := n.pending[tenant]
+	for i, p := range pending {
+		if p.Namespace == notification.Namespace && p.Name == notification.Name {
+			pending = append(pending[:i], pending[i+1:]...)
+			break
+		}
+	}
+	n.pending[tenant] = append(pending, notification)
+	log.Info("Queued failure notification", "reason", notification.Reason)
+}
+
+// Start sends the collected notifications every Interval until the context
+// is cancelled, and the remaining ones when it is.
+func (n *Notifier) Start(ctx context.Context) error {
+	interval := n.Interval
+	if interval <= 0 {
+		interval = defaultNotificationInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.flush()
+			return nil
+		case <-ticker.C:
+			n.flush()
+		}
+	}
+}
+
+// NeedLeaderElection makes the notifier run with the reconcilers queueing
+// the notifications.
+func (n *Notifier) NeedLeaderElection() bool {
+	return true
+}
+
+// flush sends one notification per tenant with the collected Dashboards.
+// Failed notifications are logged and not retried.
+func (n *Notifier) flush() {
+	n.mu.Lock()
+	pending := n.pending
+	n.pending = nil
+	n.mu.Unlock()
+	tenants := make([]string, 0, len(pending))
+	for tenant := range pending {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		notification := Notification{
+			Cluster:    n.ClusterName,
+			Tenant:     tenant,
+			Dashboards: pending[tenant],
+			Time:       time.Now().UTC(),
+		}
+		if err := n.post(notification); err != nil {
+			n.Log.Error(err, "unable to send failure notification", "tenant", tenant, "dashboards", len(notification.Dashboards))
+			continue
+		}
+		n.Log.Info("Sent failure notification", "tenant", tenant, "dashboards", len(notification.Dashboards))
+	}
+}
+
+func (n *Notifier) post(notification Notification) error {
+	var body interface{} = notification
+	if n.Format == NotificationFormatSlack {
+		body = map[string]string{"text": slackText(notification)}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	httpClient := n.HttpClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := httpClient.Post(n.URL, "application/json", bytes.NewReader(data))
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// the url of a Slack webhook is a secret
+		return fmt.Errorf("notification failed: %w", urlErr.Err)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification failed with status %s", resp.Status)
+	}
+	return nil
+}
+
+func slackText(notification Notification) string {
+	location := notification.Tenant
+	if notification.Cluster != "" {
+		location = "cluster " + notification.Cluster + ", tenant " + location
+	}
+	lines := []string{fmt.Sprintf(":rotating_light: %d dashboards of %s need attention:", len(notification.Dashboards), location)}
+	if len(notification.Dashboards) == 1 {
+		lines[0] = fmt.Sprintf(":rotating_light: 1 dashboard of %s needs attention:", location)
+	}
+	for i, dashboard := range notification.Dashboards {
+		if i == slackDashboardLimit {
+			lines = append(lines, fmt.Sprintf("• and %d more", len(notification.Dashboards)-i))
+			break
+		}
+		line := fmt.Sprintf("• *%s/%s* is %s: %s", dashboard.Namespace, dashboard.Name, slackReason(dashboard.Reason), dashboard.Message)
+		if dashboard.DashboardUrl != "" {
+			line += fmt.Sprintf(" <%s|Open in Instana>", dashboard.DashboardUrl)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func slackReason(reason string) string {
+	if reason == customv1.ConditionDegraded {
+		return "degraded"
+	}
+	return "failing to sync"
+}
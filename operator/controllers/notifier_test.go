@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestNotifier(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+	log := ctrl.Log.WithName("test")
+	notifier := &Notifier{URL: server.URL, FailureThreshold: 3, ClusterName: "prod", Log: log}
+	dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "overview"}}
+
+	// every failure up to the threshold, the threshold is notified once
+	for i := 0; i < 4; i++ {
+		setSyncAttempt(dashboard, errors.New("PUT /api/custom-dashboard/1 failed with status 400 Bad Request"))
+		setDegradedStatus(dashboard, errors.New("PUT failed"))
+		notifier.notify(dashboard, false, "https://a.instana.io", log)
+	}
+	if len(bodies) != 0 {
+		t.Fatalf("notifications = %v, want none before the interval", bodies)
+	}
+	notifier.flush()
+	if len(bodies) != 1 {
+		t.Fatalf("notifications = %v, want one at the threshold", bodies)
+	}
+	var notification Notification
+	if err := json.Unmarshal([]byte(bodies[0]), &notification); err != nil {
+		t.Fatal(err)
+	}
+	if notification.Cluster != "prod" || notification.Tenant != "https://a.instana.io" || len(notification.Dashboards) != 1 {
+		t.Fatalf("notification = %+v", notification)
+	}
+	if d := notification.Dashboards[0]; d.Reason != "SyncFailing" || d.SyncAttempts != 3 || d.Name != "overview" {
+		t.Errorf("notification of the dashboard = %+v", d)
+	}
+
+	// becoming Degraded is notified once, in the Slack format
+	notifier.Format = NotificationFormatSlack
+	bodies = nil
+	setDegradedStatus(dashboard, ErrCircuitOpen)
+	notifier.notify(dashboard, false, "https://a.instana.io", log)
+	notifier.notify(dashboard, true, "https://a.instana.io", log)
+	notifier.flush()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], `*team-a/overview* is degraded`) || !strings.Contains(bodies[0], "cluster prod, tenant https://a.instana.io") {
+		t.Errorf("notifications = %v, want one Slack message", bodies)
+	}
+}
+
+func TestNotifierAggregatesTenants(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+	log := ctrl.Log.WithName("test")
+	notifier := &Notifier{URL: server.URL, Log: log}
+
+	// an outage of a tenant makes all its dashboards Degraded at once
+	for i := 0; i < 20; i++ {
+		dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: fmt.Sprintf("d%d", i)}}
+		setDegradedStatus(dashboard, ErrCircuitOpen)
+		notifier.notify(dashboard, false, "https://a.instana.io", log)
+	}
+	other := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "overview"}}
+	setDegradedStatus(other, ErrCircuitOpen)
+	notifier.notify(other, false, "https://b.instana.io", log)
+	notifier.flush()
+	if len(bodies) != 2 {
+		t.Fatalf("notifications = %v, want one per tenant", bodies)
+	}
+	var notification Notification
+	if err := json.Unmarshal([]byte(bodies[0]), &notification); err != nil {
+		t.Fatal(err)
+	}
+	if notification.Tenant != "https://a.instana.io" || len(notification.Dashboards) != 20 {
+		t.Errorf("notification of tenant %s has %d dashboards, want 20", notification.Tenant, len(notification.Dashboards))
+	}
+
+	// nothing new, nothing sent
+	notifier.flush()
+	if len(bodies) != 2 {
+		t.Errorf("notifications = %v, want none without new failures", bodies[2:])
+	}
+
+	notification.Cluster = "prod"
+	if text := slackText(notification); !strings.Contains(text, "20 dashboards of cluster prod") || !strings.Contains(text, "and 10 more") {
+		t.Errorf("Slack text = %s", text)
+	}
+}
+
+func TestNotifierHidesUrl(t *testing.T) {
+	notifier := &Notifier{URL: "http://127.0.0.1:1/services/T000/B000/secret-hook"}
+	err := notifier.post(Notification{})
+	if err == nil || strings.Contains(err.Error(), "secret-hook") {
+		t.Errorf("post() = %v, want an error without the url", err)
+	}
+}
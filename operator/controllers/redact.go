@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+const redacted = "[REDACTED]"
+
+var redactPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// authorization header values like "apiToken <token>"
+	{regexp.MustCompile(`(?i)\b(apiToken|Bearer|Basic)\s+[A-Za-z0-9._~+/=-]+`), "$1 " + redacted},
+	// key value pairs like "api-token: <token>" or "accessToken":"<token>"
+	{regexp.MustCompile(`(?i)((?:token|password|secret)["']?\s*[:=]\s*["']?)[^\s"'&,}\]]+`), "${1}" + redacted},
+	// credentials in urls
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "${1}" + redacted + "@"},
+}
+
+// knownSecrets are the API tokens of the loaded tenant configs. They are
+// redacted wherever they show up, not only where they look like a token.
+var knownSecrets = &secretSet{values: map[string]struct{}{}}
+
+type secretSet struct {
+	mu     sync.RWMutex
+	values map[string]struct{}
+}
+
+func (s *secretSet) add(secret string) {
+	// short values would redact unrelated text
+	if len(secret) < 8 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[secret] = struct{}{}
+}
+
+func (s *secretSet) redact(text string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for secret := range s.values {
+		text = strings.ReplaceAll(text, secret, redacted)
+	}
+	return text
+}
+
+// Redact replaces API tokens, authorization headers and other token-like
+// strings in a log message, event or status message.
+func Redact(text string) string {
+	text = knownSecrets.redact(text)
+	for _, p := range redactPatterns {
+		text = p.pattern.ReplaceAllString(text, p.replacement)
+	}
+	return text
+}
+
+// redactError returns the redacted message of an error.
+func redactError(err error) string {
+	return Redact(err.Error())
+}
+
+// redactHeader returns a copy of the header without credentials.
+func redactHeader(header http.Header) http.Header {
+	clean := header.Clone()
+	for _, name := range []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Token", "X-Vault-Token"} {
+		if clean.Get(name) != "" {
+			clean.Set(name, redacted)
+		}
+	}
+	return clean
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return Redact(v)
+	case []byte:
+		return Redact(string(v))
+	case http.Header:
+		return redactHeader(v)
+	case error:
+		return errors.New(redactError(v))
+	case fmt.Stringer:
+		return Redact(v.String())
+	}
+	return value
+}
+
+func redactValues(keysAndValues []interface{}) []interface{} {
+	clean := make([]interface{}, len(keysAndValues))
+	for i, value := range keysAndValues {
+		clean[i] = redactValue(value)
+	}
+	return clean
+}
+
+// redactingLogger redacts the messages, errors and values of a logr.Logger.
+type redactingLogger struct {
+	logger logr.Logger
+}
+
+// RedactingLogger returns a logger which redacts tokens before passing the
+// log entries to logger.
+func RedactingLogger(logger logr.Logger) logr.Logger {
+	return redactingLogger{logger: logger}
+}
+
+func (l redactingLogger) Enabled() bool {
+	return l.logger.Enabled()
+}
+
+func (l redactingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Info(Redact(msg), redactValues(keysAndValues)...)
+}
+
+func (l redactingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	if err != nil {
+		err = errors.New(redactError(err))
+	}
+	l.logger.Error(err, Redact(msg), redactValues(keysAndValues)...)
+}
+
+func (l redactingLogger) V(level int) logr.Logger {
+	return redactingLogger{logger: l.logger.V(level)}
+}
+
+func (l redactingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return redactingLogger{logger: l.logger.WithValues(redactValues(keysAndValues)...)}
+}
+
+func (l redactingLogger) WithName(name string) logr.Logger {
+	return redactingLogger{logger: l.logger.WithName(name)}
+}
+
+// redactingRecorder redacts the messages of the events of a recorder.
+type redactingRecorder struct {
+	recorder record.EventRecorder
+}
+
+// RedactingRecorder returns an event recorder which redacts tokens from the
+// event messages before recording them with recorder.
+func RedactingRecorder(recorder record.EventRecorder) record.EventRecorder {
+	return redactingRecorder{recorder: recorder}
+}
+
+func (r redactingRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.recorder.Event(object, eventtype, reason, Redact(message))
+}
+
+func (r redactingRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.recorder.Event(object, eventtype, reason, Redact(fmt.Sprintf(messageFmt, args...)))
+}
+
+func (r redactingRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", Redact(fmt.Sprintf(messageFmt, args...)))
+}
+
+// redactingStatusWriter redacts the status of Dashboards before writing it.
+type redactingStatusWriter struct {
+	client.StatusWriter
+}
+
+func (w redactingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if dashboard, ok := obj.(*customv1.Dashboard); ok {
+		redactStatus(dashboard)
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w redactingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if dashboard, ok := obj.(*customv1.Dashboard); ok {
+		redactStatus(dashboard)
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
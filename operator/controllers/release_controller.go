@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=releases,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=releases/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=releases/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+
+const (
+	// deploymentRevisionAnnotation is maintained by the Deployment controller
+	// and incremented on every rollout.
+	deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+	// releaseHistory is the number of Releases kept for a Deployment.
+	releaseHistory = 10
+)
+
+// releaseKind syncs Releases with the release markers of the Releases API.
+var releaseKind = InstanaResourceKind{
+	Kind: "Release",
+	New:  func() InstanaResource { return &customv1.Release{} },
+	Path: "/api/releases",
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		release := obj.(*customv1.Release)
+		start := release.CreationTimestamp.Time
+		if release.Spec.Start != nil {
+			start = release.Spec.Start.Time
+		}
+		applications := []interface{}{}
+		for _, name := range release.Spec.Applications {
+			applications = append(applications, map[string]interface{}{"name": name})
+		}
+		services := []interface{}{}
+		for _, name := range release.Spec.Services {
+			service := map[string]interface{}{"name": name}
+			if len(applications) > 0 {
+				service["scopedTo"] = map[string]interface{}{"applications": applications}
+			}
+			services = append(services, service)
+		}
+		return map[string]interface{}{
+			"name":         release.Spec.Name,
+			"start":        start.UnixNano() / int64(time.Millisecond),
+			"applications": applications,
+			"services":     services,
+		}, nil
+	},
+}
+
+// DeploymentReleaseReconciler creates a Release for every rollout of the
+// Deployments annotated with custom.instana.io/release-marker: "true". The
+// Releases are owned by the Deployment and keep their markers in Instana when
+// they are deleted. The last 10 Releases of a Deployment are kept.
+type DeploymentReleaseReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Shard limits the reconciler to the deployments in namespaces of this shard.
+	Shard Shard
+}
+
+// Reconcile creates the Release of the current revision of the Deployment.
+func (r *DeploymentReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("deployment", req.NamespacedName)
+
+	var deployment appsv1.Deployment
+	if err := r.Get(ctx, req.NamespacedName, &deployment); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	revision := deployment.Annotations[deploymentRevisionAnnotation]
+	if deployment.Annotations[customv1.ReleaseMarkerAnnotation] != "true" || revision == "" {
+		return ctrl.Result{}, nil
+	}
+
+	release := &customv1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: deployment.Namespace,
+			Name:      deployment.Name + "-" + revision,
+			Labels:    map[string]string{customv1.DeploymentLabel: deployment.Name},
+			Annotations: map[string]string{
+				customv1.SkipRemoteDeleteAnnotation: "true",
+				deploymentRevisionAnnotation:        revision,
+			},
+		},
+		Spec: customv1.ReleaseSpec{
+			Name:         deployment.Name + " " + releaseVersion(&deployment),
+			Applications: splitList(deployment.Annotations[customv1.ReleaseApplicationsAnnotation]),
+			Services:     splitList(deployment.Annotations[customv1.ReleaseServicesAnnotation]),
+		},
+	}
+	if len(release.Spec.Services) == 0 {
+		release.Spec.Services = []string{deployment.Name}
+	}
+	if err := controllerutil.SetControllerReference(&deployment, release, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+	switch err := r.Create(ctx, release); {
+	case apierrors.IsAlreadyExists(err):
+		return ctrl.Result{}, nil
+	case err != nil:
+		return ctrl.Result{}, err
+	}
+	log.Info("Created release for rollout", "release", release.Name, "revision", revision)
+	return ctrl.Result{}, r.prune(ctx, &deployment, log)
+}
+
+// prune deletes all but the latest Releases of the Deployment.
+func (r *DeploymentReleaseReconciler) prune(ctx context.Context, deployment *appsv1.Deployment, log logr.Logger) error {
+	var releases customv1.ReleaseList
+	if err := r.List(ctx, &releases, client.InNamespace(deployment.Namespace), client.MatchingLabels{customv1.DeploymentLabel: deployment.Name}); err != nil {
+		return err
+	}
+	owned := []customv1.Release{}
+	for _, release := range releases.Items {
+		if metav1.IsControlledBy(&release, deployment) {
+			owned = append(owned, release)
+		}
+	}
+	revision := func(release customv1.Release) int {
+		n, _ := strconv.Atoi(release.Annotations[deploymentRevisionAnnotation])
+		return n
+	}
+	sort.Slice(owned, func(i, j int) bool { return revision(owned[i]) > revision(owned[j]) })
+	for i := releaseHistory; i < len(owned); i++ {
+		log.Info("Deleting old release", "release", owned[i].Name)
+		if err := r.Delete(ctx, &owned[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseVersion returns the version annotation or the image tag of the
+// first container of the Deployment.
+func releaseVersion(deployment *appsv1.Deployment) string {
+	if version := deployment.Annotations[customv1.ReleaseVersionAnnotation]; version != "" {
+		return version
+	}
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return "revision " + deployment.Annotations[deploymentRevisionAnnotation]
+	}
+	image := containers[0].Image
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DeploymentReleaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("deploymentrelease").
+		For(&appsv1.Deployment{}, builder.WithPredicates(
+			r.Shard.Predicate(),
+			// The revision annotation changes on every rollout
+			predicate.AnnotationChangedPredicate{},
+		)).
+		Complete(r)
+}
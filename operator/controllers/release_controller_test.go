@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestDeploymentReleaseReconciler(t *testing.T) {
+	ctx := context.Background()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a",
+			Name:      "shop",
+			UID:       "d-1",
+			Annotations: map[string]string{
+				customv1.ReleaseMarkerAnnotation:       "true",
+				customv1.ReleaseApplicationsAnnotation: "Shop, Checkout",
+				deploymentRevisionAnnotation:           "1",
+			},
+		},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "shop", Image: "registry.example.com:5000/shop:1.4.2"}},
+		}}},
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	r := &DeploymentReleaseReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment).Build(),
+		Log:    ctrl.Log.WithName("test"),
+		Scheme: scheme,
+	}
+	reconcile := func() {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(deployment)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconcile()
+	var release customv1.Release
+	if err := r.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "shop-1"}, &release); err != nil {
+		t.Fatal(err)
+	}
+	if release.Spec.Name != "shop 1.4.2" || len(release.Spec.Applications) != 2 || release.Spec.Services[0] != "shop" {
+		t.Errorf("spec = %+v", release.Spec)
+	}
+	if !metav1.IsControlledBy(&release, deployment) || release.Annotations[customv1.SkipRemoteDeleteAnnotation] != "true" {
+		t.Errorf("release %s should be owned by the deployment and keep its marker", release.Name)
+	}
+
+	// only the latest releases are kept
+	for revision := 2; revision <= releaseHistory+2; revision++ {
+		deployment.Annotations[deploymentRevisionAnnotation] = fmt.Sprint(revision)
+		if err := r.Update(ctx, deployment); err != nil {
+			t.Fatal(err)
+		}
+		reconcile()
+	}
+	var releases customv1.ReleaseList
+	if err := r.List(ctx, &releases, client.InNamespace("team-a")); err != nil {
+		t.Fatal(err)
+	}
+	if len(releases.Items) != releaseHistory {
+		t.Errorf("got %d releases, want %d", len(releases.Items), releaseHistory)
+	}
+	for _, release := range releases.Items {
+		if release.Name == "shop-1" || release.Name == "shop-2" {
+			t.Errorf("release %s should have been pruned", release.Name)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Shard assigns namespaces to one of several operator replicas by the hash
+// of the namespace name. Each shard holds its own leader election lease, so
+// exactly one replica is active per shard.
+type Shard struct {
+	// Count is the number of shards. Sharding is disabled with less than two shards.
+	Count int
+	// Id is the ordinal of this shard, starting at 0.
+	Id int
+	// Namespaces restricts the operator to these namespaces. All namespaces
+	// if empty.
+	Namespaces []string
+	// DashboardSelector restricts the operator to the Dashboards with
+	// matching labels. All Dashboards if nil.
+	DashboardSelector labels.Selector
+}
+
+// NewShard returns the shard with the given id. A negative id is derived from
+// the ordinal suffix of the hostname, e.g. "operator-2" of a StatefulSet.
+func NewShard(count int, id int) (Shard, error) {
+	if count <= 1 {
+		return Shard{}, nil
+	}
+	if id < 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return Shard{}, err
+		}
+		if id, err = strconv.Atoi(hostname[strings.LastIndex(hostname, "-")+1:]); err != nil {
+			return Shard{}, fmt.Errorf("unable to derive shard id from hostname %s: %w", hostname, err)
+		}
+	}
+	if id >= count {
+		return Shard{}, fmt.Errorf("shard id %d is out of range for %d shards", id, count)
+	}
+	return Shard{Count: count, Id: id}, nil
+}
+
+// Enabled returns true if the namespaces are split across several shards.
+func (s Shard) Enabled() bool {
+	return s.Count > 1
+}
+
+// Owns returns true if the namespace belongs to this shard.
+func (s Shard) Owns(namespace string) bool {
+	if len(s.Namespaces) > 0 && !containsString(s.Namespaces, namespace) {
+		return false
+	}
+	if !s.Enabled() {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(namespace))
+	return int(h.Sum32()%uint32(s.Count)) == s.Id
+}
+
+// OwnsDashboard returns true if the Dashboard belongs to this shard.
+func (s Shard) OwnsDashboard(dashboard client.Object) bool {
+	if s.DashboardSelector != nil && !s.DashboardSelector.Matches(labels.Set(dashboard.GetLabels())) {
+		return false
+	}
+	return s.Owns(dashboard.GetNamespace())
+}
+
+// OwnsClusterDashboard returns true if the Dashboard of the ClusterDashboard,
+// which has its labels, belongs to this shard.
+func (s Shard) OwnsClusterDashboard(clusterDashboard client.Object) bool {
+	if s.DashboardSelector != nil && !s.DashboardSelector.Matches(labels.Set(clusterDashboard.GetLabels())) {
+		return false
+	}
+	return s.Owns(instanaConfigNamespace)
+}
+
+// DashboardPredicate filters events of Dashboards of other shards.
+func (s Shard) DashboardPredicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(s.OwnsDashboard)
+}
+
+// labelChangedPredicate passes updates which change the labels, e.g. moving a
+// Dashboard into the selector of the shard. The controller-runtime version
+// of the operator has no LabelChangedPredicate yet.
+var labelChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return !reflect.DeepEqual(e.ObjectNew.GetLabels(), e.ObjectOld.GetLabels())
+	},
+}
+
+// Predicate filters events of objects in namespaces of other shards.
+func (s Shard) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return s.Owns(obj.GetNamespace())
+	})
+}
+
+// CacheNamespaces returns the namespaces the cache of the manager has to
+// watch: the namespaces of the operator and the namespace of the tenant
+// config maps.
+func (s Shard) CacheNamespaces() []string {
+	if containsString(s.Namespaces, instanaConfigNamespace) {
+		return s.Namespaces
+	}
+	return append(append([]string{}, s.Namespaces...), instanaConfigNamespace)
+}
+
+// LeaderElectionID returns the lease name of this shard. Operators restricted
+// to different namespaces or Dashboards get different leases, so they can run
+// side by side.
+func (s Shard) LeaderElectionID(id string) string {
+	var partition []string
+	if len(s.Namespaces) > 0 {
+		partition = append(partition, strings.Join(s.Namespaces, ","))
+	}
+	if s.DashboardSelector != nil && !s.DashboardSelector.Empty() {
+		partition = append(partition, s.DashboardSelector.String())
+	}
+	if len(partition) > 0 {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(strings.Join(partition, "/")))
+		id = fmt.Sprintf("%08x-%s", h.Sum32(), id)
+	}
+	if !s.Enabled() {
+		return id
+	}
+	return fmt.Sprintf("shard-%d-%s", s.Id, id)
+}
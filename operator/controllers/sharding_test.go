@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestShardNamespaces(t *testing.T) {
+	shard := Shard{Namespaces: []string{"team-a", "team-b"}}
+	if !shard.Owns("team-a") || shard.Owns("team-c") || shard.Owns(instanaConfigNamespace) {
+		t.Error("shard should only own its namespaces")
+	}
+	if got := shard.CacheNamespaces(); !reflect.DeepEqual(got, []string{"team-a", "team-b", instanaConfigNamespace}) {
+		t.Errorf("cache namespaces = %v", got)
+	}
+	if !(Shard{}).Owns("team-c") {
+		t.Error("shard without namespaces should own all namespaces")
+	}
+}
+
+func TestShardDashboardSelector(t *testing.T) {
+	selector, err := labels.Parse("team=a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shard := Shard{DashboardSelector: selector}
+	own := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Labels: map[string]string{"team": "a"}}}
+	other := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Labels: map[string]string{"team": "b"}}}
+	if !shard.OwnsDashboard(own) || shard.OwnsDashboard(other) {
+		t.Error("shard should only own the dashboards matching its selector")
+	}
+	ownCluster := &customv1.ClusterDashboard{ObjectMeta: metav1.ObjectMeta{Name: "platform", Labels: map[string]string{"team": "a"}}}
+	otherCluster := &customv1.ClusterDashboard{ObjectMeta: metav1.ObjectMeta{Name: "billing", Labels: map[string]string{"team": "b"}}}
+	if !shard.OwnsClusterDashboard(ownCluster) || shard.OwnsClusterDashboard(otherCluster) {
+		t.Error("shard should only own the cluster dashboards matching its selector")
+	}
+	if (Shard{Namespaces: []string{"team-a"}}).OwnsClusterDashboard(ownCluster) {
+		t.Error("the dashboards of cluster dashboards belong to the shard of the tenant config namespace")
+	}
+	if id := shard.LeaderElectionID("lease"); id == "lease" || id == (Shard{}).LeaderElectionID("lease") {
+		t.Errorf("shards with a selector need their own lease, got %s", id)
+	}
+	// relabelling a dashboard into the selector has to reach the reconciler
+	if !labelChangedPredicate.Update(event.UpdateEvent{ObjectOld: other, ObjectNew: own}) ||
+		labelChangedPredicate.Update(event.UpdateEvent{ObjectOld: own, ObjectNew: own.DeepCopy()}) {
+		t.Error("labelChangedPredicate should only pass label changes")
+	}
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=sliconfigs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=sliconfigs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=sliconfigs/finalizers,verbs=update
+
+// sliConfigKind syncs SLIConfigs with the SLI settings API. SLIs can not be
+// updated in Instana, so changed SLIs are replaced.
+var sliConfigKind = InstanaResourceKind{
+	Kind:      "SLIConfig",
+	New:       func() InstanaResource { return &customv1.SLIConfig{} },
+	Path:      "/api/settings/v2/sli",
+	Immutable: true,
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		sli := obj.(*customv1.SLIConfig)
+		spec := sli.Spec
+		applicationId, err := applicationPerspectiveId(ctx, c, sli.Namespace, spec.ApplicationPerspectiveRef, spec.ApplicationId)
+		if err != nil {
+			return nil, err
+		}
+		entity := map[string]interface{}{
+			"sliType":       "application",
+			"applicationId": applicationId,
+			"boundaryScope": spec.BoundaryScope,
+		}
+		if spec.ServiceId != "" {
+			entity["serviceId"] = spec.ServiceId
+		}
+		if spec.EndpointId != "" {
+			entity["endpointId"] = spec.EndpointId
+		}
+		return map[string]interface{}{
+			"name": spec.Name,
+			"metricConfiguration": map[string]interface{}{
+				"metricName":        spec.MetricName,
+				"metricAggregation": spec.Aggregation,
+				"threshold":         float64(spec.Threshold.MilliValue()) / 1000,
+			},
+			"sliEntity": entity,
+		}, nil
+	},
+}
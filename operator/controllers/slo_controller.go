@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=slos,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=slos/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=slos/finalizers,verbs=update
+
+// sloReportPath is the endpoint of the compliance reports of SLOs.
+const sloReportPath = "/api/slo/reports"
+
+// sloKind syncs SLOs with the SLO settings API and reads their reports into
+// the status.
+var sloKind = InstanaResourceKind{
+	Kind: "SLO",
+	New:  func() InstanaResource { return &customv1.SLO{} },
+	Path: "/api/settings/slo",
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		slo := obj.(*customv1.SLO)
+		spec := slo.Spec
+		sliId := spec.SLIId
+		if spec.SLIConfigRef != "" {
+			id, err := instanaIdOf(ctx, c, slo.Namespace, spec.SLIConfigRef, &customv1.SLIConfig{})
+			if err != nil {
+				return nil, err
+			}
+			sliId = id
+		}
+		if sliId == "" {
+			return nil, stalledError{fmt.Errorf("either sli-config-ref or sli-id is required")}
+		}
+		return map[string]interface{}{
+			"name":   spec.Name,
+			"sliId":  sliId,
+			"target": float64(spec.Target.MilliValue()) / 100000,
+			"timeWindow": map[string]interface{}{
+				"type":         "rolling",
+				"duration":     int64(spec.TimeWindow.Hours()),
+				"durationUnit": "hour",
+			},
+		}, nil
+	},
+	Report: reportSLO,
+}
+
+// reportSLO reads the compliance and the remaining error budget of the time
+// window into the status, every spec.report-interval.
+func reportSLO(ctx context.Context, obj InstanaResource, instanaClient InstanaClient, log logr.Logger) (time.Duration, error) {
+	slo := obj.(*customv1.SLO)
+	if slo.Spec.ReportInterval == nil || slo.Spec.ReportInterval.Duration <= 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	path := fmt.Sprintf("%s/%s?from=%d&to=%d", sloReportPath, slo.Status.Id,
+		now.Add(-slo.Spec.TimeWindow.Duration).UnixNano()/int64(time.Millisecond), now.UnixNano()/int64(time.Millisecond))
+	body, err := instanaClient.do(http.MethodGet, path, nil, log)
+	if err != nil {
+		return slo.Spec.ReportInterval.Duration, err
+	}
+	var report struct {
+		Sli                  float64 `json:"sli"`
+		TotalErrorBudget     float64 `json:"totalErrorBudget"`
+		ErrorBudgetRemaining float64 `json:"errorBudgetRemaining"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		return slo.Spec.ReportInterval.Duration, fmt.Errorf("unable to parse SLO report: %w", err)
+	}
+	slo.Status.Compliance = percentage(report.Sli)
+	slo.Status.ErrorBudgetRemaining = ""
+	if report.TotalErrorBudget > 0 {
+		slo.Status.ErrorBudgetRemaining = percentage(report.ErrorBudgetRemaining / report.TotalErrorBudget)
+	}
+	reported := metav1.NewTime(now)
+	slo.Status.Reported = &reported
+	return slo.Spec.ReportInterval.Duration, nil
+}
+
+// percentage formats a ratio as a percentage, e.g. "99.95%".
+func percentage(ratio float64) string {
+	return strconv.FormatFloat(ratio*100, 'f', 2, 64) + "%"
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// SyncRequest is the body of a request to the sync receiver. Dashboards are
+// given by their namespace and name or by the id of their Instana dashboard.
+type SyncRequest struct {
+	Dashboards   []SyncRequestDashboard `json:"dashboards,omitempty"`
+	DashboardIds []string               `json:"dashboard-ids,omitempty"`
+}
+
+// SyncRequestDashboard is a Dashboard of a SyncRequest.
+type SyncRequestDashboard struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// SyncResponse lists the Dashboards a sync was requested for and the
+// requested dashboards which don't exist.
+type SyncResponse struct {
+	Requested []string `json:"requested"`
+	NotFound  []string `json:"not-found,omitempty"`
+}
+
+// SyncReceiver serves an endpoint which external automation or an Instana
+// alert channel calls to sync Dashboards right away instead of waiting for
+// the next resync, e.g. after a dashboard was changed in Instana. Requests
+// must carry the shared secret as bearer token or X-Webhook-Secret header.
+// The sync is requested by the custom.instana.io/sync-requested annotation,
+// so the replica managing the Dashboard picks it up.
+type SyncReceiver struct {
+	client.Client
+	Log         logr.Logger
+	BindAddress string
+	Secret      string
+}
+
+// Start serves the receiver until the context is cancelled.
+func (s *SyncReceiver) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/sync", s)
+	server := &http.Server{Addr: s.BindAddress, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	s.Log.Info("Starting sync receiver", "address", s.BindAddress)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection lets every replica receive requests.
+func (s *SyncReceiver) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *SyncReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(req) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var request SyncRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 1<<20)).Decode(&request); err != nil {
+		http.Error(w, "invalid sync request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := s.requestSync(req.Context(), request)
+	if err != nil {
+		s.Log.Error(err, "unable to request dashboard sync")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.Log.Info("Requested dashboard sync", "dashboards", response.Requested, "notFound", response.NotFound)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s *SyncReceiver) authorized(req *http.Request) bool {
+	secret := req.Header.Get("X-Webhook-Secret")
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		secret = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return s.Secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(s.Secret)) == 1
+}
+
+// requestSync annotates the requested Dashboards.
+func (s *SyncReceiver) requestSync(ctx context.Context, request SyncRequest) (SyncResponse, error) {
+	response := SyncResponse{Requested: []string{}}
+	keys := []client.ObjectKey{}
+	for _, d := range request.Dashboards {
+		keys = append(keys, client.ObjectKey{Namespace: d.Namespace, Name: d.Name})
+	}
+	if len(request.DashboardIds) > 0 {
+		var dashboards customv1.DashboardList
+		if err := s.List(ctx, &dashboards); err != nil {
+			return response, err
+		}
+		for _, id := range request.DashboardIds {
+			found := false
+			for i := range dashboards.Items {
+				status := dashboards.Items[i].Status
+				if id == status.DashboardId || id == status.MirrorDashboardId || containsClusterDashboard(status.Clusters, id) || containsVariantDashboard(status.Variants, id) {
+					keys = append(keys, client.ObjectKeyFromObject(&dashboards.Items[i]))
+					found = true
+				}
+			}
+			if !found {
+				response.NotFound = append(response.NotFound, id)
+			}
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, key := range keys {
+		var dashboard customv1.Dashboard
+		if err := s.Get(ctx, key, &dashboard); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return response, err
+			}
+			response.NotFound = append(response.NotFound, key.String())
+			continue
+		}
+		patch := client.MergeFrom(dashboard.DeepCopy())
+		if dashboard.Annotations == nil {
+			dashboard.Annotations = map[string]string{}
+		}
+		dashboard.Annotations[customv1.SyncRequestedAnnotation] = now
+		if err := s.Patch(ctx, &dashboard, patch); err != nil {
+			return response, err
+		}
+		response.Requested = append(response.Requested, key.String())
+	}
+	return response, nil
+}
+
+func containsClusterDashboard(clusters []customv1.ClusterDashboardStatus, id string) bool {
+	for _, c := range clusters {
+		if c.DashboardId == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsVariantDashboard(variants []customv1.VariantDashboardStatus, id string) bool {
+	for _, v := range variants {
+		if v.DashboardId == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestSyncReceiver(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "overview"}},
+		&customv1.Dashboard{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "checkout"},
+			Status:     customv1.DashboardStatus{DashboardId: "abc"},
+		},
+	).Build()
+	receiver := &SyncReceiver{Client: c, Log: ctrl.Log.WithName("test"), Secret: "shared-secret"}
+
+	send := func(secret string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+secret)
+		w := httptest.NewRecorder()
+		receiver.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("wrong", `{"dashboard-ids":["abc"]}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d for a wrong secret", w.Code)
+	}
+	w := send("shared-secret", `{"dashboards":[{"namespace":"team-a","name":"overview"},{"namespace":"team-a","name":"missing"}],"dashboard-ids":["abc","unknown"]}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var response SyncResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(response.Requested, ",") != "team-a/overview,team-b/checkout" || strings.Join(response.NotFound, ",") != "unknown,team-a/missing" {
+		t.Errorf("response = %+v", response)
+	}
+	for _, key := range []client.ObjectKey{{Namespace: "team-a", Name: "overview"}, {Namespace: "team-b", Name: "checkout"}} {
+		var dashboard customv1.Dashboard
+		if err := c.Get(context.Background(), key, &dashboard); err != nil {
+			t.Fatal(err)
+		}
+		if dashboard.Annotations[customv1.SyncRequestedAnnotation] == "" {
+			t.Errorf("%s is not annotated", key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=synthetictests,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=synthetictests/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=synthetictests/finalizers,verbs=update
+
+// syntheticTestKind syncs SyntheticTests with the tests of the Synthetic
+// monitoring settings API.
+var syntheticTestKind = InstanaResourceKind{
+	Kind: "SyntheticTest",
+	New:  func() InstanaResource { return &customv1.SyntheticTest{} },
+	Path: "/api/synthetics/settings/tests",
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		spec := obj.(*customv1.SyntheticTest).Spec
+		var configuration map[string]interface{}
+		switch {
+		case spec.HTTP != nil && spec.Script != "":
+			return nil, stalledError{fmt.Errorf("only one of http and script may be set")}
+		case spec.HTTP != nil:
+			configuration = map[string]interface{}{
+				"syntheticType": "HTTPAction",
+				"url":           spec.HTTP.URL,
+				"operation":     spec.HTTP.Method,
+			}
+			if len(spec.HTTP.Headers) > 0 {
+				configuration["headers"] = spec.HTTP.Headers
+			}
+			if spec.HTTP.Body != "" {
+				configuration["body"] = spec.HTTP.Body
+			}
+			if spec.HTTP.ExpectStatus != 0 {
+				configuration["expectStatus"] = spec.HTTP.ExpectStatus
+			}
+			if spec.HTTP.ValidationString != "" {
+				configuration["validationString"] = spec.HTTP.ValidationString
+			}
+			if spec.HTTP.Timeout != nil {
+				configuration["timeout"] = fmt.Sprintf("%dms", spec.HTTP.Timeout.Milliseconds())
+			}
+		case spec.Script != "":
+			configuration = map[string]interface{}{
+				"syntheticType": "HTTPScript",
+				"script":        spec.Script,
+			}
+		default:
+			return nil, stalledError{fmt.Errorf("either http or script is required")}
+		}
+		customProperties := spec.CustomProperties
+		if customProperties == nil {
+			customProperties = map[string]string{}
+		}
+		return map[string]interface{}{
+			"label":            spec.Label,
+			"description":      spec.Description,
+			"active":           !spec.Paused,
+			"locations":        spec.Locations,
+			"testFrequency":    int64(spec.Frequency.Duration / time.Minute),
+			"playbackMode":     "Simultaneous",
+			"configuration":    configuration,
+			"customProperties": customProperties,
+		}, nil
+	},
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+var (
+	tenantDashboardsDesc = prometheus.NewDesc(
+		"instana_dashboards_managed",
+		"The number of dashboards the operator manages in the Instana tenant.",
+		[]string{"tenant"}, nil)
+	tenantDashboardsFailingDesc = prometheus.NewDesc(
+		"instana_dashboards_failing",
+		"The number of dashboards of the Instana tenant whose last sync failed.",
+		[]string{"tenant"}, nil)
+	tenantLastSuccessDesc = prometheus.NewDesc(
+		"instana_dashboards_last_successful_sync_timestamp_seconds",
+		"The time of the latest successful sync of a dashboard of the Instana tenant.",
+		[]string{"tenant"}, nil)
+)
+
+// TenantMetrics exports the number of managed and failing dashboards and the
+// time of the latest successful sync per Instana tenant, computed from the
+// status of the Dashboards on every scrape. Tenants are identified by the
+// name of their config map, like the backup metrics, and tenants of
+// --namespace-credentials by "<namespace>/<secret>". Each Instana dashboard
+// counts, i.e. every cluster of a multi-cluster Dashboard and its mirror.
+type TenantMetrics struct {
+	Client client.Reader
+	Log    logr.Logger
+	// Shard limits the metrics to dashboards of namespaces of this shard, so
+	// the metrics of all replicas add up.
+	Shard Shard
+	// Elected is closed once the replica leads its shard. Only the leader
+	// exports the metrics, so standby replicas don't duplicate them. The
+	// metrics are always exported if nil.
+	Elected <-chan struct{}
+	// NamespaceCredentials is set if Dashboards sync with the credentials
+	// Secret of their namespace.
+	NamespaceCredentials bool
+	// DryRun is set if no Dashboard is synced with Instana, as with --dry-run.
+	DryRun bool
+}
+
+type tenantCounts struct {
+	managed, failing float64
+	lastSuccess      time.Time
+}
+
+// Describe implements prometheus.Collector.
+func (m *TenantMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tenantDashboardsDesc
+	ch <- tenantDashboardsFailingDesc
+	ch <- tenantLastSuccessDesc
+}
+
+// Collect implements prometheus.Collector.
+func (m *TenantMetrics) Collect(ch chan<- prometheus.Metric) {
+	if m.Elected != nil {
+		select {
+		case <-m.Elected:
+		default:
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var dashboards customv1.DashboardList
+	if err := m.Client.List(ctx, &dashboards); err != nil {
+		m.Log.Error(err, "unable to list dashboards for the tenant metrics")
+		return
+	}
+	for tenant, counts := range m.count(dashboards.Items) {
+		ch <- prometheus.MustNewConstMetric(tenantDashboardsDesc, prometheus.GaugeValue, counts.managed, tenant)
+		ch <- prometheus.MustNewConstMetric(tenantDashboardsFailingDesc, prometheus.GaugeValue, counts.failing, tenant)
+		if !counts.lastSuccess.IsZero() {
+			ch <- prometheus.MustNewConstMetric(tenantLastSuccessDesc, prometheus.GaugeValue, float64(counts.lastSuccess.Unix()), tenant)
+		}
+	}
+}
+
+func (m *TenantMetrics) count(dashboards []customv1.Dashboard) map[string]*tenantCounts {
+	tenants := map[string]*tenantCounts{}
+	add := func(tenant string, n int, failing int, synced bool, lastSync *metav1.Time) {
+		counts, ok := tenants[tenant]
+		if !ok {
+			counts = &tenantCounts{}
+			tenants[tenant] = counts
+		}
+		counts.managed += float64(n)
+		counts.failing += float64(failing)
+		if synced && lastSync != nil && lastSync.After(counts.lastSuccess) {
+			counts.lastSuccess = lastSync.Time
+		}
+	}
+	for _, dashboard := range dashboards {
+		if !m.Shard.OwnsDashboard(&dashboard) || m.DryRun || dashboard.Spec.DryRun {
+			continue
+		}
+		status := dashboard.Status
+		primary := instanaConfigName
+		if m.NamespaceCredentials {
+			primary = dashboard.Namespace + "/" + namespaceCredentialsName
+		}
+		if len(dashboard.Spec.Clusters) > 0 {
+			failing := 0
+			for _, cluster := range status.Clusters {
+				if cluster.Error != "" {
+					failing++
+				}
+			}
+			add(primary, len(dashboard.Spec.Clusters), failing, failing < len(status.Clusters), status.LastSyncTime)
+			continue
+		}
+		if len(dashboard.Spec.Variants) > 0 {
+			failing := 0
+			for _, variant := range status.Variants {
+				if variant.Error != "" {
+					failing++
+				}
+			}
+			add(primary, len(dashboard.Spec.Variants), failing, failing < len(status.Variants), status.LastSyncTime)
+			continue
+		}
+		synced := meta.FindStatusCondition(status.Conditions, customv1.ConditionSynced)
+		add(primary, 1, boolCount(synced != nil && synced.Status == metav1.ConditionFalse), status.SyncAttempts == 0, status.LastSyncTime)
+		if tenant := dashboard.Spec.MirrorTenant; tenant != "" {
+			mirrorSynced := meta.FindStatusCondition(status.Conditions, customv1.ConditionMirrorSynced)
+			add(tenant, 1, boolCount(mirrorSynced != nil && mirrorSynced.Status == metav1.ConditionFalse),
+				mirrorSynced != nil && mirrorSynced.Status == metav1.ConditionTrue, status.LastSyncTime)
+		}
+	}
+	return tenants
+}
+
+func boolCount(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+const tenantPolicyPath = "/validate-custom-instana-io-v1-dashboard-tenant"
+
+// tenantAccessError reports a namespace which is not allowed to use a tenant.
+type tenantAccessError struct {
+	namespace string
+	tenant    string
+}
+
+func (e tenantAccessError) Error() string {
+	return fmt.Sprintf("namespace %s is not allowed to use the Instana tenant %s", e.namespace, e.tenant)
+}
+
+// namespaceAllowed checks a namespace against the "allowed-namespaces" of a
+// tenant config map, a list of namespaces or patterns like "team-a-*"
+// separated by commas. An empty list allows all namespaces.
+func namespaceAllowed(allowed string, namespace string) bool {
+	patterns := splitList(allowed)
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTenantAccess returns a tenantAccessError if the namespace of the
+// dashboard is not allowed to use the default tenant or its mirror tenant.
+// Tenants which don't exist yet are checked once they are created.
+func checkTenantAccess(ctx context.Context, c client.Reader, dashboard *customv1.Dashboard) error {
+	for _, tenant := range []string{instanaConfigName, dashboard.Spec.MirrorTenant} {
+		if tenant == "" {
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: instanaConfigNamespace, Name: tenant}, cm); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("unable to load tenant config %s: %w", tenant, err)
+		}
+		if !namespaceAllowed(cm.Data["allowed-namespaces"], dashboard.Namespace) {
+			return tenantAccessError{namespace: dashboard.Namespace, tenant: tenant}
+		}
+	}
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-custom-instana-io-v1-dashboard-tenant,mutating=false,failurePolicy=fail,sideEffects=None,groups=custom.instana.io,resources=dashboards,verbs=create;update,versions=v1,name=vdashboardtenant.kb.io,admissionReviewVersions={v1,v1beta1}
+
+// TenantPolicyWebhook denies Dashboards using a tenant whose
+// "allowed-namespaces" don't include the namespace of the Dashboard, so team
+// namespaces can't sync into the Instana tenant of another team.
+type TenantPolicyWebhook struct {
+	Client  client.Reader
+	decoder *admission.Decoder
+}
+
+// Handle validates the tenants of a created or updated Dashboard.
+func (w *TenantPolicyWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	dashboard := &customv1.Dashboard{}
+	if err := w.decoder.Decode(req, dashboard); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	// Dashboards being deleted need their finalizer removed
+	if dashboard.DeletionTimestamp != nil {
+		return admission.Allowed("")
+	}
+	err := checkTenantAccess(ctx, w.Client, dashboard)
+	var accessErr tenantAccessError
+	switch {
+	case errors.As(err, &accessErr):
+		return admission.Denied(err.Error())
+	case err != nil:
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.Allowed("")
+}
+
+// InjectDecoder implements admission.DecoderInjector.
+func (w *TenantPolicyWebhook) InjectDecoder(decoder *admission.Decoder) error {
+	w.decoder = decoder
+	return nil
+}
+
+// SetupWithManager registers the webhook with the webhook server of the Manager.
+func (w *TenantPolicyWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(tenantPolicyPath, &webhook.Admission{Handler: w})
+	return nil
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestTenantPolicyWebhook(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: instanaConfigName},
+			Data:       map[string]string{"instana-base-url": "https://shared.instana.io"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: instanaConfigNamespace, Name: "team-a-tenant"},
+			Data:       map[string]string{"instana-base-url": "https://team-a.instana.io", "allowed-namespaces": "team-a, team-a-*"},
+		},
+	).Build()
+	decoder, _ := admission.NewDecoder(scheme)
+	webhook := &TenantPolicyWebhook{Client: c}
+	_ = webhook.InjectDecoder(decoder)
+
+	tests := []struct {
+		name        string
+		namespace   string
+		tenant      string
+		wantAllowed bool
+	}{
+		{name: "default tenant", namespace: "team-b", wantAllowed: true},
+		{name: "allowed namespace", namespace: "team-a", tenant: "team-a-tenant", wantAllowed: true},
+		{name: "allowed pattern", namespace: "team-a-staging", tenant: "team-a-tenant", wantAllowed: true},
+		{name: "other team", namespace: "team-b", tenant: "team-a-tenant"},
+		{name: "tenant created later", namespace: "team-b", tenant: "team-c-tenant", wantAllowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dashboard := &customv1.Dashboard{
+				TypeMeta:   metav1.TypeMeta{APIVersion: customv1.GroupVersion.String(), Kind: "Dashboard"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: tt.namespace, Name: "shop"},
+				Spec:       customv1.DashboardSpec{MirrorTenant: tt.tenant},
+			}
+			raw, _ := json.Marshal(dashboard)
+			resp := webhook.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			}})
+			if resp.Allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v: %v", resp.Allowed, tt.wantAllowed, resp.Result)
+			}
+		})
+	}
+}
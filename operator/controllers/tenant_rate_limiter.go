@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+)
+
+// TenantRateLimiter limits the requests against each Instana tenant, shared
+// by all reconciles, so a mass resync does not exhaust the API quota of the
+// token. Tenants are identified by their base url. The requests-per-second,
+// requests-burst and max-concurrent-requests of a tenant config override the
+// defaults, e.g. for a small self-hosted Instana.
+type TenantRateLimiter struct {
+	// QPS is the number of requests per second per tenant. 0 disables the limit.
+	QPS   float64
+	Burst int
+
+	mu      sync.Mutex
+	tenants map[string]*tenantLimits
+}
+
+type tenantLimits struct {
+	// limiter is nil without a requests per second limit
+	limiter *rate.Limiter
+	// slots is nil without a concurrency limit
+	slots chan struct{}
+}
+
+// limits returns the limits of the tenant, updated to its current config.
+func (l *TenantRateLimiter) limits(apiConfig InstanaApi) *tenantLimits {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.tenants == nil {
+		l.tenants = map[string]*tenantLimits{}
+	}
+	limits, ok := l.tenants[apiConfig.BaseUrl]
+	if !ok {
+		limits = &tenantLimits{}
+		l.tenants[apiConfig.BaseUrl] = limits
+	}
+	qps, burst := l.QPS, l.Burst
+	if apiConfig.RequestsPerSecond > 0 {
+		qps = apiConfig.RequestsPerSecond
+	}
+	if apiConfig.RequestsBurst > 0 {
+		burst = apiConfig.RequestsBurst
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	switch {
+	case qps <= 0:
+		limits.limiter = nil
+	case limits.limiter == nil:
+		limits.limiter = rate.NewLimiter(rate.Limit(qps), burst)
+	case limits.limiter.Limit() != rate.Limit(qps) || limits.limiter.Burst() != burst:
+		limits.limiter.SetLimit(rate.Limit(qps))
+		limits.limiter.SetBurst(burst)
+	}
+	// requests in flight return their slot to the channel they took it from
+	if max := apiConfig.MaxConcurrentRequests; max <= 0 {
+		limits.slots = nil
+	} else if cap(limits.slots) != max {
+		limits.slots = make(chan struct{}, max)
+	}
+	return &tenantLimits{limiter: limits.limiter, slots: limits.slots}
+}
+
+// Wrap returns a client which waits for the limiter of the tenant before
+// each request and for a free slot if the tenant limits the concurrent
+// requests. Waiting is aborted when the context is cancelled.
+func (l *TenantRateLimiter) Wrap(ctx context.Context, apiConfig InstanaApi, instanaClient InstanaClient) InstanaClient {
+	if l == nil || (l.QPS <= 0 && apiConfig.RequestsPerSecond <= 0 && apiConfig.MaxConcurrentRequests <= 0) {
+		return instanaClient
+	}
+	limits := l.limits(apiConfig)
+	if limits.limiter == nil && limits.slots == nil {
+		return instanaClient
+	}
+	return &rateLimitedClient{ctx: ctx, limits: limits, next: instanaClient}
+}
+
+type rateLimitedClient struct {
+	ctx    context.Context
+	limits *tenantLimits
+	next   InstanaClient
+}
+
+// acquire waits until a request may be sent. The returned func releases the
+// slot of the request.
+func (c *rateLimitedClient) acquire() (func(), error) {
+	if c.limits.limiter != nil {
+		if err := c.limits.limiter.Wait(c.ctx); err != nil {
+			return nil, err
+		}
+	}
+	slots := c.limits.slots
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	}
+}
+
+func (c *rateLimitedClient) createDashboard(config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	release, err := c.acquire()
+	if err != nil {
+		return InstanaApiResponse{}, err
+	}
+	defer release()
+	return c.next.createDashboard(config, log)
+}
+
+func (c *rateLimitedClient) updateDashboard(id string, config []byte, log logr.Logger) (InstanaApiResponse, error) {
+	release, err := c.acquire()
+	if err != nil {
+		return InstanaApiResponse{}, err
+	}
+	defer release()
+	return c.next.updateDashboard(id, config, log)
+}
+
+func (c *rateLimitedClient) deleteDashboard(id string, log logr.Logger) error {
+	release, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.next.deleteDashboard(id, log)
+}
+
+func (c *rateLimitedClient) getDashboard(id string, log logr.Logger) ([]byte, error) {
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.next.getDashboard(id, log)
+}
+
+func (c *rateLimitedClient) listDashboards(log logr.Logger) ([]InstanaApiResponse, error) {
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.next.listDashboards(log)
+}
+
+func (c *rateLimitedClient) searchSnapshots(plugin string, query string, log logr.Logger) ([]InstanaSnapshot, error) {
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.next.searchSnapshots(plugin, query, log)
+}
+
+func (c *rateLimitedClient) getSnapshot(id string, log logr.Logger) (InstanaSnapshot, error) {
+	release, err := c.acquire()
+	if err != nil {
+		return InstanaSnapshot{}, err
+	}
+	defer release()
+	return c.next.getSnapshot(id, log)
+}
+
+func (c *rateLimitedClient) do(method string, path string, body []byte, log logr.Logger) ([]byte, error) {
+	release, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.next.do(method, path, body, log)
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// slowClient counts the requests in flight.
+type slowClient struct {
+	*fakeInstanaClient
+	inFlight, maxInFlight int32
+}
+
+func (c *slowClient) getDashboard(id string, log logr.Logger) ([]byte, error) {
+	n := atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&c.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&c.maxInFlight, max, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return []byte(`{}`), nil
+}
+
+func TestTenantRateLimiterConcurrency(t *testing.T) {
+	limiter := &TenantRateLimiter{Burst: 10}
+	small := InstanaApi{BaseUrl: "https://instana.internal", MaxConcurrentRequests: 2}
+	if c := limiter.Wrap(context.Background(), InstanaApi{BaseUrl: "https://saas.instana.io"}, newFakeInstanaClient()); c == nil {
+		t.Fatal("no client")
+	} else if _, ok := c.(*rateLimitedClient); ok {
+		t.Error("tenant without limits is limited")
+	}
+
+	instana := &slowClient{fakeInstanaClient: newFakeInstanaClient()}
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = limiter.Wrap(context.Background(), small, instana).getDashboard("a", ctrl.Log)
+		}()
+	}
+	wg.Wait()
+	if max := atomic.LoadInt32(&instana.maxInFlight); max != 2 {
+		t.Errorf("max requests in flight = %d, want 2", max)
+	}
+
+	// RequestsPerSecond overrides the default rate
+	start := time.Now()
+	fast := InstanaApi{BaseUrl: "https://instana.internal", RequestsPerSecond: 20, RequestsBurst: 1}
+	for i := 0; i < 3; i++ {
+		_, _ = limiter.Wrap(context.Background(), fast, instana).getDashboard("a", ctrl.Log)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests at 20/s with burst 1 took %v", elapsed)
+	}
+}
+
+func TestTenantRateLimiterSharedPerTenant(t *testing.T) {
+	limiter := &TenantRateLimiter{QPS: 1, Burst: 1}
+	ctx := context.Background()
+	tenantA := InstanaApi{BaseUrl: "https://a.instana.io"}
+	tenantB := InstanaApi{BaseUrl: "https://b.instana.io"}
+	instana := newFakeInstanaClient()
+	instana.dashboards["a"] = []byte(`{}`)
+
+	// the burst of each tenant is used by the first request, independent of
+	// the client it was sent with
+	start := time.Now()
+	if _, err := limiter.Wrap(ctx, tenantA, instana).getDashboard("a", ctrl.Log); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limiter.Wrap(ctx, tenantB, instana).getDashboard("a", ctrl.Log); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the first requests of two tenants took %v", elapsed)
+	}
+
+	// the next request of a tenant waits for the limiter shared by all reconciles
+	cancelled, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Wrap(cancelled, tenantA, instana).getDashboard("a", ctrl.Log); err == nil {
+		t.Error("a request over the limit was sent before the context was cancelled")
+	}
+	if len(instana.calls) != 2 {
+		t.Errorf("Instana calls = %v, want 2", instana.calls)
+	}
+}
+
+func TestTenantRateLimiterDisabled(t *testing.T) {
+	instana := newFakeInstanaClient()
+	var limiter *TenantRateLimiter
+	if c := limiter.Wrap(context.Background(), InstanaApi{BaseUrl: "https://a.instana.io"}, instana); c != instana {
+		t.Error("a nil limiter wrapped the client")
+	}
+	if c := (&TenantRateLimiter{}).Wrap(context.Background(), InstanaApi{BaseUrl: "https://a.instana.io"}, instana); c != instana {
+		t.Error("a limiter without limits wrapped the client")
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+// mirrorTenantIndex indexes Dashboards by the tenant they are mirrored to.
+const mirrorTenantIndex = "spec.mirror-tenant"
+
+func indexMirrorTenant(obj client.Object) []string {
+	dashboard := obj.(*customv1.Dashboard)
+	if dashboard.Spec.MirrorTenant == "" {
+		return nil
+	}
+	return []string{dashboard.Spec.MirrorTenant}
+}
+
+// tenantWatchKeys are the keys of a tenant config map whose changes trigger a
+// sync of the Dashboards using the tenant.
+var tenantWatchKeys = []string{
+	"instana-base-url", "instana-unit", "instana-tenant", "instana-saas-domain", "instana-api-token", "instana-api-path", "allowed-namespaces", "adoption-namespaces",
+	"vault-address", "vault-auth-mount", "vault-role", "vault-secret-path", "vault-secret-key",
+}
+
+// tenantReady returns true if the tenant config map holds a complete API
+// config, with a token or the address of the Vault holding it.
+func tenantReady(obj client.Object) bool {
+	cm, ok := obj.(*corev1.ConfigMap)
+	return ok && tenantBaseUrl(cm.Data) != "" && (cm.Data["instana-api-token"] != "" || cm.Data["vault-address"] != "")
+}
+
+// tenantReadyPredicate passes tenant config maps which became ready or whose
+// API config or allowed namespaces changed while being ready.
+var tenantReadyPredicate = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return e.Object.GetNamespace() == instanaConfigNamespace && tenantReady(e.Object)
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectNew.GetNamespace() != instanaConfigNamespace || !tenantReady(e.ObjectNew) {
+			return false
+		}
+		return !tenantReady(e.ObjectOld) || tenantConfigChanged(e.ObjectOld, e.ObjectNew)
+	},
+	DeleteFunc: func(event.DeleteEvent) bool {
+		return false
+	},
+	GenericFunc: func(event.GenericEvent) bool {
+		return false
+	},
+}
+
+// tenantChangedPredicate passes tenant config maps which were created,
+// deleted, became ready or not ready, or whose API config or allowed
+// namespaces changed, so state kept per tenant can be cleared.
+var tenantChangedPredicate = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return e.Object.GetNamespace() == instanaConfigNamespace
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectNew.GetNamespace() != instanaConfigNamespace {
+			return false
+		}
+		return tenantReady(e.ObjectOld) != tenantReady(e.ObjectNew) || tenantConfigChanged(e.ObjectOld, e.ObjectNew)
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return e.Object.GetNamespace() == instanaConfigNamespace
+	},
+	GenericFunc: func(event.GenericEvent) bool {
+		return false
+	},
+}
+
+// tenantConfigChanged returns true if a key of tenantWatchKeys changed.
+func tenantConfigChanged(old, new client.Object) bool {
+	oldCm, ok := old.(*corev1.ConfigMap)
+	if !ok {
+		return false
+	}
+	newCm, ok := new.(*corev1.ConfigMap)
+	if !ok {
+		return false
+	}
+	for _, key := range tenantWatchKeys {
+		if oldCm.Data[key] != newCm.Data[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// dashboardsForTenant maps a tenant config map to the Dashboards using it.
+// The default tenant is used by all Dashboards.
+func (r *DashboardReconciler) dashboardsForTenant(obj client.Object) []reconcile.Request {
+	if cm, ok := obj.(*corev1.ConfigMap); ok {
+		r.Capabilities.Forget(tenantBaseUrl(cm.Data))
+	}
+	var opts []client.ListOption
+	if obj.GetName() != instanaConfigName {
+		opts = append(opts, client.MatchingFields{mirrorTenantIndex: obj.GetName()})
+	}
+	var dashboards customv1.DashboardList
+	if err := r.List(context.Background(), &dashboards, opts...); err != nil {
+		r.Log.Error(err, "unable to list dashboards for tenant "+obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(dashboards.Items))
+	for _, dashboard := range dashboards.Items {
+		if !r.Shard.OwnsDashboard(&dashboard) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&dashboard)})
+	}
+	return requests
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultVaultAuthMount = "kubernetes"
+	defaultVaultSecretKey = "instana-api-token"
+	defaultVaultRefresh   = 5 * time.Minute
+	serviceAccountJwtPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// ErrVaultUnavailable is returned if the API token could not be read from Vault.
+var ErrVaultUnavailable = errors.New("vault is unavailable")
+
+// VaultConfig selects the API token of a tenant in HashiCorp Vault. The
+// operator logs in with the Kubernetes auth method using its service account.
+type VaultConfig struct {
+	Address string
+	// AuthMount is the mount of the Kubernetes auth method, "kubernetes" by default.
+	AuthMount string
+	Role      string
+	// SecretPath is the path of the secret, e.g. "secret/data/instana/prod"
+	// for the KV version 2 engine.
+	SecretPath string
+	// SecretKey is the key of the token in the secret, "instana-api-token" by default.
+	SecretKey string
+	// RefreshInterval is the maximum time tokens are cached, also if their
+	// lease is longer. Defaults to 5m.
+	RefreshInterval time.Duration
+}
+
+// vaultConfigFromConfigMap reads the vault-* keys of a tenant config map.
+func vaultConfigFromConfigMap(cm *corev1.ConfigMap) (VaultConfig, bool) {
+	config := VaultConfig{
+		Address:    cm.Data["vault-address"],
+		AuthMount:  cm.Data["vault-auth-mount"],
+		Role:       cm.Data["vault-role"],
+		SecretPath: cm.Data["vault-secret-path"],
+		SecretKey:  cm.Data["vault-secret-key"],
+	}
+	if config.Address == "" {
+		return config, false
+	}
+	if config.AuthMount == "" {
+		config.AuthMount = defaultVaultAuthMount
+	}
+	if config.SecretKey == "" {
+		config.SecretKey = defaultVaultSecretKey
+	}
+	// invalid intervals fall back to the default
+	config.RefreshInterval, _ = time.ParseDuration(cm.Data["vault-refresh-interval"])
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultVaultRefresh
+	}
+	return config, true
+}
+
+// vaultSources shares the token sources between reconciles, so Vault is only
+// asked again once a token expires.
+var vaultSources = struct {
+	sync.Mutex
+	sources map[VaultConfig]*VaultTokenSource
+}{sources: map[VaultConfig]*VaultTokenSource{}}
+
+func vaultTokenSource(config VaultConfig) *VaultTokenSource {
+	vaultSources.Lock()
+	defer vaultSources.Unlock()
+	source, ok := vaultSources.sources[config]
+	if !ok {
+		source = &VaultTokenSource{Config: config}
+		vaultSources.sources[config] = source
+	}
+	return source
+}
+
+// VaultTokenSource reads an API token from Vault. The Vault token of the
+// login and the API token are cached until their leases expire.
+type VaultTokenSource struct {
+	Config VaultConfig
+	// HttpClient defaults to a client with a timeout of 10s if nil.
+	HttpClient *http.Client
+	// ReadJwt returns the service account token used to log in. Defaults to
+	// reading the token mounted into the pod.
+	ReadJwt func() ([]byte, error)
+
+	mu                sync.Mutex
+	clientToken       string
+	clientTokenExpiry time.Time
+	token             string
+	tokenExpiry       time.Time
+}
+
+// Token returns the API token, reading it from Vault if it expired.
+func (s *VaultTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.token != "" && now.Before(s.tokenExpiry) {
+		return s.token, nil
+	}
+	if s.clientToken == "" || !now.Before(s.clientTokenExpiry) {
+		if err := s.login(now); err != nil {
+			return "", fmt.Errorf("%w: unable to log in to %s: %v", ErrVaultUnavailable, s.Config.Address, err)
+		}
+	}
+	var secret struct {
+		LeaseDuration int                    `json:"lease_duration"`
+		Data          map[string]interface{} `json:"data"`
+	}
+	if err := s.request(http.MethodGet, "/v1/"+strings.TrimPrefix(s.Config.SecretPath, "/"), s.clientToken, nil, &secret); err != nil {
+		// the Vault token may have been revoked
+		s.clientToken = ""
+		return "", fmt.Errorf("%w: unable to read %s: %v", ErrVaultUnavailable, s.Config.SecretPath, err)
+	}
+	data := secret.Data
+	// secrets of the KV version 2 engine are nested in data.data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	token, _ := data[s.Config.SecretKey].(string)
+	if token == "" {
+		return "", fmt.Errorf("vault secret %s has no key %s", s.Config.SecretPath, s.Config.SecretKey)
+	}
+	knownSecrets.add(token)
+	s.token = token
+	s.tokenExpiry = leaseExpiry(now, secret.LeaseDuration, s.Config.RefreshInterval)
+	return s.token, nil
+}
+
+// Expire makes the next call of Token read the API token again, e.g. after
+// Instana rejected it.
+func (s *VaultTokenSource) Expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+func (s *VaultTokenSource) login(now time.Time) error {
+	readJwt := s.ReadJwt
+	if readJwt == nil {
+		readJwt = func() ([]byte, error) { return ioutil.ReadFile(serviceAccountJwtPath) }
+	}
+	jwt, err := readJwt()
+	if err != nil {
+		return err
+	}
+	var login struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	body := map[string]string{"role": s.Config.Role, "jwt": strings.TrimSpace(string(jwt))}
+	if err := s.request(http.MethodPost, "/v1/auth/"+s.Config.AuthMount+"/login", "", body, &login); err != nil {
+		return err
+	}
+	if login.Auth.ClientToken == "" {
+		return errors.New("login returned no client token")
+	}
+	knownSecrets.add(login.Auth.ClientToken)
+	s.clientToken = login.Auth.ClientToken
+	s.clientTokenExpiry = leaseExpiry(now, login.Auth.LeaseDuration, s.Config.RefreshInterval)
+	return nil
+}
+
+// leaseExpiry returns when a lease of the given seconds should be renewed, at
+// 90% of its duration capped by the refresh interval. Without a lease the
+// refresh interval is used.
+func leaseExpiry(now time.Time, seconds int, refresh time.Duration) time.Time {
+	if seconds <= 0 {
+		return now.Add(refresh)
+	}
+	lease := time.Duration(seconds) * time.Second
+	if refresh < lease {
+		lease = refresh
+	}
+	return now.Add(lease * 9 / 10)
+}
+
+func (s *VaultTokenSource) request(method string, path string, token string, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(s.Config.Address, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	httpClient := s.HttpClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s failed with status %s", method, path, resp.Status)
+	}
+	return json.Unmarshal(data, result)
+}
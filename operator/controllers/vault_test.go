@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestVaultTokenSource(t *testing.T) {
+	var logins, reads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["role"] != "dashboards" || body["jwt"] != "service-account-jwt" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			logins++
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"vault-client-token","lease_duration":3600}}`))
+		case "/v1/secret/data/instana/prod":
+			if r.Header.Get("X-Vault-Token") != "vault-client-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			reads++
+			_, _ = w.Write([]byte(`{"data":{"data":{"instana-api-token":"token-from-vault"},"metadata":{"version":3}},"lease_duration":0}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config, ok := vaultConfigFromConfigMap(&corev1.ConfigMap{Data: map[string]string{
+		"vault-address":     server.URL,
+		"vault-role":        "dashboards",
+		"vault-secret-path": "secret/data/instana/prod",
+	}})
+	if !ok || config.AuthMount != defaultVaultAuthMount || config.SecretKey != defaultVaultSecretKey || config.RefreshInterval != defaultVaultRefresh {
+		t.Fatalf("config = %+v, want the defaults", config)
+	}
+	source := &VaultTokenSource{Config: config, ReadJwt: func() ([]byte, error) { return []byte("service-account-jwt\n"), nil }}
+
+	for i := 0; i < 2; i++ {
+		token, err := source.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token-from-vault" {
+			t.Errorf("token = %s", token)
+		}
+	}
+	if logins != 1 || reads != 1 {
+		t.Errorf("logins = %d, reads = %d, want the token to be cached", logins, reads)
+	}
+
+	// expired tokens are read again with the cached login
+	source.Expire()
+	if _, err := source.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 || reads != 2 {
+		t.Errorf("logins = %d, reads = %d after expiry", logins, reads)
+	}
+	if authorization, _ := (InstanaApi{Vault: source}).authorization(); authorization != "apiToken token-from-vault" {
+		t.Errorf("authorization = %s", authorization)
+	}
+
+	// an unreachable Vault degrades the dashboards
+	server.Close()
+	source.Expire()
+	_, err := source.Token()
+	if !errors.Is(err, ErrVaultUnavailable) {
+		t.Fatalf("err = %v, want ErrVaultUnavailable", err)
+	}
+	dashboard := &customv1.Dashboard{}
+	setDegradedStatus(dashboard, err)
+	if degraded := meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionDegraded); degraded == nil || degraded.Reason != "VaultUnavailable" {
+		t.Errorf("Degraded condition = %v", degraded)
+	}
+}
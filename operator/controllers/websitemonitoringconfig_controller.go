@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=websitemonitoringconfigs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=custom.instana.io,resources=websitemonitoringconfigs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=custom.instana.io,resources=websitemonitoringconfigs/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+
+// websiteMonitoringConfigKind syncs WebsiteMonitoringConfigs with the
+// websites of the Website Monitoring API, which takes the name as a query
+// parameter.
+var websiteMonitoringConfigKind = InstanaResourceKind{
+	Kind:         "WebsiteMonitoringConfig",
+	New:          func() InstanaResource { return &customv1.WebsiteMonitoringConfig{} },
+	Path:         "/api/website-monitoring/config",
+	QueryPayload: true,
+	Payload: func(ctx context.Context, c client.Client, obj InstanaResource) (map[string]interface{}, error) {
+		return map[string]interface{}{"name": obj.(*customv1.WebsiteMonitoringConfig).Spec.Name}, nil
+	},
+	Synced: writeEumKeySecret,
+}
+
+// writeEumKeySecret creates or updates spec.eum-key-secret with the EUM key
+// of the website. The Secret is owned by the WebsiteMonitoringConfig.
+func writeEumKeySecret(ctx context.Context, r *InstanaResourceReconciler, obj InstanaResource, response []byte) error {
+	website := obj.(*customv1.WebsiteMonitoringConfig)
+	if website.Spec.EumKeySecret == "" {
+		return nil
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: website.Namespace, Name: website.Spec.EumKeySecret}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		if secret.ResourceVersion == "" || metav1.IsControlledBy(secret, website) {
+			secret.Data = map[string][]byte{"eum-key": []byte(website.Status.Id)}
+			return controllerutil.SetControllerReference(website, secret, r.Scheme)
+		}
+		return fmt.Errorf("secret %s exists and is not owned by the website", secret.Name)
+	})
+	return err
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// WidgetDeprecation marks a widget type, or a field of a widget, which is
+// deprecated in Instana and will be removed with a later release.
+type WidgetDeprecation struct {
+	// WidgetType is the "type" of the widget, e.g. "chart".
+	WidgetType string `json:"widget-type"`
+	// Field is an optional dot separated path within the widget, e.g. "config.shareMaxAxisDomain".
+	// If empty the whole widget type is deprecated.
+	Field string `json:"field,omitempty"`
+	// DeprecatedIn is the Instana release which deprecated the widget or field, if known.
+	DeprecatedIn string `json:"deprecated-in,omitempty"`
+	// RemovedIn is the Instana release which is planned to remove the widget or field.
+	RemovedIn string `json:"removed-in,omitempty"`
+	// Message gives a hint on how to migrate.
+	Message string `json:"message,omitempty"`
+}
+
+// bundledWidgetDeprecations is the deprecation metadata shipped with the operator.
+// It can be replaced by the "widget-deprecations" key of the config map.
+var bundledWidgetDeprecations = `[
+  {
+    "widget-type": "chart",
+    "field": "config.shareMaxAxisDomain",
+    "message": "the axes of charts are scaled independently, remove the field"
+  }
+]`
+
+// loadWidgetDeprecations parses the deprecation metadata. An empty string
+// falls back to the bundled metadata.
+func loadWidgetDeprecations(data string) ([]WidgetDeprecation, error) {
+	if strings.TrimSpace(data) == "" {
+		data = bundledWidgetDeprecations
+	}
+	var deprecations []WidgetDeprecation
+	if err := json.Unmarshal([]byte(data), &deprecations); err != nil {
+		return nil, fmt.Errorf("unable to parse widget deprecations: %w", err)
+	}
+	return deprecations, nil
+}
+
+// findDeprecatedWidgets returns a finding for every widget in the config
+// which uses a deprecated widget type or field.
+func findDeprecatedWidgets(config []byte, deprecations []WidgetDeprecation) []string {
+	var dashboard struct {
+		Widgets []map[string]interface{} `json:"widgets"`
+	}
+	if len(deprecations) == 0 || json.Unmarshal(config, &dashboard) != nil {
+		return nil
+	}
+	var findings []string
+	for _, widget := range dashboard.Widgets {
+		widgetType, _ := widget["type"].(string)
+		for _, d := range deprecations {
+			if d.WidgetType != widgetType {
+				continue
+			}
+			if d.Field != "" && !hasField(widget, strings.Split(d.Field, ".")) {
+				continue
+			}
+			findings = append(findings, describeDeprecation(widget, d))
+		}
+	}
+	return findings
+}
+
+func hasField(value interface{}, path []string) bool {
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if value, ok = object[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func describeDeprecation(widget map[string]interface{}, d WidgetDeprecation) string {
+	subject := "type " + d.WidgetType
+	if d.Field != "" {
+		subject = "field " + d.Field
+	}
+	finding := fmt.Sprintf("widget %q uses %s which is deprecated", widget["title"], subject)
+	if d.DeprecatedIn != "" {
+		finding += " since Instana release " + d.DeprecatedIn
+	}
+	if d.RemovedIn != "" {
+		finding += " and will be removed in release " + d.RemovedIn
+	}
+	if d.Message != "" {
+		finding += ": " + d.Message
+	}
+	return finding
+}
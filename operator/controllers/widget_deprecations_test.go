@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/record"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestFindDeprecatedWidgets(t *testing.T) {
+	deprecations, err := loadWidgetDeprecations("")
+	if err != nil || len(deprecations) == 0 {
+		t.Fatalf("bundled deprecations = %v, %v", deprecations, err)
+	}
+	config := []byte(`{"widgets":[` +
+		`{"title":"Calls","type":"chart","config":{"shareMaxAxisDomain":true}},` +
+		`{"title":"Latency","type":"chart","config":{}},` +
+		`{"title":"Notes","type":"markdown"}]}`)
+	findings := findDeprecatedWidgets(config, deprecations)
+	if len(findings) != 1 || !strings.HasPrefix(findings[0], `widget "Calls" uses field config.shareMaxAxisDomain which is deprecated`) {
+		t.Errorf("findings = %v", findings)
+	}
+
+	deprecations, err = loadWidgetDeprecations(`[{"widget-type":"markdown","deprecated-in":"210","removed-in":"216"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings = findDeprecatedWidgets(config, deprecations)
+	if len(findings) != 1 || findings[0] != `widget "Notes" uses type markdown which is deprecated since Instana release 210 and will be removed in release 216` {
+		t.Errorf("findings = %v", findings)
+	}
+	if _, err := loadWidgetDeprecations("{"); err == nil {
+		t.Error("expected an error for invalid metadata")
+	}
+}
+
+func TestSetDeprecationStatus(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	dashboard := &customv1.Dashboard{}
+
+	setDeprecationStatus(dashboard, nil, recorder)
+	setDeprecationStatus(dashboard, []string{"a"}, recorder)
+	setDeprecationStatus(dashboard, []string{"a"}, recorder)
+	if len(recorder.Events) != 1 {
+		t.Errorf("events = %d, want one for the new finding", len(recorder.Events))
+	}
+	if !meta.IsStatusConditionTrue(dashboard.Status.Conditions, customv1.ConditionDeprecatedWidgets) {
+		t.Errorf("conditions = %+v", dashboard.Status.Conditions)
+	}
+	setDeprecationStatus(dashboard, []string{"a", "b"}, recorder)
+	if len(recorder.Events) != 2 {
+		t.Errorf("events = %d, want another one for changed findings", len(recorder.Events))
+	}
+	setDeprecationStatus(dashboard, nil, recorder)
+	if meta.FindStatusCondition(dashboard.Status.Conditions, customv1.ConditionDeprecatedWidgets) != nil {
+		t.Errorf("conditions = %+v", dashboard.Status.Conditions)
+	}
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=custom.instana.io,resources=widgetlibraries,verbs=get;list;watch
+
+// widgetParamPattern matches the parameter references of library widgets.
+var widgetParamPattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.-]+)\}`)
+
+// composeWidgets appends the widgets selected in spec.widgets-from to the
+// widgets of the config. Widgets without a position are placed below the
+// widgets before them.
+func composeWidgets(ctx context.Context, c client.Reader, dashboard customv1.Dashboard, config []byte) ([]byte, error) {
+	if len(dashboard.Spec.WidgetsFrom) == 0 {
+		return config, nil
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return nil, err
+	}
+	widgets, _ := payload["widgets"].([]interface{})
+	ids := map[interface{}]bool{}
+	bottom := 0.0
+	for _, w := range widgets {
+		if widget, ok := w.(map[string]interface{}); ok {
+			ids[widget["id"]] = true
+			bottom = math.Max(bottom, widgetBottom(widget))
+		}
+	}
+
+	for _, source := range dashboard.Spec.WidgetsFrom {
+		var library customv1.WidgetLibrary
+		if err := c.Get(ctx, client.ObjectKey{Namespace: dashboard.Namespace, Name: source.Library}, &library); err != nil {
+			return nil, fmt.Errorf("unable to load widget library %s: %w", source.Library, err)
+		}
+		params := map[string]string{}
+		for k, v := range library.Spec.Params {
+			params[k] = v
+		}
+		for k, v := range source.Params {
+			params[k] = v
+		}
+		names := source.Widgets
+		if len(names) == 0 {
+			for _, w := range library.Spec.Widgets {
+				names = append(names, w.Name)
+			}
+		}
+		for _, name := range names {
+			widget, err := libraryWidget(library, name, params)
+			if err != nil {
+				return nil, err
+			}
+			if id, ok := widget["id"]; ok {
+				if ids[id] {
+					return nil, fmt.Errorf("widget %s of library %s has the id %v of another widget", name, library.Name, id)
+				}
+				ids[id] = true
+			}
+			if _, ok := widget["y"]; !ok {
+				widget["x"] = 0
+				widget["y"] = bottom
+			}
+			bottom = math.Max(bottom, widgetBottom(widget))
+			widgets = append(widgets, widget)
+		}
+	}
+	payload["widgets"] = widgets
+	return json.Marshal(payload)
+}
+
+// libraryWidget returns the widget of the library with its parameters replaced.
+func libraryWidget(library customv1.WidgetLibrary, name string, params map[string]string) (map[string]interface{}, error) {
+	for _, w := range library.Spec.Widgets {
+		if w.Name != name {
+			continue
+		}
+		var widget interface{}
+		if err := json.Unmarshal(w.Widget.Raw, &widget); err != nil {
+			return nil, fmt.Errorf("invalid widget %s of library %s: %w", name, library.Name, err)
+		}
+		widget, err := replaceParams(widget, params)
+		if err != nil {
+			return nil, fmt.Errorf("widget %s of library %s: %w", name, library.Name, err)
+		}
+		result, ok := widget.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("widget %s of library %s is not an object", name, library.Name)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("widget library %s has no widget %s", library.Name, name)
+}
+
+// replaceParams replaces the parameter references in all strings of value.
+func replaceParams(value interface{}, params map[string]string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		var err error
+		replaced := widgetParamPattern.ReplaceAllStringFunc(v, func(ref string) string {
+			name := widgetParamPattern.FindStringSubmatch(ref)[1]
+			param, ok := params[name]
+			if !ok && err == nil {
+				err = fmt.Errorf("undefined parameter %s", name)
+			}
+			return param
+		})
+		return replaced, err
+	case map[string]interface{}:
+		for key, item := range v {
+			replaced, err := replaceParams(item, params)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = replaced
+		}
+	case []interface{}:
+		for i, item := range v {
+			replaced, err := replaceParams(item, params)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = replaced
+		}
+	}
+	return value, nil
+}
+
+func widgetBottom(widget map[string]interface{}) float64 {
+	y, _ := widget["y"].(float64)
+	height, _ := widget["height"].(float64)
+	return y + height
+}
+
+// dashboardsForLibrary maps a WidgetLibrary to the Dashboards using it in
+// spec.widgets-from or spec.includes.
+func (r *DashboardReconciler) dashboardsForLibrary(obj client.Object) []reconcile.Request {
+	var dashboards customv1.DashboardList
+	if err := r.List(context.Background(), &dashboards, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "unable to list dashboards")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, dashboard := range dashboards.Items {
+		if !r.Shard.OwnsDashboard(&dashboard) {
+			continue
+		}
+		uses := includes(dashboard, "WidgetLibrary", obj.GetName())
+		for _, source := range dashboard.Spec.WidgetsFrom {
+			uses = uses || source.Library == obj.GetName()
+		}
+		if uses {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&dashboard)})
+			// and the dashboards including it
+			requests = append(requests, r.dashboardsForInclude(&dashboard)...)
+		}
+	}
+	return requests
+}
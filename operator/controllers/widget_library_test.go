@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestComposeWidgets(t *testing.T) {
+	library := &customv1.WidgetLibrary{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "golden-signals"},
+		Spec: customv1.WidgetLibrarySpec{
+			Params: map[string]string{"service": "shop", "env": "prod"},
+			Widgets: []customv1.LibraryWidget{
+				{Name: "calls", Widget: apiextensionsv1.JSON{Raw: []byte(`{"id":"${service}-calls","title":"Calls of ${service} (${env})","height":10}`)}},
+				{Name: "latency", Widget: apiextensionsv1.JSON{Raw: []byte(`{"id":"${service}-latency","height":10,"x":6,"y":2}`)}},
+			},
+		},
+	}
+	scheme := runtime.NewScheme()
+	_ = customv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(library).Build()
+	dashboard := customv1.Dashboard{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"},
+		Spec: customv1.DashboardSpec{WidgetsFrom: []customv1.WidgetSource{
+			{Library: "golden-signals"},
+			{Library: "golden-signals", Widgets: []string{"calls"}, Params: map[string]string{"service": "checkout"}},
+		}},
+	}
+
+	config, err := composeWidgets(context.Background(), c, dashboard, []byte(`{"title":"Shop","widgets":[{"id":"intro","y":0,"height":2}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Widgets []struct {
+			Id    string
+			Title string
+			X     float64
+			Y     float64
+		}
+	}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Widgets) != 4 {
+		t.Fatalf("widgets = %s", config)
+	}
+	if w := payload.Widgets[1]; w.Id != "shop-calls" || w.Title != "Calls of shop (prod)" || w.Y != 2 {
+		t.Errorf("widget = %+v", w)
+	}
+	if w := payload.Widgets[2]; w.Id != "shop-latency" || w.X != 6 || w.Y != 2 {
+		t.Errorf("widget with a position should keep it, got %+v", w)
+	}
+	if w := payload.Widgets[3]; w.Id != "checkout-calls" || w.Title != "Calls of checkout (prod)" || w.Y != 12 {
+		t.Errorf("widget = %+v", w)
+	}
+
+	// widgets must not reuse ids
+	dashboard.Spec.WidgetsFrom[1].Params = nil
+	if _, err := composeWidgets(context.Background(), c, dashboard, []byte(`{"widgets":[]}`)); err == nil {
+		t.Error("expected an error for duplicate widget ids")
+	}
+	// parameters must be defined
+	library.Spec.Widgets[0].Widget.Raw = []byte(`{"title":"${missing}"}`)
+	if err := c.Update(context.Background(), library); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := composeWidgets(context.Background(), c, dashboard, []byte(`{"widgets":[]}`)); err == nil {
+		t.Error("expected an error for an undefined parameter")
+	}
+}
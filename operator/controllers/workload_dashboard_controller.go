@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch
+
+const defaultWorkloadTemplate = "workload"
+
+// workloadKinds are the kinds of workloads with generated dashboards.
+var workloadKinds = map[string]struct {
+	New     func() client.Object
+	NewList func() client.ObjectList
+}{
+	"Deployment": {
+		New:     func() client.Object { return &appsv1.Deployment{} },
+		NewList: func() client.ObjectList { return &appsv1.DeploymentList{} },
+	},
+	"StatefulSet": {
+		New:     func() client.Object { return &appsv1.StatefulSet{} },
+		NewList: func() client.ObjectList { return &appsv1.StatefulSetList{} },
+	},
+}
+
+// WorkloadDashboardReconciler generates a Dashboard for every workload of
+// its Kind annotated with custom.instana.io/generate-dashboard: "true", from
+// the template of custom.instana.io/dashboard-template with the parameters of
+// custom.instana.io/dashboard-params. The Dashboard <kind>-<name> is owned by
+// the workload and deleted with it or when the annotation is removed.
+type WorkloadDashboardReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Kind is Deployment or StatefulSet.
+	Kind string
+	// Variables are passed to the templates.
+	Variables RenderVariables
+	// Shard limits the reconciler to the workloads in namespaces of this shard.
+	Shard Shard
+}
+
+// Reconcile creates, updates or deletes the Dashboard of the workload.
+func (r *WorkloadDashboardReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues(strings.ToLower(r.Kind), req.NamespacedName)
+
+	workload := workloadKinds[r.Kind].New()
+	if err := r.Get(ctx, req.NamespacedName, workload); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if workload.GetDeletionTimestamp() != nil {
+		// the dashboard is deleted with the workload
+		return ctrl.Result{}, nil
+	}
+	dashboard := &customv1.Dashboard{ObjectMeta: metav1.ObjectMeta{
+		Namespace: workload.GetNamespace(),
+		Name:      strings.ToLower(r.Kind) + "-" + workload.GetName(),
+	}}
+	data := TemplateData{RenderVariables: r.Variables, Namespace: workload.GetNamespace(), Kind: r.Kind, Name: workload.GetName()}
+	if err := applyGeneratedDashboard(ctx, r.Client, r.Scheme, workload, dashboard, strings.ToLower(r.Kind), defaultWorkloadTemplate, data, log); err != nil {
+		log.Error(err, "unable to apply workload dashboard")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// workloadsForTemplates maps the templates ConfigMap to the workloads with a
+// generated dashboard.
+func (r *WorkloadDashboardReconciler) workloadsForTemplates(obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != instanaConfigNamespace || obj.GetName() != dashboardTemplatesName {
+		return nil
+	}
+	list := workloadKinds[r.Kind].NewList()
+	if err := r.List(context.Background(), list); err != nil {
+		r.Log.Error(err, "unable to list workloads")
+		return nil
+	}
+	var requests []reconcile.Request
+	_ = meta.EachListItem(list, func(item runtime.Object) error {
+		workload := item.(client.Object)
+		if workload.GetAnnotations()[customv1.GenerateDashboardAnnotation] == "true" && r.Shard.Owns(workload.GetNamespace()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(workload)})
+		}
+		return nil
+	})
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WorkloadDashboardReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(strings.ToLower(r.Kind)+"dashboard").
+		For(workloadKinds[r.Kind].New(), builder.WithPredicates(
+			r.Shard.Predicate(),
+			predicate.AnnotationChangedPredicate{},
+		)).
+		// Restore generated Dashboards which were edited or deleted by hand
+		Owns(&customv1.Dashboard{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.workloadsForTemplates)).
+		Complete(r)
+}
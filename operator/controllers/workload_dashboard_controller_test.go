@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	customv1 "github.com/luebken/custom-dashboards/api/v1"
+)
+
+func TestWorkloadDashboardReconciler(t *testing.T) {
+	ctx := context.Background()
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "team-a",
+		Name:      "db",
+		UID:       "sts-1",
+		Annotations: map[string]string{
+			customv1.GenerateDashboardAnnotation: "true",
+			customv1.DashboardParamsAnnotation:   "title=Orders DB, owner = team-a",
+		},
+	}}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = customv1.AddToScheme(scheme)
+	r := &WorkloadDashboardReconciler{
+		Client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(statefulSet).Build(),
+		Log:       ctrl.Log.WithName("test"),
+		Scheme:    scheme,
+		Kind:      "StatefulSet",
+		Variables: RenderVariables{ClusterName: "prod"},
+	}
+	reconcile := func() {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(statefulSet)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := client.ObjectKey{Namespace: "team-a", Name: "statefulset-db"}
+
+	reconcile()
+	var dashboard customv1.Dashboard
+	if err := r.Get(ctx, key, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Title   string
+		Widgets []map[string]interface{}
+	}
+	if err := json.Unmarshal(dashboard.Spec.Config.Raw, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Title != "Orders DB" || len(config.Widgets) != 4 {
+		t.Errorf("config = %s", dashboard.Spec.Config.Raw)
+	}
+	if !strings.Contains(string(dashboard.Spec.Config.Raw), `"name":"kubernetes.statefulset.name","operator":"EQUALS","type":"TAG_FILTER","value":"db"`) {
+		t.Errorf("widgets should filter on the statefulset, got %s", dashboard.Spec.Config.Raw)
+	}
+	if !metav1.IsControlledBy(&dashboard, statefulSet) || dashboard.Labels[customv1.GeneratorLabel] != "statefulset" {
+		t.Errorf("dashboard should be owned by the statefulset and labelled, got %+v", dashboard.ObjectMeta)
+	}
+
+	// the dashboard is deleted with the annotation
+	statefulSet.Annotations[customv1.GenerateDashboardAnnotation] = "false"
+	if err := r.Update(ctx, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if err := r.Get(ctx, key, &dashboard); !apierrors.IsNotFound(err) {
+		t.Errorf("dashboard should have been deleted, got %v", err)
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	params := ParseKeyValues("title=Orders DB, owner = team-a,invalid,=empty,url=http://x?a=b")
+	if len(params) != 3 || params["title"] != "Orders DB" || params["owner"] != "team-a" || params["url"] != "http://x?a=b" {
+		t.Errorf("params = %v", params)
+	}
+}
@@ -3,11 +3,19 @@ module github.com/luebken/custom-dashboards
 go 1.15
 
 require (
+	github.com/evanphx/json-patch v4.9.0+incompatible
+	github.com/go-git/go-git/v5 v5.2.0
 	github.com/go-logr/logr v0.3.0
+	github.com/google/go-jsonnet v0.17.0
 	github.com/onsi/ginkgo v1.14.1
 	github.com/onsi/gomega v1.10.2
+	github.com/prometheus/client_golang v1.7.1
+	github.com/robfig/cron/v3 v3.0.1
+	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
 	k8s.io/api v0.19.2
+	k8s.io/apiextensions-apiserver v0.19.2
 	k8s.io/apimachinery v0.19.2
 	k8s.io/client-go v0.19.2
 	sigs.k8s.io/controller-runtime v0.7.2
+	sigs.k8s.io/yaml v1.2.0
 )
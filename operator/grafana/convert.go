@@ -0,0 +1,222 @@
+// Package grafana converts Grafana dashboards into Instana custom dashboard configs.
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// InstanaMetric is the Instana infrastructure metric a Prometheus metric is mapped to.
+type InstanaMetric struct {
+	// Type is the entity type, e.g. "host" or "docker".
+	Type   string
+	Metric string
+}
+
+// MetricMapping maps Prometheus metric names to Instana metrics. Queries of
+// other metrics are reported as unconvertible.
+var MetricMapping = map[string]InstanaMetric{
+	"node_cpu_seconds_total":                   {Type: "host", Metric: "cpu.used"},
+	"node_load1":                               {Type: "host", Metric: "load.1min"},
+	"node_memory_MemAvailable_bytes":           {Type: "host", Metric: "memory.available"},
+	"node_memory_MemTotal_bytes":               {Type: "host", Metric: "memory.total"},
+	"node_filesystem_avail_bytes":              {Type: "host", Metric: "fs.free"},
+	"node_network_receive_bytes_total":         {Type: "host", Metric: "net.rx"},
+	"node_network_transmit_bytes_total":        {Type: "host", Metric: "net.tx"},
+	"container_cpu_usage_seconds_total":        {Type: "docker", Metric: "cpu.total_usage"},
+	"container_memory_usage_bytes":             {Type: "docker", Metric: "memory.usage"},
+	"container_network_receive_bytes_total":    {Type: "docker", Metric: "network.rx.bytes"},
+	"container_network_transmit_bytes_total":   {Type: "docker", Metric: "network.tx.bytes"},
+	"kube_pod_container_status_restarts_total": {Type: "kubernetesPod", Metric: "restartCount"},
+}
+
+// Result of a conversion.
+type Result struct {
+	// Config is the Instana custom dashboard config.
+	Config []byte
+	// Report lists the panels and queries which could not be converted.
+	Report []string
+}
+
+type dashboard struct {
+	Title  string  `json:"title"`
+	Panels []panel `json:"panels"`
+	Rows   []struct {
+		Title string `json:"title"`
+	} `json:"rows"`
+}
+
+type panel struct {
+	Title   string `json:"title"`
+	Type    string `json:"type"`
+	GridPos struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"gridPos"`
+	Targets []struct {
+		Expr string `json:"expr"`
+	} `json:"targets"`
+	Options struct {
+		Content string `json:"content"`
+	} `json:"options"`
+	Content string  `json:"content"`
+	Panels  []panel `json:"panels"`
+}
+
+// Convert translates a Grafana dashboard JSON into an Instana custom
+// dashboard config. Time series, graph, stat and gauge panels with mappable
+// Prometheus queries become charts, text panels become markdown widgets.
+func Convert(grafanaJson []byte) (Result, error) {
+	var d dashboard
+	if err := json.Unmarshal(grafanaJson, &d); err != nil {
+		return Result{}, fmt.Errorf("unable to parse Grafana dashboard: %w", err)
+	}
+	if d.Title == "" {
+		return Result{}, fmt.Errorf("Grafana dashboard has no title")
+	}
+	var result Result
+	if len(d.Rows) > 0 {
+		result.Report = append(result.Report, fmt.Sprintf("%d rows of the legacy dashboard format were skipped, re-save the dashboard in a recent Grafana first", len(d.Rows)))
+	}
+	widgets := []interface{}{}
+	for i, p := range flatten(d.Panels) {
+		widget, problems := convertPanel(p, i)
+		result.Report = append(result.Report, problems...)
+		if widget != nil {
+			widgets = append(widgets, widget)
+		}
+	}
+	config, err := json.Marshal(map[string]interface{}{
+		"title":   d.Title,
+		"widgets": widgets,
+	})
+	result.Config = config
+	return result, err
+}
+
+// flatten replaces collapsed rows with their panels.
+func flatten(panels []panel) []panel {
+	var result []panel
+	for _, p := range panels {
+		if p.Type == "row" {
+			result = append(result, p.Panels...)
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+func convertPanel(p panel, index int) (map[string]interface{}, []string) {
+	name := fmt.Sprintf("panel %q", p.Title)
+	widget := map[string]interface{}{
+		"id":    fmt.Sprintf("grafana-%d", index),
+		"title": p.Title,
+		// Grafana uses a grid of 24 columns, Instana one of 12
+		"width":  int(math.Max(1, math.Round(float64(p.GridPos.W)/2))),
+		"height": int(math.Max(2, math.Round(float64(p.GridPos.H)*13/8))),
+		"x":      p.GridPos.X / 2,
+		"y":      int(math.Round(float64(p.GridPos.Y) * 13 / 8)),
+	}
+	switch p.Type {
+	case "text":
+		content := p.Options.Content
+		if content == "" {
+			content = p.Content
+		}
+		widget["type"] = "markdown"
+		widget["config"] = content
+		return widget, nil
+	case "timeseries", "graph", "stat", "gauge":
+	default:
+		return nil, []string{fmt.Sprintf("%s: panel type %s is not supported", name, p.Type)}
+	}
+
+	var problems []string
+	metrics := []interface{}{}
+	for _, target := range p.Targets {
+		mapped, aggregation, ok := mapQuery(target.Expr)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: query %q has no Instana equivalent", name, target.Expr))
+			continue
+		}
+		if len(mapped) > 1 {
+			problems = append(problems, fmt.Sprintf("%s: query %q combines metrics, they are charted separately", name, target.Expr))
+		}
+		for _, metric := range mapped {
+			metrics = append(metrics, map[string]interface{}{
+				"metric":      metric.Metric,
+				"type":        metric.Type,
+				"aggregation": aggregation,
+				"source":      "INFRASTRUCTURE_METRICS",
+				"label":       "",
+				"tagFilterExpression": map[string]interface{}{
+					"type":            "EXPRESSION",
+					"logicalOperator": "AND",
+					"elements":        []interface{}{},
+				},
+			})
+		}
+	}
+	if len(metrics) == 0 {
+		return nil, append(problems, fmt.Sprintf("%s: skipped, none of its queries could be converted", name))
+	}
+	chartType := "TIME_SERIES"
+	if p.Type == "stat" || p.Type == "gauge" {
+		chartType = "BIG_NUMBER"
+	}
+	widget["type"] = "chart"
+	widget["config"] = map[string]interface{}{
+		"type": chartType,
+		"y1": map[string]interface{}{
+			"formatter": "number.detailed",
+			"renderer":  "line",
+			"metrics":   metrics,
+		},
+	}
+	return widget, problems
+}
+
+var (
+	// label matchers, ranges and grouping clauses contain names which are no metrics
+	ignoredQueryPattern = regexp.MustCompile(`\{[^}]*\}|\[[^\]]*\]|\b(by|without)\s*\([^)]*\)`)
+	metricNamePattern   = regexp.MustCompile(`[a-zA-Z_:][a-zA-Z0-9_:]*`)
+	aggregationPattern  = regexp.MustCompile(`^\s*(sum|avg|max|min|count)\b`)
+	// Instana has no count of series, the sum is the closest for counters
+	aggregations   = map[string]string{"sum": "SUM", "avg": "MEAN", "max": "MAX", "min": "MIN", "count": "SUM"}
+	promqlKeywords = map[string]bool{"sum": true, "avg": true, "max": true, "min": true, "rate": true, "irate": true,
+		"increase": true, "by": true, "without": true, "count": true, "topk": true, "bottomk": true}
+)
+
+// mapQuery maps a PromQL query to the Instana metrics of all its metric
+// names, it fails if one of them has no mapping. The outermost aggregation
+// operator becomes the aggregation.
+func mapQuery(expr string) ([]InstanaMetric, string, bool) {
+	expr = ignoredQueryPattern.ReplaceAllString(expr, "")
+	var metrics []InstanaMetric
+	seen := map[string]bool{}
+	for _, name := range metricNamePattern.FindAllString(expr, -1) {
+		if promqlKeywords[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		metric, ok := MetricMapping[name]
+		if !ok {
+			return nil, "", false
+		}
+		metrics = append(metrics, metric)
+	}
+	if len(metrics) == 0 {
+		return nil, "", false
+	}
+	aggregation := "MEAN"
+	if match := aggregationPattern.FindStringSubmatch(expr); match != nil {
+		aggregation = aggregations[strings.ToLower(match[1])]
+	}
+	return metrics, aggregation, true
+}
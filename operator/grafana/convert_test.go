@@ -0,0 +1,83 @@
+package grafana
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMapQuery(t *testing.T) {
+	tests := []struct {
+		expr        string
+		metrics     string
+		aggregation string
+		ok          bool
+	}{
+		{`node_load1`, "load.1min", "MEAN", true},
+		{`sum by (pod) (rate(container_cpu_usage_seconds_total{namespace="node_load1"}[5m]))`, "cpu.total_usage", "SUM", true},
+		{`max(node_memory_MemAvailable_bytes)`, "memory.available", "MAX", true},
+		{`count(kube_pod_container_status_restarts_total)`, "restartCount", "SUM", true},
+		{`node_memory_MemTotal_bytes - node_memory_MemAvailable_bytes`, "memory.total memory.available", "MEAN", true},
+		{`node_load1 / http_requests_total`, "", "", false},
+		{`http_requests_total`, "", "", false},
+	}
+	for _, tt := range tests {
+		metrics, aggregation, ok := mapQuery(tt.expr)
+		var names []string
+		for _, metric := range metrics {
+			names = append(names, metric.Metric)
+		}
+		if ok != tt.ok || strings.Join(names, " ") != tt.metrics || aggregation != tt.aggregation {
+			t.Errorf("mapQuery(%q) = %v, %q, %v; want %q, %q, %v", tt.expr, names, aggregation, ok, tt.metrics, tt.aggregation, tt.ok)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	result, err := Convert([]byte(`{
+		"title": "Nodes",
+		"panels": [
+			{"type": "timeseries", "title": "Load", "gridPos": {"x": 0, "y": 0, "w": 12, "h": 8}, "targets": [{"expr": "node_load1"}, {"expr": "count(node_cpu_seconds_total)"}]},
+			{"type": "row", "panels": [{"type": "text", "title": "Notes", "options": {"content": "Hello"}}]},
+			{"type": "heatmap", "title": "Latency"},
+			{"type": "stat", "title": "Requests", "targets": [{"expr": "sum(http_requests_total)"}]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Title   string `json:"title"`
+		Widgets []struct {
+			Type   string          `json:"type"`
+			Width  int             `json:"width"`
+			Config json.RawMessage `json:"config"`
+		} `json:"widgets"`
+	}
+	if err := json.Unmarshal(result.Config, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Title != "Nodes" || len(config.Widgets) != 2 {
+		t.Fatalf("unexpected config %s", result.Config)
+	}
+	if config.Widgets[0].Type != "chart" || config.Widgets[0].Width != 6 || config.Widgets[1].Type != "markdown" {
+		t.Errorf("unexpected widgets %s", result.Config)
+	}
+	var chart struct {
+		Y1 struct {
+			Metrics []struct {
+				Metric      string `json:"metric"`
+				Aggregation string `json:"aggregation"`
+			} `json:"metrics"`
+		} `json:"y1"`
+	}
+	if err := json.Unmarshal(config.Widgets[0].Config, &chart); err != nil {
+		t.Fatal(err)
+	}
+	if metrics := chart.Y1.Metrics; len(metrics) != 2 || metrics[1].Metric != "cpu.used" || metrics[1].Aggregation != "SUM" {
+		t.Errorf("expected a metric per target, got %+v", metrics)
+	}
+	if len(result.Report) != 3 {
+		t.Errorf("expected the heatmap panel and both problems of the stat panel in the report, got %v", result.Report)
+	}
+}
@@ -0,0 +1,293 @@
+// Package instanatest provides a fake Instana API for tests.
+package instanatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Server is an in-memory Instana API implementing the custom dashboard
+// endpoints. Requests without the expected token are rejected with 401.
+type Server struct {
+	*httptest.Server
+	Token string
+
+	mux        *http.ServeMux
+	mu         sync.Mutex
+	dashboards map[string]map[string]interface{}
+	settings   map[string]map[string]map[string]interface{}
+	nextId     int
+}
+
+// NewServer starts a fake Instana API accepting the given api token.
+// Callers should call Close when finished.
+func NewServer(token string) *Server {
+	s := &Server{Token: token, dashboards: map[string]map[string]interface{}{}, settings: map[string]map[string]map[string]interface{}{}}
+	mux := http.NewServeMux()
+	s.mux = mux
+	mux.HandleFunc("/api/instana/version", s.handleVersion)
+	mux.HandleFunc("/api/custom-dashboard", s.handleDashboards)
+	mux.HandleFunc("/api/custom-dashboard/", s.handleDashboard)
+	s.Server = httptest.NewServer(s.authorize(mux))
+	return s
+}
+
+// Dashboard returns a copy of the stored dashboard with the given id.
+func (s *Server) Dashboard(id string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.dashboards[id]
+	return deepCopy(d), ok
+}
+
+// DashboardCount returns the number of stored dashboards.
+func (s *Server) DashboardCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.dashboards)
+}
+
+// PutDashboard stores a dashboard directly, e.g. to simulate a change in the UI.
+func (s *Server) PutDashboard(id string, dashboard map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dashboard = deepCopy(dashboard)
+	dashboard["id"] = id
+	s.dashboards[id] = dashboard
+}
+
+// HandleSettings serves a generic settings API under path: POST <path>
+// creates an object with a generated id, PUT and POST <path>/<id> create or
+// replace the object with the id, GET and DELETE <path>/<id> read and delete it.
+func (s *Server) HandleSettings(path string) {
+	s.mu.Lock()
+	s.settings[path] = map[string]map[string]interface{}{}
+	s.mu.Unlock()
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		s.handleSettings(path, w, r)
+	})
+	s.mux.HandleFunc(path+"/", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSettings(path, w, r)
+	})
+}
+
+// Handle serves an additional endpoint, e.g. a report of a settings API.
+func (s *Server) Handle(pattern string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// Setting returns a copy of the stored object with the given id of the
+// settings API under path.
+func (s *Server) Setting(path string, id string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.settings[path][id]
+	return deepCopy(o), ok
+}
+
+// PutSetting stores an object of a settings API directly, e.g. to simulate a
+// change in the UI.
+func (s *Server) PutSetting(path string, id string, object map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object = deepCopy(object)
+	object["id"] = id
+	s.settings[path][id] = object
+}
+
+// DeleteSetting deletes an object of a settings API directly.
+func (s *Server) DeleteSetting(path string, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.settings[path], id)
+}
+
+func (s *Server) handleSettings(path string, w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	objects := s.settings[path]
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, path), "/")
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			list := []map[string]interface{}{}
+			for _, o := range objects {
+				list = append(list, o)
+			}
+			writeJson(w, http.StatusOK, list)
+		case http.MethodPost:
+			object, err := readObject(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.nextId++
+			object["id"] = fmt.Sprintf("fake-%d", s.nextId)
+			objects[object["id"].(string)] = object
+			writeJson(w, http.StatusOK, object)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		object, ok := objects[id]
+		if !ok {
+			http.Error(w, `{"errors":["not found"]}`, http.StatusNotFound)
+			return
+		}
+		writeJson(w, http.StatusOK, object)
+	case http.MethodPut, http.MethodPost:
+		object, err := readObject(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		object["id"] = id
+		objects[id] = object
+		writeJson(w, http.StatusOK, object)
+	case http.MethodDelete:
+		if _, ok := objects[id]; !ok {
+			http.Error(w, `{"errors":["not found"]}`, http.StatusNotFound)
+			return
+		}
+		delete(objects, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "apiToken "+s.Token {
+			http.Error(w, `{"errors":["unauthorized"]}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]string{"imageTag": "1.0.0-fake"})
+}
+
+func (s *Server) handleDashboards(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		list := []map[string]interface{}{}
+		for id, d := range s.dashboards {
+			list = append(list, map[string]interface{}{"id": id, "title": d["title"]})
+		}
+		writeJson(w, http.StatusOK, list)
+	case http.MethodPost:
+		dashboard, err := readDashboard(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.nextId++
+		id := fmt.Sprintf("fake-%d", s.nextId)
+		dashboard["id"] = id
+		s.dashboards[id] = dashboard
+		writeJson(w, http.StatusOK, dashboard)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := strings.TrimPrefix(r.URL.Path, "/api/custom-dashboard/")
+	existing, ok := s.dashboards[id]
+	if !ok {
+		http.Error(w, `{"errors":["not found"]}`, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJson(w, http.StatusOK, existing)
+	case http.MethodPut:
+		dashboard, err := readDashboard(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if dashboard["id"] != id {
+			http.Error(w, `{"errors":["id mismatch"]}`, http.StatusBadRequest)
+			return
+		}
+		s.dashboards[id] = dashboard
+		writeJson(w, http.StatusOK, dashboard)
+	case http.MethodDelete:
+		delete(s.dashboards, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func readDashboard(r *http.Request) (map[string]interface{}, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var dashboard map[string]interface{}
+	if err := json.Unmarshal(body, &dashboard); err != nil {
+		return nil, err
+	}
+	if _, ok := dashboard["title"].(string); !ok {
+		return nil, fmt.Errorf("title is required")
+	}
+	return dashboard, nil
+}
+
+func readObject(r *http.Request) (map[string]interface{}, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 && len(r.URL.Query()) > 0 {
+		// APIs taking their payload as query parameters
+		object := map[string]interface{}{}
+		for k := range r.URL.Query() {
+			object[k] = r.URL.Query().Get(k)
+		}
+		return object, nil
+	}
+	var object map[string]interface{}
+	err = json.Unmarshal(body, &object)
+	return object, err
+}
+
+func writeJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// deepCopy copies a JSON object, so callers can't change the stored objects
+// without a request.
+func deepCopy(object map[string]interface{}) map[string]interface{} {
+	if object == nil {
+		return nil
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		panic(err)
+	}
+	var copied map[string]interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		panic(err)
+	}
+	return copied
+}
@@ -0,0 +1,59 @@
+package instanatest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServerRejectsWrongToken(t *testing.T) {
+	s := NewServer("secret")
+	defer s.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/api/custom-dashboard", nil)
+	req.Header.Set("authorization", "apiToken wrong")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerCreatesDashboards(t *testing.T) {
+	s := NewServer("secret")
+	defer s.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, s.URL+"/api/custom-dashboard", strings.NewReader(`{"title":"Test"}`))
+	req.Header.Set("authorization", "apiToken secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if d, ok := s.Dashboard("fake-1"); !ok || d["title"] != "Test" {
+		t.Errorf("expected dashboard fake-1 with title Test, got %v", d)
+	}
+}
+
+func TestServerReturnsCopies(t *testing.T) {
+	s := NewServer("secret")
+	defer s.Close()
+
+	put := map[string]interface{}{"title": "Test", "widgets": []interface{}{map[string]interface{}{"type": "chart"}}}
+	s.PutDashboard("d1", put)
+	put["title"] = "Changed"
+	d, _ := s.Dashboard("d1")
+	d["title"] = "Changed"
+	d["widgets"].([]interface{})[0].(map[string]interface{})["type"] = "markdown"
+
+	stored, _ := s.Dashboard("d1")
+	if stored["title"] != "Test" || stored["widgets"].([]interface{})[0].(map[string]interface{})["type"] != "chart" {
+		t.Errorf("the stored dashboard was changed without a request: %v", stored)
+	}
+}
@@ -0,0 +1,282 @@
+// Package lint checks Instana custom dashboard configs for common mistakes
+// before they are applied, e.g. unknown widget types, metrics without an
+// aggregation or overlapping widgets.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity of a finding.
+type Severity string
+
+const (
+	// SeverityError is a mistake which breaks the dashboard or its widget.
+	SeverityError Severity = "error"
+	// SeverityWarning is likely a mistake, e.g. a widget type unknown to the linter.
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a mistake found in a config.
+type Finding struct {
+	Severity Severity
+	// Path is the JSON pointer of the field, e.g. "/widgets/2/config".
+	Path    string
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Path, f.Message)
+}
+
+// GridColumns is the number of columns of the dashboard grid.
+const GridColumns = 12
+
+// Placeholder marks values of widget templates which need to be set.
+const Placeholder = "<NEEDS TO BE SET>"
+
+// WidgetTypes are the widget types known to the linter. Other types are
+// reported as warnings.
+var WidgetTypes = []string{"chart", "markdown", "bigNumber", "pie"}
+
+// Aggregations are the metric aggregations known to the linter. Other
+// aggregations are reported as warnings.
+var Aggregations = []string{"MEAN", "SUM", "MIN", "MAX", "P25", "P50", "P75", "P90", "P95", "P98", "P99", "DISTINCT_COUNT", "PER_SECOND"}
+
+// Options of the linter.
+type Options struct {
+	// WidgetTypes are known in addition to the package WidgetTypes.
+	WidgetTypes []string
+}
+
+// Config checks a dashboard config and returns its findings ordered by
+// path. Values which are Go templates, as in templated configs, are not
+// checked.
+func Config(config []byte, options Options) ([]Finding, error) {
+	var payload interface{}
+	if err := json.Unmarshal(config, &payload); err != nil {
+		return nil, fmt.Errorf("config is not valid JSON: %w", err)
+	}
+	l := &linter{widgetTypes: append(append([]string{}, WidgetTypes...), options.WidgetTypes...)}
+	l.dashboard(payload)
+	sort.SliceStable(l.findings, func(i, j int) bool { return l.findings[i].Path < l.findings[j].Path })
+	return l.findings, nil
+}
+
+// Errors returns the findings with the severity error.
+func Errors(findings []Finding) []Finding {
+	var errors []Finding
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errors = append(errors, f)
+		}
+	}
+	return errors
+}
+
+type linter struct {
+	widgetTypes []string
+	findings    []Finding
+}
+
+func (l *linter) report(severity Severity, path string, format string, args ...interface{}) {
+	if path == "" {
+		path = "/"
+	}
+	l.findings = append(l.findings, Finding{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// box is the grid area of a widget.
+type box struct {
+	index               int
+	id                  string
+	x, y, width, height float64
+}
+
+func (b box) overlaps(o box) bool {
+	return b.x < o.x+o.width && o.x < b.x+b.width && b.y < o.y+o.height && o.y < b.y+b.height
+}
+
+func (l *linter) dashboard(payload interface{}) {
+	l.placeholders("", payload)
+	dashboard, ok := payload.(map[string]interface{})
+	if !ok {
+		l.report(SeverityError, "", "config is not an object")
+		return
+	}
+	if title, _ := dashboard["title"].(string); strings.TrimSpace(title) == "" {
+		l.report(SeverityError, "/title", "dashboard has no title")
+	}
+	raw, ok := dashboard["widgets"]
+	if !ok {
+		return
+	}
+	widgets, ok := raw.([]interface{})
+	if !ok {
+		l.report(SeverityError, "/widgets", "widgets is not a list")
+		return
+	}
+	ids := map[string]int{}
+	var boxes []box
+	for i, w := range widgets {
+		path := "/widgets/" + strconv.Itoa(i)
+		widget, ok := w.(map[string]interface{})
+		if !ok {
+			l.report(SeverityError, path, "widget is not an object")
+			continue
+		}
+		id, _ := widget["id"].(string)
+		if id == "" {
+			l.report(SeverityWarning, path+"/id", "widget has no id, advisory widgets and drift reports can't refer to it")
+		} else if first, ok := ids[id]; ok {
+			l.report(SeverityError, path+"/id", "id %q is already used by /widgets/%d", id, first)
+		} else {
+			ids[id] = i
+		}
+		l.widgetType(path, widget)
+		if b, ok := l.position(path, widget); ok {
+			b.index, b.id = i, id
+			for _, other := range boxes {
+				if b.overlaps(other) {
+					l.report(SeverityError, path, "widget overlaps /widgets/%d%s", other.index, quoteId(other.id))
+				}
+			}
+			boxes = append(boxes, b)
+		}
+		l.metrics(path+"/config", widget["config"])
+	}
+}
+
+func quoteId(id string) string {
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%q)", id)
+}
+
+func (l *linter) widgetType(path string, widget map[string]interface{}) {
+	widgetType, _ := widget["type"].(string)
+	switch {
+	case widgetType == "":
+		l.report(SeverityError, path+"/type", "widget has no type")
+	case isTemplate(widgetType):
+	case !contains(l.widgetTypes, widgetType):
+		l.report(SeverityWarning, path+"/type", "unknown widget type %q, known types are %s", widgetType, strings.Join(l.widgetTypes, ", "))
+	}
+}
+
+// position returns the grid area of the widget. It returns false if the
+// position is invalid or templated.
+func (l *linter) position(path string, widget map[string]interface{}) (box, bool) {
+	var values [4]float64
+	valid := true
+	for i, field := range []string{"x", "y", "width", "height"} {
+		switch v := widget[field].(type) {
+		case float64:
+			values[i] = v
+			min := 0.0
+			if i >= 2 {
+				min = 1
+			}
+			if v < min || v != float64(int64(v)) {
+				l.report(SeverityError, path+"/"+field, "%s must be a whole number of at least %v, not %v", field, min, v)
+				valid = false
+			}
+		case string:
+			if !isTemplate(v) {
+				l.report(SeverityError, path+"/"+field, "%s must be a number, not %q", field, v)
+			}
+			valid = false
+		case nil:
+			l.report(SeverityError, path+"/"+field, "widget has no %s", field)
+			valid = false
+		default:
+			l.report(SeverityError, path+"/"+field, "%s must be a number", field)
+			valid = false
+		}
+	}
+	b := box{x: values[0], y: values[1], width: values[2], height: values[3]}
+	if valid && b.x+b.width > GridColumns {
+		l.report(SeverityError, path+"/width", "widget ends at column %v, beyond the %d columns of the grid", b.x+b.width, GridColumns)
+	}
+	return b, valid
+}
+
+// metrics checks the metrics of the widget config, e.g. of config.y1.metrics.
+func (l *linter) metrics(path string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if list, ok := child.([]interface{}); ok && key == "metrics" {
+				for i, m := range list {
+					l.metric(path+"/metrics/"+strconv.Itoa(i), m)
+				}
+				continue
+			}
+			l.metrics(path+"/"+escape(key), child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			l.metrics(path+"/"+strconv.Itoa(i), child)
+		}
+	}
+}
+
+func (l *linter) metric(path string, value interface{}) {
+	metric, ok := value.(map[string]interface{})
+	if !ok {
+		l.report(SeverityError, path, "metric is not an object")
+		return
+	}
+	if name, _ := metric["metric"].(string); name == "" {
+		l.report(SeverityError, path+"/metric", "metric has no metric name")
+	}
+	aggregation, _ := metric["aggregation"].(string)
+	switch {
+	case aggregation == "":
+		l.report(SeverityError, path+"/aggregation", "metric has no aggregation")
+	case isTemplate(aggregation):
+	case !contains(Aggregations, aggregation):
+		l.report(SeverityWarning, path+"/aggregation", "unknown aggregation %q, known aggregations are %s", aggregation, strings.Join(Aggregations, ", "))
+	}
+}
+
+// placeholders reports the values of widget templates which were not set.
+func (l *linter) placeholders(path string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if strings.Contains(v, Placeholder) {
+			l.report(SeverityError, path, "value %q needs to be set", v)
+		}
+	case map[string]interface{}:
+		for key, child := range v {
+			l.placeholders(path+"/"+escape(key), child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			l.placeholders(path+"/"+strconv.Itoa(i), child)
+		}
+	}
+}
+
+// escape escapes a key for a JSON pointer.
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+func isTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
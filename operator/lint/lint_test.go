@@ -0,0 +1,59 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	config := `{
+		"title": "Shop",
+		"widgets": [
+			{"id": "cpu", "type": "chart", "x": 0, "y": 0, "width": 6, "height": 6, "config": {"y1": {"metrics": [
+				{"metric": "cpu.used", "aggregation": "MEAN"},
+				{"metric": "", "aggregation": "AVERAGE"}
+			]}}},
+			{"id": "cpu", "type": "chart", "x": 3, "y": 2, "width": 6, "height": 6, "config": {"y1": {"metrics": [{"metric": "memory.used"}]}}},
+			{"id": "info", "type": "note", "x": 8, "y": 10, "width": 6, "height": 2, "config": "<NEEDS TO BE SET>"},
+			{"id": "templated", "type": "markdown", "x": "{{ .Vars.x }}", "y": 0, "width": 2, "height": 2},
+			{"type": "markdown", "x": 0, "y": 20, "width": 1.5}
+		]
+	}`
+	findings, err := Config([]byte(config), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, string(f.Severity)+" "+f.Path)
+	}
+	want := []string{
+		"warning /widgets/0/config/y1/metrics/1/aggregation",
+		"error /widgets/0/config/y1/metrics/1/metric",
+		"error /widgets/1",
+		"error /widgets/1/config/y1/metrics/0/aggregation",
+		"error /widgets/1/id",
+		"error /widgets/2/config",
+		"warning /widgets/2/type",
+		"error /widgets/2/width",
+		"error /widgets/4/height",
+		"warning /widgets/4/id",
+		"error /widgets/4/width",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("findings =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(Errors(findings)) != 8 {
+		t.Errorf("Errors() = %v", Errors(findings))
+	}
+
+	if findings, _ := Config([]byte(`{"title":"Shop","widgets":[{"id":"a","type":"bigNumber","x":0,"y":0,"width":12,"height":4}]}`), Options{}); len(findings) != 0 {
+		t.Errorf("findings = %v for a valid config", findings)
+	}
+	if findings, _ := Config([]byte(`{"title":"Shop","widgets":[{"id":"a","type":"topList","x":0,"y":0,"width":4,"height":4}]}`), Options{WidgetTypes: []string{"topList"}}); len(findings) != 0 {
+		t.Errorf("findings = %v for an additional widget type", findings)
+	}
+	if _, err := Config([]byte(`{`), Options{}); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
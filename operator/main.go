@@ -18,20 +18,30 @@ package main
 
 import (
 	"flag"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	customv1 "github.com/luebken/custom-dashboards/api/v1"
+	customv2 "github.com/luebken/custom-dashboards/api/v2"
 	"github.com/luebken/custom-dashboards/controllers"
 	//+kubebuilder:scaffold:imports
 )
@@ -45,6 +55,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(customv1.AddToScheme(scheme))
+	utilruntime.Must(customv2.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -52,40 +63,444 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var clusterName string
+	var zone string
+	var templateVars string
+	var gcInterval time.Duration
+	var idStore string
+	var driftCheckInterval time.Duration
+	var requeueBaseDelay time.Duration
+	var requeueMaxDelay time.Duration
+	var requeueQPS float64
+	var requeueBurst int
+	var maxConcurrentReconciles int
+	var shardCount int
+	var shardId int
+	var namespaces string
+	var watchLabelSelector string
+	var loadSheddingThreshold int
+	var loadSheddingInterval time.Duration
+	var forceDeleteTimeout time.Duration
+	var repositoryTimeout time.Duration
+	var linkCheckInterval time.Duration
+	var linkCheckHosts string
+	var instanaQPS float64
+	var instanaBurst int
+	var circuitBreakerThreshold int
+	var circuitBreakerCooldown time.Duration
+	var backupLocation string
+	var backupInterval time.Duration
+	var releaseMarkers bool
+	var namespaceDashboards bool
+	var workloadDashboards bool
+	var enableWebhooks bool
+	var namespaceCredentials bool
+	var enableDebug bool
+	var auditEvents bool
+	var kubeApiQPS float64
+	var dryRun bool
+	var dashboardListTTL time.Duration
+	transport := controllers.DefaultTransportConfig
+	var kubeApiBurst int
+	var syncReceiverAddr string
+	var notificationUrl string
+	var notificationFormat string
+	var notificationFailureThreshold int
+	var notificationInterval time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&clusterName, "cluster-name", "", "The name of this cluster. Recorded in the managed marker of created dashboards.")
+	flag.DurationVar(&gcInterval, "gc-interval", time.Hour, "The interval of the garbage collection of orphaned dashboards.")
+	flag.StringVar(&idStore, "id-store", "none", "Where to persist dashboard ids in addition to the status: configmap, namespace-annotation or none.")
+	flag.DurationVar(&driftCheckInterval, "drift-check-interval", 5*time.Minute, "The interval in which dashboards are checked for changes done in Instana.")
+	flag.DurationVar(&requeueBaseDelay, "requeue-base-delay", 5*time.Millisecond, "The initial backoff of a failed dashboard sync.")
+	flag.DurationVar(&requeueMaxDelay, "requeue-max-delay", 1000*time.Second, "The maximum backoff of a failed dashboard sync.")
+	flag.Float64Var(&requeueQPS, "requeue-qps", 10, "The overall number of dashboard retries per second.")
+	flag.IntVar(&requeueBurst, "requeue-burst", 100, "The burst of dashboard retries.")
+	flag.Float64Var(&kubeApiQPS, "kube-api-qps", 20, "The number of requests per second of the operator against the Kubernetes API server.")
+	flag.IntVar(&kubeApiBurst, "kube-api-burst", 30, "The burst of requests against the Kubernetes API server.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "The number of dashboards which are synced in parallel.")
+	flag.IntVar(&shardCount, "shard-count", 1, "The number of shards the namespaces are split into. Each shard is managed by one active replica.")
+	flag.IntVar(&shardId, "shard-id", -1, "The shard of this replica. Derived from the ordinal of the hostname if not set.")
+	flag.IntVar(&loadSheddingThreshold, "load-shedding-threshold", 0, "The work queue depth above which resyncs of unchanged dashboards are skipped. 0 disables load shedding.")
+	flag.DurationVar(&loadSheddingInterval, "load-shedding-interval", 10*time.Second, "The interval in which the work queue depth is sampled for load shedding.")
+	flag.DurationVar(&forceDeleteTimeout, "force-delete-timeout", time.Hour, "The time after which a Dashboard is deleted although the deletion in Instana keeps failing. 0 retries forever.")
+	flag.DurationVar(&repositoryTimeout, "repository-timeout", 2*time.Minute, "The time after which the clone of a DashboardRepository is cancelled.")
+	flag.Float64Var(&instanaQPS, "instana-qps", 0, "The number of requests per second against each Instana tenant, shared by all reconciles. 0 disables the limit.")
+	flag.IntVar(&instanaBurst, "instana-burst", 10, "The burst of requests against each Instana tenant.")
+	flag.IntVar(&transport.MaxIdleConnsPerHost, "instana-max-idle-conns", transport.MaxIdleConnsPerHost, "The number of idle connections kept alive per Instana tenant.")
+	flag.IntVar(&transport.MaxConnsPerHost, "instana-max-conns", transport.MaxConnsPerHost, "The maximum number of connections per Instana tenant. 0 means no limit.")
+	flag.DurationVar(&transport.IdleConnTimeout, "instana-idle-conn-timeout", transport.IdleConnTimeout, "The time an idle connection to an Instana tenant is kept alive.")
+	flag.DurationVar(&transport.Timeout, "instana-timeout", transport.Timeout, "The timeout of a request against Instana including reading the response. 0 means no timeout.")
+	flag.BoolVar(&transport.HTTP2, "instana-http2", transport.HTTP2, "Use HTTP/2 with Instana tenants supporting it.")
+	flag.DurationVar(&dashboardListTTL, "dashboard-list-ttl", 30*time.Second, "The time the dashboard list of an Instana tenant is shared between reconciles. 0 disables the cache.")
+	flag.IntVar(&circuitBreakerThreshold, "circuit-breaker-threshold", 5, "The number of consecutive server errors or timeouts after which syncs with an Instana tenant are suspended. 0 disables the circuit breaker.")
+	flag.DurationVar(&circuitBreakerCooldown, "circuit-breaker-cooldown", 5*time.Minute, "The time syncs with a failing Instana tenant are suspended.")
+	flag.DurationVar(&linkCheckInterval, "link-check-interval", 0, "The interval in which links embedded in dashboards are checked. 0 disables the link checker.")
+	flag.StringVar(&linkCheckHosts, "link-check-hosts", "", "Comma separated hosts besides the Instana tenant whose links are checked by the link checker, e.g. the host of the runbooks.")
+	flag.StringVar(&backupLocation, "backup-location", "", "Where to store backups of the managed dashboards: s3://<bucket>/<prefix>, gs://<bucket>/<prefix> or a directory. Empty disables backups.")
+	flag.DurationVar(&backupInterval, "backup-interval", 24*time.Hour, "The interval in which the managed dashboards are backed up.")
+	flag.BoolVar(&releaseMarkers, "release-markers", false, "Create a Release on every rollout of the Deployments annotated with custom.instana.io/release-marker: \"true\".")
+	flag.BoolVar(&namespaceDashboards, "namespace-dashboards", false, "Generate a Dashboard in every Namespace annotated with custom.instana.io/generate-dashboard: \"true\".")
+	flag.BoolVar(&workloadDashboards, "workload-dashboards", false, "Generate a Dashboard for every Deployment and StatefulSet annotated with custom.instana.io/generate-dashboard: \"true\".")
+	flag.StringVar(&zone, "zone", "", "The zone or region of this cluster. Passed as .Zone to templated dashboard configs.")
+	flag.StringVar(&templateVars, "template-vars", "", "Variables passed as .Vars to templated dashboard configs, key=value pairs separated by commas.")
+	flag.StringVar(&namespaces, "namespaces", os.Getenv("WATCH_NAMESPACE"), "The namespaces the operator is restricted to, separated by commas. Defaults to the WATCH_NAMESPACE environment variable, empty watches all namespaces.")
+	flag.StringVar(&watchLabelSelector, "watch-label-selector", "", "The label selector of the Dashboards managed by the operator, e.g. team=a. Empty manages all Dashboards.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the admission webhooks protecting Dashboards annotated with dashboards.instana.io/protected: \"true\" from deletion and enforcing the allowed-namespaces of the tenants, the webhook returning lint findings of Dashboard configs as warnings, and the conversion webhook of the Dashboard versions. Requires a serving certificate.")
+	flag.StringVar(&notificationUrl, "notification-url", os.Getenv("NOTIFICATION_URL"), "The Slack incoming webhook or generic webhook notified about Degraded and repeatedly failing Dashboards. Defaults to the NOTIFICATION_URL environment variable, empty disables notifications.")
+	flag.StringVar(&notificationFormat, "notification-format", controllers.NotificationFormatJSON, "The format of the notifications: json or slack.")
+	flag.IntVar(&notificationFailureThreshold, "notification-failure-threshold", 5, "The number of consecutive sync failures of a Dashboard which are notified. 0 only notifies Degraded Dashboards.")
+	flag.DurationVar(&notificationInterval, "notification-interval", time.Minute, "The interval in which the failing Dashboards of a tenant are notified together. At most one notification is sent per tenant and interval.")
+	flag.StringVar(&syncReceiverAddr, "sync-receiver-bind-address", "", "The address of the endpoint which triggers the sync of Dashboards, e.g. :8082. Requires the shared secret in the SYNC_RECEIVER_SECRET environment variable. Empty disables the receiver.")
+	flag.BoolVar(&auditEvents, "audit-events", false, "Record every create, update and delete sent to Instana as Event of the custom resource in addition to the audit log.")
+	flag.BoolVar(&enableDebug, "enable-debug-endpoints", false, "Serve pprof on /debug/pprof/ and the in-memory state of the operator on /debug/state of the metrics endpoint. Only use with a metrics endpoint bound to localhost.")
+	flag.BoolVar(&dryRun, "dry-run", false, "Render, validate and diff all resources but only log and report the changes which would be applied in Instana, e.g. for a first rollout.")
+	flag.BoolVar(&namespaceCredentials, "namespace-credentials", false, "Read the Instana API token of the Dashboards from the Secret instana-dashboard-credentials in their namespace instead of the tenant config.")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	// API tokens are redacted from all logs, events and status messages
+	ctrl.SetLogger(controllers.RedactingLogger(zap.New(zap.UseFlagOptions(&opts))))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	controllers.SetTransportConfig(transport)
+
+	variables := controllers.RenderVariables{ClusterName: clusterName, Zone: zone, Vars: controllers.ParseKeyValues(templateVars)}
+
+	shard, err := controllers.NewShard(shardCount, shardId)
+	if err != nil {
+		setupLog.Error(err, "unable to set up sharding")
+		os.Exit(1)
+	}
+	options := ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "facc7a0c.instana.io",
-	})
+	}
+	if namespaces != "" {
+		if namespaceDashboards {
+			setupLog.Error(nil, "--namespace-dashboards requires access to all namespaces and can't be combined with --namespaces")
+			os.Exit(1)
+		}
+		shard.Namespaces = splitList(namespaces)
+		setupLog.Info("Restricting operator to namespaces", "namespaces", shard.Namespaces)
+		options.NewCache = cache.MultiNamespacedCacheBuilder(shard.CacheNamespaces())
+		// Namespaces are cluster scoped and read directly, e.g. by the namespace-annotation id store
+		options.ClientDisableCacheFor = []client.Object{&corev1.Namespace{}}
+	}
+	if watchLabelSelector != "" {
+		if shard.DashboardSelector, err = labels.Parse(watchLabelSelector); err != nil {
+			setupLog.Error(err, "invalid --watch-label-selector")
+			os.Exit(1)
+		}
+		setupLog.Info("Restricting operator to dashboards", "selector", shard.DashboardSelector.String())
+	}
+	options.LeaderElectionID = shard.LeaderElectionID("facc7a0c.instana.io")
+
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = float32(kubeApiQPS)
+	restConfig.Burst = kubeApiBurst
+	mgr, err := ctrl.NewManager(restConfig, options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	circuitBreaker := &controllers.CircuitBreaker{Threshold: circuitBreakerThreshold, Cooldown: circuitBreakerCooldown}
+	instanaClients := &controllers.InstanaClients{
+		TenantRateLimiter: &controllers.TenantRateLimiter{QPS: instanaQPS, Burst: instanaBurst},
+		CircuitBreaker:    circuitBreaker,
+		ListCache:         &controllers.DashboardListCache{TTL: dashboardListTTL},
+		DryRun:            dryRun,
+	}
+	if linkCheckInterval > 0 {
+		if err = mgr.Add(&controllers.LinkChecker{
+			Client:       mgr.GetClient(),
+			Log:          ctrl.Log.WithName("controllers").WithName("LinkChecker"),
+			Interval:     linkCheckInterval,
+			Shard:        shard,
+			HttpClient:   &http.Client{Timeout: 10 * time.Second},
+			AllowedHosts: splitList(linkCheckHosts),
+			Variables:    variables,
+			Instana:      instanaClients,
+			APIReader:    mgr.GetAPIReader(),
+		}); err != nil {
+			setupLog.Error(err, "unable to add link checker")
+			os.Exit(1)
+		}
+	}
+
+	var backupStore controllers.BackupStore
+	if backupLocation != "" {
+		if backupStore, err = controllers.NewBackupStore(backupLocation); err != nil {
+			setupLog.Error(err, "unable to set up backup store")
+			os.Exit(1)
+		}
+	}
+
+	if auditEvents {
+		controllers.Audit.Recorder = controllers.RedactingRecorder(mgr.GetEventRecorderFor("instana-audit"))
+	}
+
+	credentialCheck := &controllers.CredentialCheck{
+		Client:               mgr.GetClient(),
+		Log:                  ctrl.Log.WithName("controllers").WithName("CredentialCheck"),
+		Recorder:             controllers.RedactingRecorder(mgr.GetEventRecorderFor("credential-check")),
+		Kinds:                controllers.InstanaResourceKinds,
+		Instana:              instanaClients,
+		NamespaceCredentials: namespaceCredentials,
+		Shard:                shard,
+	}
+	if err = credentialCheck.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CredentialCheck")
+		os.Exit(1)
+	}
+
+	capabilities := &controllers.CapabilityCache{}
+	if err = mgr.Add(&controllers.CapabilityProbe{
+		Client:  mgr.GetClient(),
+		Log:     ctrl.Log.WithName("controllers").WithName("CapabilityProbe"),
+		Cache:   capabilities,
+		Instana: instanaClients,
+	}); err != nil {
+		setupLog.Error(err, "unable to add capability probe")
+		os.Exit(1)
+	}
+
+	var notifier *controllers.Notifier
+	if notificationUrl != "" {
+		if notificationFormat != controllers.NotificationFormatJSON && notificationFormat != controllers.NotificationFormatSlack {
+			setupLog.Error(nil, "invalid --notification-format, must be json or slack", "format", notificationFormat)
+			os.Exit(1)
+		}
+		notifier = &controllers.Notifier{
+			URL:              notificationUrl,
+			Format:           notificationFormat,
+			FailureThreshold: int32(notificationFailureThreshold),
+			ClusterName:      clusterName,
+			Interval:         notificationInterval,
+			Log:              ctrl.Log.WithName("controllers").WithName("Notifier"),
+		}
+		if err = mgr.Add(notifier); err != nil {
+			setupLog.Error(err, "unable to add notifier")
+			os.Exit(1)
+		}
+	}
+
+	dashboardIdStore, err := controllers.NewIdStore(idStore, mgr.GetClient())
+	if err != nil {
+		setupLog.Error(err, "invalid --id-store")
+		os.Exit(1)
+	}
+	loadShedder := &controllers.LoadShedder{Threshold: loadSheddingThreshold, QueueName: "dashboard", Interval: loadSheddingInterval}
+	if loadSheddingThreshold > 0 {
+		if err = mgr.Add(loadShedder); err != nil {
+			setupLog.Error(err, "unable to add load shedder")
+			os.Exit(1)
+		}
+	}
 	if err = (&controllers.DashboardReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Dashboard"),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("Dashboard"),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                controllers.RedactingRecorder(mgr.GetEventRecorderFor("dashboard-controller")),
+		ClusterName:             clusterName,
+		IdStore:                 dashboardIdStore,
+		DriftCheckInterval:      driftCheckInterval,
+		RateLimiter:             controllers.NewRateLimiter(requeueBaseDelay, requeueMaxDelay, requeueQPS, requeueBurst),
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		Shard:                   shard,
+		LoadShedder:             loadShedder,
+		Capabilities:            capabilities,
+		ForceDeleteTimeout:      forceDeleteTimeout,
+		APIReader:               mgr.GetAPIReader(),
+		TenantRateLimiter:       instanaClients.TenantRateLimiter,
+		CircuitBreaker:          instanaClients.CircuitBreaker,
+		BackupStore:             backupStore,
+		Variables:               variables,
+		NamespaceCredentials:    namespaceCredentials,
+		Notifier:                notifier,
+		ListCache:               instanaClients.ListCache,
+		DryRun:                  dryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Dashboard")
 		os.Exit(1)
 	}
+	if err = mgr.Add(&controllers.DashboardGarbageCollector{
+		Client:               mgr.GetClient(),
+		Log:                  ctrl.Log.WithName("controllers").WithName("DashboardGarbageCollector"),
+		ClusterName:          clusterName,
+		Interval:             gcInterval,
+		Shard:                shard,
+		NamespaceCredentials: namespaceCredentials,
+		APIReader:            mgr.GetAPIReader(),
+		Instana:              instanaClients,
+	}); err != nil {
+		setupLog.Error(err, "unable to add garbage collector")
+		os.Exit(1)
+	}
+	if err = metrics.Registry.Register(&controllers.TenantMetrics{
+		Client:               mgr.GetClient(),
+		Log:                  ctrl.Log.WithName("controllers").WithName("TenantMetrics"),
+		Shard:                shard,
+		Elected:              mgr.Elected(),
+		NamespaceCredentials: namespaceCredentials,
+		DryRun:               dryRun,
+	}); err != nil {
+		setupLog.Error(err, "unable to register tenant metrics")
+		os.Exit(1)
+	}
+	for _, kind := range controllers.InstanaResourceKinds {
+		if err = (&controllers.InstanaResourceReconciler{
+			Client:             mgr.GetClient(),
+			Log:                ctrl.Log.WithName("controllers").WithName(kind.Kind),
+			Scheme:             mgr.GetScheme(),
+			Recorder:           controllers.RedactingRecorder(mgr.GetEventRecorderFor(strings.ToLower(kind.Kind) + "-controller")),
+			Kind:               kind,
+			Shard:              shard,
+			DriftCheckInterval: driftCheckInterval,
+			Instana:            instanaClients,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", kind.Kind)
+			os.Exit(1)
+		}
+	}
+	if err = (&controllers.DashboardRepositoryReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("DashboardRepository"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: controllers.RedactingRecorder(mgr.GetEventRecorderFor("dashboardrepository-controller")),
+		Shard:    shard,
+		Timeout:  repositoryTimeout,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DashboardRepository")
+		os.Exit(1)
+	}
+	// ClusterDashboards are cluster-scoped and not watched by namespace-scoped operators
+	if namespaces == "" {
+		if err = (&controllers.ClusterDashboardReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("ClusterDashboard"),
+			Scheme: mgr.GetScheme(),
+			Shard:  shard,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClusterDashboard")
+			os.Exit(1)
+		}
+	}
+	if releaseMarkers {
+		if err = (&controllers.DeploymentReleaseReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("DeploymentRelease"),
+			Scheme: mgr.GetScheme(),
+			Shard:  shard,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "DeploymentRelease")
+			os.Exit(1)
+		}
+	}
+	if namespaceDashboards {
+		if err = (&controllers.NamespaceDashboardReconciler{
+			Client:    mgr.GetClient(),
+			Log:       ctrl.Log.WithName("controllers").WithName("NamespaceDashboard"),
+			Scheme:    mgr.GetScheme(),
+			Variables: variables,
+			Shard:     shard,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NamespaceDashboard")
+			os.Exit(1)
+		}
+	}
+	if workloadDashboards {
+		for _, kind := range []string{"Deployment", "StatefulSet"} {
+			if err = (&controllers.WorkloadDashboardReconciler{
+				Client:    mgr.GetClient(),
+				Log:       ctrl.Log.WithName("controllers").WithName(kind + "Dashboard"),
+				Scheme:    mgr.GetScheme(),
+				Kind:      kind,
+				Variables: variables,
+				Shard:     shard,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", kind+"Dashboard")
+				os.Exit(1)
+			}
+		}
+	}
+	if backupStore != nil {
+		if err = mgr.Add(&controllers.DashboardBackup{
+			Client:               mgr.GetClient(),
+			Log:                  ctrl.Log.WithName("controllers").WithName("DashboardBackup"),
+			Store:                backupStore,
+			Interval:             backupInterval,
+			Shard:                shard,
+			Instana:              instanaClients,
+			NamespaceCredentials: namespaceCredentials,
+			APIReader:            mgr.GetAPIReader(),
+		}); err != nil {
+			setupLog.Error(err, "unable to add dashboard backup")
+			os.Exit(1)
+		}
+	}
+	if syncReceiverAddr != "" {
+		secret := os.Getenv("SYNC_RECEIVER_SECRET")
+		if secret == "" {
+			setupLog.Error(nil, "--sync-receiver-bind-address requires the SYNC_RECEIVER_SECRET environment variable")
+			os.Exit(1)
+		}
+		if err = mgr.Add(&controllers.SyncReceiver{
+			Client:      mgr.GetClient(),
+			Log:         ctrl.Log.WithName("controllers").WithName("SyncReceiver"),
+			BindAddress: syncReceiverAddr,
+			Secret:      secret,
+		}); err != nil {
+			setupLog.Error(err, "unable to add sync receiver")
+			os.Exit(1)
+		}
+	}
+	if enableWebhooks {
+		if err = (&customv1.Dashboard{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Dashboard")
+			os.Exit(1)
+		}
+		if err = (&controllers.TenantPolicyWebhook{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "TenantPolicy")
+			os.Exit(1)
+		}
+		if err = (&controllers.LintWebhook{}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Lint")
+			os.Exit(1)
+		}
+	}
+	if enableDebug {
+		handlers := map[string]http.Handler{
+			"/debug/pprof/":        http.HandlerFunc(pprof.Index),
+			"/debug/pprof/cmdline": http.HandlerFunc(pprof.Cmdline),
+			"/debug/pprof/profile": http.HandlerFunc(pprof.Profile),
+			"/debug/pprof/symbol":  http.HandlerFunc(pprof.Symbol),
+			"/debug/pprof/trace":   http.HandlerFunc(pprof.Trace),
+			"/debug/state": &controllers.DebugHandler{
+				Reader:          mgr.GetClient(),
+				Log:             ctrl.Log.WithName("debug"),
+				Shard:           shard,
+				CircuitBreaker:  circuitBreaker,
+				CredentialCheck: credentialCheck,
+			},
+		}
+		for path, handler := range handlers {
+			if err = mgr.AddMetricsExtraHandler(path, handler); err != nil {
+				setupLog.Error(err, "unable to add debug endpoint", "path", path)
+				os.Exit(1)
+			}
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -96,6 +511,10 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("instana-credentials", credentialCheck.Checker); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -103,3 +522,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma separated flag, ignoring spaces and empty entries.
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,80 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type state struct {
+	Version   int `json:"version"`
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey   interface{} `json:"index_key"`
+			Attributes struct {
+				Id         string `json:"id"`
+				Title      string `json:"title"`
+				AccessRule []struct {
+					AccessType   string `json:"access_type"`
+					RelationType string `json:"relation_type"`
+					RelatedId    string `json:"related_id"`
+				} `json:"access_rule"`
+				Widgets string `json:"widgets"`
+			} `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// ParseState returns the instana_custom_dashboard resources of a Terraform
+// state file. Name is the address of the resource in the state, e.g.
+// module.team_a.instana_custom_dashboard.shop["prod"], Config is built from
+// the title, access rules and widgets of the resource.
+func ParseState(data []byte) ([]Dashboard, error) {
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("unable to parse Terraform state: %w", err)
+	}
+	if s.Version != 4 {
+		return nil, fmt.Errorf("unsupported Terraform state version %d, need 4", s.Version)
+	}
+	var dashboards []Dashboard
+	for _, r := range s.Resources {
+		if r.Mode != "managed" || r.Type != ResourceType {
+			continue
+		}
+		address := r.Type + "." + r.Name
+		if r.Module != "" {
+			address = r.Module + "." + address
+		}
+		for _, instance := range r.Instances {
+			name := address
+			switch key := instance.IndexKey.(type) {
+			case string:
+				name += fmt.Sprintf("[%q]", key)
+			case float64:
+				name += fmt.Sprintf("[%v]", key)
+			}
+			attributes := instance.Attributes
+			c := config{Title: attributes.Title, AccessRules: []accessRule{}}
+			for _, a := range attributes.AccessRule {
+				c.AccessRules = append(c.AccessRules, accessRule{AccessType: a.AccessType, RelationType: a.RelationType, RelatedId: a.RelatedId})
+			}
+			c.Widgets = json.RawMessage("[]")
+			if attributes.Widgets != "" {
+				if !json.Valid([]byte(attributes.Widgets)) {
+					return nil, fmt.Errorf("widgets of %s are not valid JSON", name)
+				}
+				c.Widgets = json.RawMessage(attributes.Widgets)
+			}
+			config, err := json.Marshal(c)
+			if err != nil {
+				return nil, err
+			}
+			dashboards = append(dashboards, Dashboard{Name: name, Id: attributes.Id, Config: config})
+		}
+	}
+	return dashboards, nil
+}
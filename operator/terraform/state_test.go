@@ -0,0 +1,36 @@
+package terraform
+
+import (
+	"testing"
+)
+
+func TestParseState(t *testing.T) {
+	dashboards, err := ParseState([]byte(`{
+		"version": 4,
+		"resources": [
+			{"mode": "managed", "type": "instana_custom_dashboard", "name": "shop", "module": "module.team_a", "instances": [
+				{"index_key": "prod", "attributes": {"id": "abc", "title": "Shop", "access_rule": [{"access_type": "READ_WRITE", "relation_type": "GLOBAL", "related_id": ""}], "widgets": "[{\"id\":\"w1\"}]"}}
+			]},
+			{"mode": "data", "type": "instana_custom_dashboard", "name": "other", "instances": [{"attributes": {"id": "def"}}]},
+			{"mode": "managed", "type": "instana_alerting_channel", "name": "mail", "instances": [{"attributes": {"id": "ghi"}}]}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dashboards) != 1 {
+		t.Fatalf("dashboards = %+v, want 1", dashboards)
+	}
+	d := dashboards[0]
+	if d.Name != `module.team_a.instana_custom_dashboard.shop["prod"]` || d.Id != "abc" {
+		t.Errorf("dashboard = %s %s", d.Name, d.Id)
+	}
+	want := `{"title":"Shop","accessRules":[{"accessType":"READ_WRITE","relationType":"GLOBAL"}],"widgets":[{"id":"w1"}]}`
+	if string(d.Config) != want {
+		t.Errorf("config = %s, want %s", d.Config, want)
+	}
+
+	if _, err := ParseState([]byte(`{"version": 3}`)); err == nil {
+		t.Error("expected an error for state version 3")
+	}
+}
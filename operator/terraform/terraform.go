@@ -0,0 +1,136 @@
+// Package terraform converts dashboard configs into instana_custom_dashboard
+// resources of the Instana Terraform provider, for migrations between the
+// provider and the operator.
+package terraform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ResourceType is the type of dashboard resources of the Instana Terraform provider.
+const ResourceType = "instana_custom_dashboard"
+
+// Dashboard is an instana_custom_dashboard resource.
+type Dashboard struct {
+	// Name is the name of the resource. Characters which are not valid in
+	// Terraform names are replaced by underscores.
+	Name string
+	// Id is the id of the dashboard in Instana. If set, an import block
+	// adopts the dashboard instead of creating a copy.
+	Id string
+	// Config is the dashboard config with title, accessRules and widgets.
+	Config []byte
+}
+
+type accessRule struct {
+	AccessType   string `json:"accessType"`
+	RelationType string `json:"relationType"`
+	RelatedId    string `json:"relatedId,omitempty"`
+}
+
+type config struct {
+	Title       string          `json:"title"`
+	AccessRules []accessRule    `json:"accessRules"`
+	Widgets     json.RawMessage `json:"widgets"`
+}
+
+// fields are the config fields the provider has attributes for.
+var fields = map[string]bool{"title": true, "accessRules": true, "widgets": true}
+
+// Write writes the dashboards as HCL. It fails for configs with fields the
+// provider can't express, so a migration doesn't lose them silently.
+func Write(w io.Writer, dashboards []Dashboard) error {
+	for i, d := range dashboards {
+		block, err := resource(d)
+		if err != nil {
+			return fmt.Errorf("unable to convert dashboard %s: %w", d.Name, err)
+		}
+		if i > 0 {
+			block = append([]byte("\n"), block...)
+		}
+		if _, err := w.Write(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resource(d Dashboard) ([]byte, error) {
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(d.Config, &payload); err != nil {
+		return nil, fmt.Errorf("config is not a JSON object: %w", err)
+	}
+	var unsupported []string
+	for field := range payload {
+		if !fields[field] {
+			unsupported = append(unsupported, field)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return nil, fmt.Errorf("the provider has no attributes for the fields %s", strings.Join(unsupported, ", "))
+	}
+	var c config
+	if err := json.Unmarshal(d.Config, &c); err != nil {
+		return nil, err
+	}
+	if c.Title == "" {
+		return nil, fmt.Errorf("config has no title")
+	}
+	widgets := []byte("[]")
+	if len(c.Widgets) > 0 && string(c.Widgets) != "null" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, c.Widgets, "    ", "  "); err != nil {
+			return nil, err
+		}
+		widgets = indented.Bytes()
+	}
+
+	name := ResourceName(d.Name)
+	var b bytes.Buffer
+	if d.Id != "" {
+		fmt.Fprintf(&b, "import {\n  to = %s.%s\n  id = %s\n}\n\n", ResourceType, name, quote(d.Id))
+	}
+	fmt.Fprintf(&b, "resource %q %q {\n", ResourceType, name)
+	fmt.Fprintf(&b, "  title = %s\n", quote(c.Title))
+	for _, r := range c.AccessRules {
+		fmt.Fprintf(&b, "\n  access_rule {\n    access_type   = %s\n    relation_type = %s\n", quote(r.AccessType), quote(r.RelationType))
+		if r.RelatedId != "" {
+			fmt.Fprintf(&b, "    related_id    = %s\n", quote(r.RelatedId))
+		}
+		b.WriteString("  }\n")
+	}
+	fmt.Fprintf(&b, "\n  widgets = <<-EOT\n    %s\n  EOT\n}\n", escapeTemplate(string(widgets)))
+	return b.Bytes(), nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ResourceName returns a valid Terraform resource name for a Dashboard name.
+func ResourceName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "dashboard_" + name
+	}
+	return name
+}
+
+// quote returns value as HCL string literal.
+func quote(value string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	return escapeTemplate(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// escapeTemplate escapes the template sequences of HCL strings.
+func escapeTemplate(value string) string {
+	return strings.NewReplacer("${", "$${", "%{", "%%{").Replace(value)
+}
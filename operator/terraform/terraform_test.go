@@ -0,0 +1,54 @@
+package terraform
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+	err := Write(&b, []Dashboard{{
+		Name:   "9-shop",
+		Id:     "abc",
+		Config: []byte(`{"title":"Shop ${env}","accessRules":[{"accessType":"READ_WRITE","relationType":"GLOBAL"},{"accessType":"READ","relationType":"USER","relatedId":"u1"}],"widgets":[{"id":"w1","type":"markdown"}]}`),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `import {
+  to = instana_custom_dashboard.dashboard_9_shop
+  id = "abc"
+}
+
+resource "instana_custom_dashboard" "dashboard_9_shop" {
+  title = "Shop $${env}"
+
+  access_rule {
+    access_type   = "READ_WRITE"
+    relation_type = "GLOBAL"
+  }
+
+  access_rule {
+    access_type   = "READ"
+    relation_type = "USER"
+    related_id    = "u1"
+  }
+
+  widgets = <<-EOT
+    [
+      {
+        "id": "w1",
+        "type": "markdown"
+      }
+    ]
+  EOT
+}
+`
+	if b.String() != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	if err := Write(&b, []Dashboard{{Name: "shop", Config: []byte(`{"title":"Shop","layout":"grid"}`)}}); err == nil {
+		t.Error("expected an error for a field the provider can't express")
+	}
+}